@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
 	"banking-system/internal/server"
 )
 
@@ -36,7 +43,111 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	done <- true
 }
 
+// runMigrate implements `main migrate up|down [steps]|status`.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	db := database.New()
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		for _, m := range applied {
+			log.Printf("applied %d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := db.MigrateDown(ctx, steps)
+		for _, m := range reverted {
+			log.Printf("reverted %d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := db.MigrationStatus(ctx)
+		if errors.Is(err, database.ErrMigrationsNotInitialised) {
+			log.Println("database not initialised, run `migrate up` to create schema_migrations")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			} else if status.Applied {
+				appliedAt = "applied, time unknown"
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// runSetRole implements `main set-role <email> <role>`. It is how the first
+// admin is created, since only admins can change roles through the API.
+func runSetRole(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set-role <email> customer|support|admin")
+	}
+
+	role := models.Role(args[1])
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q, expected customer, support or admin", args[1])
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	userRepository := repositories.NewUserRepository(db)
+	user, err := userRepository.GetUserByEmail(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to find user %s: %w", args[0], err)
+	}
+
+	updated, err := userRepository.SetUserRole(user.ID, role, models.SystemActor)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("user %d (%s) is now %s", updated.ID, updated.Email, updated.Role)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "set-role" {
+		if err := runSetRole(os.Args[2:]); err != nil {
+			log.Fatalf("set-role: %v", err)
+		}
+		return
+	}
+
 	server := server.NewServer()
 
 	// Create a done channel to signal when the shutdown is complete
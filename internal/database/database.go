@@ -31,8 +31,11 @@ type Service interface {
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	ExecTx(ctx context.Context, fn func(*sql.Tx) error, opts *sql.TxOptions) error
 	ExecTxReadOnly(ctx context.Context, fn func(*sql.Tx) error) error
-	GeneratePDF(transactions []models.Transaction, totalAmount float64, userID int, userFullName string) (string, error)
+	GeneratePDF(transactions []models.Transaction, totalAmount models.Money, userID int, userFullName string) (string, error)
 	StartMetricsCollection()
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	MigrateDown(ctx context.Context, steps int) ([]Migration, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
 }
 
 type service struct {
@@ -191,7 +194,7 @@ func (s *service) ExecTxReadOnly(ctx context.Context, fn func(*sql.Tx) error) er
 	return s.ExecTx(ctx, fn, opts)
 }
 
-func (s *service) GeneratePDF(transactions []models.Transaction, totalAmount float64, userID int, userFullName string) (string, error) {
+func (s *service) GeneratePDF(transactions []models.Transaction, totalAmount models.Money, userID int, userFullName string) (string, error) {
 	generator := pdf.NewStatementGenerator(pdf.StatementConfig{
 		OutputDir:    "statements",
 		BankName:    "Bank of Go",
@@ -0,0 +1,349 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the Postgres advisory lock held while
+// migrations run, so two instances starting together never migrate at once.
+const migrationLockID int64 = 72401173
+
+// ErrMigrationsNotInitialised is returned by MigrationStatus when the
+// database has no schema_migrations table yet.
+var ErrMigrationsNotInitialised = errors.New("migrations not initialised: schema_migrations does not exist")
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change read from the embedded
+// migrations directory.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// loadMigrations parses the embedded migrations, ordered by version. Every
+// version must have both an up and a down file.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file in migrations: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in version order and returns
+// the ones it applied.
+func (s *service) MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = s.withMigrationLock(ctx, migrations, func(conn *sql.Conn, done map[int64]time.Time) error {
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, m.up, `
+				INSERT INTO schema_migrations (version, dirty, name, applied_at)
+				VALUES ($1, FALSE, $2, CURRENT_TIMESTAMP)
+			`, m.Version, m.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// MigrateDown reverts the latest steps applied migrations and returns the
+// ones it reverted.
+func (s *service) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = s.withMigrationLock(ctx, migrations, func(conn *sql.Conn, done map[int64]time.Time) error {
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, m.down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+// MigrationStatus lists every embedded migration and whether it has run.
+// It only reads: no lock is taken and schema_migrations is never created or
+// adopted, so it returns ErrMigrationsNotInitialised on a fresh database.
+// Versions recorded by the golang-migrate CLI are reported as applied with
+// no time.
+func (s *service) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var exists, tracksTime bool
+	err = s.db.QueryRowContext(ctx, `
+		SELECT to_regclass('schema_migrations') IS NOT NULL,
+			EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema()
+					AND table_name = 'schema_migrations'
+					AND column_name = 'applied_at'
+			)
+	`).Scan(&exists, &tracksTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if !exists {
+		return nil, ErrMigrationsNotInitialised
+	}
+
+	query := `SELECT version, applied_at FROM schema_migrations`
+	if !tracksTime {
+		query = `SELECT version, NULL::TIMESTAMP FROM schema_migrations`
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	var legacyVersion int64
+	for rows.Next() {
+		var version int64
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		if appliedAt.Valid {
+			done[version] = appliedAt.Time
+		} else if version > legacyVersion {
+			legacyVersion = version
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := done[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		} else if m.Version <= legacyVersion {
+			status.Applied = true
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// withMigrationLock runs fn on a dedicated connection that holds the
+// migration advisory lock. fn receives the versions already applied.
+func (s *service) withMigrationLock(ctx context.Context, migrations []Migration, fn func(*sql.Conn, map[int64]time.Time) error) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn, migrations); err != nil {
+		return err
+	}
+
+	done, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, done)
+}
+
+// ensureMigrationsTable creates schema_migrations, or adopts the table left
+// by the golang-migrate CLI. That tool keeps a single row with the current
+// version; it is expanded into one row per applied migration.
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn, migrations []Migration) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT PRIMARY KEY, dirty BOOLEAN NOT NULL DEFAULT FALSE)`,
+		`ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS name VARCHAR(255)`,
+		`ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS applied_at TIMESTAMP`,
+	}
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+	}
+
+	var legacyVersion int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `
+		SELECT version, dirty FROM schema_migrations
+		WHERE applied_at IS NULL
+		ORDER BY version DESC
+		LIMIT 1
+	`).Scan(&legacyVersion, &dirty)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("database is dirty at version %d; fix the schema by hand and clear the dirty flag", legacyVersion)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE applied_at IS NULL`); err != nil {
+		return fmt.Errorf("failed to adopt schema_migrations: %w", err)
+	}
+	for _, m := range migrations {
+		if m.Version > legacyVersion {
+			break
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, dirty, name, applied_at)
+			VALUES ($1, FALSE, $2, CURRENT_TIMESTAMP)
+		`, m.Version, m.Name)
+		if err != nil {
+			return fmt.Errorf("failed to adopt schema_migrations: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+
+	return done, rows.Err()
+}
+
+// runMigration executes a migration script and its bookkeeping statement in
+// one transaction, so a failed migration leaves no trace.
+func runMigration(ctx context.Context, conn *sql.Conn, script string, bookkeeping string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,20 @@
+package database
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected migration %d at position %d; got %d_%s", i+1, i, m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_transactions_reference_id;
+
+ALTER TABLE transactions DROP COLUMN description;
+
+ALTER TABLE transactions DROP CONSTRAINT fk_transactions_counterparty_accounts;
+ALTER TABLE transactions DROP COLUMN counterparty_account_id;
+
+ALTER TABLE transactions DROP COLUMN direction;
+
+DROP TYPE transaction_direction;
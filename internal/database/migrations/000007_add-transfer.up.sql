@@ -0,0 +1,15 @@
+CREATE TYPE transaction_direction AS ENUM ('DEBIT', 'CREDIT');
+
+ALTER TABLE transactions ADD COLUMN direction transaction_direction;
+UPDATE transactions SET direction = CASE
+    WHEN transaction_type = 'WITHDRAWAL' THEN 'DEBIT'::transaction_direction
+    ELSE 'CREDIT'::transaction_direction
+END;
+ALTER TABLE transactions ALTER COLUMN direction SET NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN counterparty_account_id INT;
+ALTER TABLE transactions ADD CONSTRAINT fk_transactions_counterparty_accounts FOREIGN KEY (counterparty_account_id) REFERENCES accounts(id);
+
+ALTER TABLE transactions ADD COLUMN description TEXT;
+
+CREATE INDEX idx_transactions_reference_id ON transactions(reference_id);
@@ -0,0 +1,15 @@
+DROP TRIGGER IF EXISTS trg_journal_entries_append_only ON journal_entries;
+DROP TRIGGER IF EXISTS trg_postings_append_only ON postings;
+DROP TRIGGER IF EXISTS trg_postings_balanced ON postings;
+
+DROP FUNCTION IF EXISTS prevent_ledger_mutation();
+DROP FUNCTION IF EXISTS check_journal_entry_balanced();
+
+DROP INDEX IF EXISTS idx_postings_account_id;
+DROP INDEX IF EXISTS idx_postings_journal_entry_id;
+DROP INDEX IF EXISTS idx_journal_entries_reference_id;
+
+DROP TABLE IF EXISTS postings;
+DROP TABLE IF EXISTS journal_entries;
+
+DROP TYPE IF EXISTS posting_side;
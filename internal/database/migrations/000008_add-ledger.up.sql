@@ -0,0 +1,117 @@
+CREATE TYPE posting_side AS ENUM ('DEBIT', 'CREDIT');
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id SERIAL PRIMARY KEY,
+    reference_id VARCHAR(255) NOT NULL,
+    entry_type VARCHAR(32) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every posting targets either a customer account or a named system account
+-- such as CASH_IN or CASH_OUT, never both.
+CREATE TABLE IF NOT EXISTS postings (
+    id SERIAL PRIMARY KEY,
+    journal_entry_id INT NOT NULL,
+    account_id INT,
+    system_account VARCHAR(64),
+    transaction_id INT,
+    side posting_side NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    currency currency_type NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_postings_amount_positive CHECK (amount > 0),
+    CONSTRAINT chk_postings_target CHECK ((account_id IS NULL) <> (system_account IS NULL))
+);
+
+ALTER TABLE postings ADD CONSTRAINT fk_postings_journal_entries FOREIGN KEY (journal_entry_id) REFERENCES journal_entries(id);
+ALTER TABLE postings ADD CONSTRAINT fk_postings_accounts FOREIGN KEY (account_id) REFERENCES accounts(id);
+ALTER TABLE postings ADD CONSTRAINT fk_postings_transactions FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+CREATE INDEX idx_journal_entries_reference_id ON journal_entries(reference_id);
+CREATE INDEX idx_postings_journal_entry_id ON postings(journal_entry_id);
+CREATE INDEX idx_postings_account_id ON postings(account_id);
+
+-- Backfill: one journal entry per reference ID (both legs of a transfer share one)
+INSERT INTO journal_entries (reference_id, entry_type, created_at)
+SELECT COALESCE(reference_id, 'legacy-' || id::text), MIN(transaction_type::text), MIN(created_at)
+FROM transactions
+GROUP BY COALESCE(reference_id, 'legacy-' || id::text);
+
+-- Customer legs
+INSERT INTO postings (journal_entry_id, account_id, transaction_id, side, amount, currency, created_at)
+SELECT je.id, t.account_id, t.id, t.direction::text::posting_side, t.amount, a.currency, t.created_at
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+JOIN journal_entries je ON je.reference_id = COALESCE(t.reference_id, 'legacy-' || t.id::text)
+WHERE t.amount > 0;
+
+-- System legs for deposits and withdrawals
+INSERT INTO postings (journal_entry_id, system_account, side, amount, currency, created_at)
+SELECT je.id,
+    CASE WHEN t.transaction_type = 'DEPOSIT' THEN 'CASH_IN' ELSE 'CASH_OUT' END,
+    CASE WHEN t.transaction_type = 'DEPOSIT' THEN 'DEBIT'::posting_side ELSE 'CREDIT'::posting_side END,
+    t.amount, a.currency, t.created_at
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+JOIN journal_entries je ON je.reference_id = COALESCE(t.reference_id, 'legacy-' || t.id::text)
+WHERE t.transaction_type IN ('DEPOSIT', 'WITHDRAWAL') AND t.amount > 0;
+
+-- Opening balances for any difference between the cached balance and history
+WITH drift AS (
+    SELECT a.id, a.currency,
+        a.balance - COALESCE(SUM(CASE WHEN p.side = 'CREDIT' THEN p.amount ELSE -p.amount END), 0) AS difference
+    FROM accounts a
+    LEFT JOIN postings p ON p.account_id = a.id
+    GROUP BY a.id, a.currency, a.balance
+), opening AS (
+    INSERT INTO journal_entries (reference_id, entry_type, description)
+    SELECT 'opening-' || id::text, 'OPENING_BALANCE', 'Opening balance carried over from accounts.balance'
+    FROM drift
+    WHERE difference <> 0
+    RETURNING id, reference_id
+)
+INSERT INTO postings (journal_entry_id, account_id, system_account, side, amount, currency)
+SELECT o.id, leg.account_id, leg.system_account, leg.side, ABS(d.difference), d.currency
+FROM drift d
+JOIN opening o ON o.reference_id = 'opening-' || d.id::text
+CROSS JOIN LATERAL (VALUES
+    (d.id, NULL::VARCHAR, CASE WHEN d.difference > 0 THEN 'CREDIT'::posting_side ELSE 'DEBIT'::posting_side END),
+    (NULL::INT, 'OPENING_BALANCE'::VARCHAR, CASE WHEN d.difference > 0 THEN 'DEBIT'::posting_side ELSE 'CREDIT'::posting_side END)
+) AS leg(account_id, system_account, side);
+
+-- Every journal entry must balance per currency by the time its transaction commits
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1
+        FROM postings
+        WHERE journal_entry_id = NEW.journal_entry_id
+        GROUP BY currency
+        HAVING SUM(CASE WHEN side = 'DEBIT' THEN amount ELSE -amount END) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.journal_entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+    AFTER INSERT ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
+
+-- The ledger is append-only: corrections are new entries, never edits
+CREATE OR REPLACE FUNCTION prevent_ledger_mutation() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_postings_append_only
+    BEFORE UPDATE OR DELETE ON postings
+    FOR EACH ROW EXECUTE FUNCTION prevent_ledger_mutation();
+
+CREATE TRIGGER trg_journal_entries_append_only
+    BEFORE UPDATE OR DELETE ON journal_entries
+    FOR EACH ROW EXECUTE FUNCTION prevent_ledger_mutation();
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id INT NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_method VARCHAR(10) NOT NULL,
+    request_path TEXT NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    response_status INT,
+    response_body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+ALTER TABLE idempotency_keys ADD CONSTRAINT fk_idempotency_keys_users FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
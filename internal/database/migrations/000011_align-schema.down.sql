@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_statements_user_id;
+DROP INDEX IF EXISTS idx_transactions_user_id;
+
+ALTER TABLE statements ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE transactions ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN status DROP DEFAULT;
+
+ALTER TABLE accounts ALTER COLUMN account_description DROP NOT NULL;
+ALTER TABLE accounts ALTER COLUMN account_description DROP DEFAULT;
+ALTER TABLE accounts ALTER COLUMN account_name DROP NOT NULL;
+ALTER TABLE accounts ALTER COLUMN account_name DROP DEFAULT;
@@ -0,0 +1,41 @@
+-- Columns the repositories scan into plain strings must never be NULL
+UPDATE accounts SET account_name = '' WHERE account_name IS NULL;
+UPDATE accounts SET account_description = '' WHERE account_description IS NULL;
+ALTER TABLE accounts ALTER COLUMN account_name SET DEFAULT '';
+ALTER TABLE accounts ALTER COLUMN account_name SET NOT NULL;
+ALTER TABLE accounts ALTER COLUMN account_description SET DEFAULT '';
+ALTER TABLE accounts ALTER COLUMN account_description SET NOT NULL;
+
+-- Every transaction is written with a status and an owner
+UPDATE transactions SET status = 'completed' WHERE status IS NULL;
+ALTER TABLE transactions ALTER COLUMN status SET DEFAULT 'completed';
+ALTER TABLE transactions ALTER COLUMN status SET NOT NULL;
+
+UPDATE transactions t SET user_id = a.user_id
+FROM accounts a
+WHERE a.id = t.account_id AND t.user_id IS NULL;
+ALTER TABLE transactions ALTER COLUMN user_id SET NOT NULL;
+
+-- Statements written before 000006 lost their owner when account_id was
+-- dropped. The generator names each PDF statement_<user id>_<time>.pdf, so
+-- the owner is recovered from the file name.
+UPDATE statements s SET user_id = substring(s.pdf_url FROM 'statement_([0-9]+)_[^/]*$')::INT
+WHERE s.user_id IS NULL
+    AND EXISTS (SELECT 1 FROM users u WHERE u.id = substring(s.pdf_url FROM 'statement_([0-9]+)_[^/]*$')::INT);
+
+-- Anything left has no known owner. Deleting it cannot be undone, so an
+-- operator decides what happens to it.
+DO $$
+DECLARE
+    orphans INT;
+BEGIN
+    SELECT COUNT(*) INTO orphans FROM statements WHERE user_id IS NULL;
+    IF orphans > 0 THEN
+        RAISE EXCEPTION '% statements have no owner (SELECT * FROM statements WHERE user_id IS NULL). Set their user_id, or move them out of the table, then run the migration again.', orphans;
+    END IF;
+END $$;
+ALTER TABLE statements ALTER COLUMN user_id SET NOT NULL;
+
+-- Lookups are scoped by owner
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_statements_user_id ON statements(user_id);
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_statements_user_id ON statements(user_id);
+
+DROP INDEX IF EXISTS idx_statements_user_created_at_id;
+DROP INDEX IF EXISTS idx_transactions_user_created_at_id;
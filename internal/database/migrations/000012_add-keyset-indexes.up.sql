@@ -0,0 +1,7 @@
+-- Back keyset pagination of a user's history ordered by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_transactions_user_created_at_id ON transactions(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_statements_user_created_at_id ON statements(user_id, created_at DESC, id DESC);
+
+-- The composite indexes make the single-column owner indexes redundant
+DROP INDEX IF EXISTS idx_transactions_user_id;
+DROP INDEX IF EXISTS idx_statements_user_id;
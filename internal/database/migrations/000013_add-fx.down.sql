@@ -0,0 +1,7 @@
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transactions_fx_quotes;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_quote_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_spread;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS fx_rates;
@@ -0,0 +1,38 @@
+-- Mid-market rates published for the DB-backed rate provider. Each row is
+-- the price of one unit of base_currency in quote_currency.
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base_currency currency_type NOT NULL,
+    quote_currency currency_type NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (base_currency, quote_currency),
+    CONSTRAINT chk_fx_rates_positive CHECK (rate > 0),
+    CONSTRAINT chk_fx_rates_pair CHECK (base_currency <> quote_currency)
+);
+
+-- A quote fixes the customer rate for one transfer until it expires
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY,
+    user_id INT NOT NULL,
+    from_currency currency_type NOT NULL,
+    to_currency currency_type NOT NULL,
+    mid_rate NUMERIC(20, 10) NOT NULL,
+    spread NUMERIC(20, 10) NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL,
+    source_amount DECIMAL(10, 2) NOT NULL,
+    destination_amount DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+ALTER TABLE fx_quotes ADD CONSTRAINT fk_fx_quotes_users FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE INDEX idx_fx_quotes_expires_at ON fx_quotes(expires_at);
+
+-- Both legs of a cross-currency transfer record the rate they were booked at
+ALTER TABLE transactions ADD COLUMN fx_rate NUMERIC(20, 10);
+ALTER TABLE transactions ADD COLUMN fx_spread NUMERIC(20, 10);
+ALTER TABLE transactions ADD COLUMN fx_quote_id UUID;
+
+ALTER TABLE transactions ADD CONSTRAINT fk_transactions_fx_quotes FOREIGN KEY (fx_quote_id) REFERENCES fx_quotes(id);
@@ -0,0 +1,21 @@
+-- Rows in currencies other than USD, EUR and GBP make this fail; move or
+-- remove them first.
+CREATE TYPE currency_type AS ENUM ('USD', 'EUR', 'GBP');
+
+ALTER TABLE fx_quotes DROP CONSTRAINT IF EXISTS fk_fx_quotes_to_currencies;
+ALTER TABLE fx_quotes DROP CONSTRAINT IF EXISTS fk_fx_quotes_from_currencies;
+ALTER TABLE fx_rates DROP CONSTRAINT IF EXISTS fk_fx_rates_quote_currencies;
+ALTER TABLE fx_rates DROP CONSTRAINT IF EXISTS fk_fx_rates_base_currencies;
+ALTER TABLE postings DROP CONSTRAINT IF EXISTS fk_postings_currencies;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS fk_accounts_currencies;
+
+ALTER TABLE fx_quotes ALTER COLUMN to_currency TYPE currency_type USING to_currency::currency_type;
+ALTER TABLE fx_quotes ALTER COLUMN from_currency TYPE currency_type USING from_currency::currency_type;
+ALTER TABLE fx_rates ALTER COLUMN quote_currency TYPE currency_type USING quote_currency::currency_type;
+ALTER TABLE fx_rates ALTER COLUMN base_currency TYPE currency_type USING base_currency::currency_type;
+ALTER TABLE postings ALTER COLUMN currency TYPE currency_type USING currency::currency_type;
+ALTER TABLE accounts ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE accounts ALTER COLUMN currency TYPE currency_type USING currency::currency_type;
+ALTER TABLE accounts ALTER COLUMN currency SET DEFAULT 'USD';
+
+DROP TABLE IF EXISTS currencies;
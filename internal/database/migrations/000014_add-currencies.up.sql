@@ -0,0 +1,57 @@
+-- The currency catalogue replaces the currency_type enum. Only enabled
+-- currencies can be used for new accounts and conversions; disabled ones
+-- stay valid for the accounts that already hold them.
+CREATE TABLE IF NOT EXISTS currencies (
+    code VARCHAR(3) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    minor_units SMALLINT NOT NULL,
+    symbol VARCHAR(8) NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_currencies_code CHECK (code ~ '^[A-Z]{3}$'),
+    -- Amount columns are DECIMAL(10, 2), so three-decimal currencies such
+    -- as KWD cannot be represented yet
+    CONSTRAINT chk_currencies_minor_units CHECK (minor_units BETWEEN 0 AND 2)
+);
+
+INSERT INTO currencies (code, name, minor_units, symbol, enabled) VALUES
+    ('USD', 'US Dollar', 2, '$', TRUE),
+    ('EUR', 'Euro', 2, '€', TRUE),
+    ('GBP', 'Pound Sterling', 2, '£', TRUE),
+    ('JPY', 'Yen', 0, '¥', FALSE),
+    ('CHF', 'Swiss Franc', 2, 'CHF', FALSE),
+    ('CAD', 'Canadian Dollar', 2, '$', FALSE),
+    ('AUD', 'Australian Dollar', 2, '$', FALSE),
+    ('NZD', 'New Zealand Dollar', 2, '$', FALSE),
+    ('SGD', 'Singapore Dollar', 2, '$', FALSE),
+    ('HKD', 'Hong Kong Dollar', 2, '$', FALSE),
+    ('CNY', 'Yuan Renminbi', 2, '¥', FALSE),
+    ('SEK', 'Swedish Krona', 2, 'kr', FALSE),
+    ('NOK', 'Norwegian Krone', 2, 'kr', FALSE),
+    ('DKK', 'Danish Krone', 2, 'kr', FALSE),
+    ('PLN', 'Zloty', 2, 'zł', FALSE),
+    ('INR', 'Indian Rupee', 2, '₹', FALSE),
+    ('MXN', 'Mexican Peso', 2, '$', FALSE),
+    ('ZAR', 'Rand', 2, 'R', FALSE),
+    ('PHP', 'Philippine Peso', 2, '₱', FALSE),
+    ('KRW', 'Won', 0, '₩', FALSE)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE accounts ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE accounts ALTER COLUMN currency TYPE VARCHAR(3) USING currency::text;
+ALTER TABLE accounts ALTER COLUMN currency SET DEFAULT 'USD';
+ALTER TABLE postings ALTER COLUMN currency TYPE VARCHAR(3) USING currency::text;
+ALTER TABLE fx_rates ALTER COLUMN base_currency TYPE VARCHAR(3) USING base_currency::text;
+ALTER TABLE fx_rates ALTER COLUMN quote_currency TYPE VARCHAR(3) USING quote_currency::text;
+ALTER TABLE fx_quotes ALTER COLUMN from_currency TYPE VARCHAR(3) USING from_currency::text;
+ALTER TABLE fx_quotes ALTER COLUMN to_currency TYPE VARCHAR(3) USING to_currency::text;
+
+ALTER TABLE accounts ADD CONSTRAINT fk_accounts_currencies FOREIGN KEY (currency) REFERENCES currencies(code);
+ALTER TABLE postings ADD CONSTRAINT fk_postings_currencies FOREIGN KEY (currency) REFERENCES currencies(code);
+ALTER TABLE fx_rates ADD CONSTRAINT fk_fx_rates_base_currencies FOREIGN KEY (base_currency) REFERENCES currencies(code);
+ALTER TABLE fx_rates ADD CONSTRAINT fk_fx_rates_quote_currencies FOREIGN KEY (quote_currency) REFERENCES currencies(code);
+ALTER TABLE fx_quotes ADD CONSTRAINT fk_fx_quotes_from_currencies FOREIGN KEY (from_currency) REFERENCES currencies(code);
+ALTER TABLE fx_quotes ADD CONSTRAINT fk_fx_quotes_to_currencies FOREIGN KEY (to_currency) REFERENCES currencies(code);
+
+DROP TYPE IF EXISTS currency_type;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_transactions_reversal_of_id;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transactions_reversal_of;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of_id;
+
+-- Postgres cannot drop enum values, so REVERSAL and reversed stay defined
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_accounts_status;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_role;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
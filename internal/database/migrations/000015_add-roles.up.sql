@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'customer';
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('customer', 'support', 'admin'));
+
+-- Frozen accounts keep receiving money but cannot send any
+ALTER TABLE accounts ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE accounts ADD CONSTRAINT chk_accounts_status CHECK (status IN ('active', 'frozen'));
+
+-- A reversal books compensating rows that point back at the rows they undo
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'REVERSAL';
+ALTER TYPE transaction_status ADD VALUE IF NOT EXISTS 'reversed';
+
+ALTER TABLE transactions ADD COLUMN reversal_of_id INT;
+ALTER TABLE transactions ADD CONSTRAINT fk_transactions_reversal_of FOREIGN KEY (reversal_of_id) REFERENCES transactions(id);
+
+CREATE INDEX idx_transactions_reversal_of_id ON transactions(reversal_of_id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_accounts_status;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_accounts_closed_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
+
+-- Closed accounts stay blocked from sending money as frozen ones
+UPDATE accounts SET status = 'active' WHERE status = 'dormant';
+UPDATE accounts SET status = 'frozen' WHERE status = 'closed';
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_accounts_status;
+ALTER TABLE accounts ADD CONSTRAINT chk_accounts_status CHECK (status IN ('active', 'frozen'));
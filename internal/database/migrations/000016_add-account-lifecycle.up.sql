@@ -0,0 +1,9 @@
+-- Dormant accounts have seen no activity for a long time; closed accounts are
+-- kept for their history but never move money again
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_accounts_status;
+ALTER TABLE accounts ADD CONSTRAINT chk_accounts_status CHECK (status IN ('active', 'frozen', 'dormant', 'closed'));
+
+ALTER TABLE accounts ADD COLUMN closed_at TIMESTAMP;
+ALTER TABLE accounts ADD CONSTRAINT chk_accounts_closed_at CHECK ((status = 'closed') = (closed_at IS NOT NULL));
+
+CREATE INDEX idx_accounts_status ON accounts(status);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_audit_events_no_truncate ON audit_events;
+DROP TRIGGER IF EXISTS trg_audit_events_append_only ON audit_events;
+DROP INDEX IF EXISTS idx_audit_events_resource;
+DROP INDEX IF EXISTS idx_audit_events_actor_user_id;
+DROP INDEX IF EXISTS idx_audit_events_occurred_at;
+DROP TABLE IF EXISTS audit_events;
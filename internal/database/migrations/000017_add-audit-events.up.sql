@@ -0,0 +1,32 @@
+-- One row per change, chained by hash: each row's hash covers its own fields
+-- and the hash of the row before it, so editing or removing a row breaks
+-- every hash after it
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    actor_user_id INT,
+    actor_role VARCHAR(16),
+    action VARCHAR(64) NOT NULL,
+    resource_type VARCHAR(32) NOT NULL,
+    resource_id VARCHAR(64) NOT NULL,
+    -- JSON rather than JSONB keeps the exact text that was hashed
+    before_value JSON,
+    after_value JSON,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    request_id VARCHAR(64),
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at);
+CREATE INDEX idx_audit_events_actor_user_id ON audit_events(actor_user_id);
+CREATE INDEX idx_audit_events_resource ON audit_events(resource_type, resource_id);
+
+CREATE TRIGGER trg_audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION prevent_ledger_mutation();
+
+CREATE TRIGGER trg_audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION prevent_ledger_mutation();
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_transactions_single_reversal;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transactions_refunded_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS refunded_amount;
+
+-- Postgres cannot drop enum values, so REFUND and partially_refunded stay defined
@@ -0,0 +1,12 @@
+-- A refund gives back part of a transaction; refunds that add up to the
+-- whole amount leave it reversed, like a reversal does
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'REFUND';
+ALTER TYPE transaction_status ADD VALUE IF NOT EXISTS 'partially_refunded';
+
+ALTER TABLE transactions ADD COLUMN refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD CONSTRAINT chk_transactions_refunded_amount CHECK (refunded_amount >= 0 AND refunded_amount <= amount);
+
+UPDATE transactions SET refunded_amount = amount WHERE status = 'reversed';
+
+-- A transaction row can be reversed once at most, whatever the application does
+CREATE UNIQUE INDEX idx_transactions_single_reversal ON transactions(reversal_of_id) WHERE transaction_type = 'REVERSAL';
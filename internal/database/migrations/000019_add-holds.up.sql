@@ -0,0 +1,31 @@
+-- A hold reserves money on an account without moving it. The reserved amount
+-- is booked as a pending WITHDRAWAL transaction, which is completed for the
+-- captured amount or failed when the hold is released or expires.
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    account_id INT NOT NULL,
+    user_id INT NOT NULL,
+    transaction_id INT NOT NULL UNIQUE,
+    amount DECIMAL(10, 2) NOT NULL,
+    captured_amount DECIMAL(10, 2),
+    status VARCHAR(16) NOT NULL DEFAULT 'authorized',
+    description TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_holds_amount_positive CHECK (amount > 0),
+    CONSTRAINT chk_holds_status CHECK (status IN ('authorized', 'captured', 'released', 'expired')),
+    CONSTRAINT chk_holds_captured_amount CHECK (
+        (status = 'captured') = (captured_amount IS NOT NULL)
+        AND (captured_amount IS NULL OR (captured_amount > 0 AND captured_amount <= amount))
+    )
+);
+
+ALTER TABLE holds ADD CONSTRAINT fk_holds_accounts FOREIGN KEY (account_id) REFERENCES accounts(id);
+ALTER TABLE holds ADD CONSTRAINT fk_holds_users FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE holds ADD CONSTRAINT fk_holds_transactions FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+-- Available balances sum the authorized holds of an account, and the expiry
+-- job scans authorized holds by expiry
+CREATE INDEX idx_holds_account_id_authorized ON holds(account_id) WHERE status = 'authorized';
+CREATE INDEX idx_holds_expires_at_authorized ON holds(expires_at) WHERE status = 'authorized';
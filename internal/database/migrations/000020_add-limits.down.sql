@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_transactions_user_limit_usage;
+DROP INDEX IF EXISTS idx_transactions_limit_usage;
+
+DROP TABLE IF EXISTS transaction_limits;
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS chk_accounts_tier;
+ALTER TABLE accounts DROP COLUMN IF EXISTS tier;
@@ -0,0 +1,37 @@
+-- Accounts are grouped into tiers that can carry their own limits
+ALTER TABLE accounts ADD COLUMN tier VARCHAR(16) NOT NULL DEFAULT 'standard';
+ALTER TABLE accounts ADD CONSTRAINT chk_accounts_tier CHECK (tier IN ('standard', 'premium', 'business'));
+
+-- A limit caps the amount and/or the number of transactions of one type and
+-- currency per calendar period, either on each account or across all of a
+-- user's accounts. A limit for a user overrides one for the account tier,
+-- which overrides the default with neither set.
+CREATE TABLE IF NOT EXISTS transaction_limits (
+    id SERIAL PRIMARY KEY,
+    transaction_type transaction_type NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    period VARCHAR(8) NOT NULL,
+    scope VARCHAR(8) NOT NULL DEFAULT 'account',
+    account_tier VARCHAR(16),
+    user_id INT,
+    max_amount DECIMAL(10, 2),
+    max_count INT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_transaction_limits_type CHECK (transaction_type IN ('DEPOSIT', 'WITHDRAWAL', 'TRANSFER')),
+    CONSTRAINT chk_transaction_limits_period CHECK (period IN ('daily', 'weekly', 'monthly')),
+    CONSTRAINT chk_transaction_limits_scope CHECK (scope IN ('account', 'user')),
+    CONSTRAINT chk_transaction_limits_tier CHECK (account_tier IN ('standard', 'premium', 'business')),
+    CONSTRAINT chk_transaction_limits_target CHECK (account_tier IS NULL OR user_id IS NULL),
+    CONSTRAINT chk_transaction_limits_cap CHECK (max_amount IS NOT NULL OR max_count IS NOT NULL),
+    CONSTRAINT chk_transaction_limits_non_negative CHECK (COALESCE(max_amount, 0) >= 0 AND COALESCE(max_count, 0) >= 0)
+);
+
+ALTER TABLE transaction_limits ADD CONSTRAINT fk_transaction_limits_currencies FOREIGN KEY (currency) REFERENCES currencies(code);
+ALTER TABLE transaction_limits ADD CONSTRAINT fk_transaction_limits_users FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE UNIQUE INDEX idx_transaction_limits_target ON transaction_limits(transaction_type, currency, period, scope, COALESCE(account_tier, ''), COALESCE(user_id, 0));
+
+-- Usage is summed per account or per user over the current period
+CREATE INDEX idx_transactions_limit_usage ON transactions(account_id, transaction_type, created_at);
+CREATE INDEX idx_transactions_user_limit_usage ON transactions(user_id, transaction_type, created_at);
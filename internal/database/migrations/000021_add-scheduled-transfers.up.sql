@@ -0,0 +1,58 @@
+-- A scheduled transfer moves money out of an account once at start_at, or
+-- repeatedly from start_at on. A transfer has a destination account; a
+-- withdrawal has none. next_run_at is when the worker picks it up next and is
+-- cleared once the schedule has finished.
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    account_id INT NOT NULL,
+    destination_account_id INT,
+    transaction_type transaction_type NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    description TEXT,
+    frequency VARCHAR(8) NOT NULL,
+    start_at TIMESTAMP NOT NULL,
+    end_at TIMESTAMP,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    occurrence INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_scheduled_transfers_amount_positive CHECK (amount > 0),
+    CONSTRAINT chk_scheduled_transfers_type CHECK (
+        (transaction_type = 'TRANSFER' AND destination_account_id IS NOT NULL)
+        OR (transaction_type = 'WITHDRAWAL' AND destination_account_id IS NULL)
+    ),
+    CONSTRAINT chk_scheduled_transfers_frequency CHECK (frequency IN ('once', 'daily', 'weekly', 'monthly')),
+    CONSTRAINT chk_scheduled_transfers_status CHECK (status IN ('active', 'paused', 'completed', 'cancelled', 'failed')),
+    CONSTRAINT chk_scheduled_transfers_next_run CHECK ((status IN ('active', 'paused')) = (next_run_at IS NOT NULL))
+);
+
+ALTER TABLE scheduled_transfers ADD CONSTRAINT fk_scheduled_transfers_users FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE scheduled_transfers ADD CONSTRAINT fk_scheduled_transfers_accounts FOREIGN KEY (account_id) REFERENCES accounts(id);
+ALTER TABLE scheduled_transfers ADD CONSTRAINT fk_scheduled_transfers_destination_accounts FOREIGN KEY (destination_account_id) REFERENCES accounts(id);
+
+-- Every attempt to run a scheduled transfer, successful or not
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+    id SERIAL PRIMARY KEY,
+    scheduled_transfer_id INT NOT NULL,
+    occurrence INT NOT NULL,
+    attempt INT NOT NULL,
+    scheduled_for TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    transaction_id INT,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_scheduled_transfer_runs_status CHECK (status IN ('succeeded', 'failed')),
+    CONSTRAINT chk_scheduled_transfer_runs_outcome CHECK ((status = 'succeeded') = (transaction_id IS NOT NULL))
+);
+
+ALTER TABLE scheduled_transfer_runs ADD CONSTRAINT fk_scheduled_transfer_runs_scheduled_transfers FOREIGN KEY (scheduled_transfer_id) REFERENCES scheduled_transfers(id);
+ALTER TABLE scheduled_transfer_runs ADD CONSTRAINT fk_scheduled_transfer_runs_transactions FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+-- The worker scans active schedules by due time
+CREATE INDEX idx_scheduled_transfers_next_run_at_active ON scheduled_transfers(next_run_at) WHERE status = 'active';
+CREATE INDEX idx_scheduled_transfers_user_id ON scheduled_transfers(user_id);
+CREATE INDEX idx_scheduled_transfer_runs_scheduled_transfer_id ON scheduled_transfer_runs(scheduled_transfer_id);
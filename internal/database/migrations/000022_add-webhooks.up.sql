@@ -0,0 +1,55 @@
+-- A webhook endpoint receives a user's events of the listed types, or of
+-- every type when event_types is empty. The secret signs each delivery.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    event_types JSONB NOT NULL DEFAULT '[]',
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_webhook_endpoints_event_types CHECK (jsonb_typeof(event_types) = 'array')
+);
+
+ALTER TABLE webhook_endpoints ADD CONSTRAINT fk_webhook_endpoints_users FOREIGN KEY (user_id) REFERENCES users(id);
+
+-- The outbox: events are written in the database transaction that causes
+-- them, together with one delivery per subscribed endpoint, so an event is
+-- delivered exactly when the change commits
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE webhook_events ADD CONSTRAINT fk_webhook_events_users FOREIGN KEY (user_id) REFERENCES users(id);
+
+-- A delivery is pending until the endpoint answers 2xx, and dead once every
+-- attempt has failed. next_attempt_at is set exactly while it is pending.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL,
+    endpoint_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP,
+    response_status INT,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_webhook_deliveries_status CHECK (status IN ('pending', 'succeeded', 'dead')),
+    CONSTRAINT chk_webhook_deliveries_next_attempt CHECK ((status = 'pending') = (next_attempt_at IS NOT NULL)),
+    CONSTRAINT uq_webhook_deliveries_event_endpoint UNIQUE (event_id, endpoint_id)
+);
+
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_events FOREIGN KEY (event_id) REFERENCES webhook_events(id) ON DELETE CASCADE;
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_endpoints FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(id) ON DELETE CASCADE;
+
+-- The dispatcher scans pending deliveries by due time
+CREATE INDEX idx_webhook_deliveries_next_attempt_at_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_endpoint_id ON webhook_deliveries(endpoint_id);
+CREATE INDEX idx_webhook_endpoints_user_id ON webhook_endpoints(user_id);
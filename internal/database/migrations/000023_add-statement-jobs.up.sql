@@ -0,0 +1,27 @@
+-- Statements are rendered by a worker pool. A job is queued by the API,
+-- running while a worker holds its lease (locked_until), and ends succeeded
+-- with the statement it produced, or failed. A running job whose lease ran
+-- out, e.g. because the server restarted, is picked up again.
+CREATE TABLE IF NOT EXISTS statement_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued',
+    request JSONB NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    statement_id INT,
+    error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_statement_jobs_status CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    CONSTRAINT chk_statement_jobs_statement CHECK ((status = 'succeeded') = (statement_id IS NOT NULL))
+);
+
+ALTER TABLE statement_jobs ADD CONSTRAINT fk_statement_jobs_users FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE statement_jobs ADD CONSTRAINT fk_statement_jobs_statements FOREIGN KEY (statement_id) REFERENCES statements(id);
+
+-- Workers claim the oldest unfinished job
+CREATE INDEX idx_statement_jobs_unfinished ON statement_jobs(id) WHERE status IN ('queued', 'running');
+CREATE INDEX idx_statement_jobs_user_id ON statement_jobs(user_id);
@@ -0,0 +1,20 @@
+-- TOTP two-factor authentication. totp_secret is set from enrolment on;
+-- two-factor is on once the user confirmed a code (totp_enabled_at).
+-- totp_last_step is the time step of the last accepted code, so a code
+-- cannot be used twice.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- One-time recovery codes for a lost authenticator; only the SHA-256 hash
+-- is stored
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_recovery_codes_user_code UNIQUE (user_id, code_hash)
+);
+
+ALTER TABLE recovery_codes ADD CONSTRAINT fk_recovery_codes_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
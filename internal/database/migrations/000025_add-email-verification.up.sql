@@ -0,0 +1,21 @@
+-- Users registered before email verification existed are treated as
+-- verified; everyone else verifies through an emailed token
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+UPDATE users SET email_verified_at = created_at;
+
+-- Single-use tokens mailed to users, for email verification and password
+-- reset. Only the SHA-256 hash is stored.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_user_tokens_purpose CHECK (purpose IN ('email_verification', 'password_reset'))
+);
+
+ALTER TABLE user_tokens ADD CONSTRAINT fk_user_tokens_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_user_tokens_user_id_purpose ON user_tokens(user_id, purpose);
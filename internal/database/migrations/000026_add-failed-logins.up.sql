@@ -0,0 +1,11 @@
+-- Failed login attempts, kept for the lockout window so that throttling
+-- holds across every instance. Emails are stored lower-cased.
+CREATE TABLE IF NOT EXISTS failed_logins (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    attempted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_failed_logins_email_attempted_at ON failed_logins(email, attempted_at);
+CREATE INDEX idx_failed_logins_ip_attempted_at ON failed_logins(ip, attempted_at);
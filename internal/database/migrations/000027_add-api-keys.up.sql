@@ -0,0 +1,20 @@
+-- API keys let scripts call the API as their owner, limited to scopes. A
+-- key is "<prefix>_<secret>"; the prefix finds the row, and only the
+-- SHA-256 of the whole key is stored. Keys also serve as OAuth2 clients,
+-- with the prefix as client_id.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE api_keys ADD CONSTRAINT fk_api_keys_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
@@ -0,0 +1,6 @@
+-- A reserved key is only held for a short lease. When the request that
+-- reserved it dies without storing a response, a retry with the same body
+-- takes the key over once the lease has passed, instead of waiting for the
+-- key to expire.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP;
+UPDATE idempotency_keys SET locked_until = CURRENT_TIMESTAMP WHERE response_status IS NULL;
@@ -5,17 +5,25 @@ import "time"
 type Account struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
-	Balance   float64   `json:"balance"`
+	Balance   Money     `json:"balance" swaggertype:"number"`
+	// LedgerBalance is the booked balance, the same as Balance.
+	// AvailableBalance is what can still be spent: the ledger balance less
+	// authorized holds.
+	LedgerBalance    Money `json:"ledger_balance" swaggertype:"number"`
+	AvailableBalance Money `json:"available_balance" swaggertype:"number"`
 	Currency  Currency  `json:"currency"`
 	AccountName string `json:"account_name"`
 	AccountDescription string `json:"account_description"`
+	Status    AccountStatus `json:"status"`
+	Tier      AccountTier   `json:"tier"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type AccountDTO struct {
 	ID        int       `json:"id"`
-	Balance   float64   `json:"balance"`
+	Balance   Money     `json:"balance" swaggertype:"number"`
 	Currency  Currency  `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -35,5 +43,15 @@ type DeleteAccountRequest struct {
 	ID int `json:"id"`
 }
 
+// CloseAccountRequest closes an account. An account that still holds money
+// can only be closed by sweeping the balance into SweepToAccountID, another
+// account of the same owner and currency.
+type CloseAccountRequest struct {
+	AccountID        int  `json:"account_id"`
+	SweepToAccountID *int `json:"sweep_to_account_id,omitempty"`
+}
 
-
+type UpdateAccountStatusRequest struct {
+	AccountID int    `json:"account_id"`
+	Reason    string `json:"reason"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// APIScope is an area of the API that an API key may call. Each scope
+// covers reading (":read") or changing (":write") one kind of resource.
+type APIScope string
+
+const (
+	ScopeAccountsRead      APIScope = "accounts:read"
+	ScopeAccountsWrite     APIScope = "accounts:write"
+	ScopeTransactionsRead  APIScope = "transactions:read"
+	ScopeTransactionsWrite APIScope = "transactions:write"
+	ScopeHoldsRead         APIScope = "holds:read"
+	ScopeHoldsWrite        APIScope = "holds:write"
+	ScopeScheduledRead     APIScope = "scheduled:read"
+	ScopeScheduledWrite    APIScope = "scheduled:write"
+	ScopeStatementsRead    APIScope = "statements:read"
+	ScopeStatementsWrite   APIScope = "statements:write"
+	ScopeWebhooksRead      APIScope = "webhooks:read"
+	ScopeWebhooksWrite     APIScope = "webhooks:write"
+	ScopeProfileRead       APIScope = "profile:read"
+	ScopeAdminRead         APIScope = "admin:read"
+	ScopeAdminWrite        APIScope = "admin:write"
+)
+
+// APIScopes lists every scope, in the order they are documented
+var APIScopes = []APIScope{
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeTransactionsRead,
+	ScopeTransactionsWrite,
+	ScopeHoldsRead,
+	ScopeHoldsWrite,
+	ScopeScheduledRead,
+	ScopeScheduledWrite,
+	ScopeStatementsRead,
+	ScopeStatementsWrite,
+	ScopeWebhooksRead,
+	ScopeWebhooksWrite,
+	ScopeProfileRead,
+	ScopeAdminRead,
+	ScopeAdminWrite,
+}
+
+func (s APIScope) Valid() bool {
+	for _, scope := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey lets a script call the API as UserID, limited to Scopes. Key is
+// only returned when the key is created.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []APIScope `json:"scopes"`
+	Key        string     `json:"key,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []APIScope `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type RevokeAPIKeyRequest struct {
+	ID int `json:"id"`
+}
+
+// APIKeyPrincipal is who an API key or a client-credentials token acts for
+type APIKeyPrincipal struct {
+	KeyID  int
+	Prefix string
+	UserID int
+	Role   Role
+	Scopes []APIScope
+}
+
+// OAuthTokenResponse is the RFC 6749 access token response of the
+// client-credentials grant.
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// OAuthErrorResponse is an RFC 6749 error response.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions, named <resource>.<verb>
+const (
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditUserPasswordChange = "user.password_change"
+	AuditUserPasswordReset  = "user.password_reset"
+	AuditUserEmailVerify    = "user.email_verify"
+	AuditUserRoleChange     = "user.role_change"
+	AuditUserTwoFactorOn    = "user.two_factor_enable"
+	AuditUserTwoFactorOff   = "user.two_factor_disable"
+	AuditUserUnlock         = "user.unlock"
+	AuditAPIKeyCreate       = "api_key.create"
+	AuditAPIKeyRevoke       = "api_key.revoke"
+	AuditAccountCreate      = "account.create"
+	AuditAccountClose       = "account.close"
+	AuditAccountStatus      = "account.status_change"
+	AuditDeposit            = "transaction.deposit"
+	AuditWithdrawal         = "transaction.withdrawal"
+	AuditTransfer           = "transaction.transfer"
+	AuditReversal           = "transaction.reversal"
+	AuditRefund             = "transaction.refund"
+	AuditHoldAuthorize      = "hold.authorize"
+	AuditHoldCapture        = "hold.capture"
+	AuditHoldRelease        = "hold.release"
+	AuditHoldExpire         = "hold.expire"
+	AuditAccountTier        = "account.tier_change"
+	AuditLimitSet           = "limit.set"
+	AuditLimitDelete        = "limit.delete"
+	AuditScheduleCreate     = "scheduled_transfer.create"
+	AuditScheduleStatus     = "scheduled_transfer.status_change"
+	AuditWebhookCreate      = "webhook.create"
+	AuditWebhookDelete      = "webhook.delete"
+	AuditWebhookReplay      = "webhook.replay"
+	AuditCurrencyUpdate     = "currency.update"
+)
+
+// AuditActor is who made a change and from where. UserID is nil for changes
+// made by the system itself or by an anonymous caller such as a new user
+// registering.
+type AuditActor struct {
+	UserID    *int
+	Role      Role
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// SystemActor is the actor recorded for background jobs.
+var SystemActor = AuditActor{}
+
+// AuditEvent is one row of the audit log. Before and After hold the changed
+// values as JSON; either is empty when there is nothing to show, e.g. Before
+// on a create.
+type AuditEvent struct {
+	ID           int64           `json:"id"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	ActorUserID  *int            `json:"actor_user_id,omitempty"`
+	ActorRole    Role            `json:"actor_role,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After        json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	IP           string          `json:"ip,omitempty"`
+	UserAgent    string          `json:"user_agent,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+type AuditFilter struct {
+	ActorUserID  *int
+	Action       *string
+	ResourceType *string
+	ResourceID   *string
+	RequestID    *string
+	DateFrom     *time.Time
+	DateTo       *time.Time
+}
+
+// AuditVerification is the result of re-computing the audit hash chain.
+// BrokenAt is the first event whose stored hash does not match.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt *int64 `json:"broken_at,omitempty"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CurrencyInfo is one ISO 4217 currency in the catalogue.
+type CurrencyInfo struct {
+	Code       Currency  `json:"code"`
+	Name       string    `json:"name"`
+	MinorUnits int       `json:"minor_units"`
+	Symbol     string    `json:"symbol"`
+	Enabled    bool      `json:"enabled"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type UpdateCurrencyRequest struct {
+	Code    Currency `json:"code"`
+	Enabled bool     `json:"enabled"`
+}
+
+// currencies is the in-memory copy of the currencies table. It starts with
+// the currencies every installation has, so code running before the table
+// is loaded (and tests) can still format money.
+var currencies = struct {
+	sync.RWMutex
+	byCode map[Currency]CurrencyInfo
+}{
+	byCode: map[Currency]CurrencyInfo{
+		USD: {Code: USD, Name: "US Dollar", MinorUnits: 2, Symbol: "$", Enabled: true},
+		EUR: {Code: EUR, Name: "Euro", MinorUnits: 2, Symbol: "€", Enabled: true},
+		GBP: {Code: GBP, Name: "Pound Sterling", MinorUnits: 2, Symbol: "£", Enabled: true},
+	},
+}
+
+// SetCurrencies replaces the catalogue with the rows read from the database.
+func SetCurrencies(list []CurrencyInfo) {
+	byCode := make(map[Currency]CurrencyInfo, len(list))
+	for _, info := range list {
+		byCode[info.Code] = info
+	}
+
+	currencies.Lock()
+	currencies.byCode = byCode
+	currencies.Unlock()
+}
+
+// Currencies lists the catalogue ordered by code.
+func Currencies() []CurrencyInfo {
+	currencies.RLock()
+	list := make([]CurrencyInfo, 0, len(currencies.byCode))
+	for _, info := range currencies.byCode {
+		list = append(list, info)
+	}
+	currencies.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Info returns the catalogue entry of the currency.
+func (c Currency) Info() (CurrencyInfo, error) {
+	currencies.RLock()
+	info, ok := currencies.byCode[c]
+	currencies.RUnlock()
+
+	if !ok {
+		return CurrencyInfo{}, fmt.Errorf("unsupported currency: %s", c)
+	}
+	return info, nil
+}
+
+// MinorUnits returns the number of decimal places the currency is quoted in.
+// Disabled currencies still have minor units so existing balances format.
+func (c Currency) MinorUnits() (int, error) {
+	info, err := c.Info()
+	if err != nil {
+		return 0, err
+	}
+	return info.MinorUnits, nil
+}
+
+// CheckEnabled fails unless the currency can be used for new accounts and
+// conversions.
+func (c Currency) CheckEnabled() error {
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	if !info.Enabled {
+		return fmt.Errorf("currency %s is not enabled", c)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestCurrencyCatalogue(t *testing.T) {
+	defaults := Currencies()
+	t.Cleanup(func() { SetCurrencies(defaults) })
+
+	SetCurrencies(append(defaults,
+		CurrencyInfo{Code: "JPY", Name: "Yen", MinorUnits: 0, Symbol: "¥", Enabled: false},
+		CurrencyInfo{Code: "CHF", Name: "Swiss Franc", MinorUnits: 2, Symbol: "CHF", Enabled: true},
+	))
+
+	yen, err := ParseMoney("1500", "JPY")
+	if err != nil {
+		t.Fatalf("ParseMoney unexpected error: %v", err)
+	}
+	if yen.Minor != 1500 || yen.String() != "1500" || yen.Display() != "¥1500" {
+		t.Errorf("got %d, %q, %q; want 1500, \"1500\", \"¥1500\"", yen.Minor, yen.String(), yen.Display())
+	}
+	if _, err := ParseMoney("1500.5", "JPY"); err == nil {
+		t.Error("expected JPY to reject decimal places")
+	}
+	if err := Currency("JPY").CheckEnabled(); err == nil {
+		t.Error("expected disabled JPY to fail CheckEnabled")
+	}
+
+	if got := NewMoney(-1230, "CHF").Display(); got != "-12.30 CHF" {
+		t.Errorf("Display = %q; want %q", got, "-12.30 CHF")
+	}
+	if got := NewMoney(-1230, EUR).Display(); got != "-€12.30" {
+		t.Errorf("Display = %q; want %q", got, "-€12.30")
+	}
+
+	if _, err := Currency("XXX").MinorUnits(); err == nil {
+		t.Error("expected unknown currency to fail")
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CursorDirection tells whether a cursor pages towards older (next) or
+// newer (prev) rows.
+type CursorDirection string
+
+const (
+	CursorNext CursorDirection = "next"
+	CursorPrev CursorDirection = "prev"
+)
+
+// Cursor is a position in a listing ordered by (created_at, id) descending.
+// Clients only ever see it encoded.
+type Cursor struct {
+	CreatedAt time.Time       `json:"t"`
+	ID        int             `json:"i"`
+	Direction CursorDirection `json:"d"`
+}
+
+// Encode returns the opaque form of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, fmt.Errorf("malformed cursor")
+	}
+	if c.ID < 1 || c.CreatedAt.IsZero() || (c.Direction != CursorNext && c.Direction != CursorPrev) {
+		return Cursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	return c, nil
+}
+
+// CursorPaginationRequest asks for one page of a keyset-paginated listing.
+// A nil Cursor means the first (newest) page.
+type CursorPaginationRequest struct {
+	Cursor       *Cursor
+	PageSize     int
+	IncludeTotal bool
+}
+
+type CursorPaginationResponse struct {
+	PageSize     int     `json:"page_size"`
+	NextCursor   *string `json:"next_cursor"`
+	PrevCursor   *string `json:"prev_cursor"`
+	TotalRecords *int64  `json:"total_records,omitempty"`
+}
+
+type CursorPaginatedResponse[T any] struct {
+	Data       []T                      `json:"data"`
+	Pagination CursorPaginationResponse `json:"pagination"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// rateScale is the number of decimal places exchange rates are stored with.
+const rateScale = 10
+
+// ExchangeRate is the mid-market price of one unit of Base in Quote.
+type ExchangeRate struct {
+	Base  Currency  `json:"base"`
+	Quote Currency  `json:"quote"`
+	Rate  string    `json:"rate"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// FXQuote is a rate offered to a user for converting SourceAmount into
+// DestinationAmount. It can be used by exactly one transfer before ExpiresAt.
+type FXQuote struct {
+	ID                string    `json:"id"`
+	FromCurrency      Currency  `json:"from_currency"`
+	ToCurrency        Currency  `json:"to_currency"`
+	MidRate           string    `json:"mid_rate"`
+	Spread            string    `json:"spread"`
+	Rate              string    `json:"rate"`
+	SourceAmount      Money     `json:"source_amount" swaggertype:"number"`
+	DestinationAmount Money     `json:"destination_amount" swaggertype:"number"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type CreateFXQuoteRequest struct {
+	FromCurrency Currency `json:"from_currency"`
+	ToCurrency   Currency `json:"to_currency"`
+	Amount       Amount   `json:"amount" swaggertype:"number"`
+}
+
+// ConvertedTotal is the sum of a user's balances expressed in one currency
+// at mid-market rates.
+type ConvertedTotal struct {
+	Currency Currency          `json:"currency"`
+	Amount   Money             `json:"amount" swaggertype:"number"`
+	Rates    map[string]string `json:"rates"`
+}
+
+// ParseRate parses a positive decimal exchange rate such as "1.0850".
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "eE/") {
+		return nil, fmt.Errorf("invalid rate: %q", s)
+	}
+
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid rate: %q", s)
+	}
+	return rate, nil
+}
+
+// FormatRate renders a rate with at most rateScale decimal places and no
+// trailing zeros, rounding half away from zero.
+func FormatRate(rate *big.Rat) string {
+	s := rate.FloatString(rateScale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// RoundRate rounds a rate to the precision it is stored with, so a stored
+// rate always reproduces the amounts it was quoted with.
+func RoundRate(rate *big.Rat) *big.Rat {
+	rounded, _ := new(big.Rat).SetString(rate.FloatString(rateScale))
+	return rounded
+}
+
+// Convert exchanges the amount into another currency at rate, truncating
+// towards zero to the target currency's minor units so a conversion never
+// hands out more than the rate allows.
+func (m Money) Convert(to Currency, rate *big.Rat) (Money, error) {
+	fromUnits, err := m.Currency.MinorUnits()
+	if err != nil {
+		return Money{}, err
+	}
+	toUnits, err := to.MinorUnits()
+	if err != nil {
+		return Money{}, err
+	}
+
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor), rate)
+	value.Mul(value, new(big.Rat).SetFrac(
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(toUnits)), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(fromUnits)), nil),
+	))
+
+	minor := new(big.Int).Quo(value.Num(), value.Denom())
+	if !minor.IsInt64() {
+		return Money{}, fmt.Errorf("converted amount is out of range")
+	}
+
+	return Money{Minor: minor.Int64(), Currency: to}, nil
+}
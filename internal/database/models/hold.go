@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// HoldStatus is where a hold is in its lifecycle. Only authorized holds
+// reserve money.
+type HoldStatus string
+
+const (
+	HoldAuthorized HoldStatus = "authorized"
+	HoldCaptured   HoldStatus = "captured"
+	HoldReleased   HoldStatus = "released"
+	HoldExpired    HoldStatus = "expired"
+)
+
+// Hold reserves Amount on an account until it is captured, released or
+// expires. TransactionID is the pending WITHDRAWAL that settles the hold.
+type Hold struct {
+	ID             int        `json:"id"`
+	AccountID      int        `json:"account_id"`
+	TransactionID  int        `json:"transaction_id"`
+	Amount         Money      `json:"amount" swaggertype:"number"`
+	CapturedAmount *Money     `json:"captured_amount,omitempty" swaggertype:"number"`
+	Currency       Currency   `json:"currency"`
+	Status         HoldStatus `json:"status"`
+	Description    string     `json:"description,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type AuthorizeHoldRequest struct {
+	AccountID   int    `json:"account_id"`
+	Amount      Amount `json:"amount" swaggertype:"number"`
+	Description string `json:"description"`
+}
+
+// CaptureHoldRequest settles a hold. Without Amount the whole hold is
+// captured; a smaller amount captures part of it and releases the rest.
+type CaptureHoldRequest struct {
+	HoldID int     `json:"hold_id"`
+	Amount *Amount `json:"amount,omitempty" swaggertype:"number"`
+}
+
+type ReleaseHoldRequest struct {
+	HoldID int `json:"hold_id"`
+}
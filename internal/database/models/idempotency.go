@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is a stored Idempotency-Key together with the request it
+// was first used for and, once the request finished, the response to replay.
+type IdempotencyRecord struct {
+	UserID         int
+	Key            string
+	RequestMethod  string
+	RequestPath    string
+	RequestHash    string
+	ResponseStatus *int
+	ResponseBody   []byte
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// SystemAccount names a bank-side ledger account that balances customer
+// postings, e.g. the cash that came in through a deposit.
+type SystemAccount string
+
+const (
+	CashIn         SystemAccount = "CASH_IN"
+	CashOut        SystemAccount = "CASH_OUT"
+	OpeningBalance SystemAccount = "OPENING_BALANCE"
+	FXPosition     SystemAccount = "FX_POSITION"
+)
+
+// JournalEntry is one balanced money movement. The sum of its DEBIT postings
+// equals the sum of its CREDIT postings for every currency involved.
+type JournalEntry struct {
+	ID          int       `json:"id"`
+	ReferenceID string    `json:"reference_id"`
+	EntryType   string    `json:"entry_type"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Postings    []Posting `json:"postings"`
+}
+
+// Posting is a single leg of a journal entry. Exactly one of AccountID and
+// SystemAccount is set. A CREDIT increases a customer account balance and a
+// DEBIT decreases it.
+type Posting struct {
+	ID             int                  `json:"id"`
+	JournalEntryID int                  `json:"journal_entry_id"`
+	AccountID      *int                 `json:"account_id,omitempty"`
+	SystemAccount  *SystemAccount       `json:"system_account,omitempty"`
+	TransactionID  *int                 `json:"transaction_id,omitempty"`
+	Side           TransactionDirection `json:"side"`
+	Amount         Money                `json:"amount" swaggertype:"number"`
+	Currency       Currency             `json:"currency"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+type LedgerReconciliation struct {
+	AccountID     int      `json:"account_id"`
+	Currency      Currency `json:"currency"`
+	Balance       Money    `json:"balance" swaggertype:"number"`
+	LedgerBalance Money    `json:"ledger_balance" swaggertype:"number"`
+	Difference    Money    `json:"difference" swaggertype:"number"`
+	Balanced      bool     `json:"balanced"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import "time"
+
+// AccountTier groups accounts that share transaction limits
+type AccountTier string
+
+const (
+	TierStandard AccountTier = "standard"
+	TierPremium  AccountTier = "premium"
+	TierBusiness AccountTier = "business"
+)
+
+func (t AccountTier) Valid() bool {
+	switch t {
+	case TierStandard, TierPremium, TierBusiness:
+		return true
+	}
+	return false
+}
+
+// LimitPeriod is the calendar window a limit is counted over, in UTC
+type LimitPeriod string
+
+const (
+	LimitDaily   LimitPeriod = "daily"
+	LimitWeekly  LimitPeriod = "weekly"
+	LimitMonthly LimitPeriod = "monthly"
+)
+
+func (p LimitPeriod) Valid() bool {
+	switch p {
+	case LimitDaily, LimitWeekly, LimitMonthly:
+		return true
+	}
+	return false
+}
+
+// Window returns the start of the period containing now and the start of the
+// next one. Weeks start on Monday.
+func (p LimitPeriod) Window(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch p {
+	case LimitWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case LimitMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// LimitScope tells whether a limit counts one account's transactions or
+// those of all the owner's accounts in the limit's currency
+type LimitScope string
+
+const (
+	LimitPerAccount LimitScope = "account"
+	LimitPerUser    LimitScope = "user"
+)
+
+func (s LimitScope) Valid() bool {
+	return s == LimitPerAccount || s == LimitPerUser
+}
+
+// TransactionLimit caps the amount and/or count of transactions of one type
+// and currency per period. A limit with UserID set overrides one with
+// AccountTier set, which overrides the default with neither.
+type TransactionLimit struct {
+	ID              int             `json:"id"`
+	TransactionType TransactionType `json:"transaction_type"`
+	Currency        Currency        `json:"currency"`
+	Period          LimitPeriod     `json:"period"`
+	Scope           LimitScope      `json:"scope"`
+	AccountTier     *AccountTier    `json:"account_tier,omitempty"`
+	UserID          *int            `json:"user_id,omitempty"`
+	MaxAmount       *Money          `json:"max_amount,omitempty" swaggertype:"number"`
+	MaxCount        *int            `json:"max_count,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// LimitUsage is how much of a limit has been used in the current period.
+// The remaining fields are only set for the caps the limit has.
+type LimitUsage struct {
+	TransactionLimit
+	UsedAmount      Money     `json:"used_amount" swaggertype:"number"`
+	UsedCount       int       `json:"used_count"`
+	RemainingAmount *Money    `json:"remaining_amount,omitempty" swaggertype:"number"`
+	RemainingCount  *int      `json:"remaining_count,omitempty"`
+	ResetsAt        time.Time `json:"resets_at"`
+}
+
+// SetLimitRequest creates a limit, or replaces the caps of the limit with the
+// same type, currency, period, scope and target.
+type SetLimitRequest struct {
+	TransactionType TransactionType `json:"transaction_type"`
+	Currency        Currency        `json:"currency"`
+	Period          LimitPeriod     `json:"period"`
+	Scope           LimitScope      `json:"scope"`
+	AccountTier     *AccountTier    `json:"account_tier,omitempty"`
+	UserID          *int            `json:"user_id,omitempty"`
+	MaxAmount       *Amount         `json:"max_amount,omitempty" swaggertype:"number"`
+	MaxCount        *int            `json:"max_count,omitempty"`
+}
+
+type UpdateAccountTierRequest struct {
+	AccountID int         `json:"account_id"`
+	Tier      AccountTier `json:"tier"`
+}
+
+// LimitMeasure is the cap of a limit that a transaction would break
+type LimitMeasure string
+
+const (
+	LimitMeasureAmount LimitMeasure = "amount"
+	LimitMeasureCount  LimitMeasure = "count"
+)
+
+// LimitBreach describes the limit a rejected transaction would have broken
+type LimitBreach struct {
+	Measure LimitMeasure `json:"measure"`
+	Limit   LimitUsage   `json:"limit"`
+}
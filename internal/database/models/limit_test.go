@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitPeriodWindow(t *testing.T) {
+	// A Wednesday evening, given in a zone where it is already Thursday
+	now := time.Date(2024, 2, 28, 22, 30, 0, 0, time.UTC).In(time.FixedZone("UTC+3", 3*60*60))
+
+	tests := []struct {
+		period     LimitPeriod
+		start, end time.Time
+	}{
+		{LimitDaily, time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{LimitWeekly, time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{LimitMonthly, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		start, end := tt.period.Window(now)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("%s window = [%s, %s); want [%s, %s)", tt.period, start, end, tt.start, tt.end)
+		}
+	}
+
+	// Sunday still belongs to the week that started on Monday
+	sunday := time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)
+	if start, _ := LimitWeekly.Window(sunday); !start.Equal(time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly window of a Sunday starts %s; want 2024-02-26", start)
+	}
+}
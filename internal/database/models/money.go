@@ -0,0 +1,279 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxAmountDigits keeps every amount well inside int64 once scaled to minor units.
+const maxAmountDigits = 15
+
+// Money is an exact amount of a currency held as an integer count of the
+// currency's minor units, e.g. 1234 USD is $12.34. It is written to JSON as a
+// plain number and to the database as a decimal string, never as a float.
+type Money struct {
+	Minor    int64
+	Currency Currency
+}
+
+func NewMoney(minor int64, currency Currency) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "12.30" into Money. It fails
+// instead of rounding when the value has more decimal places than the
+// currency allows.
+func ParseMoney(s string, currency Currency) (Money, error) {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return amount.Money(currency)
+}
+
+func (m Money) scale() int {
+	units, err := m.Currency.MinorUnits()
+	if err != nil {
+		return 0
+	}
+	return units
+}
+
+// String formats the amount as a plain decimal, e.g. "-12.30".
+func (m Money) String() string {
+	units := m.scale()
+
+	abs := uint64(m.Minor)
+	sign := ""
+	if m.Minor < 0 {
+		abs = uint64(-(m.Minor + 1)) + 1
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if units == 0 {
+		return sign + digits
+	}
+	if len(digits) <= units {
+		digits = strings.Repeat("0", units-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
+
+// Format renders the amount with its currency code for display, e.g. "12.30 USD".
+func (m Money) Format() string {
+	return fmt.Sprintf("%s %s", m.String(), m.Currency)
+}
+
+// Display renders the amount with the currency symbol when it has one,
+// e.g. "€12.30", and falls back to Format otherwise.
+func (m Money) Display() string {
+	info, err := m.Currency.Info()
+	if err != nil || info.Symbol == "" || info.Symbol == string(m.Currency) {
+		return m.Format()
+	}
+	if m.Minor < 0 {
+		return "-" + info.Symbol + m.Neg().String()
+	}
+	return info.Symbol + m.String()
+}
+
+// Float64 approximates the amount for metrics. Never use it for arithmetic.
+func (m Money) Float64() float64 {
+	return float64(m.Minor) / math.Pow10(m.scale())
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.Minor + other.Minor
+	if (other.Minor > 0 && sum < m.Minor) || (other.Minor < 0 && sum > m.Minor) {
+		return Money{}, fmt.Errorf("amount overflow")
+	}
+	return Money{Minor: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+// Cmp returns -1, 0 or 1 when m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Minor < other.Minor:
+		return -1, nil
+	case m.Minor > other.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) sameCurrency(other Money) error {
+	// A zero value without a currency can be combined with anything
+	if m.Currency == "" || other.Currency == "" || m.Currency == other.Currency {
+		return nil
+	}
+	return fmt.Errorf("currency mismatch: %s and %s", m.Currency, other.Currency)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Value writes the amount as an exact decimal string for DECIMAL columns.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Amount is an exact decimal number as sent by a client, before it is bound
+// to a currency. It accepts JSON numbers and numeric strings and keeps every
+// digit, so "10.005" is never silently rounded to "10.01".
+type Amount struct {
+	negative bool
+	whole    string
+	fraction string
+}
+
+// ParseAmount parses a plain decimal such as "12", "-0.5" or "100.25".
+// Exponents, thousands separators and bare dots are rejected.
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	raw := s
+
+	var a Amount
+	if strings.HasPrefix(s, "-") {
+		a.negative = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	whole, fraction, hasDot := strings.Cut(s, ".")
+	if whole == "" || (hasDot && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Amount{}, fmt.Errorf("invalid amount: %q", raw)
+	}
+
+	a.whole = strings.TrimLeft(whole, "0")
+	if a.whole == "" {
+		a.whole = "0"
+	}
+	a.fraction = strings.TrimRight(fraction, "0")
+
+	if len(a.whole)+len(a.fraction) > maxAmountDigits {
+		return Amount{}, fmt.Errorf("amount %q has too many digits", raw)
+	}
+
+	if a.IsZero() {
+		a.negative = false
+	}
+
+	return a, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Amount) IsZero() bool {
+	return (a.whole == "" || a.whole == "0") && a.fraction == ""
+}
+
+func (a Amount) IsNegative() bool {
+	return a.negative
+}
+
+func (a Amount) String() string {
+	s := a.whole
+	if s == "" {
+		s = "0"
+	}
+	if a.fraction != "" {
+		s += "." + a.fraction
+	}
+	if a.negative {
+		s = "-" + s
+	}
+	return s
+}
+
+// Cmp compares two amounts exactly and returns -1, 0 or +1.
+func (a Amount) Cmp(other Amount) int {
+	x, _ := new(big.Rat).SetString(a.String())
+	y, _ := new(big.Rat).SetString(other.String())
+	return x.Cmp(y)
+}
+
+// Money binds the amount to a currency. It fails if the amount has more
+// decimal places than the currency's minor units.
+func (a Amount) Money(currency Currency) (Money, error) {
+	units, err := currency.MinorUnits()
+	if err != nil {
+		return Money{}, err
+	}
+
+	if len(a.fraction) > units {
+		return Money{}, fmt.Errorf("amount %s has more than %d decimal places for %s", a, units, currency)
+	}
+
+	whole := a.whole
+	if whole == "" {
+		whole = "0"
+	}
+
+	minor, err := strconv.ParseInt(whole+a.fraction+strings.Repeat("0", units-len(a.fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %s is out of range", a)
+	}
+	if a.negative {
+		minor = -minor
+	}
+
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
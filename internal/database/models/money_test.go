@@ -0,0 +1,159 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		want     int64
+		wantErr  bool
+	}{
+		{"12.34", USD, 1234, false},
+		{"12.3", USD, 1230, false},
+		{"12", USD, 1200, false},
+		{"0012.300", USD, 1230, false},
+		{"-0.01", USD, -1, false},
+		{"0.1", EUR, 10, false},
+		{"10.005", USD, 0, true},
+		{"1e3", USD, 0, true},
+		{".5", USD, 0, true},
+		{"5.", USD, 0, true},
+		{"1,000.00", USD, 0, true},
+		{"", USD, 0, true},
+		{"1.00", Currency("XXX"), 0, true},
+		{"1234567890123456", USD, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %s) expected error, got %v", tt.input, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s) unexpected error: %v", tt.input, tt.currency, err)
+			continue
+		}
+		if got.Minor != tt.want || got.Currency != tt.currency {
+			t.Errorf("ParseMoney(%q, %s) = %d %s; want %d %s", tt.input, tt.currency, got.Minor, got.Currency, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{NewMoney(1234, USD), "12.34"},
+		{NewMoney(5, USD), "0.05"},
+		{NewMoney(-5, USD), "-0.05"},
+		{NewMoney(0, USD), "0.00"},
+		{NewMoney(100000000, GBP), "1000000.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money{%d %s}.String() = %q; want %q", tt.money.Minor, tt.money.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := NewMoney(10, USD)
+	b := NewMoney(20, USD)
+
+	// 0.1 + 0.2 must be exactly 0.3
+	sum, err := a.Add(b)
+	if err != nil || sum.Minor != 30 {
+		t.Fatalf("0.10 + 0.20 = %v (err %v); want 0.30", sum, err)
+	}
+
+	if _, err := a.Add(NewMoney(10, EUR)); err == nil {
+		t.Error("expected currency mismatch error")
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	var request CreateTransactionRequest
+	if err := json.Unmarshal([]byte(`{"amount": 100.10, "account_id": 1}`), &request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Amount.String() != "100.1" {
+		t.Errorf("amount = %s; want 100.1", request.Amount)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": "7.25"}`), &request); err != nil {
+		t.Fatalf("unexpected error for string amount: %v", err)
+	}
+	if request.Amount.String() != "7.25" {
+		t.Errorf("amount = %s; want 7.25", request.Amount)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": 1e2}`), &request); err == nil {
+		t.Error("expected error for exponent amount")
+	}
+
+	out, err := json.Marshal(Account{Balance: NewMoney(1050, USD), Currency: USD})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(decoded["balance"]) != "10.50" {
+		t.Errorf("balance JSON = %s; want 10.50", decoded["balance"])
+	}
+}
+
+func TestMoneyConvert(t *testing.T) {
+	tests := []struct {
+		money Money
+		to    Currency
+		rate  string
+		want  int64
+	}{
+		{NewMoney(10000, USD), EUR, "0.92", 9200},
+		{NewMoney(1, USD), EUR, "0.92", 0},
+		{NewMoney(333, EUR), USD, "1.0869565217", 361},
+		{NewMoney(-10000, USD), GBP, "0.79", -7900},
+	}
+
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q) unexpected error: %v", tt.rate, err)
+		}
+		got, err := tt.money.Convert(tt.to, rate)
+		if err != nil {
+			t.Errorf("%s.Convert(%s, %s) unexpected error: %v", tt.money.Format(), tt.to, tt.rate, err)
+			continue
+		}
+		if got.Minor != tt.want || got.Currency != tt.to {
+			t.Errorf("%s.Convert(%s, %s) = %s; want %d %s", tt.money.Format(), tt.to, tt.rate, got.Format(), tt.want, tt.to)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, input := range []string{"", "0", "-1.2", "1e3", "1/3", "abc"} {
+		if _, err := ParseRate(input); err == nil {
+			t.Errorf("ParseRate(%q) expected error", input)
+		}
+	}
+
+	rate, err := ParseRate("1.08500")
+	if err != nil {
+		t.Fatalf("ParseRate unexpected error: %v", err)
+	}
+	if got := FormatRate(rate); got != "1.085" {
+		t.Errorf("FormatRate = %q; want %q", got, "1.085")
+	}
+}
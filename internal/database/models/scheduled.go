@@ -0,0 +1,117 @@
+package models
+
+import "time"
+
+// ScheduleFrequency is how often a scheduled transfer repeats
+type ScheduleFrequency string
+
+const (
+	ScheduleOnce    ScheduleFrequency = "once"
+	ScheduleDaily   ScheduleFrequency = "daily"
+	ScheduleWeekly  ScheduleFrequency = "weekly"
+	ScheduleMonthly ScheduleFrequency = "monthly"
+)
+
+func (f ScheduleFrequency) Valid() bool {
+	switch f {
+	case ScheduleOnce, ScheduleDaily, ScheduleWeekly, ScheduleMonthly:
+		return true
+	}
+	return false
+}
+
+// Occurrence returns when the nth run of a schedule starting at start is
+// due; the 0th is start itself. Monthly runs keep the day of the month of
+// start, moved back to the last day in shorter months, so a schedule on the
+// 31st runs on the 30th in April without drifting in May.
+func (f ScheduleFrequency) Occurrence(start time.Time, n int) time.Time {
+	switch f {
+	case ScheduleDaily:
+		return start.AddDate(0, 0, n)
+	case ScheduleWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case ScheduleMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		return first.AddDate(0, 0, min(start.Day(), lastDay)-1)
+	}
+	return start
+}
+
+// ScheduledTransferStatus is where a scheduled transfer is in its
+// lifecycle. Only active schedules run; active and paused ones have a next
+// run.
+type ScheduledTransferStatus string
+
+const (
+	ScheduleActive    ScheduledTransferStatus = "active"
+	SchedulePaused    ScheduledTransferStatus = "paused"
+	ScheduleCompleted ScheduledTransferStatus = "completed"
+	ScheduleCancelled ScheduledTransferStatus = "cancelled"
+	ScheduleFailed    ScheduledTransferStatus = "failed"
+)
+
+// ScheduledTransfer moves Amount out of an account at StartAt and then every
+// Frequency until EndAt. TRANSFERs go to DestinationAccountID; WITHDRAWALs
+// have no destination. Occurrence counts the runs already done or given up
+// on, and Attempts the failed attempts at the current one.
+type ScheduledTransfer struct {
+	ID                   int                     `json:"id"`
+	UserID               int                     `json:"user_id"`
+	AccountID            int                     `json:"account_id"`
+	DestinationAccountID *int                    `json:"destination_account_id,omitempty"`
+	TransactionType      TransactionType         `json:"transaction_type"`
+	Amount               Money                   `json:"amount" swaggertype:"number"`
+	Currency             Currency                `json:"currency"`
+	Description          string                  `json:"description,omitempty"`
+	Frequency            ScheduleFrequency       `json:"frequency"`
+	StartAt              time.Time               `json:"start_at"`
+	EndAt                *time.Time              `json:"end_at,omitempty"`
+	Status               ScheduledTransferStatus `json:"status"`
+	Occurrence           int                     `json:"occurrence"`
+	Attempts             int                     `json:"attempts"`
+	NextRunAt            *time.Time              `json:"next_run_at,omitempty"`
+	LastRunAt            *time.Time              `json:"last_run_at,omitempty"`
+	CreatedAt            time.Time               `json:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at"`
+}
+
+// ScheduledTransferRunStatus is the outcome of one attempt to run a
+// scheduled transfer
+type ScheduledTransferRunStatus string
+
+const (
+	RunSucceeded ScheduledTransferRunStatus = "succeeded"
+	RunFailed    ScheduledTransferRunStatus = "failed"
+)
+
+// ScheduledTransferRun records one attempt. TransactionID is the withdrawal
+// or the debit leg of the transfer when it succeeded; Error says why it
+// failed.
+type ScheduledTransferRun struct {
+	ID                  int                        `json:"id"`
+	ScheduledTransferID int                        `json:"scheduled_transfer_id"`
+	Occurrence          int                        `json:"occurrence"`
+	Attempt             int                        `json:"attempt"`
+	ScheduledFor        time.Time                  `json:"scheduled_for"`
+	Status              ScheduledTransferRunStatus `json:"status"`
+	TransactionID       *int                       `json:"transaction_id,omitempty"`
+	Error               string                     `json:"error,omitempty"`
+	CreatedAt           time.Time                  `json:"created_at"`
+}
+
+// CreateScheduledTransferRequest schedules a transfer, or a withdrawal when
+// DestinationAccountID is unset. Frequency defaults to once.
+type CreateScheduledTransferRequest struct {
+	AccountID            int               `json:"account_id"`
+	DestinationAccountID *int              `json:"destination_account_id,omitempty"`
+	Amount               Amount            `json:"amount" swaggertype:"number"`
+	Description          string            `json:"description"`
+	Frequency            ScheduleFrequency `json:"frequency"`
+	StartAt              time.Time         `json:"start_at"`
+	EndAt                *time.Time        `json:"end_at,omitempty"`
+}
+
+type ScheduledTransferActionRequest struct {
+	ScheduledTransferID int `json:"scheduled_transfer_id"`
+}
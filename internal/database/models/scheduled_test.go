@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleOccurrence(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		frequency ScheduleFrequency
+		n         int
+		want      time.Time
+	}{
+		{ScheduleOnce, 3, start},
+		{ScheduleDaily, 1, time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{ScheduleWeekly, 2, time.Date(2024, 2, 14, 9, 0, 0, 0, time.UTC)},
+		// The 31st falls back to the last day of shorter months...
+		{ScheduleMonthly, 1, time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC)},
+		{ScheduleMonthly, 3, time.Date(2024, 4, 30, 9, 0, 0, 0, time.UTC)},
+		// ...without drifting in the months after
+		{ScheduleMonthly, 4, time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC)},
+		{ScheduleMonthly, 12, time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.frequency.Occurrence(start, tt.n); !got.Equal(tt.want) {
+			t.Errorf("%s occurrence %d = %s; want %s", tt.frequency, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	// All revokes every session of the user instead of only the current one
+	All bool `json:"all"`
+}
+
+// UserTokenPurpose is what a token mailed to a user is good for
+type UserTokenPurpose string
+
+const (
+	TokenEmailVerification UserTokenPurpose = "email_verification"
+	TokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
@@ -28,8 +28,28 @@ type GenerateSOACustomRequest struct {
 	Currency string `json:"currency" default:"USD"`
 }
 
-
-
-
-
-
+// StatementJobStatus is where an asynchronous statement generation is
+type StatementJobStatus string
+
+const (
+	StatementJobQueued    StatementJobStatus = "queued"
+	StatementJobRunning   StatementJobStatus = "running"
+	StatementJobSucceeded StatementJobStatus = "succeeded"
+	StatementJobFailed    StatementJobStatus = "failed"
+)
+
+// StatementJob renders a statement in the background. StatementID is set once
+// it succeeded; download the statement with it.
+type StatementJob struct {
+	ID          int                      `json:"id"`
+	UserID      int                      `json:"user_id"`
+	Status      StatementJobStatus       `json:"status"`
+	Request     GenerateSOACustomRequest `json:"request"`
+	Attempts    int                      `json:"attempts"`
+	StatementID *int                     `json:"statement_id,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+	StartedAt   *time.Time               `json:"started_at,omitempty"`
+	FinishedAt  *time.Time               `json:"finished_at,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+}
@@ -5,18 +5,27 @@ import "time"
 type Transaction struct {
 	ID          int             `json:"id"`
 	AccountID   int             `json:"account_id"`
-	Amount      float64         `json:"amount"`
+	Amount      Money           `json:"amount" swaggertype:"number"`
+	Currency    Currency        `json:"currency"`
 	Type        TransactionType `json:"type"`
 	Status      TransactionStatus `json:"status"`
+	Direction   TransactionDirection `json:"direction"`
+	CounterpartyAccountID *int  `json:"counterparty_account_id,omitempty"`
+	Description string          `json:"description,omitempty"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 	ReferenceID string          `json:"reference_id"`
+	FXRate      *string         `json:"fx_rate,omitempty"`
+	FXSpread    *string         `json:"fx_spread,omitempty"`
+	FXQuoteID   *string         `json:"fx_quote_id,omitempty"`
+	ReversalOfID *int           `json:"reversal_of_id,omitempty"`
+	RefundedAmount Money        `json:"refunded_amount" swaggertype:"number"`
 }
 
 
 type TransactionDTO struct {
 	ID          int             `json:"id"`
-	Amount      float64         `json:"amount"`
+	Amount      Money           `json:"amount" swaggertype:"number"`
 	Type        TransactionType `json:"type"`
 	Status      TransactionStatus `json:"status"`
 	Description string          `json:"description"`
@@ -24,13 +33,28 @@ type TransactionDTO struct {
 }
 
 type CreateTransactionRequest struct {
-	Amount      float64         `json:"amount"`
+	Amount      Amount          `json:"amount" swaggertype:"number"`
 	AccountID   int             `json:"account_id"`
 }
 
 type CreateTransferRequest struct {
-	Amount      float64         `json:"amount"`
+	Amount      Amount          `json:"amount" swaggertype:"number"`
 	Description string          `json:"description"`
 	AccountID   int             `json:"account_id"`
 	DestinationAccountID int             `json:"destination_account_id"`
+	QuoteID     string          `json:"quote_id,omitempty"`
+}
+
+// ReverseTransactionRequest undoes a transaction. TransactionID is not needed
+// when the transaction is named in the path.
+type ReverseTransactionRequest struct {
+	TransactionID int    `json:"transaction_id,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// RefundTransactionRequest gives back part of a transaction. Amount is in the
+// currency of the transaction.
+type RefundTransactionRequest struct {
+	Amount Amount `json:"amount" swaggertype:"number"`
+	Reason string `json:"reason"`
 }
@@ -0,0 +1,34 @@
+package models
+
+// TwoFactorEnrollment is returned when a user starts enrolling an
+// authenticator. Show ProvisioningURI as a QR code, or let the user type in
+// Secret.
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorCodeRequest carries a code from the authenticator. Where noted, a
+// recovery code is accepted instead.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorRecoveryCodes are shown once, when two-factor is turned on. Each
+// can be used once in place of a code.
+type TwoFactorRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse is returned by login instead of a token pair when the
+// user has two-factor on. Exchange MFAToken and a code at /auth/2fa/verify.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type VerifyTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
@@ -19,8 +19,28 @@ type TransactionType string
 const (
     Deposit    TransactionType = "DEPOSIT"
     Withdrawal TransactionType = "WITHDRAWAL"
+    Transfer   TransactionType = "TRANSFER"
+    Reversal   TransactionType = "REVERSAL"
+    Refund     TransactionType = "REFUND"
 )
 
+// TransactionDirection tells whether a transaction row moved money out of
+// (DEBIT) or into (CREDIT) its account.
+type TransactionDirection string
+
+const (
+    Debit  TransactionDirection = "DEBIT"
+    Credit TransactionDirection = "CREDIT"
+)
+
+// Opposite returns the direction that undoes d
+func (d TransactionDirection) Opposite() TransactionDirection {
+    if d == Debit {
+        return Credit
+    }
+    return Debit
+}
+
 // Common response structure
 type Response struct {
     StatusCode int    `json:"status_code"`
@@ -35,8 +55,51 @@ const (
 	Pending TransactionStatus = "pending"
 	Completed TransactionStatus = "completed"
 	Failed TransactionStatus = "failed"
+	Reversed TransactionStatus = "reversed"
+	PartiallyRefunded TransactionStatus = "partially_refunded"
+)
+
+// Role decides which routes a user may call
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleCustomer || r == RoleSupport || r == RoleAdmin
+}
+
+// AccountStatus tells whether an account can move money
+type AccountStatus string
+
+const (
+	AccountActive  AccountStatus = "active"
+	AccountFrozen  AccountStatus = "frozen"
+	AccountDormant AccountStatus = "dormant"
+	AccountClosed  AccountStatus = "closed"
 )
 
+// accountTransitions lists the statuses each status may move to. Closed is
+// final.
+var accountTransitions = map[AccountStatus][]AccountStatus{
+	AccountActive:  {AccountFrozen, AccountDormant, AccountClosed},
+	AccountFrozen:  {AccountActive},
+	AccountDormant: {AccountActive, AccountFrozen, AccountClosed},
+}
+
+// CanTransitionTo tells whether an account may move from s to next
+func (s AccountStatus) CanTransitionTo(next AccountStatus) bool {
+	for _, allowed := range accountTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // Common pagination, filtering and sorting types
 type PaginationRequest struct {
     Page     int `json:"page"`
@@ -57,8 +120,8 @@ type SortRequest struct {
 
 // Filter types for different entities
 type AccountFilter struct {
-    MinBalance *float64        `json:"min_balance,omitempty"`
-    MaxBalance *float64        `json:"max_balance,omitempty"`
+    MinBalance *Amount         `json:"min_balance,omitempty" swaggertype:"number"`
+    MaxBalance *Amount         `json:"max_balance,omitempty" swaggertype:"number"`
     Currency   *Currency       `json:"currency,omitempty"`
     DateFrom   *time.Time      `json:"date_from,omitempty"`
     DateTo     *time.Time      `json:"date_to,omitempty"`
@@ -67,13 +130,18 @@ type AccountFilter struct {
 type TransactionFilter struct {
     Type          *TransactionType   `json:"type,omitempty"`
     Status        *TransactionStatus `json:"status,omitempty"`
-    MinAmount     *float64          `json:"min_amount,omitempty"`
-    MaxAmount     *float64          `json:"max_amount,omitempty"`
+    MinAmount     *Amount           `json:"min_amount,omitempty" swaggertype:"number"`
+    MaxAmount     *Amount           `json:"max_amount,omitempty" swaggertype:"number"`
     DateFrom      *time.Time        `json:"date_from,omitempty"`
     DateTo        *time.Time        `json:"date_to,omitempty"`
     AccountID     *int              `json:"account_id,omitempty"`
 }
 
+type StatementFilter struct {
+    DateFrom      *time.Time        `json:"date_from,omitempty"`
+    DateTo        *time.Time        `json:"date_to,omitempty"`
+}
+
 type UserFilter struct {
     Email         *string    `json:"email,omitempty"`
     Status        *string    `json:"status,omitempty"`
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestAccountStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from AccountStatus
+		to   AccountStatus
+		want bool
+	}{
+		{AccountActive, AccountFrozen, true},
+		{AccountActive, AccountDormant, true},
+		{AccountActive, AccountClosed, true},
+		{AccountActive, AccountActive, false},
+		{AccountFrozen, AccountActive, true},
+		{AccountFrozen, AccountClosed, false},
+		{AccountDormant, AccountActive, true},
+		{AccountDormant, AccountClosed, true},
+		{AccountClosed, AccountActive, false},
+		{AccountClosed, AccountFrozen, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: got %v; want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
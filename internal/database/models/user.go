@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID        int       `json:"id"`
@@ -8,6 +11,7 @@ type User struct {
 	LastName  string    `json:"last_name"`
 	Email     string    `json:"email"`
 	Password  string    `json:"password"`
+	Role      Role      `json:"role,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Accounts []AccountMinimal `json:"accounts"`
@@ -15,14 +19,27 @@ type User struct {
 
 type AccountMinimal struct {
 	ID       int     `json:"id"`
-	Balance  float64 `json:"balance"`
+	Balance  Money   `json:"balance" swaggertype:"number"`
 	Currency string  `json:"currency"`
 }
 
+// UnmarshalJSON reads the json_build_object rows produced by the user
+// queries, binding the balance to the account's currency.
+func (a *AccountMinimal) UnmarshalJSON(data []byte) error {
+	id, balance, currency, err := decodeAccountBalance(data)
+	if err != nil {
+		return err
+	}
+	*a = AccountMinimal{ID: id, Balance: balance, Currency: currency}
+	return nil
+}
+
 type UserDTO struct {
+	ID        int       `json:"id"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
 	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -48,16 +65,55 @@ type UpdateUserRequest struct {
 type UpdateUserPasswordRequest struct {
 	NewPassword string `json:"new_password"`
 	OldPassword string `json:"old_password"`
+	// TOTPCode is required when the user has two-factor on
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+type UpdateUserRoleRequest struct {
+	UserID int  `json:"user_id"`
+	Role   Role `json:"role"`
+}
+
+type UnlockUserRequest struct {
+	UserID int `json:"user_id"`
 }
 
 type ViewBalanceResponse struct {
 	Accounts           []AccountBalance    `json:"accounts"`
-	BalancesByCurrency map[string]float64 `json:"balances_by_currency"`
+	BalancesByCurrency map[string]Money   `json:"balances_by_currency" swaggertype:"object,number"`
+	Total              *ConvertedTotal    `json:"total,omitempty"`
 }
 
 type AccountBalance struct {
 	ID       int     `json:"id"`
-	Balance  float64 `json:"balance"`
+	Balance  Money   `json:"balance" swaggertype:"number"`
 	Currency string  `json:"currency"`
 }
 
+func (a *AccountBalance) UnmarshalJSON(data []byte) error {
+	id, balance, currency, err := decodeAccountBalance(data)
+	if err != nil {
+		return err
+	}
+	*a = AccountBalance{ID: id, Balance: balance, Currency: currency}
+	return nil
+}
+
+func decodeAccountBalance(data []byte) (int, Money, string, error) {
+	var raw struct {
+		ID       int         `json:"id"`
+		Balance  json.Number `json:"balance"`
+		Currency string      `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, Money{}, "", err
+	}
+
+	balance, err := ParseMoney(raw.Balance.String(), Currency(raw.Currency))
+	if err != nil {
+		return 0, Money{}, "", err
+	}
+
+	return raw.ID, balance, raw.Currency, nil
+}
+
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEventType names an event that webhook endpoints can subscribe to
+type WebhookEventType string
+
+const (
+	EventTransactionCompleted WebhookEventType = "transaction.completed"
+	EventTransactionFailed    WebhookEventType = "transaction.failed"
+	EventAccountCreated       WebhookEventType = "account.created"
+	EventStatementGenerated   WebhookEventType = "statement.generated"
+)
+
+// WebhookEventTypes lists every event type, in the order they are documented
+var WebhookEventTypes = []WebhookEventType{
+	EventTransactionCompleted,
+	EventTransactionFailed,
+	EventAccountCreated,
+	EventStatementGenerated,
+}
+
+func (t WebhookEventType) Valid() bool {
+	for _, eventType := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpoint receives the owner's events of EventTypes, or of every type
+// when EventTypes is empty. Secret is only returned when the endpoint is
+// created.
+type WebhookEndpoint struct {
+	ID          int                `json:"id"`
+	URL         string             `json:"url"`
+	EventTypes  []WebhookEventType `json:"event_types"`
+	Description string             `json:"description,omitempty"`
+	Secret      string             `json:"secret,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// WebhookEvent is the JSON body POSTed to an endpoint. Data is the resource
+// the event is about, e.g. the transaction for transaction.completed.
+type WebhookEvent struct {
+	ID        int64            `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      json.RawMessage  `json:"data" swaggertype:"object"`
+}
+
+// WebhookDeliveryStatus is where the delivery of one event to one endpoint
+// is. A delivery is dead once every attempt has failed.
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	EventID        int64                 `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	EndpointID     int                   `json:"endpoint_id"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int                  `json:"response_status,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+type WebhookDeliveryFilter struct {
+	EndpointID *int                   `json:"endpoint_id,omitempty"`
+	Status     *WebhookDeliveryStatus `json:"status,omitempty"`
+}
+
+// CreateWebhookEndpointRequest registers an endpoint. Leave EventTypes empty
+// to receive every event type.
+type CreateWebhookEndpointRequest struct {
+	URL         string             `json:"url"`
+	EventTypes  []WebhookEventType `json:"event_types"`
+	Description string             `json:"description"`
+}
+
+type ReplayWebhookDeliveryRequest struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
@@ -8,18 +8,22 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 )
 
 type AccountRepository interface {
-	CreateAccount(account models.CreateAccountRequest, userID int) (int, error)
-	GetAccount(id int) (models.Account, error)
+	CreateAccount(account models.CreateAccountRequest, userID int, actor models.AuditActor) (int, error)
+	GetAccount(id int, userID int) (models.Account, error)
 	GetAccounts(
 		userID int, 
 		filter *models.AccountFilter, 
 		sort *models.SortRequest, 
 		pagination *models.PaginationRequest,
 	) (*models.PaginatedResponse[models.Account], error)
-	DeleteAccount(id int) error
+	CloseAccount(request models.CloseAccountRequest, userID int, actor models.AuditActor) (models.Account, error)
+	GetAccountByID(id int) (models.Account, error)
+	SetAccountStatus(id int, status models.AccountStatus, reason string, actor models.AuditActor) (models.Account, error)
+	MarkDormantAccounts(inactiveSince time.Time) (int64, error)
 }
 
 
@@ -31,7 +35,7 @@ func NewAccountRepository(db database.Service) AccountRepository {
 	return &accountRepository{db: db}
 }
 
-func (r *accountRepository) CreateAccount(account models.CreateAccountRequest, userID int) (int, error) {
+func (r *accountRepository) CreateAccount(account models.CreateAccountRequest, userID int, actor models.AuditActor) (int, error) {
 	var accountID int
 	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
 		// First verify the user exists
@@ -54,7 +58,7 @@ func (r *accountRepository) CreateAccount(account models.CreateAccountRequest, u
 		err = tx.QueryRow(
 			query,
 			userID,           // Make sure we use the parameter userID, not the local variable
-			0.0,             // Initial balance as decimal
+			models.NewMoney(0, account.Currency), // Initial balance as decimal
 			account.Currency,
 			account.AccountName,
 			account.AccountDescription,
@@ -63,7 +67,21 @@ func (r *accountRepository) CreateAccount(account models.CreateAccountRequest, u
 		if err != nil {
 			return fmt.Errorf("failed to create account (userID=%d): %w", userID, err)
 		}
-		return nil
+
+		var created models.Account
+		if err := scanAccount(tx.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = $1`, accountID), &created); err != nil {
+			return fmt.Errorf("failed to read account %d: %w", accountID, err)
+		}
+		if err := enqueueWebhookEvent(tx, userID, models.EventAccountCreated, created); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, models.AuditAccountCreate, "account", accountID, nil, map[string]interface{}{
+			"user_id":             userID,
+			"currency":            account.Currency,
+			"account_name":        account.AccountName,
+			"account_description": account.AccountDescription,
+		})
 	}, &sql.TxOptions{
 		Isolation: sql.LevelSerializable, // Highest isolation level to ensure consistency
 	})
@@ -75,9 +93,69 @@ func (r *accountRepository) CreateAccount(account models.CreateAccountRequest, u
 	return accountID, nil
 }
 
-const accountColumns = `id, user_id, balance, currency, account_name, account_description, created_at, updated_at`
+// accountColumns reads the available balance in the same statement as the
+// account. Code that holds the account lock and needs an up-to-date figure
+// calls heldAmount instead, since this subquery sees the statement snapshot.
+const accountColumns = `id, user_id, balance, balance - ` + heldAmountSubquery + `, currency, account_name, account_description, status, tier, closed_at, created_at, updated_at`
+
+func scanAccount(row rowScanner, account *models.Account) error {
+	var balance, available string
+	var closedAt sql.NullTime
+	if err := row.Scan(
+		&account.ID,
+		&account.UserID,
+		&balance,
+		&available,
+		&account.Currency,
+		&account.AccountName,
+		&account.AccountDescription,
+		&account.Status,
+		&account.Tier,
+		&closedAt,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if closedAt.Valid {
+		account.ClosedAt = &closedAt.Time
+	}
+
+	var err error
+	if account.Balance, err = models.ParseMoney(balance, account.Currency); err != nil {
+		return err
+	}
+	account.LedgerBalance = account.Balance
+	account.AvailableBalance, err = models.ParseMoney(available, account.Currency)
+	return err
+}
+
+// GetAccount returns the account only if it belongs to userID.
+func (r *accountRepository) GetAccount(id int, userID int) (models.Account, error) {
+	var account models.Account
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		query := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id = $1 AND user_id = $2`
+
+		return scanAccount(tx.QueryRow(query, id, userID), &account)
+	})
+
+	if err == sql.ErrNoRows {
+		return models.Account{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	return account, nil
+}
 
-func (r *accountRepository) GetAccount(id int) (models.Account, error) {
+// GetAccountByID returns any account regardless of its owner. It is meant
+// for staff routes; customer routes must use GetAccount.
+func (r *accountRepository) GetAccountByID(id int) (models.Account, error) {
 	var account models.Account
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
 		query := `
@@ -85,16 +163,53 @@ func (r *accountRepository) GetAccount(id int) (models.Account, error) {
 		FROM accounts
 		WHERE id = $1`
 
-		return tx.QueryRow(query, id).Scan(
-			&account.ID,
-			&account.UserID,
-			&account.Balance,
-			&account.Currency,
-			&account.AccountName,
-			&account.AccountDescription,
-			&account.CreatedAt,
-			&account.UpdatedAt,
+		return scanAccount(tx.QueryRow(query, id), &account)
+	})
+
+	if err == sql.ErrNoRows {
+		return models.Account{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	return account, nil
+}
+
+// SetAccountStatus moves an account into status if the transition is
+// allowed. Accounts can only be closed through CloseAccount, which checks the
+// balance.
+func (r *accountRepository) SetAccountStatus(id int, status models.AccountStatus, reason string, actor models.AuditActor) (models.Account, error) {
+	var account models.Account
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var current models.AccountStatus
+		err := tx.QueryRow(`SELECT status FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		if status == models.AccountClosed || !current.CanTransitionTo(status) {
+			return ErrInvalidStatusTransition
+		}
+
+		query := `
+		UPDATE accounts
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + accountColumns
+
+		if err := scanAccount(tx.QueryRow(query, id, status), &account); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, models.AuditAccountStatus, "account", id,
+			map[string]interface{}{"status": current},
+			map[string]interface{}{"status": status, "reason": reason},
 		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
 	})
 
 	if err != nil {
@@ -104,6 +219,82 @@ func (r *accountRepository) GetAccount(id int) (models.Account, error) {
 	return account, nil
 }
 
+// MarkDormantAccounts moves active accounts without a transaction since
+// inactiveSince to dormant and returns how many it moved.
+func (r *accountRepository) MarkDormantAccounts(inactiveSince time.Time) (int64, error) {
+	var marked int64
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			UPDATE accounts
+			SET status = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE status = $2
+				AND created_at < $3
+				AND NOT EXISTS (
+					SELECT 1 FROM transactions
+					WHERE transactions.account_id = accounts.id AND transactions.created_at >= $3
+				)
+			RETURNING id
+		`, models.AccountDormant, models.AccountActive, inactiveSince)
+		if err != nil {
+			return fmt.Errorf("failed to mark dormant accounts: %w", err)
+		}
+
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan dormant account: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating dormant accounts: %w", err)
+		}
+
+		for _, id := range ids {
+			err := recordAudit(tx, models.SystemActor, models.AuditAccountStatus, "account", id,
+				map[string]interface{}{"status": models.AccountActive},
+				map[string]interface{}{"status": models.AccountDormant, "reason": "no activity since " + inactiveSince.Format(time.RFC3339)},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		marked = int64(len(ids))
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return marked, err
+}
+
+// checkCanSend reports why an account cannot send money, if it cannot.
+func checkCanSend(status models.AccountStatus) error {
+	switch status {
+	case models.AccountActive:
+		return nil
+	case models.AccountFrozen:
+		return ErrAccountFrozen
+	case models.AccountDormant:
+		return ErrAccountDormant
+	default:
+		return ErrAccountClosed
+	}
+}
+
+// checkCanReceive reports why an account cannot receive money, if it cannot.
+// Frozen and dormant accounts can still be paid into.
+func checkCanReceive(status models.AccountStatus) error {
+	if status == models.AccountClosed {
+		return ErrAccountClosed
+	}
+	return nil
+}
+
 func (r *accountRepository) GetAccounts(
 	userID int, 
 	filter *models.AccountFilter, 
@@ -152,16 +343,7 @@ func (r *accountRepository) GetAccounts(
 	accounts := make([]models.Account, 0, pagination.PageSize)
 	for rows.Next() {
 		var account models.Account
-		if err := rows.Scan(
-			&account.ID,
-			&account.UserID,
-			&account.Balance,
-			&account.Currency,
-			&account.AccountName,
-			&account.AccountDescription,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		); err != nil {
+		if err := scanAccount(rows, &account); err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, account)
@@ -198,24 +380,22 @@ func buildAccountFilterClause(filter *models.AccountFilter, startParam int) (str
 	if filter.MinBalance != nil {
 		paramCount++
 		conditions = append(conditions, fmt.Sprintf("balance >= $%d", paramCount))
-		args = append(args, *filter.MinBalance)
+		args = append(args, filter.MinBalance.String())
 	}
 
 	if filter.MaxBalance != nil {
 		paramCount++
 		conditions = append(conditions, fmt.Sprintf("balance <= $%d", paramCount))
-		args = append(args, *filter.MaxBalance)
+		args = append(args, filter.MaxBalance.String())
 	}
 
 	if filter.Currency != nil {
-		switch *filter.Currency {
-		case models.USD, models.EUR, models.GBP:
-			paramCount++
-			conditions = append(conditions, fmt.Sprintf("currency = $%d", paramCount))
-			args = append(args, string(*filter.Currency))
-		default:
-			return "", nil, fmt.Errorf("invalid currency: %s", *filter.Currency)
+		if _, err := filter.Currency.Info(); err != nil {
+			return "", nil, err
 		}
+		paramCount++
+		conditions = append(conditions, fmt.Sprintf("currency = $%d", paramCount))
+		args = append(args, string(*filter.Currency))
 	}
 
 	if filter.DateFrom != nil {
@@ -239,7 +419,7 @@ func buildAccountFilterClause(filter *models.AccountFilter, startParam int) (str
 
 func buildSortClause(sort *models.SortRequest, allowedFields []string) string {
 	if sort == nil || sort.Field == "" {
-		return " ORDER BY created_at DESC"
+		return " ORDER BY created_at DESC, id DESC"
 	}
 
 	// Check if the sort field is allowed
@@ -252,7 +432,7 @@ func buildSortClause(sort *models.SortRequest, allowedFields []string) string {
 	}
 
 	if !isAllowed {
-		return " ORDER BY created_at DESC"
+		return " ORDER BY created_at DESC, id DESC"
 	}
 
 	direction := "ASC"
@@ -260,32 +440,110 @@ func buildSortClause(sort *models.SortRequest, allowedFields []string) string {
 		direction = "DESC"
 	}
 
-	return fmt.Sprintf(" ORDER BY %s %s", sort.Field, direction)
+	// id breaks ties so that pages never overlap or skip rows
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", sort.Field, direction, direction)
 }
 
-func (r *accountRepository) DeleteAccount(id int) error {
-	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
-		query := `
-		DELETE FROM accounts
-		WHERE id = $1
-		`
+// CloseAccount closes one of userID's accounts. A remaining balance is
+// swept into request.SweepToAccountID first, in the same transaction; without
+// one the account must be empty. The account and its history stay readable.
+func (r *accountRepository) CloseAccount(request models.CloseAccountRequest, userID int, actor models.AuditActor) (models.Account, error) {
+	var account models.Account
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		lockQuery := `
+		SELECT ` + accountColumns + `
+		FROM accounts
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`
 
-		result, err := tx.Exec(query, id)
-		if err != nil {
-			return fmt.Errorf("failed to delete account: %w", err)
+		if request.SweepToAccountID != nil && *request.SweepToAccountID == request.AccountID {
+			return fmt.Errorf("cannot sweep an account into itself")
 		}
 
-		rows, err := result.RowsAffected()
+		// Lock both accounts in ascending ID order, like Transfer does
+		var sweepTo models.Account
+		if request.SweepToAccountID != nil && *request.SweepToAccountID < request.AccountID {
+			if err := scanAccount(tx.QueryRow(lockQuery, *request.SweepToAccountID, userID), &sweepTo); err != nil {
+				return err
+			}
+		}
+		if err := scanAccount(tx.QueryRow(lockQuery, request.AccountID, userID), &account); err != nil {
+			return err
+		}
+		if request.SweepToAccountID != nil && *request.SweepToAccountID > request.AccountID {
+			if err := scanAccount(tx.QueryRow(lockQuery, *request.SweepToAccountID, userID), &sweepTo); err != nil {
+				return err
+			}
+		}
+
+		if !account.Status.CanTransitionTo(models.AccountClosed) {
+			return ErrInvalidStatusTransition
+		}
+		held, err := heldAmount(tx, account.ID, account.Currency)
 		if err != nil {
-			return fmt.Errorf("failed to get affected rows: %w", err)
+			return err
 		}
-		if rows == 0 {
-			return fmt.Errorf("account not found")
+		if !held.IsZero() {
+			return ErrAccountHasHolds
+		}
+		before := map[string]interface{}{"status": account.Status, "balance": account.Balance}
+		after := map[string]interface{}{"status": models.AccountClosed}
+
+		if account.Balance.IsPositive() {
+			if request.SweepToAccountID == nil {
+				return ErrAccountNotEmpty
+			}
+			if sweepTo.Currency != account.Currency {
+				return ErrCurrencyMismatch
+			}
+			if err := checkCanReceive(sweepTo.Status); err != nil {
+				return err
+			}
+
+			_, _, referenceID, err := bookTransfer(tx, transferBooking{
+				sourceAccountID:      account.ID,
+				sourceUserID:         userID,
+				destinationAccountID: sweepTo.ID,
+				destinationUserID:    userID,
+				debit:                account.Balance,
+				credit:               account.Balance,
+				description:          fmt.Sprintf("Balance of closed account %d", account.ID),
+			})
+			if err != nil {
+				return err
+			}
+			account.Balance = models.NewMoney(0, account.Currency)
+			account.LedgerBalance, account.AvailableBalance = account.Balance, account.Balance
+			after["swept_to_account_id"] = sweepTo.ID
+			after["sweep_reference_id"] = referenceID
 		}
 
-		return nil
+		var closedAt time.Time
+		query := `
+		UPDATE accounts
+		SET status = $2, closed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING closed_at
+		`
+		if err := tx.QueryRow(query, account.ID, models.AccountClosed).Scan(&closedAt); err != nil {
+			return fmt.Errorf("failed to close account: %w", err)
+		}
+
+		account.Status = models.AccountClosed
+		account.ClosedAt = &closedAt
+		account.UpdatedAt = closedAt
+
+		return recordAudit(tx, actor, models.AuditAccountClose, "account", account.ID, before, after)
 	}, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
-}
 
+	if err == sql.ErrNoRows {
+		return models.Account{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	return account, nil
+}
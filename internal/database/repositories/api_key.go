@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// APIKeyRepository stores API keys. Callers pass the SHA-256 hash of a key,
+// never the key itself. Times are written in UTC, as for failed logins.
+type APIKeyRepository interface {
+	CreateAPIKey(request models.CreateAPIKeyRequest, prefix string, keyHash string, userID int, actor models.AuditActor) (models.APIKey, error)
+	GetAPIKeys(userID int) ([]models.APIKey, error)
+	RevokeAPIKey(keyID int, userID int, actor models.AuditActor) (models.APIKey, error)
+	RevokeAnyAPIKey(keyID int, actor models.AuditActor) (models.APIKey, error)
+	Authenticate(prefix string, keyHash string) (models.APIKeyPrincipal, error)
+	GetActivePrincipal(prefix string) (models.APIKeyPrincipal, error)
+}
+
+type apiKeyRepository struct {
+	db database.Service
+}
+
+func NewAPIKeyRepository(db database.Service) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes::text, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner, key *models.APIKey) error {
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt); err != nil {
+		return err
+	}
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return json.Unmarshal([]byte(scopes), &key.Scopes)
+}
+
+func (r *apiKeyRepository) CreateAPIKey(request models.CreateAPIKeyRequest, prefix string, keyHash string, userID int, actor models.AuditActor) (models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		encoded, err := json.Marshal(request.Scopes)
+		if err != nil {
+			return fmt.Errorf("failed to encode scopes: %w", err)
+		}
+
+		var expiresAt *time.Time
+		if request.ExpiresAt != nil {
+			utc := request.ExpiresAt.UTC()
+			expiresAt = &utc
+		}
+
+		err = scanAPIKey(tx.QueryRow(`
+			INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+apiKeyColumns,
+			userID, request.Name, prefix, keyHash, string(encoded), expiresAt,
+		), &key)
+		if err != nil {
+			return fmt.Errorf("failed to create API key: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditAPIKeyCreate, "api_key", key.ID, nil, key)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return key, err
+}
+
+// GetAPIKeys lists userID's keys, revoked and expired ones included.
+func (r *apiKeyRepository) GetAPIKeys(userID int) ([]models.APIKey, error) {
+	rows, err := r.db.Query(context.Background(), `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes one of userID's keys. Revoking a key again is a
+// no-op.
+func (r *apiKeyRepository) RevokeAPIKey(keyID int, userID int, actor models.AuditActor) (models.APIKey, error) {
+	return r.revokeAPIKey(`id = $1 AND user_id = $2`, actor, keyID, userID)
+}
+
+// RevokeAnyAPIKey revokes a key whoever owns it.
+func (r *apiKeyRepository) RevokeAnyAPIKey(keyID int, actor models.AuditActor) (models.APIKey, error) {
+	return r.revokeAPIKey(`id = $1`, actor, keyID)
+}
+
+func (r *apiKeyRepository) revokeAPIKey(condition string, actor models.AuditActor, args ...interface{}) (models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		err := scanAPIKey(tx.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE `+condition+` FOR UPDATE`, args...), &key)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock API key: %w", err)
+		}
+		if key.RevokedAt != nil {
+			return nil
+		}
+
+		before := key
+		err = scanAPIKey(tx.QueryRow(`
+			UPDATE api_keys SET revoked_at = $2 WHERE id = $1
+			RETURNING `+apiKeyColumns,
+			key.ID, time.Now().UTC(),
+		), &key)
+		if err != nil {
+			return fmt.Errorf("failed to revoke API key: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditAPIKeyRevoke, "api_key", key.ID, before, key)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return key, err
+}
+
+// Authenticate checks a key presented on a request and records its use.
+func (r *apiKeyRepository) Authenticate(prefix string, keyHash string) (models.APIKeyPrincipal, error) {
+	principal, storedHash, err := r.activePrincipal(prefix)
+	if err != nil {
+		return models.APIKeyPrincipal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(storedHash), []byte(keyHash)) != 1 {
+		return models.APIKeyPrincipal{}, ErrInvalidAPIKey
+	}
+
+	_, err = r.db.Exec(context.Background(), `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, principal.KeyID, time.Now().UTC())
+	if err != nil {
+		return models.APIKeyPrincipal{}, fmt.Errorf("failed to record API key use: %w", err)
+	}
+
+	return principal, nil
+}
+
+// GetActivePrincipal returns who the key with prefix acts for, as long as
+// it is neither revoked nor expired. It backs tokens issued to the key.
+func (r *apiKeyRepository) GetActivePrincipal(prefix string) (models.APIKeyPrincipal, error) {
+	principal, _, err := r.activePrincipal(prefix)
+	return principal, err
+}
+
+// activePrincipal reads an unrevoked, unexpired key with the owner's
+// current role.
+func (r *apiKeyRepository) activePrincipal(prefix string) (models.APIKeyPrincipal, string, error) {
+	var principal models.APIKeyPrincipal
+	var keyHash, scopes string
+	err := r.db.QueryRow(context.Background(), `
+		SELECT api_keys.id, api_keys.prefix, api_keys.user_id, users.role, api_keys.key_hash, api_keys.scopes::text
+		FROM api_keys
+		JOIN users ON users.id = api_keys.user_id
+		WHERE api_keys.prefix = $1
+			AND api_keys.revoked_at IS NULL
+			AND (api_keys.expires_at IS NULL OR api_keys.expires_at > $2)
+	`, prefix, time.Now().UTC()).Scan(&principal.KeyID, &principal.Prefix, &principal.UserID, &principal.Role, &keyHash, &scopes)
+	if err == sql.ErrNoRows {
+		return models.APIKeyPrincipal{}, "", ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKeyPrincipal{}, "", fmt.Errorf("failed to read API key: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &principal.Scopes); err != nil {
+		return models.APIKeyPrincipal{}, "", fmt.Errorf("failed to decode API key scopes: %w", err)
+	}
+	return principal, keyHash, nil
+}
@@ -0,0 +1,304 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditChainLock is the advisory lock key that serialises writers of the
+// audit hash chain, so no two events ever claim the same predecessor.
+const auditChainLock = 0x61756469
+
+// auditGenesisHash is the predecessor of the first audit event.
+var auditGenesisHash = strings.Repeat("0", 64)
+
+type AuditRepository interface {
+	ListEvents(filter *models.AuditFilter, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.AuditEvent], error)
+	VerifyChain() (models.AuditVerification, error)
+}
+
+type auditRepository struct {
+	db database.Service
+}
+
+func NewAuditRepository(db database.Service) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// recordAudit appends an event to the audit log. It must run in the
+// transaction that makes the change, so the event commits or rolls back with
+// it. Call it last: it holds the chain lock until the transaction ends.
+func recordAudit(tx *sql.Tx, actor models.AuditActor, action string, resourceType string, resourceID interface{}, before interface{}, after interface{}) error {
+	event := models.AuditEvent{
+		OccurredAt:   time.Now().UTC().Truncate(time.Microsecond),
+		ActorUserID:  actor.UserID,
+		ActorRole:    actor.Role,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   fmt.Sprint(resourceID),
+		IP:           actor.IP,
+		UserAgent:    actor.UserAgent,
+		RequestID:    actor.RequestID,
+	}
+
+	var err error
+	if event.Before, err = marshalAuditValue(before); err != nil {
+		return err
+	}
+	if event.After, err = marshalAuditValue(after); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	err = tx.QueryRow(`SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&event.PrevHash)
+	if err == sql.ErrNoRows {
+		event.PrevHash = auditGenesisHash
+	} else if err != nil {
+		return fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+	event.Hash = auditHash(event)
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_events (occurred_at, actor_user_id, actor_role, action, resource_type, resource_id, before_value, after_value, ip, user_agent, request_id, prev_hash, hash)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12, $13)
+	`,
+		event.OccurredAt,
+		event.ActorUserID,
+		event.ActorRole,
+		event.Action,
+		event.ResourceType,
+		event.ResourceID,
+		nullableJSON(event.Before),
+		nullableJSON(event.After),
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+		event.PrevHash,
+		event.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+func marshalAuditValue(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit value: %w", err)
+	}
+	return data, nil
+}
+
+func nullableJSON(value json.RawMessage) interface{} {
+	if value == nil {
+		return nil
+	}
+	return string(value)
+}
+
+// auditHash is the SHA-256 of the event's fields and its predecessor's hash.
+// Every field is length-prefixed so that no two events hash the same input.
+func auditHash(event models.AuditEvent) string {
+	actorUserID := ""
+	if event.ActorUserID != nil {
+		actorUserID = strconv.Itoa(*event.ActorUserID)
+	}
+
+	h := sha256.New()
+	for _, field := range []string{
+		event.PrevHash,
+		event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		actorUserID,
+		string(event.ActorRole),
+		event.Action,
+		event.ResourceType,
+		event.ResourceID,
+		string(event.Before),
+		string(event.After),
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+	} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const auditEventColumns = `id, occurred_at, actor_user_id, COALESCE(actor_role, ''), action, resource_type, resource_id, before_value::text, after_value::text, COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(request_id, ''), prev_hash, hash`
+
+func scanAuditEvent(row rowScanner, event *models.AuditEvent) error {
+	var actorUserID sql.NullInt64
+	var before, after sql.NullString
+	if err := row.Scan(
+		&event.ID,
+		&event.OccurredAt,
+		&actorUserID,
+		&event.ActorRole,
+		&event.Action,
+		&event.ResourceType,
+		&event.ResourceID,
+		&before,
+		&after,
+		&event.IP,
+		&event.UserAgent,
+		&event.RequestID,
+		&event.PrevHash,
+		&event.Hash,
+	); err != nil {
+		return err
+	}
+
+	if actorUserID.Valid {
+		id := int(actorUserID.Int64)
+		event.ActorUserID = &id
+	}
+	if before.Valid {
+		event.Before = json.RawMessage(before.String)
+	}
+	if after.Valid {
+		event.After = json.RawMessage(after.String)
+	}
+	return nil
+}
+
+func buildAuditFilterClause(filter *models.AuditFilter) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorUserID != nil {
+		add("actor_user_id = $%d", *filter.ActorUserID)
+	}
+	if filter.Action != nil {
+		add("action = $%d", *filter.Action)
+	}
+	if filter.ResourceType != nil {
+		add("resource_type = $%d", *filter.ResourceType)
+	}
+	if filter.ResourceID != nil {
+		add("resource_id = $%d", *filter.ResourceID)
+	}
+	if filter.RequestID != nil {
+		add("request_id = $%d", *filter.RequestID)
+	}
+	if filter.DateFrom != nil {
+		add("occurred_at >= $%d", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		add("occurred_at <= $%d", *filter.DateTo)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// ListEvents returns matching events, newest first.
+func (r *auditRepository) ListEvents(filter *models.AuditFilter, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.AuditEvent], error) {
+	whereClause, args := buildAuditFilterClause(filter)
+	baseQuery := `FROM audit_events` + whereClause
+
+	var response *models.PaginatedResponse[models.AuditEvent]
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		var totalRecords int64
+		if err := tx.QueryRow("SELECT COUNT(*) "+baseQuery, args...).Scan(&totalRecords); err != nil {
+			return fmt.Errorf("failed to count audit events: %w", err)
+		}
+
+		offset := (pagination.Page - 1) * pagination.PageSize
+		query := "SELECT " + auditEventColumns + " " + baseQuery +
+			fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		rows, err := tx.Query(query, append(args, pagination.PageSize, offset)...)
+		if err != nil {
+			return fmt.Errorf("failed to query audit events: %w", err)
+		}
+		defer rows.Close()
+
+		events := make([]models.AuditEvent, 0, pagination.PageSize)
+		for rows.Next() {
+			var event models.AuditEvent
+			if err := scanAuditEvent(rows, &event); err != nil {
+				return fmt.Errorf("failed to scan audit event: %w", err)
+			}
+			events = append(events, event)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating audit events: %w", err)
+		}
+
+		response = &models.PaginatedResponse[models.AuditEvent]{
+			Data: events,
+			Pagination: models.PaginationResponse{
+				CurrentPage:  pagination.Page,
+				PageSize:     pagination.PageSize,
+				TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pagination.PageSize))),
+				TotalRecords: totalRecords,
+			},
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// VerifyChain re-computes every hash in id order and reports the first event
+// that was altered, or whose predecessor was altered or removed.
+func (r *auditRepository) VerifyChain() (models.AuditVerification, error) {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+auditEventColumns+` FROM audit_events ORDER BY id`)
+	if err != nil {
+		return models.AuditVerification{}, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	verification := models.AuditVerification{Valid: true}
+	prevHash := auditGenesisHash
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := scanAuditEvent(rows, &event); err != nil {
+			return models.AuditVerification{}, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		verification.Checked++
+
+		if event.PrevHash != prevHash || auditHash(event) != event.Hash {
+			verification.Valid = false
+			verification.BrokenAt = &event.ID
+			return verification, nil
+		}
+		prevHash = event.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return models.AuditVerification{}, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return verification, nil
+}
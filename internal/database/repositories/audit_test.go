@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAuditHash(t *testing.T) {
+	userID := 7
+	event := models.AuditEvent{
+		OccurredAt:   time.Date(2024, 3, 1, 12, 0, 0, 123456000, time.UTC),
+		ActorUserID:  &userID,
+		ActorRole:    models.RoleAdmin,
+		Action:       models.AuditAccountStatus,
+		ResourceType: "account",
+		ResourceID:   "10",
+		Before:       json.RawMessage(`{"status":"active"}`),
+		After:        json.RawMessage(`{"status":"frozen"}`),
+		IP:           "203.0.113.5",
+		RequestID:    "req-1",
+		PrevHash:     auditGenesisHash,
+	}
+
+	hash := auditHash(event)
+	if len(hash) != 64 {
+		t.Fatalf("expected a 64 character hex hash; got %q", hash)
+	}
+	if auditHash(event) != hash {
+		t.Fatal("hash is not deterministic")
+	}
+
+	// The same instant in another zone is the same event
+	local := event
+	local.OccurredAt = event.OccurredAt.In(time.FixedZone("UTC+2", 2*60*60))
+	if auditHash(local) != hash {
+		t.Error("hash depends on the time zone of occurred_at")
+	}
+
+	changes := map[string]func(e *models.AuditEvent){
+		"prev hash": func(e *models.AuditEvent) { e.PrevHash = hash },
+		"actor":     func(e *models.AuditEvent) { e.ActorUserID = nil },
+		"after":     func(e *models.AuditEvent) { e.After = json.RawMessage(`{"status":"closed"}`) },
+		"time":      func(e *models.AuditEvent) { e.OccurredAt = e.OccurredAt.Add(time.Microsecond) },
+		// Moving bytes between adjacent fields must not collide
+		"boundary": func(e *models.AuditEvent) { e.ResourceType, e.ResourceID = "account1", "0" },
+	}
+	for name, change := range changes {
+		changed := event
+		change(&changed)
+		if auditHash(changed) == hash {
+			t.Errorf("changing the %s did not change the hash", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type CurrencyRepository interface {
+	ListCurrencies() ([]models.CurrencyInfo, error)
+	SetCurrencyEnabled(code models.Currency, enabled bool, actor models.AuditActor) (models.CurrencyInfo, error)
+}
+
+type currencyRepository struct {
+	db database.Service
+}
+
+func NewCurrencyRepository(db database.Service) CurrencyRepository {
+	return &currencyRepository{db: db}
+}
+
+const currencyColumns = `code, name, minor_units, symbol, enabled, updated_at`
+
+func scanCurrency(row rowScanner, c *models.CurrencyInfo) error {
+	return row.Scan(&c.Code, &c.Name, &c.MinorUnits, &c.Symbol, &c.Enabled, &c.UpdatedAt)
+}
+
+func (r *currencyRepository) ListCurrencies() ([]models.CurrencyInfo, error) {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+currencyColumns+` FROM currencies ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query currencies: %w", err)
+	}
+	defer rows.Close()
+
+	currencies := make([]models.CurrencyInfo, 0)
+	for rows.Next() {
+		var c models.CurrencyInfo
+		if err := scanCurrency(rows, &c); err != nil {
+			return nil, fmt.Errorf("failed to scan currency: %w", err)
+		}
+		currencies = append(currencies, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating currencies: %w", err)
+	}
+
+	return currencies, nil
+}
+
+func (r *currencyRepository) SetCurrencyEnabled(code models.Currency, enabled bool, actor models.AuditActor) (models.CurrencyInfo, error) {
+	var currency models.CurrencyInfo
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var previous bool
+		err := tx.QueryRow(`SELECT enabled FROM currencies WHERE code = $1 FOR UPDATE`, code).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock currency: %w", err)
+		}
+
+		err = scanCurrency(tx.QueryRow(`
+			UPDATE currencies
+			SET enabled = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE code = $1
+			RETURNING `+currencyColumns,
+			code, enabled,
+		), &currency)
+		if err != nil {
+			return fmt.Errorf("failed to update currency: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditCurrencyUpdate, "currency", code,
+			map[string]interface{}{"enabled": previous},
+			map[string]interface{}{"enabled": enabled},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.CurrencyInfo{}, err
+	}
+
+	return currency, nil
+}
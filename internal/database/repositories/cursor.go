@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"fmt"
+	"time"
+)
+
+// keysetClause returns the condition and ordering that select the rows after
+// cursor in a listing ordered newest first. Pages towards newer rows are read
+// in ascending order and reversed by cursorPage.
+func keysetClause(cursor *models.Cursor, paramCount int) (string, string, []interface{}) {
+	if cursor == nil {
+		return "", " ORDER BY created_at DESC, id DESC", nil
+	}
+
+	if cursor.Direction == models.CursorPrev {
+		return fmt.Sprintf(" AND (created_at, id) > ($%d, $%d)", paramCount+1, paramCount+2),
+			" ORDER BY created_at ASC, id ASC",
+			[]interface{}{cursor.CreatedAt, cursor.ID}
+	}
+
+	return fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", paramCount+1, paramCount+2),
+		" ORDER BY created_at DESC, id DESC",
+		[]interface{}{cursor.CreatedAt, cursor.ID}
+}
+
+// cursorPage turns the rows of a keyset query, fetched with one extra
+// look-ahead row, into a page in newest-first order with its cursors.
+func cursorPage[T any](items []T, request *models.CursorPaginationRequest, key func(T) (time.Time, int)) ([]T, models.CursorPaginationResponse) {
+	hasMore := len(items) > request.PageSize
+	if hasMore {
+		items = items[:request.PageSize]
+	}
+
+	backwards := request.Cursor != nil && request.Cursor.Direction == models.CursorPrev
+	if backwards {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	pagination := models.CursorPaginationResponse{PageSize: request.PageSize}
+	if len(items) == 0 {
+		return items, pagination
+	}
+
+	encode := func(item T, direction models.CursorDirection) *string {
+		createdAt, id := key(item)
+		cursor := models.Cursor{CreatedAt: createdAt, ID: id, Direction: direction}.Encode()
+		return &cursor
+	}
+
+	first, last := items[0], items[len(items)-1]
+	switch {
+	case request.Cursor == nil:
+		if hasMore {
+			pagination.NextCursor = encode(last, models.CursorNext)
+		}
+	case backwards:
+		pagination.NextCursor = encode(last, models.CursorNext)
+		if hasMore {
+			pagination.PrevCursor = encode(first, models.CursorPrev)
+		}
+	default:
+		pagination.PrevCursor = encode(first, models.CursorPrev)
+		if hasMore {
+			pagination.NextCursor = encode(last, models.CursorNext)
+		}
+	}
+
+	return items, pagination
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"testing"
+	"time"
+)
+
+type cursorRow struct {
+	id        int
+	createdAt time.Time
+}
+
+func cursorKey(r cursorRow) (time.Time, int) { return r.createdAt, r.id }
+
+func decode(t *testing.T, s *string) models.Cursor {
+	t.Helper()
+	if s == nil {
+		t.Fatal("expected a cursor")
+	}
+	c, err := models.DecodeCursor(*s)
+	if err != nil {
+		t.Fatalf("failed to decode cursor: %v", err)
+	}
+	return c
+}
+
+func TestCursorPage(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rows := func(ids ...int) []cursorRow {
+		out := make([]cursorRow, len(ids))
+		for i, id := range ids {
+			out[i] = cursorRow{id: id, createdAt: base.Add(time.Duration(id) * time.Minute)}
+		}
+		return out
+	}
+
+	// First page: newest first with a look-ahead row
+	page, p := cursorPage(rows(9, 8, 7), &models.CursorPaginationRequest{PageSize: 2}, cursorKey)
+	if len(page) != 2 || page[0].id != 9 || page[1].id != 8 {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+	if p.PrevCursor != nil {
+		t.Error("first page must not have a prev cursor")
+	}
+	next := decode(t, p.NextCursor)
+	if next.ID != 8 || next.Direction != models.CursorNext || !next.CreatedAt.Equal(page[1].createdAt) {
+		t.Errorf("unexpected next cursor: %+v", next)
+	}
+
+	// Last page going forward: no look-ahead row means no next cursor
+	page, p = cursorPage(rows(7), &models.CursorPaginationRequest{PageSize: 2, Cursor: &next}, cursorKey)
+	if len(page) != 1 || p.NextCursor != nil {
+		t.Fatalf("unexpected last page: %+v %+v", page, p)
+	}
+	prev := decode(t, p.PrevCursor)
+	if prev.ID != 7 || prev.Direction != models.CursorPrev {
+		t.Errorf("unexpected prev cursor: %+v", prev)
+	}
+
+	// Going back, rows arrive oldest first and are reversed
+	page, p = cursorPage(rows(8, 9, 10), &models.CursorPaginationRequest{PageSize: 2, Cursor: &prev}, cursorKey)
+	if len(page) != 2 || page[0].id != 9 || page[1].id != 8 {
+		t.Fatalf("unexpected previous page: %+v", page)
+	}
+	if decode(t, p.PrevCursor).ID != 9 || decode(t, p.NextCursor).ID != 8 {
+		t.Errorf("unexpected cursors: %+v", p)
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not-base64!", "e30", models.Cursor{ID: 1, Direction: "sideways", CreatedAt: time.Now()}.Encode()} {
+		if _, err := models.DecodeCursor(s); err == nil {
+			t.Errorf("expected error for cursor %q", s)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a resource does not exist or is not owned
+	// by the caller. The two cases are deliberately indistinguishable.
+	ErrNotFound = errors.New("not found")
+
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, session revoked")
+
+	ErrInvalidUserToken     = errors.New("invalid, expired or already used token")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrEmailNotVerified     = errors.New("verify your email address first")
+
+	ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already on")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not on")
+	ErrTwoFactorNotEnrolled = errors.New("no authenticator is being enrolled, start enrolment first")
+	ErrInvalidTwoFactorCode = errors.New("invalid or already used two-factor code")
+
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCurrencyMismatch  = errors.New("accounts hold different currencies")
+
+	ErrAccountFrozen           = errors.New("account is frozen")
+	ErrAccountDormant          = errors.New("account is dormant")
+	ErrAccountClosed           = errors.New("account is closed")
+	ErrAccountNotEmpty         = errors.New("account still holds money, sweep it to another account")
+	ErrInvalidStatusTransition = errors.New("account cannot move to that status")
+
+	ErrAlreadyReversed = errors.New("transaction was already reversed")
+	ErrNotReversible   = errors.New("transaction cannot be reversed")
+	ErrNotRefundable   = errors.New("transfers between currencies can only be reversed in full")
+	ErrRefundTooLarge  = errors.New("refund exceeds the amount not yet refunded")
+	ErrInvalidAmount   = errors.New("invalid amount")
+
+	ErrHoldNotAuthorized = errors.New("hold was already captured, released or expired")
+	ErrHoldExpired       = errors.New("hold has expired")
+	ErrAccountHasHolds   = errors.New("account has authorized holds, capture or release them first")
+
+	ErrInvalidScheduleTransition = errors.New("scheduled transfer cannot move to that status")
+
+	// ErrLimitExceeded matches every *LimitExceededError
+	ErrLimitExceeded = errors.New("transaction limit exceeded")
+
+	ErrFXQuoteRequired    = errors.New("transfers between currencies need an fx quote")
+	ErrFXQuoteInvalid     = errors.New("fx quote does not match this transfer")
+	ErrFXQuoteUnavailable = errors.New("fx quote has expired or was already used")
+)
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FailedLoginRepository tracks failed logins per email and per IP for
+// throttling. Emails are matched ignoring case.
+//
+// Times are written in UTC: TIMESTAMP columns drop the zone, and the
+// throttle compares what it reads back against the current time.
+type FailedLoginRepository interface {
+	BeginAttempt(email string, ip string, since time.Time, emailLimit int, ipLimit int, retryAfter func(emailFailures []time.Time, ipFailures []time.Time) time.Duration) (int64, time.Duration, error)
+	ForgetAttempt(id int64) error
+	ClearFailures(email string) error
+	UnlockUser(userID int, actor models.AuditActor) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type failedLoginRepository struct {
+	db database.Service
+}
+
+func NewFailedLoginRepository(db database.Service) FailedLoginRepository {
+	return &failedLoginRepository{db: db}
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// BeginAttempt records an attempt for email from ip as a failure before
+// the password or code is checked, so concurrent attempts count each other.
+// It locks the email and the ip, reads their failures since since, newest
+// first and up to emailLimit and ipLimit, and passes them to retryAfter. A
+// positive wait is returned without recording anything. Otherwise the
+// attempt is recorded and its id returned; the caller forgets it or clears
+// the failures once the attempt turns out to be right.
+func (r *failedLoginRepository) BeginAttempt(email string, ip string, since time.Time, emailLimit int, ipLimit int, retryAfter func(emailFailures []time.Time, ipFailures []time.Time) time.Duration) (int64, time.Duration, error) {
+	email = normalizeLoginEmail(email)
+
+	var id int64
+	var wait time.Duration
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		// Always the email first, so two attempts cannot deadlock
+		for _, key := range []string{"failed_logins:email:" + email, "failed_logins:ip:" + ip} {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+				return fmt.Errorf("failed to lock failed logins: %w", err)
+			}
+		}
+
+		emailFailures, err := recentFailures(tx, "email", email, since, emailLimit)
+		if err != nil {
+			return err
+		}
+		ipFailures, err := recentFailures(tx, "ip", ip, since, ipLimit)
+		if err != nil {
+			return err
+		}
+
+		if wait = retryAfter(emailFailures, ipFailures); wait > 0 {
+			return nil
+		}
+
+		err = tx.QueryRow(`
+			INSERT INTO failed_logins (email, ip, attempted_at)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`, email, ip, time.Now().UTC()).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, wait, nil
+}
+
+// recentFailures reads failures matching column, which is "email" or "ip".
+func recentFailures(tx *sql.Tx, column string, value string, since time.Time, limit int) ([]time.Time, error) {
+	rows, err := tx.Query(`
+		SELECT attempted_at
+		FROM failed_logins
+		WHERE `+column+` = $1 AND attempted_at > $2
+		ORDER BY attempted_at DESC
+		LIMIT $3
+	`, value, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failed logins: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []time.Time
+	for rows.Next() {
+		var attemptedAt time.Time
+		if err := rows.Scan(&attemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan failed login: %w", err)
+		}
+		failures = append(failures, attemptedAt)
+	}
+	return failures, rows.Err()
+}
+
+// ForgetAttempt drops an attempt begun by BeginAttempt that was not a
+// failure, keeping the failures before it.
+func (r *failedLoginRepository) ForgetAttempt(id int64) error {
+	_, err := r.db.Exec(context.Background(), `DELETE FROM failed_logins WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to forget login attempt: %w", err)
+	}
+	return nil
+}
+
+// ClearFailures forgets the failures for email after a successful login.
+func (r *failedLoginRepository) ClearFailures(email string) error {
+	_, err := r.db.Exec(context.Background(), `DELETE FROM failed_logins WHERE email = $1`, normalizeLoginEmail(email))
+	if err != nil {
+		return fmt.Errorf("failed to clear failed logins: %w", err)
+	}
+	return nil
+}
+
+// UnlockUser lifts a lockout early by forgetting the user's failures.
+func (r *failedLoginRepository) UnlockUser(userID int, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var email string
+		err := tx.QueryRow(`SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&email)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		if err := clearFailedLogins(tx, email); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, models.AuditUserUnlock, "user", userID, nil, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+func clearFailedLogins(tx *sql.Tx, email string) error {
+	if _, err := tx.Exec(`DELETE FROM failed_logins WHERE email = $1`, normalizeLoginEmail(email)); err != nil {
+		return fmt.Errorf("failed to clear failed logins: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired drops failures older than before, which no longer count.
+func (r *failedLoginRepository) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec(context.Background(), `DELETE FROM failed_logins WHERE attempted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired failed logins: %w", err)
+	}
+	return result.RowsAffected()
+}
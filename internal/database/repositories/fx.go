@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/fx"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FXRepository stores quotes and serves the rates published in fx_rates,
+// which makes it the DB-backed fx.FXRateProvider.
+type FXRepository interface {
+	fx.FXRateProvider
+	CreateQuote(quote models.FXQuote, userID int) (models.FXQuote, error)
+}
+
+type fxRepository struct {
+	db database.Service
+}
+
+func NewFXRepository(db database.Service) FXRepository {
+	return &fxRepository{db: db}
+}
+
+// Rate reads every published pair so that inverse and cross rates can be
+// derived; fx_rates holds one row per pair and stays small.
+func (r *fxRepository) Rate(ctx context.Context, base models.Currency, quote models.Currency) (models.ExchangeRate, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT base_currency, quote_currency, rate::text, updated_at FROM fx_rates`)
+	if err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("failed to query fx rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.AsOf); err != nil {
+			return models.ExchangeRate{}, fmt.Errorf("failed to scan fx rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return models.ExchangeRate{}, fmt.Errorf("error iterating fx rates: %w", err)
+	}
+
+	table, err := fx.NewRateTable(rates)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	return table.Rate(ctx, base, quote)
+}
+
+func (r *fxRepository) CreateQuote(quote models.FXQuote, userID int) (models.FXQuote, error) {
+	quote.ID = uuid.New().String()
+
+	_, err := r.db.Exec(context.Background(), `
+		INSERT INTO fx_quotes (id, user_id, from_currency, to_currency, mid_rate, spread, rate, source_amount, destination_amount, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		quote.ID,
+		userID,
+		quote.FromCurrency,
+		quote.ToCurrency,
+		quote.MidRate,
+		quote.Spread,
+		quote.Rate,
+		quote.SourceAmount,
+		quote.DestinationAmount,
+		quote.CreatedAt.UTC(),
+		quote.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("failed to create fx quote: %w", err)
+	}
+
+	return quote, nil
+}
+
+// consumeFXQuote locks the caller's quote, checks it still prices the
+// conversion from source into destination and marks it used. It must run in
+// the transaction that books the transfer.
+func consumeFXQuote(tx *sql.Tx, quoteID string, userID int, source models.Money, destination models.Currency) (models.FXQuote, error) {
+	if _, err := uuid.Parse(quoteID); err != nil {
+		return models.FXQuote{}, ErrFXQuoteInvalid
+	}
+
+	var quote models.FXQuote
+	var sourceAmount, destinationAmount string
+	var usedAt sql.NullTime
+	err := tx.QueryRow(`
+		SELECT id, from_currency, to_currency, mid_rate::text, spread::text, rate::text, source_amount, destination_amount, created_at, expires_at, used_at
+		FROM fx_quotes
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, quoteID, userID).Scan(
+		&quote.ID,
+		&quote.FromCurrency,
+		&quote.ToCurrency,
+		&quote.MidRate,
+		&quote.Spread,
+		&quote.Rate,
+		&sourceAmount,
+		&destinationAmount,
+		&quote.CreatedAt,
+		&quote.ExpiresAt,
+		&usedAt,
+	)
+	if err == sql.ErrNoRows {
+		return models.FXQuote{}, ErrFXQuoteInvalid
+	}
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("failed to lock fx quote: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if usedAt.Valid || !now.Before(quote.ExpiresAt) {
+		return models.FXQuote{}, ErrFXQuoteUnavailable
+	}
+
+	if quote.SourceAmount, err = models.ParseMoney(sourceAmount, quote.FromCurrency); err != nil {
+		return models.FXQuote{}, err
+	}
+	if quote.DestinationAmount, err = models.ParseMoney(destinationAmount, quote.ToCurrency); err != nil {
+		return models.FXQuote{}, err
+	}
+
+	if quote.FromCurrency != source.Currency || quote.ToCurrency != destination || quote.SourceAmount.Minor != source.Minor {
+		return models.FXQuote{}, ErrFXQuoteInvalid
+	}
+
+	for _, value := range []*string{&quote.MidRate, &quote.Spread, &quote.Rate} {
+		rate, ok := new(big.Rat).SetString(*value)
+		if !ok {
+			return models.FXQuote{}, fmt.Errorf("invalid rate on fx quote %s", quote.ID)
+		}
+		*value = models.FormatRate(rate)
+	}
+
+	if _, err := tx.Exec(`UPDATE fx_quotes SET used_at = $1 WHERE id = $2`, now, quote.ID); err != nil {
+		return models.FXQuote{}, fmt.Errorf("failed to mark fx quote used: %w", err)
+	}
+
+	return quote, nil
+}
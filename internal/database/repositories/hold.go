@@ -0,0 +1,480 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// heldAmountSubquery sums the authorized holds of the account in the
+// surrounding query. A hold that has passed its expiry stops counting at once,
+// before the expiry job gets to it.
+const heldAmountSubquery = `COALESCE((
+	SELECT SUM(holds.amount) FROM holds
+	WHERE holds.account_id = accounts.id AND holds.status = 'authorized' AND holds.expires_at > CURRENT_TIMESTAMP
+), 0)`
+
+type HoldRepository interface {
+	AuthorizeHold(request models.AuthorizeHoldRequest, userID int, ttl time.Duration, actor models.AuditActor) (models.Hold, error)
+	CaptureHold(request models.CaptureHoldRequest, userID int, actor models.AuditActor) (models.Hold, error)
+	ReleaseHold(holdID int, userID int, actor models.AuditActor) (models.Hold, error)
+	GetHold(holdID int, userID int) (models.Hold, error)
+	GetHolds(userID int, accountID *int, status *models.HoldStatus) ([]models.Hold, error)
+	ExpireHolds() (int64, error)
+}
+
+type holdRepository struct {
+	db database.Service
+}
+
+func NewHoldRepository(db database.Service) HoldRepository {
+	return &holdRepository{db: db}
+}
+
+// heldAmount returns the money reserved by authorized holds on an account.
+// Call it after locking the account, so that it sees every hold committed
+// before the lock was granted.
+func heldAmount(tx *sql.Tx, accountID int, currency models.Currency) (models.Money, error) {
+	var held string
+	err := tx.QueryRow(`SELECT `+heldAmountSubquery+` FROM accounts WHERE id = $1`, accountID).Scan(&held)
+	if err != nil {
+		return models.Money{}, fmt.Errorf("failed to read held amount: %w", err)
+	}
+	return models.ParseMoney(held, currency)
+}
+
+// availableBalance is balance less the holds on the account.
+func availableBalance(tx *sql.Tx, accountID int, balance models.Money) (models.Money, error) {
+	held, err := heldAmount(tx, accountID, balance.Currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return balance.Sub(held)
+}
+
+const holdColumns = `holds.id, holds.account_id, holds.transaction_id, holds.amount, holds.captured_amount, accounts.currency, holds.status, COALESCE(holds.description, ''), holds.expires_at, holds.created_at, holds.updated_at`
+
+func scanHold(row rowScanner, hold *models.Hold) error {
+	var amount string
+	var capturedAmount sql.NullString
+	if err := row.Scan(
+		&hold.ID,
+		&hold.AccountID,
+		&hold.TransactionID,
+		&amount,
+		&capturedAmount,
+		&hold.Currency,
+		&hold.Status,
+		&hold.Description,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	var err error
+	if hold.Amount, err = models.ParseMoney(amount, hold.Currency); err != nil {
+		return err
+	}
+	if capturedAmount.Valid {
+		captured, err := models.ParseMoney(capturedAmount.String, hold.Currency)
+		if err != nil {
+			return err
+		}
+		hold.CapturedAmount = &captured
+	}
+	return nil
+}
+
+// AuthorizeHold reserves money on one of userID's accounts for ttl. The
+// ledger balance does not change; the available balance drops by the amount.
+func (r *holdRepository) AuthorizeHold(request models.AuthorizeHoldRequest, userID int, ttl time.Duration, actor models.AuditActor) (models.Hold, error) {
+	var hold models.Hold
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var balance string
+		var currency models.Currency
+		var accountStatus models.AccountStatus
+		var tier models.AccountTier
+		err := tx.QueryRow(`SELECT balance, currency, status, tier FROM accounts WHERE id = $1 AND user_id = $2 FOR UPDATE`, request.AccountID, userID).Scan(&balance, &currency, &accountStatus, &tier)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		if err := checkCanSend(accountStatus); err != nil {
+			return err
+		}
+
+		ledger, err := models.ParseMoney(balance, currency)
+		if err != nil {
+			return err
+		}
+		available, err := availableBalance(tx, request.AccountID, ledger)
+		if err != nil {
+			return err
+		}
+
+		amount, err := request.Amount.Money(currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+		if available.Minor < amount.Minor {
+			return ErrInsufficientFunds
+		}
+
+		// A hold counts against withdrawal limits when it is authorized,
+		// since capturing it only settles money already reserved
+		if err := checkLimits(tx, request.AccountID, userID, tier, models.Withdrawal, amount); err != nil {
+			return err
+		}
+
+		// The pending withdrawal shows the reservation in the account history
+		// and is what a capture completes
+		var transactionID int
+		err = tx.QueryRow(`
+			INSERT INTO transactions (account_id, amount, transaction_type, status, direction, description, created_at, updated_at, reference_id, user_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $7, $8)
+			RETURNING id
+		`,
+			request.AccountID,
+			amount,
+			models.Withdrawal,
+			models.Pending,
+			models.Debit,
+			request.Description,
+			uuid.New().String(),
+			userID,
+		).Scan(&transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to create pending transaction: %w", err)
+		}
+
+		var holdID int
+		err = tx.QueryRow(`
+			INSERT INTO holds (account_id, user_id, transaction_id, amount, status, description, expires_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), CURRENT_TIMESTAMP + make_interval(secs => $7))
+			RETURNING id
+		`, request.AccountID, userID, transactionID, amount, models.HoldAuthorized, request.Description, ttl.Seconds()).Scan(&holdID)
+		if err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+
+		if err := scanHold(tx.QueryRow(`SELECT `+holdColumns+` FROM holds JOIN accounts ON accounts.id = holds.account_id WHERE holds.id = $1`, holdID), &hold); err != nil {
+			return fmt.Errorf("failed to read hold: %w", err)
+		}
+
+		newAvailable, err := available.Sub(amount)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditHoldAuthorize, "hold", holdID, nil,
+			map[string]interface{}{"account_id": request.AccountID, "amount": amount, "available_balance": newAvailable, "transaction_id": transactionID, "expires_at": hold.ExpiresAt},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// lockHold locks one of userID's holds and checks that it can still be
+// captured or released.
+func lockHold(tx *sql.Tx, holdID int, userID int) (models.Hold, error) {
+	var hold models.Hold
+	err := scanHold(tx.QueryRow(`
+		SELECT `+holdColumns+`
+		FROM holds
+		JOIN accounts ON accounts.id = holds.account_id
+		WHERE holds.id = $1 AND holds.user_id = $2
+		FOR UPDATE OF holds
+	`, holdID, userID), &hold)
+	if err == sql.ErrNoRows {
+		return models.Hold{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Hold{}, fmt.Errorf("failed to lock hold: %w", err)
+	}
+
+	if hold.Status != models.HoldAuthorized {
+		return models.Hold{}, ErrHoldNotAuthorized
+	}
+	return hold, nil
+}
+
+// CaptureHold settles a hold for its whole amount, or for part of it when
+// request.Amount is set. The pending withdrawal is completed for the captured
+// amount and posted to the ledger; whatever was not captured is released.
+func (r *holdRepository) CaptureHold(request models.CaptureHoldRequest, userID int, actor models.AuditActor) (models.Hold, error) {
+	var hold models.Hold
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		if hold, err = lockHold(tx, request.HoldID, userID); err != nil {
+			return err
+		}
+		// Judged by the database clock, like the available balance and the
+		// expiry sweep
+		var live bool
+		if err := tx.QueryRow(`SELECT expires_at > CURRENT_TIMESTAMP FROM holds WHERE id = $1`, hold.ID).Scan(&live); err != nil {
+			return fmt.Errorf("failed to check hold expiry: %w", err)
+		}
+		if !live {
+			return ErrHoldExpired
+		}
+
+		capture := hold.Amount
+		if request.Amount != nil {
+			if capture, err = request.Amount.Money(hold.Currency); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+			}
+			if !capture.IsPositive() || capture.Minor > hold.Amount.Minor {
+				return fmt.Errorf("%w: capture must be greater than 0 and at most the held %s", ErrInvalidAmount, hold.Amount.Format())
+			}
+		}
+
+		var balance string
+		var accountStatus models.AccountStatus
+		err = tx.QueryRow(`SELECT balance, status FROM accounts WHERE id = $1 FOR UPDATE`, hold.AccountID).Scan(&balance, &accountStatus)
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		if err := checkCanSend(accountStatus); err != nil {
+			return err
+		}
+		// The hold reserved the money, but a reversal can still have taken
+		// the ledger balance below it
+		ledger, err := models.ParseMoney(balance, hold.Currency)
+		if err != nil {
+			return err
+		}
+		if ledger.Minor < capture.Minor {
+			return ErrInsufficientFunds
+		}
+
+		var referenceID string
+		err = tx.QueryRow(`
+			UPDATE transactions
+			SET amount = $2, status = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING reference_id
+		`, hold.TransactionID, capture, models.Completed).Scan(&referenceID)
+		if err != nil {
+			return fmt.Errorf("failed to complete pending transaction: %w", err)
+		}
+
+		_, err = postJournalEntry(tx, models.JournalEntry{
+			ReferenceID: referenceID,
+			EntryType:   string(models.Withdrawal),
+			Description: hold.Description,
+			Postings: []models.Posting{
+				accountPosting(hold.AccountID, hold.TransactionID, models.Debit, capture),
+				systemPosting(models.CashOut, models.Credit, capture),
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(`
+			UPDATE holds
+			SET status = $2, captured_amount = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING updated_at
+		`, hold.ID, models.HoldCaptured, capture).Scan(&hold.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to capture hold: %w", err)
+		}
+		hold.Status = models.HoldCaptured
+		hold.CapturedAmount = &capture
+
+		if err := enqueueTransactionEvents(tx, models.EventTransactionCompleted, hold.TransactionID); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, models.AuditHoldCapture, "hold", hold.ID,
+			map[string]interface{}{"status": models.HoldAuthorized, "amount": hold.Amount},
+			map[string]interface{}{"status": models.HoldCaptured, "captured_amount": capture, "transaction_id": hold.TransactionID, "reference_id": referenceID},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ReleaseHold gives the reserved money back to the available balance and
+// fails the pending withdrawal.
+func (r *holdRepository) ReleaseHold(holdID int, userID int, actor models.AuditActor) (models.Hold, error) {
+	var hold models.Hold
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		if hold, err = lockHold(tx, holdID, userID); err != nil {
+			return err
+		}
+
+		if hold.UpdatedAt, err = endHold(tx, hold, models.HoldReleased); err != nil {
+			return err
+		}
+		hold.Status = models.HoldReleased
+
+		return recordAudit(tx, actor, models.AuditHoldRelease, "hold", hold.ID,
+			map[string]interface{}{"status": models.HoldAuthorized},
+			map[string]interface{}{"status": models.HoldReleased, "amount": hold.Amount},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// endHold moves a hold to released or expired and fails its pending
+// withdrawal, returning the hold's new updated_at. Nothing was posted to the
+// ledger, so there is nothing to undo.
+func endHold(tx *sql.Tx, hold models.Hold, status models.HoldStatus) (time.Time, error) {
+	var updatedAt time.Time
+	err := tx.QueryRow(`UPDATE holds SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING updated_at`, hold.ID, status).Scan(&updatedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to end hold %d: %w", hold.ID, err)
+	}
+	_, err = tx.Exec(`
+		UPDATE transactions
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, hold.TransactionID, models.Failed)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fail pending transaction %d: %w", hold.TransactionID, err)
+	}
+	return updatedAt, enqueueTransactionEvents(tx, models.EventTransactionFailed, hold.TransactionID)
+}
+
+// GetHold returns the hold only if it is on one of userID's accounts.
+func (r *holdRepository) GetHold(holdID int, userID int) (models.Hold, error) {
+	var hold models.Hold
+	err := scanHold(r.db.QueryRow(context.Background(), `
+		SELECT `+holdColumns+`
+		FROM holds
+		JOIN accounts ON accounts.id = holds.account_id
+		WHERE holds.id = $1 AND holds.user_id = $2
+	`, holdID, userID), &hold)
+	if err == sql.ErrNoRows {
+		return models.Hold{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Hold{}, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	return hold, nil
+}
+
+// GetHolds lists userID's holds, newest first, optionally for one account or
+// in one status.
+func (r *holdRepository) GetHolds(userID int, accountID *int, status *models.HoldStatus) ([]models.Hold, error) {
+	query := `
+		SELECT ` + holdColumns + `
+		FROM holds
+		JOIN accounts ON accounts.id = holds.account_id
+		WHERE holds.user_id = $1`
+	args := []interface{}{userID}
+
+	if accountID != nil {
+		args = append(args, *accountID)
+		query += fmt.Sprintf(" AND holds.account_id = $%d", len(args))
+	}
+	if status != nil {
+		args = append(args, *status)
+		query += fmt.Sprintf(" AND holds.status = $%d", len(args))
+	}
+	query += " ORDER BY holds.id DESC"
+
+	rows, err := r.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := make([]models.Hold, 0)
+	for rows.Next() {
+		var hold models.Hold
+		if err := scanHold(rows, &hold); err != nil {
+			return nil, fmt.Errorf("failed to scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating holds: %w", err)
+	}
+
+	return holds, nil
+}
+
+// ExpireHolds ends every authorized hold past its expiry and returns how many
+// it ended.
+func (r *holdRepository) ExpireHolds() (int64, error) {
+	var expired int64
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			SELECT `+holdColumns+`
+			FROM holds
+			JOIN accounts ON accounts.id = holds.account_id
+			WHERE holds.status = $1 AND holds.expires_at <= CURRENT_TIMESTAMP
+			ORDER BY holds.id
+			FOR UPDATE OF holds SKIP LOCKED
+		`, models.HoldAuthorized)
+		if err != nil {
+			return fmt.Errorf("failed to query expired holds: %w", err)
+		}
+
+		var holds []models.Hold
+		for rows.Next() {
+			var hold models.Hold
+			if err := scanHold(rows, &hold); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan hold: %w", err)
+			}
+			holds = append(holds, hold)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating expired holds: %w", err)
+		}
+
+		for _, hold := range holds {
+			if _, err := endHold(tx, hold, models.HoldExpired); err != nil {
+				return err
+			}
+			err := recordAudit(tx, models.SystemActor, models.AuditHoldExpire, "hold", hold.ID,
+				map[string]interface{}{"status": models.HoldAuthorized},
+				map[string]interface{}{"status": models.HoldExpired, "amount": hold.Amount, "expired_at": hold.ExpiresAt},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		expired = int64(len(holds))
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return expired, err
+}
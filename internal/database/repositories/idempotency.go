@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	Reserve(record models.IdempotencyRecord, ttl time.Duration, lease time.Duration) (existing *models.IdempotencyRecord, err error)
+	Complete(userID int, key string, status int, body []byte) error
+	Release(userID int, key string) error
+	DeleteExpired() (int64, error)
+}
+
+type idempotencyRepository struct {
+	db database.Service
+}
+
+func NewIdempotencyRepository(db database.Service) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve claims the key for a new request, holding it for lease. A key
+// reserved for the same request whose lease has passed without a response
+// is taken over, since the request that reserved it died. Otherwise, if the
+// key is already in use and has not expired, the stored record is returned
+// instead and nothing is written.
+func (r *idempotencyRepository) Reserve(record models.IdempotencyRecord, ttl time.Duration, lease time.Duration) (*models.IdempotencyRecord, error) {
+	var existing *models.IdempotencyRecord
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		// An expired key behaves as if it was never used
+		_, err := tx.Exec(`
+			DELETE FROM idempotency_keys
+			WHERE user_id = $1 AND idempotency_key = $2 AND expires_at <= CURRENT_TIMESTAMP
+		`, record.UserID, record.Key)
+		if err != nil {
+			return fmt.Errorf("failed to clear expired idempotency key: %w", err)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO idempotency_keys (user_id, idempotency_key, request_method, request_path, request_hash, expires_at, locked_until)
+			VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + make_interval(secs => $6), CURRENT_TIMESTAMP + make_interval(secs => $7))
+			ON CONFLICT (user_id, idempotency_key) DO UPDATE
+			SET locked_until = EXCLUDED.locked_until
+			WHERE idempotency_keys.response_status IS NULL
+				AND idempotency_keys.locked_until <= CURRENT_TIMESTAMP
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash
+		`, record.UserID, record.Key, record.RequestMethod, record.RequestPath, record.RequestHash, ttl.Seconds(), lease.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if rows == 1 {
+			return nil
+		}
+
+		var stored models.IdempotencyRecord
+		var status sql.NullInt64
+		err = tx.QueryRow(`
+			SELECT user_id, idempotency_key, request_method, request_path, request_hash, response_status, response_body, created_at, expires_at
+			FROM idempotency_keys
+			WHERE user_id = $1 AND idempotency_key = $2
+		`, record.UserID, record.Key).Scan(
+			&stored.UserID,
+			&stored.Key,
+			&stored.RequestMethod,
+			&stored.RequestPath,
+			&stored.RequestHash,
+			&status,
+			&stored.ResponseBody,
+			&stored.CreatedAt,
+			&stored.ExpiresAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		if status.Valid {
+			code := int(status.Int64)
+			stored.ResponseStatus = &code
+		}
+
+		existing = &stored
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// Complete stores the response for the key and ends its lease.
+func (r *idempotencyRepository) Complete(userID int, key string, status int, body []byte) error {
+	_, err := r.db.Exec(context.Background(), `
+		UPDATE idempotency_keys
+		SET response_status = $1, response_body = $2, locked_until = NULL
+		WHERE user_id = $3 AND idempotency_key = $4
+	`, status, body, userID, key)
+	return err
+}
+
+// Release forgets a reserved key so the client can retry a request that
+// failed on the server side.
+func (r *idempotencyRepository) Release(userID int, key string) error {
+	_, err := r.db.Exec(context.Background(), `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2 AND response_status IS NULL
+	`, userID, key)
+	return err
+}
+
+func (r *idempotencyRepository) DeleteExpired() (int64, error) {
+	result, err := r.db.Exec(context.Background(), `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
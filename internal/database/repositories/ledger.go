@@ -0,0 +1,263 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type LedgerRepository interface {
+	ReconcileAccount(accountID int, userID int) (models.LedgerReconciliation, error)
+}
+
+type ledgerRepository struct {
+	db database.Service
+}
+
+func NewLedgerRepository(db database.Service) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func accountPosting(accountID int, transactionID int, side models.TransactionDirection, amount models.Money) models.Posting {
+	return models.Posting{
+		AccountID:     &accountID,
+		TransactionID: &transactionID,
+		Side:          side,
+		Amount:        amount,
+		Currency:      amount.Currency,
+	}
+}
+
+func systemPosting(account models.SystemAccount, side models.TransactionDirection, amount models.Money) models.Posting {
+	return models.Posting{
+		SystemAccount: &account,
+		Side:          side,
+		Amount:        amount,
+		Currency:      amount.Currency,
+	}
+}
+
+// postJournalEntry writes a balanced journal entry and applies its customer
+// postings to the cached accounts.balance column. It must run inside the same
+// transaction as the rows it describes, after the affected accounts have been
+// locked by the caller.
+func postJournalEntry(tx *sql.Tx, entry models.JournalEntry) (int, error) {
+	if err := validateJournalEntry(entry); err != nil {
+		return 0, err
+	}
+
+	var entryID int
+	err := tx.QueryRow(`
+		INSERT INTO journal_entries (reference_id, entry_type, description)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING id
+	`, entry.ReferenceID, entry.EntryType, entry.Description).Scan(&entryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	postingQuery := `
+		INSERT INTO postings (journal_entry_id, account_id, system_account, transaction_id, side, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	balanceQuery := `
+		UPDATE accounts
+		SET balance = balance + $1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`
+
+	for _, posting := range entry.Postings {
+		if _, err := tx.Exec(postingQuery,
+			entryID,
+			posting.AccountID,
+			posting.SystemAccount,
+			posting.TransactionID,
+			posting.Side,
+			posting.Amount,
+			posting.Amount.Currency,
+		); err != nil {
+			return 0, fmt.Errorf("failed to create posting: %w", err)
+		}
+
+		if posting.AccountID == nil {
+			continue
+		}
+
+		delta := posting.Amount
+		if posting.Side == models.Debit {
+			delta = delta.Neg()
+		}
+
+		result, err := tx.Exec(balanceQuery, delta, *posting.AccountID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update account balance: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if rows == 0 {
+			return 0, fmt.Errorf("account not found")
+		}
+	}
+
+	return entryID, nil
+}
+
+// validateJournalEntry checks that an entry can be posted: at least two
+// positive postings, each on exactly one account, whose debits and credits
+// cancel out in every currency. The deferred trigger on postings checks the
+// balance again at commit.
+func validateJournalEntry(entry models.JournalEntry) error {
+	if len(entry.Postings) < 2 {
+		return fmt.Errorf("journal entry needs at least two postings")
+	}
+
+	net := make(map[models.Currency]int64)
+	for _, posting := range entry.Postings {
+		if !posting.Amount.IsPositive() {
+			return fmt.Errorf("posting amount must be greater than 0")
+		}
+		if (posting.AccountID == nil) == (posting.SystemAccount == nil) {
+			return fmt.Errorf("posting must target either an account or a system account")
+		}
+
+		if posting.Side == models.Debit {
+			net[posting.Amount.Currency] += posting.Amount.Minor
+		} else {
+			net[posting.Amount.Currency] -= posting.Amount.Minor
+		}
+	}
+	for currency, diff := range net {
+		if diff != 0 {
+			return fmt.Errorf("journal entry is not balanced for %s", currency)
+		}
+	}
+	return nil
+}
+
+func (r *ledgerRepository) ReconcileAccount(accountID int, userID int) (models.LedgerReconciliation, error) {
+	var reconciliation models.LedgerReconciliation
+	var balance, ledgerBalance string
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		query := `
+		SELECT a.id, a.currency, a.balance,
+			COALESCE(SUM(CASE WHEN p.side = 'CREDIT' THEN p.amount ELSE -p.amount END), 0)
+		FROM accounts a
+		LEFT JOIN postings p ON p.account_id = a.id
+		WHERE a.id = $1 AND a.user_id = $2
+		GROUP BY a.id, a.currency, a.balance
+		`
+
+		return tx.QueryRow(query, accountID, userID).Scan(
+			&reconciliation.AccountID,
+			&reconciliation.Currency,
+			&balance,
+			&ledgerBalance,
+		)
+	})
+
+	if err == sql.ErrNoRows {
+		return models.LedgerReconciliation{}, ErrNotFound
+	}
+	if err != nil {
+		return models.LedgerReconciliation{}, err
+	}
+
+	if reconciliation.Balance, err = models.ParseMoney(balance, reconciliation.Currency); err != nil {
+		return models.LedgerReconciliation{}, err
+	}
+	if reconciliation.LedgerBalance, err = models.ParseMoney(ledgerBalance, reconciliation.Currency); err != nil {
+		return models.LedgerReconciliation{}, err
+	}
+	if reconciliation.Difference, err = reconciliation.Balance.Sub(reconciliation.LedgerBalance); err != nil {
+		return models.LedgerReconciliation{}, err
+	}
+	reconciliation.Balanced = reconciliation.Difference.IsZero()
+
+	return reconciliation, nil
+}
+
+// loadJournalEntry reads the first journal entry booked under referenceID
+// together with its postings.
+func loadJournalEntry(tx *sql.Tx, referenceID string) (models.JournalEntry, error) {
+	var entry models.JournalEntry
+	err := tx.QueryRow(`
+		SELECT id, reference_id, entry_type, COALESCE(description, ''), created_at
+		FROM journal_entries
+		WHERE reference_id = $1
+		ORDER BY id
+		LIMIT 1
+	`, referenceID).Scan(
+		&entry.ID,
+		&entry.ReferenceID,
+		&entry.EntryType,
+		&entry.Description,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, journal_entry_id, account_id, system_account, transaction_id, side, amount, currency, created_at
+		FROM postings
+		WHERE journal_entry_id = $1
+		ORDER BY id
+	`, entry.ID)
+	if err != nil {
+		return models.JournalEntry{}, fmt.Errorf("failed to query postings: %w", err)
+	}
+	defer rows.Close()
+
+	entry.Postings = make([]models.Posting, 0)
+	for rows.Next() {
+		var (
+			p             models.Posting
+			amount        string
+			accountID     sql.NullInt64
+			systemAccount sql.NullString
+			transactionID sql.NullInt64
+		)
+		if err := rows.Scan(
+			&p.ID,
+			&p.JournalEntryID,
+			&accountID,
+			&systemAccount,
+			&transactionID,
+			&p.Side,
+			&amount,
+			&p.Currency,
+			&p.CreatedAt,
+		); err != nil {
+			return models.JournalEntry{}, fmt.Errorf("failed to scan posting: %w", err)
+		}
+		if p.Amount, err = models.ParseMoney(amount, p.Currency); err != nil {
+			return models.JournalEntry{}, err
+		}
+		if accountID.Valid {
+			id := int(accountID.Int64)
+			p.AccountID = &id
+		}
+		if systemAccount.Valid {
+			account := models.SystemAccount(systemAccount.String)
+			p.SystemAccount = &account
+		}
+		if transactionID.Valid {
+			id := int(transactionID.Int64)
+			p.TransactionID = &id
+		}
+		entry.Postings = append(entry.Postings, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.JournalEntry{}, err
+	}
+
+	return entry, nil
+}
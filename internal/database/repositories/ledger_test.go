@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"strings"
+	"testing"
+)
+
+func TestValidateJournalEntry(t *testing.T) {
+	usd := func(minor int64) models.Money { return models.NewMoney(minor, models.USD) }
+	eur := func(minor int64) models.Money { return models.NewMoney(minor, models.EUR) }
+
+	both := accountPosting(10, 1, models.Debit, usd(500))
+	cash := models.CashIn
+	both.SystemAccount = &cash
+
+	tests := []struct {
+		name     string
+		postings []models.Posting
+		wantErr  string
+	}{
+		{
+			name: "transfer",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(500)),
+				accountPosting(11, 2, models.Credit, usd(500)),
+			},
+		},
+		{
+			name: "deposit against a system account",
+			postings: []models.Posting{
+				systemPosting(models.CashIn, models.Debit, usd(500)),
+				accountPosting(10, 1, models.Credit, usd(500)),
+			},
+		},
+		{
+			name: "transfer between currencies through the FX position",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(1000)),
+				accountPosting(11, 2, models.Credit, eur(900)),
+				systemPosting(models.FXPosition, models.Credit, usd(1000)),
+				systemPosting(models.FXPosition, models.Debit, eur(900)),
+			},
+		},
+		{
+			name:    "empty",
+			wantErr: "at least two postings",
+		},
+		{
+			name:     "single posting",
+			postings: []models.Posting{accountPosting(10, 1, models.Credit, usd(500))},
+			wantErr:  "at least two postings",
+		},
+		{
+			name: "unbalanced",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(500)),
+				accountPosting(11, 2, models.Credit, usd(499)),
+			},
+			wantErr: "not balanced for USD",
+		},
+		{
+			name: "same side twice",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(500)),
+				accountPosting(11, 2, models.Debit, usd(500)),
+			},
+			wantErr: "not balanced for USD",
+		},
+		{
+			// Equal amounts in different currencies do not cancel out
+			name: "mixed currencies",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(500)),
+				accountPosting(11, 2, models.Credit, eur(500)),
+			},
+			wantErr: "not balanced",
+		},
+		{
+			name: "zero amount",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(0)),
+				accountPosting(11, 2, models.Credit, usd(0)),
+			},
+			wantErr: "greater than 0",
+		},
+		{
+			name: "negative amount",
+			postings: []models.Posting{
+				accountPosting(10, 1, models.Debit, usd(-500)),
+				accountPosting(11, 2, models.Credit, usd(-500)),
+			},
+			wantErr: "greater than 0",
+		},
+		{
+			name: "posting on two accounts",
+			postings: []models.Posting{
+				both,
+				accountPosting(11, 2, models.Credit, usd(500)),
+			},
+			wantErr: "either an account or a system account",
+		},
+		{
+			name: "posting on no account",
+			postings: []models.Posting{
+				{Side: models.Debit, Amount: usd(500), Currency: models.USD},
+				accountPosting(11, 2, models.Credit, usd(500)),
+			},
+			wantErr: "either an account or a system account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := models.JournalEntry{ReferenceID: "ref", EntryType: "TEST", Postings: tt.postings}
+
+			err := validateJournalEntry(entry)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q; got %v", tt.wantErr, err)
+			}
+
+			// postJournalEntry refuses the entry before touching the
+			// database, so a nil transaction is never used
+			if _, err := postJournalEntry(nil, entry); err == nil {
+				t.Error("postJournalEntry accepted an invalid entry")
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// limitUserLock is the advisory lock class that serialises one user's
+// transactions while their user-wide limits are checked. The user ID is the
+// second key.
+const limitUserLock = 0x6c696d69
+
+// LimitExceededError is returned when a transaction would break a limit. It
+// names the limit and how much of it is left.
+type LimitExceededError struct {
+	Breach models.LimitBreach
+}
+
+func (e *LimitExceededError) Error() string {
+	limit := e.Breach.Limit
+	return fmt.Sprintf("%s %s %s limit per %s exceeded", limit.Period, strings.ToLower(string(limit.TransactionType)), e.Breach.Measure, limit.Scope)
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type LimitRepository interface {
+	ListLimits() ([]models.TransactionLimit, error)
+	SetLimit(request models.SetLimitRequest, actor models.AuditActor) (models.TransactionLimit, error)
+	DeleteLimit(limitID int, actor models.AuditActor) error
+	GetAccountLimits(accountID int, userID int) ([]models.LimitUsage, error)
+	SetAccountTier(accountID int, tier models.AccountTier, actor models.AuditActor) (models.Account, error)
+}
+
+type limitRepository struct {
+	db database.Service
+}
+
+func NewLimitRepository(db database.Service) LimitRepository {
+	return &limitRepository{db: db}
+}
+
+const limitColumns = `id, transaction_type, currency, period, scope, account_tier, user_id, max_amount, max_count, created_at, updated_at`
+
+func scanLimit(row rowScanner, limit *models.TransactionLimit) error {
+	var tier sql.NullString
+	var userID, maxCount sql.NullInt64
+	var maxAmount sql.NullString
+	if err := row.Scan(
+		&limit.ID,
+		&limit.TransactionType,
+		&limit.Currency,
+		&limit.Period,
+		&limit.Scope,
+		&tier,
+		&userID,
+		&maxAmount,
+		&maxCount,
+		&limit.CreatedAt,
+		&limit.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if tier.Valid {
+		accountTier := models.AccountTier(tier.String)
+		limit.AccountTier = &accountTier
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		limit.UserID = &id
+	}
+	if maxAmount.Valid {
+		amount, err := models.ParseMoney(maxAmount.String, limit.Currency)
+		if err != nil {
+			return err
+		}
+		limit.MaxAmount = &amount
+	}
+	if maxCount.Valid {
+		count := int(maxCount.Int64)
+		limit.MaxCount = &count
+	}
+	return nil
+}
+
+// limitDirection is the side of the account a transaction type counts on:
+// deposits are the money coming in, withdrawals and transfers going out.
+func limitDirection(transactionType models.TransactionType) models.TransactionDirection {
+	if transactionType == models.Deposit {
+		return models.Credit
+	}
+	return models.Debit
+}
+
+var limitPeriodOrder = map[models.LimitPeriod]int{
+	models.LimitDaily:   0,
+	models.LimitWeekly:  1,
+	models.LimitMonthly: 2,
+}
+
+// limitSpecificity ranks a user override over an account tier over the
+// default.
+func limitSpecificity(limit models.TransactionLimit) int {
+	switch {
+	case limit.UserID != nil:
+		return 2
+	case limit.AccountTier != nil:
+		return 1
+	}
+	return 0
+}
+
+// resolveLimits keeps the most specific limit for each transaction type,
+// currency, period and scope, so that a user override replaces the tier's
+// limit instead of adding to it. The result is ordered by type, period and
+// scope.
+func resolveLimits(limits []models.TransactionLimit) []models.TransactionLimit {
+	type key struct {
+		transactionType models.TransactionType
+		currency        models.Currency
+		period          models.LimitPeriod
+		scope           models.LimitScope
+	}
+
+	chosen := make(map[key]models.TransactionLimit)
+	for _, limit := range limits {
+		k := key{limit.TransactionType, limit.Currency, limit.Period, limit.Scope}
+		if current, ok := chosen[k]; !ok || limitSpecificity(limit) > limitSpecificity(current) {
+			chosen[k] = limit
+		}
+	}
+
+	resolved := make([]models.TransactionLimit, 0, len(chosen))
+	for _, limit := range chosen {
+		resolved = append(resolved, limit)
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		a, b := resolved[i], resolved[j]
+		if a.TransactionType != b.TransactionType {
+			return a.TransactionType < b.TransactionType
+		}
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Period != b.Period {
+			return limitPeriodOrder[a.Period] < limitPeriodOrder[b.Period]
+		}
+		return a.Scope < b.Scope
+	})
+	return resolved
+}
+
+// applicableLimits returns the resolved limits on an account of the given
+// tier and currency owned by userID. A nil transactionType returns them for
+// every type.
+func applicableLimits(tx *sql.Tx, userID int, tier models.AccountTier, currency models.Currency, transactionType *models.TransactionType) ([]models.TransactionLimit, error) {
+	rows, err := tx.Query(`
+		SELECT `+limitColumns+` FROM transaction_limits
+		WHERE currency = $1
+			AND ($2::transaction_type IS NULL OR transaction_type = $2)
+			AND (user_id = $3 OR account_tier = $4 OR (user_id IS NULL AND account_tier IS NULL))
+	`, currency, transactionType, userID, tier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query limits: %w", err)
+	}
+	defer rows.Close()
+
+	var limits []models.TransactionLimit
+	for rows.Next() {
+		var limit models.TransactionLimit
+		if err := scanLimit(rows, &limit); err != nil {
+			return nil, fmt.Errorf("failed to scan limit: %w", err)
+		}
+		limits = append(limits, limit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating limits: %w", err)
+	}
+
+	return resolveLimits(limits), nil
+}
+
+// limitUsage sums what has counted against limit in the current period.
+// Failed and fully reversed transactions do not count, and refunds give
+// back what they returned. Pending withdrawals from holds count from the
+// moment they are authorized.
+func limitUsage(tx *sql.Tx, limit models.TransactionLimit, accountID int, userID int, now time.Time) (models.LimitUsage, error) {
+	start, end := limit.Period.Window(now)
+	usage := models.LimitUsage{TransactionLimit: limit, ResetsAt: end}
+
+	query := `
+		SELECT COALESCE(SUM(t.amount - t.refunded_amount), 0), COUNT(*)
+		FROM transactions t
+		JOIN accounts a ON a.id = t.account_id
+		WHERE t.transaction_type = $1
+			AND t.direction = $2
+			AND t.status IN ('pending', 'completed', 'partially_refunded')
+			AND t.created_at >= $3
+			AND a.currency = $4`
+	args := []interface{}{limit.TransactionType, limitDirection(limit.TransactionType), start, limit.Currency}
+	if limit.Scope == models.LimitPerUser {
+		query += ` AND t.user_id = $5`
+		args = append(args, userID)
+	} else {
+		query += ` AND t.account_id = $5`
+		args = append(args, accountID)
+	}
+
+	var used string
+	if err := tx.QueryRow(query, args...).Scan(&used, &usage.UsedCount); err != nil {
+		return models.LimitUsage{}, fmt.Errorf("failed to read limit usage: %w", err)
+	}
+
+	var err error
+	if usage.UsedAmount, err = models.ParseMoney(used, limit.Currency); err != nil {
+		return models.LimitUsage{}, err
+	}
+
+	if limit.MaxAmount != nil {
+		remaining := models.NewMoney(max(limit.MaxAmount.Minor-usage.UsedAmount.Minor, 0), limit.Currency)
+		usage.RemainingAmount = &remaining
+	}
+	if limit.MaxCount != nil {
+		remaining := max(*limit.MaxCount-usage.UsedCount, 0)
+		usage.RemainingCount = &remaining
+	}
+	return usage, nil
+}
+
+// checkLimits fails with a *LimitExceededError when moving amount as
+// transactionType on the account would break one of its limits. Call it
+// after locking the account: the lock keeps two transactions on the account
+// from both fitting under the same limit. User-wide limits also take a
+// per-user lock, which is held until the transaction ends.
+func checkLimits(tx *sql.Tx, accountID int, userID int, tier models.AccountTier, transactionType models.TransactionType, amount models.Money) error {
+	limits, err := applicableLimits(tx, userID, tier, amount.Currency, &transactionType)
+	if err != nil {
+		return err
+	}
+
+	userLocked := false
+	now := time.Now()
+	for _, limit := range limits {
+		if limit.Scope == models.LimitPerUser && !userLocked {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, limitUserLock, userID); err != nil {
+				return fmt.Errorf("failed to lock user limits: %w", err)
+			}
+			userLocked = true
+		}
+
+		usage, err := limitUsage(tx, limit, accountID, userID, now)
+		if err != nil {
+			return err
+		}
+
+		if limit.MaxCount != nil && usage.UsedCount+1 > *limit.MaxCount {
+			return &LimitExceededError{Breach: models.LimitBreach{Measure: models.LimitMeasureCount, Limit: usage}}
+		}
+		if limit.MaxAmount != nil && usage.UsedAmount.Minor+amount.Minor > limit.MaxAmount.Minor {
+			return &LimitExceededError{Breach: models.LimitBreach{Measure: models.LimitMeasureAmount, Limit: usage}}
+		}
+	}
+	return nil
+}
+
+func (r *limitRepository) ListLimits() ([]models.TransactionLimit, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+limitColumns+` FROM transaction_limits
+		ORDER BY transaction_type, currency, period, scope, account_tier NULLS FIRST, user_id NULLS FIRST
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query limits: %w", err)
+	}
+	defer rows.Close()
+
+	limits := make([]models.TransactionLimit, 0)
+	for rows.Next() {
+		var limit models.TransactionLimit
+		if err := scanLimit(rows, &limit); err != nil {
+			return nil, fmt.Errorf("failed to scan limit: %w", err)
+		}
+		limits = append(limits, limit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// SetLimit creates a limit, or replaces the caps of the one with the same
+// type, currency, period, scope and target.
+func (r *limitRepository) SetLimit(request models.SetLimitRequest, actor models.AuditActor) (models.TransactionLimit, error) {
+	var limit models.TransactionLimit
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var maxAmount *models.Money
+		if request.MaxAmount != nil {
+			amount, err := request.MaxAmount.Money(request.Currency)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+			}
+			maxAmount = &amount
+		}
+
+		if request.UserID != nil {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, *request.UserID).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to look up user: %w", err)
+			}
+			if !exists {
+				return ErrNotFound
+			}
+		}
+
+		var before interface{}
+		var previous models.TransactionLimit
+		err := scanLimit(tx.QueryRow(`
+			SELECT `+limitColumns+` FROM transaction_limits
+			WHERE transaction_type = $1 AND currency = $2 AND period = $3 AND scope = $4
+				AND COALESCE(account_tier, '') = COALESCE($5, '') AND COALESCE(user_id, 0) = COALESCE($6, 0)
+			FOR UPDATE
+		`, request.TransactionType, request.Currency, request.Period, request.Scope, request.AccountTier, request.UserID), &previous)
+		if err == nil {
+			before = previous
+		} else if err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read limit: %w", err)
+		}
+
+		err = scanLimit(tx.QueryRow(`
+			INSERT INTO transaction_limits (transaction_type, currency, period, scope, account_tier, user_id, max_amount, max_count)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (transaction_type, currency, period, scope, (COALESCE(account_tier, '')), (COALESCE(user_id, 0)))
+			DO UPDATE SET max_amount = EXCLUDED.max_amount, max_count = EXCLUDED.max_count, updated_at = CURRENT_TIMESTAMP
+			RETURNING `+limitColumns,
+			request.TransactionType,
+			request.Currency,
+			request.Period,
+			request.Scope,
+			request.AccountTier,
+			request.UserID,
+			maxAmount,
+			request.MaxCount,
+		), &limit)
+		if err != nil {
+			return fmt.Errorf("failed to save limit: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditLimitSet, "limit", limit.ID, before, limit)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return limit, err
+}
+
+func (r *limitRepository) DeleteLimit(limitID int, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var limit models.TransactionLimit
+		err := scanLimit(tx.QueryRow(`DELETE FROM transaction_limits WHERE id = $1 RETURNING `+limitColumns, limitID), &limit)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete limit: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditLimitDelete, "limit", limitID, limit, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// GetAccountLimits reports every limit on one of userID's accounts and how
+// much of it is left in the current period.
+func (r *limitRepository) GetAccountLimits(accountID int, userID int) ([]models.LimitUsage, error) {
+	usages := make([]models.LimitUsage, 0)
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var currency models.Currency
+		var tier models.AccountTier
+		err := tx.QueryRow(`SELECT currency, tier FROM accounts WHERE id = $1 AND user_id = $2`, accountID, userID).Scan(&currency, &tier)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+
+		limits, err := applicableLimits(tx, userID, tier, currency, nil)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, limit := range limits {
+			usage, err := limitUsage(tx, limit, accountID, userID, now)
+			if err != nil {
+				return err
+			}
+			usages = append(usages, usage)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+		ReadOnly:  true,
+	})
+
+	return usages, err
+}
+
+func (r *limitRepository) SetAccountTier(accountID int, tier models.AccountTier, actor models.AuditActor) (models.Account, error) {
+	var account models.Account
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var current models.AccountTier
+		err := tx.QueryRow(`SELECT tier FROM accounts WHERE id = $1 FOR UPDATE`, accountID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+
+		if _, err := tx.Exec(`UPDATE accounts SET tier = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, tier, accountID); err != nil {
+			return fmt.Errorf("failed to update account tier: %w", err)
+		}
+
+		if err := scanAccount(tx.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = $1`, accountID), &account); err != nil {
+			return fmt.Errorf("failed to read account: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditAccountTier, "account", accountID,
+			map[string]interface{}{"tier": current},
+			map[string]interface{}{"tier": tier},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return account, err
+}
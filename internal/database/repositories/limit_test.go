@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"testing"
+)
+
+func TestResolveLimits(t *testing.T) {
+	premium := models.TierPremium
+	userID := 3
+	limit := func(id int, transactionType models.TransactionType, period models.LimitPeriod, scope models.LimitScope) models.TransactionLimit {
+		return models.TransactionLimit{ID: id, TransactionType: transactionType, Currency: models.USD, Period: period, Scope: scope}
+	}
+
+	tierLimit := limit(2, models.Withdrawal, models.LimitDaily, models.LimitPerAccount)
+	tierLimit.AccountTier = &premium
+	userLimit := limit(3, models.Withdrawal, models.LimitDaily, models.LimitPerAccount)
+	userLimit.UserID = &userID
+	monthlyTier := limit(5, models.Withdrawal, models.LimitMonthly, models.LimitPerAccount)
+	monthlyTier.AccountTier = &premium
+
+	got := resolveLimits([]models.TransactionLimit{
+		monthlyTier,
+		limit(1, models.Withdrawal, models.LimitDaily, models.LimitPerAccount),
+		userLimit,
+		tierLimit,
+		limit(4, models.Withdrawal, models.LimitDaily, models.LimitPerUser),
+		limit(6, models.Deposit, models.LimitWeekly, models.LimitPerAccount),
+	})
+
+	// The user override beats the tier limit and the default for the same
+	// period and scope; other periods and scopes are kept side by side
+	want := []int{6, 3, 4, 5}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d limits; got %d", len(want), len(got))
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Errorf("position %d: expected limit %d; got %d", i, id, got[i].ID)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// scheduledMaxAttempts is how many times one occurrence of a scheduled
+	// transfer is tried before it is given up on
+	scheduledMaxAttempts = 5
+
+	// scheduledRetryBase is the wait after the first failed attempt; it
+	// doubles after every further failure
+	scheduledRetryBase = 5 * time.Minute
+)
+
+type ScheduledTransferRepository interface {
+	CreateScheduledTransfer(request models.CreateScheduledTransferRequest, userID int, actor models.AuditActor) (models.ScheduledTransfer, error)
+	GetScheduledTransfer(scheduledTransferID int, userID int) (models.ScheduledTransfer, error)
+	GetScheduledTransfers(userID int, status *models.ScheduledTransferStatus) ([]models.ScheduledTransfer, error)
+	GetScheduledTransferRuns(scheduledTransferID int, userID int) ([]models.ScheduledTransferRun, error)
+	SetScheduledTransferStatus(scheduledTransferID int, userID int, status models.ScheduledTransferStatus, actor models.AuditActor) (models.ScheduledTransfer, error)
+	RunDueTransfer() (bool, error)
+}
+
+type scheduledTransferRepository struct {
+	db database.Service
+}
+
+func NewScheduledTransferRepository(db database.Service) ScheduledTransferRepository {
+	return &scheduledTransferRepository{db: db}
+}
+
+const scheduledTransferColumns = `scheduled_transfers.id, scheduled_transfers.user_id, scheduled_transfers.account_id, scheduled_transfers.destination_account_id, scheduled_transfers.transaction_type, scheduled_transfers.amount, accounts.currency, COALESCE(scheduled_transfers.description, ''), scheduled_transfers.frequency, scheduled_transfers.start_at, scheduled_transfers.end_at, scheduled_transfers.status, scheduled_transfers.occurrence, scheduled_transfers.attempts, scheduled_transfers.next_run_at, scheduled_transfers.last_run_at, scheduled_transfers.created_at, scheduled_transfers.updated_at`
+
+const scheduledTransferFrom = ` FROM scheduled_transfers JOIN accounts ON accounts.id = scheduled_transfers.account_id`
+
+func scanScheduledTransfer(row rowScanner, s *models.ScheduledTransfer) error {
+	var destinationAccountID sql.NullInt64
+	var amount string
+	var endAt, nextRunAt, lastRunAt sql.NullTime
+	if err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.AccountID,
+		&destinationAccountID,
+		&s.TransactionType,
+		&amount,
+		&s.Currency,
+		&s.Description,
+		&s.Frequency,
+		&s.StartAt,
+		&endAt,
+		&s.Status,
+		&s.Occurrence,
+		&s.Attempts,
+		&nextRunAt,
+		&lastRunAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if destinationAccountID.Valid {
+		id := int(destinationAccountID.Int64)
+		s.DestinationAccountID = &id
+	}
+	if endAt.Valid {
+		s.EndAt = &endAt.Time
+	}
+	if nextRunAt.Valid {
+		s.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+
+	var err error
+	s.Amount, err = models.ParseMoney(amount, s.Currency)
+	return err
+}
+
+// scheduledRetryDelay is the wait after the attempt-th failed attempt at an
+// occurrence.
+func scheduledRetryDelay(attempt int) time.Duration {
+	return scheduledRetryBase << (attempt - 1)
+}
+
+// isPermanentScheduleError tells whether a failed run can never succeed, so
+// retrying it or running later occurrences is pointless.
+func isPermanentScheduleError(err error) bool {
+	return errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrAccountClosed) ||
+		errors.Is(err, ErrFXQuoteRequired)
+}
+
+// advanceSchedule updates s after an attempt at its current occurrence made
+// at now. A failed attempt is retried with backoff until it has been tried
+// scheduledMaxAttempts times. After that, or after a success, the schedule
+// moves on to its next occurrence. Occurrences missed while the schedule
+// was paused or the worker was down are skipped rather than run in a burst.
+func advanceSchedule(s *models.ScheduledTransfer, runErr error, now time.Time) {
+	s.Attempts++
+	s.LastRunAt = &now
+
+	if runErr != nil && isPermanentScheduleError(runErr) {
+		s.Status, s.NextRunAt = models.ScheduleFailed, nil
+		return
+	}
+	if runErr != nil && s.Attempts < scheduledMaxAttempts {
+		retryAt := now.Add(scheduledRetryDelay(s.Attempts))
+		s.NextRunAt = &retryAt
+		return
+	}
+
+	s.Attempts = 0
+	if s.Frequency == models.ScheduleOnce {
+		s.Occurrence++
+		s.NextRunAt = nil
+		if runErr != nil {
+			s.Status = models.ScheduleFailed
+		} else {
+			s.Status = models.ScheduleCompleted
+		}
+		return
+	}
+
+	next := s.Frequency.Occurrence(s.StartAt, s.Occurrence)
+	for !next.After(now) {
+		s.Occurrence++
+		next = s.Frequency.Occurrence(s.StartAt, s.Occurrence)
+	}
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		s.Status, s.NextRunAt = models.ScheduleCompleted, nil
+		return
+	}
+	s.NextRunAt = &next
+}
+
+// CreateScheduledTransfer schedules money to leave one of userID's accounts.
+// Both accounts must hold the same currency, as an FX quote would have
+// expired by the time the transfer runs.
+func (r *scheduledTransferRepository) CreateScheduledTransfer(request models.CreateScheduledTransferRequest, userID int, actor models.AuditActor) (models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var currency models.Currency
+		var accountStatus models.AccountStatus
+		err := tx.QueryRow(`SELECT currency, status FROM accounts WHERE id = $1 AND user_id = $2`, request.AccountID, userID).Scan(&currency, &accountStatus)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get account: %w", err)
+		}
+		if accountStatus == models.AccountClosed {
+			return ErrAccountClosed
+		}
+
+		transactionType := models.Withdrawal
+		if request.DestinationAccountID != nil {
+			transactionType = models.Transfer
+
+			var destinationCurrency models.Currency
+			var destinationStatus models.AccountStatus
+			err := tx.QueryRow(`SELECT currency, status FROM accounts WHERE id = $1`, *request.DestinationAccountID).Scan(&destinationCurrency, &destinationStatus)
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get destination account: %w", err)
+			}
+			if destinationStatus == models.AccountClosed {
+				return ErrAccountClosed
+			}
+			if destinationCurrency != currency {
+				return ErrCurrencyMismatch
+			}
+		}
+
+		amount, err := request.Amount.Money(currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+		}
+
+		var endAt *time.Time
+		if request.EndAt != nil {
+			end := request.EndAt.UTC()
+			endAt = &end
+		}
+		startAt := request.StartAt.UTC()
+
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO scheduled_transfers (user_id, account_id, destination_account_id, transaction_type, amount, description, frequency, start_at, end_at, status, next_run_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $8)
+			RETURNING id
+		`,
+			userID,
+			request.AccountID,
+			request.DestinationAccountID,
+			transactionType,
+			amount,
+			request.Description,
+			request.Frequency,
+			startAt,
+			endAt,
+			models.ScheduleActive,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to create scheduled transfer: %w", err)
+		}
+
+		if err := scanScheduledTransfer(tx.QueryRow(`SELECT `+scheduledTransferColumns+scheduledTransferFrom+` WHERE scheduled_transfers.id = $1`, id), &scheduled); err != nil {
+			return fmt.Errorf("failed to read scheduled transfer: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditScheduleCreate, "scheduled_transfer", id, nil, scheduled)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return scheduled, err
+}
+
+func (r *scheduledTransferRepository) GetScheduledTransfer(scheduledTransferID int, userID int) (models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := scanScheduledTransfer(r.db.QueryRow(context.Background(),
+		`SELECT `+scheduledTransferColumns+scheduledTransferFrom+` WHERE scheduled_transfers.id = $1 AND scheduled_transfers.user_id = $2`,
+		scheduledTransferID, userID,
+	), &scheduled)
+	if err == sql.ErrNoRows {
+		return models.ScheduledTransfer{}, ErrNotFound
+	}
+	if err != nil {
+		return models.ScheduledTransfer{}, fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+
+	return scheduled, nil
+}
+
+func (r *scheduledTransferRepository) GetScheduledTransfers(userID int, status *models.ScheduledTransferStatus) ([]models.ScheduledTransfer, error) {
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT `+scheduledTransferColumns+scheduledTransferFrom+`
+		WHERE scheduled_transfers.user_id = $1 AND ($2::text IS NULL OR scheduled_transfers.status = $2)
+		ORDER BY scheduled_transfers.next_run_at NULLS LAST, scheduled_transfers.id DESC
+	`, userID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	scheduledTransfers := make([]models.ScheduledTransfer, 0)
+	for rows.Next() {
+		var scheduled models.ScheduledTransfer
+		if err := scanScheduledTransfer(rows, &scheduled); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer: %w", err)
+		}
+		scheduledTransfers = append(scheduledTransfers, scheduled)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled transfers: %w", err)
+	}
+
+	return scheduledTransfers, nil
+}
+
+// GetScheduledTransferRuns lists every attempt at one of userID's scheduled
+// transfers, newest first.
+func (r *scheduledTransferRepository) GetScheduledTransferRuns(scheduledTransferID int, userID int) ([]models.ScheduledTransferRun, error) {
+	if _, err := r.GetScheduledTransfer(scheduledTransferID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(context.Background(), `
+		SELECT id, scheduled_transfer_id, occurrence, attempt, scheduled_for, status, transaction_id, COALESCE(error, ''), created_at
+		FROM scheduled_transfer_runs
+		WHERE scheduled_transfer_id = $1
+		ORDER BY id DESC
+	`, scheduledTransferID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transfer runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]models.ScheduledTransferRun, 0)
+	for rows.Next() {
+		var run models.ScheduledTransferRun
+		var transactionID sql.NullInt64
+		if err := rows.Scan(&run.ID, &run.ScheduledTransferID, &run.Occurrence, &run.Attempt, &run.ScheduledFor, &run.Status, &transactionID, &run.Error, &run.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer run: %w", err)
+		}
+		if transactionID.Valid {
+			id := int(transactionID.Int64)
+			run.TransactionID = &id
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled transfer runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// SetScheduledTransferStatus pauses, resumes or cancels one of userID's
+// scheduled transfers. A resumed schedule runs at once if its next run fell
+// due while it was paused.
+func (r *scheduledTransferRepository) SetScheduledTransferStatus(scheduledTransferID int, userID int, status models.ScheduledTransferStatus, actor models.AuditActor) (models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var current models.ScheduledTransferStatus
+		err := tx.QueryRow(`SELECT status FROM scheduled_transfers WHERE id = $1 AND user_id = $2 FOR UPDATE`, scheduledTransferID, userID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock scheduled transfer: %w", err)
+		}
+
+		allowed := map[models.ScheduledTransferStatus][]models.ScheduledTransferStatus{
+			models.ScheduleActive:    {models.SchedulePaused},
+			models.SchedulePaused:    {models.ScheduleActive},
+			models.ScheduleCancelled: {models.ScheduleActive, models.SchedulePaused},
+		}
+		valid := false
+		for _, from := range allowed[status] {
+			if current == from {
+				valid = true
+			}
+		}
+		if !valid {
+			return ErrInvalidScheduleTransition
+		}
+
+		_, err = tx.Exec(`
+			UPDATE scheduled_transfers
+			SET status = $1,
+				next_run_at = CASE WHEN $1 = 'cancelled' THEN NULL ELSE next_run_at END,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $2
+		`, status, scheduledTransferID)
+		if err != nil {
+			return fmt.Errorf("failed to update scheduled transfer: %w", err)
+		}
+
+		if err := scanScheduledTransfer(tx.QueryRow(`SELECT `+scheduledTransferColumns+scheduledTransferFrom+` WHERE scheduled_transfers.id = $1`, scheduledTransferID), &scheduled); err != nil {
+			return fmt.Errorf("failed to read scheduled transfer: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditScheduleStatus, "scheduled_transfer", scheduledTransferID,
+			map[string]interface{}{"status": current},
+			map[string]interface{}{"status": status},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return scheduled, err
+}
+
+// RunDueTransfer runs the scheduled transfer that has been due longest and
+// reports whether there was one. The schedule stays locked while it runs,
+// and SKIP LOCKED lets other workers take the next due schedule meanwhile,
+// so each occurrence runs on one replica only. The money moves in the same
+// database transaction as the run is recorded, so a crash cannot leave a
+// transfer booked but not recorded, or the other way round.
+func (r *scheduledTransferRepository) RunDueTransfer() (bool, error) {
+	ran := false
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+
+		var scheduled models.ScheduledTransfer
+		err := scanScheduledTransfer(tx.QueryRow(`
+			SELECT `+scheduledTransferColumns+scheduledTransferFrom+`
+			WHERE scheduled_transfers.status = $1 AND scheduled_transfers.next_run_at <= $2
+			ORDER BY scheduled_transfers.next_run_at
+			LIMIT 1
+			FOR UPDATE OF scheduled_transfers SKIP LOCKED
+		`, models.ScheduleActive, now), &scheduled)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim scheduled transfer: %w", err)
+		}
+		ran = true
+
+		scheduledFor := *scheduled.NextRunAt
+		occurrence, attempt := scheduled.Occurrence, scheduled.Attempts+1
+
+		// A failed run is rolled back to the savepoint, so the failure can
+		// still be recorded in this transaction
+		if _, err := tx.Exec(`SAVEPOINT scheduled_transfer_run`); err != nil {
+			return fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		transactionID, runErr := executeScheduledTransfer(tx, scheduled)
+		if runErr != nil {
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT scheduled_transfer_run`); err != nil {
+				return fmt.Errorf("failed to roll back failed run: %w", err)
+			}
+		} else if _, err := tx.Exec(`RELEASE SAVEPOINT scheduled_transfer_run`); err != nil {
+			return fmt.Errorf("failed to release savepoint: %w", err)
+		}
+
+		runStatus, runError := models.RunSucceeded, ""
+		var runTransactionID *int
+		if runErr != nil {
+			runStatus, runError = models.RunFailed, runErr.Error()
+		} else {
+			runTransactionID = &transactionID
+		}
+		_, err = tx.Exec(`
+			INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, occurrence, attempt, scheduled_for, status, transaction_id, error)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		`, scheduled.ID, occurrence, attempt, scheduledFor, runStatus, runTransactionID, runError)
+		if err != nil {
+			return fmt.Errorf("failed to record scheduled transfer run: %w", err)
+		}
+
+		advanceSchedule(&scheduled, runErr, now)
+		_, err = tx.Exec(`
+			UPDATE scheduled_transfers
+			SET status = $1, occurrence = $2, attempts = $3, next_run_at = $4, last_run_at = $5, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $6
+		`, scheduled.Status, scheduled.Occurrence, scheduled.Attempts, scheduled.NextRunAt, scheduled.LastRunAt, scheduled.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update scheduled transfer: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return ran, err
+}
+
+// executeScheduledTransfer books one run of a schedule in tx, as its owner
+// would through the API, and returns the withdrawal or the debit leg.
+func executeScheduledTransfer(tx *sql.Tx, scheduled models.ScheduledTransfer) (int, error) {
+	actor := models.SystemActor
+	actor.RequestID = fmt.Sprintf("scheduled-transfer-%d", scheduled.ID)
+
+	amount, err := models.ParseAmount(scheduled.Amount.String())
+	if err != nil {
+		return 0, err
+	}
+
+	if scheduled.DestinationAccountID == nil {
+		transactionID, _, err := withdrawFunds(tx, models.CreateTransactionRequest{
+			AccountID: scheduled.AccountID,
+			Amount:    amount,
+		}, scheduled.UserID, actor)
+		return transactionID, err
+	}
+
+	debitTransactionID, _, _, err := transferFunds(tx, models.CreateTransferRequest{
+		AccountID:            scheduled.AccountID,
+		DestinationAccountID: *scheduled.DestinationAccountID,
+		Amount:               amount,
+		Description:          scheduled.Description,
+	}, scheduled.UserID, actor)
+	return debitTransactionID, err
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"testing"
+	"time"
+)
+
+func TestAdvanceSchedule(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	monthly := func() models.ScheduledTransfer {
+		next := start
+		return models.ScheduledTransfer{Frequency: models.ScheduleMonthly, StartAt: start, Status: models.ScheduleActive, NextRunAt: &next}
+	}
+
+	t.Run("success moves to the next occurrence", func(t *testing.T) {
+		s := monthly()
+		advanceSchedule(&s, nil, start.Add(time.Second))
+		if s.Occurrence != 1 || s.Attempts != 0 || !s.NextRunAt.Equal(time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("got occurrence %d, attempts %d, next run %v", s.Occurrence, s.Attempts, s.NextRunAt)
+		}
+	})
+
+	t.Run("failures back off then give up on the occurrence", func(t *testing.T) {
+		s := monthly()
+		now := start
+		for attempt := 1; attempt < scheduledMaxAttempts; attempt++ {
+			advanceSchedule(&s, ErrInsufficientFunds, now)
+			if s.Attempts != attempt || !s.NextRunAt.Equal(now.Add(scheduledRetryDelay(attempt))) {
+				t.Fatalf("attempt %d: got attempts %d, next run %v", attempt, s.Attempts, s.NextRunAt)
+			}
+			now = *s.NextRunAt
+		}
+		if scheduledRetryDelay(2) != 2*scheduledRetryDelay(1) {
+			t.Errorf("retry delay does not double")
+		}
+
+		advanceSchedule(&s, ErrInsufficientFunds, now)
+		if s.Status != models.ScheduleActive || s.Occurrence != 1 || s.Attempts != 0 {
+			t.Errorf("after the last attempt got status %s, occurrence %d, attempts %d", s.Status, s.Occurrence, s.Attempts)
+		}
+	})
+
+	t.Run("missed occurrences are skipped", func(t *testing.T) {
+		s := monthly()
+		advanceSchedule(&s, nil, time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC))
+		if s.Occurrence != 4 || !s.NextRunAt.Equal(time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("got occurrence %d, next run %v", s.Occurrence, s.NextRunAt)
+		}
+	})
+
+	t.Run("schedule completes after its end", func(t *testing.T) {
+		s := monthly()
+		end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+		s.EndAt = &end
+		advanceSchedule(&s, nil, start)
+		if s.Status != models.ScheduleCompleted || s.NextRunAt != nil {
+			t.Errorf("got status %s, next run %v", s.Status, s.NextRunAt)
+		}
+	})
+
+	t.Run("one-off transfers finish either way", func(t *testing.T) {
+		s := monthly()
+		s.Frequency = models.ScheduleOnce
+		s.Attempts = scheduledMaxAttempts - 1
+		advanceSchedule(&s, ErrInsufficientFunds, start)
+		if s.Status != models.ScheduleFailed || s.NextRunAt != nil {
+			t.Errorf("got status %s, next run %v", s.Status, s.NextRunAt)
+		}
+	})
+
+	t.Run("permanent errors stop the schedule", func(t *testing.T) {
+		s := monthly()
+		advanceSchedule(&s, ErrAccountClosed, start)
+		if s.Status != models.ScheduleFailed || s.NextRunAt != nil {
+			t.Errorf("got status %s, next run %v", s.Status, s.NextRunAt)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	CreateSession(userID int, userAgent string, ipAddress string, refreshTokenHash string, ttl time.Duration) (models.Session, error)
+	RotateRefreshToken(oldTokenHash string, newTokenHash string, ttl time.Duration) (models.Session, error)
+	RevokeSession(sessionID string, userID int) error
+	RevokeAllSessions(userID int) error
+	RevokeToken(tokenID string, userID int, expiresAt time.Time) error
+	IsRevoked(tokenID string, sessionID string) (bool, error)
+	DeleteExpired() error
+}
+
+type sessionRepository struct {
+	db database.Service
+}
+
+func NewSessionRepository(db database.Service) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) CreateSession(userID int, userAgent string, ipAddress string, refreshTokenHash string, ttl time.Duration) (models.Session, error) {
+	session := models.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+	}
+
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			INSERT INTO sessions (id, user_id, user_agent, ip_address, expires_at)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + make_interval(secs => $5))
+			RETURNING created_at, last_used_at, expires_at
+		`, session.ID, userID, userAgent, ipAddress, ttl.Seconds()).Scan(&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)
+		`, session.ID, refreshTokenHash, session.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and extends the
+// session. Presenting a token that was already used means it leaked, so the
+// whole session is revoked and ErrRefreshTokenReused is returned.
+func (r *sessionRepository) RotateRefreshToken(oldTokenHash string, newTokenHash string, ttl time.Duration) (models.Session, error) {
+	var session models.Session
+	reused := false
+
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var tokenID int
+		var usedAt, revokedAt sql.NullTime
+		var tokenExpired bool
+		err := tx.QueryRow(`
+			SELECT rt.id, rt.used_at, rt.expires_at <= CURRENT_TIMESTAMP, s.id, s.user_id, COALESCE(s.user_agent, ''), COALESCE(s.ip_address, ''), s.created_at, s.revoked_at
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.id = rt.session_id
+			WHERE rt.token_hash = $1
+			FOR UPDATE OF rt, s
+		`, oldTokenHash).Scan(
+			&tokenID,
+			&usedAt,
+			&tokenExpired,
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.CreatedAt,
+			&revokedAt,
+		)
+		if err == sql.ErrNoRows {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get refresh token: %w", err)
+		}
+
+		// Expiry is judged by the database clock, as DeleteExpired judges it
+		if revokedAt.Valid || tokenExpired {
+			return ErrInvalidRefreshToken
+		}
+
+		if usedAt.Valid {
+			// Commit the revocation, then report the reuse to the caller
+			reused = true
+			_, err := tx.Exec(`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1`, session.ID)
+			return err
+		}
+
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = $1`, tokenID); err != nil {
+			return fmt.Errorf("failed to mark refresh token as used: %w", err)
+		}
+
+		err = tx.QueryRow(`
+			UPDATE sessions
+			SET last_used_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP + make_interval(secs => $1)
+			WHERE id = $2
+			RETURNING last_used_at, expires_at
+		`, ttl.Seconds(), session.ID).Scan(&session.LastUsedAt, &session.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)
+		`, session.ID, newTokenHash, session.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to create refresh token: %w", err)
+		}
+
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if reused {
+		return models.Session{}, ErrRefreshTokenReused
+	}
+
+	return session, nil
+}
+
+func (r *sessionRepository) RevokeSession(sessionID string, userID int) error {
+	_, err := r.db.Exec(context.Background(), `
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	return err
+}
+
+func (r *sessionRepository) RevokeAllSessions(userID int) error {
+	_, err := r.db.Exec(context.Background(), `
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+// RevokeToken blocks an access token until it expires. expiresAt is sent as
+// TIMESTAMPTZ so the database converts it to its own clock, which
+// DeleteExpired compares against.
+func (r *sessionRepository) RevokeToken(tokenID string, userID int, expiresAt time.Time) error {
+	_, err := r.db.Exec(context.Background(), `
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3::TIMESTAMPTZ)
+		ON CONFLICT (jti) DO NOTHING
+	`, tokenID, userID, expiresAt)
+	return err
+}
+
+// IsRevoked reports whether the access token itself, or the session it was
+// issued for, has been revoked.
+func (r *sessionRepository) IsRevoked(tokenID string, sessionID string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow(context.Background(), `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR NOT EXISTS(SELECT 1 FROM sessions WHERE id = $2 AND revoked_at IS NULL)
+	`, tokenID, sessionID).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// DeleteExpired removes revocation entries for access tokens that have
+// expired anyway, and refresh tokens of sessions that ended.
+func (r *sessionRepository) DeleteExpired() error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+			return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+		}
+
+		_, err := tx.Exec(`
+			DELETE FROM refresh_tokens
+			WHERE session_id IN (
+				SELECT id FROM sessions
+				WHERE expires_at <= CURRENT_TIMESTAMP OR revoked_at IS NOT NULL
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+		}
+
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
@@ -7,13 +7,24 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"time"
 )
 
 type SOARepository interface {
 	GetSOA(userID int, request models.GenerateSOACustomRequest) (*models.SOA, error)
 	SavePDF(pdfURL string, userID int) error
-	GetGeneratedSOA(userID int) (*models.PaginatedResponse[models.SOA], error)
-	GetSOAByID(soaID int) (*models.SOA, error)
+	GetGeneratedSOA(
+		userID int,
+		filter *models.StatementFilter,
+		sort *models.SortRequest,
+		pagination *models.PaginationRequest,
+	) (*models.PaginatedResponse[models.SOA], error)
+	GetGeneratedSOAByCursor(
+		userID int,
+		filter *models.StatementFilter,
+		pagination *models.CursorPaginationRequest,
+	) (*models.CursorPaginatedResponse[models.SOA], error)
+	GetSOAByID(soaID int, userID int) (*models.SOA, error)
 }
 
 type soaRepository struct {
@@ -40,7 +51,7 @@ func (r *soaRepository) GetSOA(userID int, request models.GenerateSOACustomReque
 		return nil, err
 	}
 
-	totalAmount := 0.0
+	var totalAmount models.Money
 
 	userBalance, err := userRepository.ViewBalance(userID)
 	if err != nil {
@@ -78,43 +89,82 @@ func (r *soaRepository) GetSOA(userID int, request models.GenerateSOACustomReque
 	}, nil
 }
 
+// SavePDF records a generated statement and tells the owner's webhooks
+// about it in the same transaction.
 func (r *soaRepository) SavePDF(pdfURL string, userID int) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		_, err := saveStatement(tx, pdfURL, userID)
+		return err
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+func saveStatement(tx *sql.Tx, pdfURL string, userID int) (models.SOA, error) {
 	query := `
 		INSERT INTO statements (pdf_url, user_id, created_at, statement_date, updated_at)
 		VALUES ($1, $2, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING id, pdf_url, user_id, created_at, statement_date, updated_at
 	`
-	_, err := r.db.Exec(context.Background(), query, pdfURL, userID)
-	return err
+	var soa models.SOA
+	err := tx.QueryRow(query, pdfURL, userID).Scan(
+		&soa.ID,
+		&soa.PDFUrl,
+		&soa.UserID,
+		&soa.CreatedAt,
+		&soa.StatementDate,
+		&soa.UpdatedAt,
+	)
+	if err != nil {
+		return soa, fmt.Errorf("failed to save statement: %w", err)
+	}
+
+	return soa, enqueueWebhookEvent(tx, userID, models.EventStatementGenerated, soa)
 }
 
-func (r *soaRepository) GetGeneratedSOA(userID int) (*models.PaginatedResponse[models.SOA], error) {
+func (r *soaRepository) GetGeneratedSOA(
+	userID int,
+	filter *models.StatementFilter,
+	sort *models.SortRequest,
+	pagination *models.PaginationRequest,
+) (*models.PaginatedResponse[models.SOA], error) {
 	var statements []models.SOA
 	var totalRecords int64
 	var response *models.PaginatedResponse[models.SOA]
 
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		baseQuery := `FROM statements WHERE user_id = $1`
+		args := []interface{}{userID}
+		paramCount := 1
+
+		if filter != nil && filter.DateFrom != nil {
+			paramCount++
+			baseQuery += fmt.Sprintf(" AND created_at >= $%d", paramCount)
+			args = append(args, *filter.DateFrom)
+		}
+
+		if filter != nil && filter.DateTo != nil {
+			paramCount++
+			baseQuery += fmt.Sprintf(" AND created_at <= $%d", paramCount)
+			args = append(args, *filter.DateTo)
+		}
+
 		// Get total count for pagination
-		countQuery := `SELECT COUNT(*) FROM statements WHERE user_id = $1`
-		err := tx.QueryRow(countQuery, userID).Scan(&totalRecords)
+		countQuery := "SELECT COUNT(*) " + baseQuery
+		err := tx.QueryRow(countQuery, args...).Scan(&totalRecords)
 		if err != nil {
 			return fmt.Errorf("failed to get total count: %w", err)
 		}
 
-		// Get paginated results
-		query := `
-			SELECT id, pdf_url, user_id, created_at, statement_date, updated_at
-			FROM statements 
-			WHERE user_id = $1
-			ORDER BY created_at DESC
-			LIMIT $2 OFFSET $3
-		`
+		// Apply sorting and pagination
+		query := `SELECT id, pdf_url, user_id, created_at, statement_date, updated_at ` + baseQuery
+		query += buildSortClause(sort, []string{"created_at", "statement_date"})
 
-		// Default pagination values if not provided
-		pageSize := 10
-		page := 1
-		offset := (page - 1) * pageSize
+		offset := (pagination.Page - 1) * pagination.PageSize
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", paramCount+1, paramCount+2)
+		args = append(args, pagination.PageSize, offset)
 
-		rows, err := tx.Query(query, userID, pageSize, offset)
+		rows, err := tx.Query(query, args...)
 		if err != nil {
 			return fmt.Errorf("failed to query statements: %w", err)
 		}
@@ -141,13 +191,13 @@ func (r *soaRepository) GetGeneratedSOA(userID int) (*models.PaginatedResponse[m
 		}
 
 		// Calculate pagination metadata
-		totalPages := int(math.Ceil(float64(totalRecords) / float64(pageSize)))
+		totalPages := int(math.Ceil(float64(totalRecords) / float64(pagination.PageSize)))
 
 		response = &models.PaginatedResponse[models.SOA]{
 			Data: statements,
 			Pagination: models.PaginationResponse{
-				CurrentPage:  page,
-				PageSize:     pageSize,
+				CurrentPage:  pagination.Page,
+				PageSize:     pagination.PageSize,
 				TotalPages:   totalPages,
 				TotalRecords: totalRecords,
 			},
@@ -163,15 +213,105 @@ func (r *soaRepository) GetGeneratedSOA(userID int) (*models.PaginatedResponse[m
 	return response, nil
 }
 
-func (r *soaRepository) GetSOAByID(soaID int) (*models.SOA, error) {
+// GetGeneratedSOAByCursor pages through a user's statements newest first
+// using keyset pagination on (created_at, id).
+func (r *soaRepository) GetGeneratedSOAByCursor(
+	userID int,
+	filter *models.StatementFilter,
+	pagination *models.CursorPaginationRequest,
+) (*models.CursorPaginatedResponse[models.SOA], error) {
+	var response *models.CursorPaginatedResponse[models.SOA]
+
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		baseQuery := `FROM statements WHERE user_id = $1`
+		args := []interface{}{userID}
+		paramCount := 1
+
+		if filter != nil && filter.DateFrom != nil {
+			paramCount++
+			baseQuery += fmt.Sprintf(" AND created_at >= $%d", paramCount)
+			args = append(args, *filter.DateFrom)
+		}
+
+		if filter != nil && filter.DateTo != nil {
+			paramCount++
+			baseQuery += fmt.Sprintf(" AND created_at <= $%d", paramCount)
+			args = append(args, *filter.DateTo)
+		}
+
+		var totalRecords *int64
+		if pagination.IncludeTotal {
+			var total int64
+			if err := tx.QueryRow("SELECT COUNT(*) "+baseQuery, args...).Scan(&total); err != nil {
+				return fmt.Errorf("failed to get total count: %w", err)
+			}
+			totalRecords = &total
+		}
+
+		keyset, order, keysetArgs := keysetClause(pagination.Cursor, paramCount)
+		args = append(args, keysetArgs...)
+		paramCount += len(keysetArgs)
+
+		// Fetch one extra row to learn whether another page follows
+		query := `SELECT id, pdf_url, user_id, created_at, statement_date, updated_at ` + baseQuery + keyset + order + fmt.Sprintf(" LIMIT $%d", paramCount+1)
+		args = append(args, pagination.PageSize+1)
+
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query statements: %w", err)
+		}
+		defer rows.Close()
+
+		statements := make([]models.SOA, 0, pagination.PageSize+1)
+		for rows.Next() {
+			var s models.SOA
+			if err := rows.Scan(
+				&s.ID,
+				&s.PDFUrl,
+				&s.UserID,
+				&s.CreatedAt,
+				&s.StatementDate,
+				&s.UpdatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to scan statement: %w", err)
+			}
+			statements = append(statements, s)
+		}
+
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("error iterating statements: %w", err)
+		}
+
+		page, cursors := cursorPage(statements, pagination, func(s models.SOA) (time.Time, int) {
+			return s.CreatedAt, s.ID
+		})
+		cursors.TotalRecords = totalRecords
+
+		response = &models.CursorPaginatedResponse[models.SOA]{
+			Data:       page,
+			Pagination: cursors,
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statements: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetSOAByID returns the statement only if it belongs to userID.
+func (r *soaRepository) GetSOAByID(soaID int, userID int) (*models.SOA, error) {
 	query := `
 		SELECT id, pdf_url, user_id, created_at, statement_date, updated_at
 		FROM statements
-		WHERE id = $1
+		WHERE id = $1 AND user_id = $2
 	`
 
 	var soa models.SOA
-	err := r.db.QueryRow(context.Background(), query, soaID).Scan(
+	err := r.db.QueryRow(context.Background(), query, soaID, userID).Scan(
 		&soa.ID,
 		&soa.PDFUrl,
 		&soa.UserID,
@@ -179,6 +319,9 @@ func (r *soaRepository) GetSOAByID(soaID int) (*models.SOA, error) {
 		&soa.StatementDate,
 		&soa.UpdatedAt,
 	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
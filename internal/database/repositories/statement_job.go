@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// statementJobMaxAttempts is how many times a job is started before it is
+// failed. A job is restarted when rendering errors or when the worker
+// running it went away.
+const statementJobMaxAttempts = 3
+
+// ErrStatementJobLost is returned when a worker finishes a job it no longer
+// holds, because its lease ran out and another worker took the job over.
+var ErrStatementJobLost = errors.New("statement job was taken over by another worker")
+
+type StatementJobRepository interface {
+	CreateStatementJob(userID int, request models.GenerateSOACustomRequest) (models.StatementJob, error)
+	GetStatementJob(jobID int, userID int) (models.StatementJob, error)
+	ClaimStatementJob(lease time.Duration) (*models.StatementJob, error)
+	CompleteStatementJob(job models.StatementJob, pdfURL string) (models.SOA, error)
+	FailStatementJob(job models.StatementJob, jobErr error) error
+}
+
+type statementJobRepository struct {
+	db database.Service
+}
+
+func NewStatementJobRepository(db database.Service) StatementJobRepository {
+	return &statementJobRepository{db: db}
+}
+
+const statementJobColumns = `id, user_id, status, request::text, attempts, statement_id, COALESCE(error, ''), started_at, finished_at, created_at, updated_at`
+
+func scanStatementJob(row rowScanner, job *models.StatementJob) error {
+	var request string
+	var statementID sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&request,
+		&job.Attempts,
+		&statementID,
+		&job.Error,
+		&startedAt,
+		&finishedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if statementID.Valid {
+		id := int(statementID.Int64)
+		job.StatementID = &id
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return json.Unmarshal([]byte(request), &job.Request)
+}
+
+func (r *statementJobRepository) CreateStatementJob(userID int, request models.GenerateSOACustomRequest) (models.StatementJob, error) {
+	var job models.StatementJob
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return job, fmt.Errorf("failed to encode statement request: %w", err)
+	}
+
+	err = scanStatementJob(r.db.QueryRow(context.Background(), `
+		INSERT INTO statement_jobs (user_id, request)
+		VALUES ($1, $2)
+		RETURNING `+statementJobColumns,
+		userID, string(encoded),
+	), &job)
+	if err != nil {
+		return job, fmt.Errorf("failed to queue statement job: %w", err)
+	}
+	return job, nil
+}
+
+// GetStatementJob returns the job only if userID queued it.
+func (r *statementJobRepository) GetStatementJob(jobID int, userID int) (models.StatementJob, error) {
+	var job models.StatementJob
+	err := scanStatementJob(r.db.QueryRow(context.Background(), `SELECT `+statementJobColumns+` FROM statement_jobs WHERE id = $1 AND user_id = $2`, jobID, userID), &job)
+	if err == sql.ErrNoRows {
+		return job, ErrNotFound
+	}
+	if err != nil {
+		return job, fmt.Errorf("failed to get statement job: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimStatementJob starts the oldest queued job, or a running one whose
+// worker's lease ran out, and leases it to the caller. It returns nil when
+// there is nothing to do. Jobs abandoned statementJobMaxAttempts times are
+// failed instead of being started again.
+func (r *statementJobRepository) ClaimStatementJob(lease time.Duration) (*models.StatementJob, error) {
+	var claimed *models.StatementJob
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		_, err := tx.Exec(`
+			UPDATE statement_jobs
+			SET status = $3, locked_until = NULL, error = 'worker stopped while rendering the statement', finished_at = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE status = 'running' AND locked_until <= $1 AND attempts >= $2
+		`, now, statementJobMaxAttempts, models.StatementJobFailed)
+		if err != nil {
+			return fmt.Errorf("failed to fail abandoned statement jobs: %w", err)
+		}
+
+		var job models.StatementJob
+		err = scanStatementJob(tx.QueryRow(`
+			UPDATE statement_jobs
+			SET status = $3, attempts = attempts + 1, locked_until = $2, started_at = COALESCE(started_at, $1), updated_at = CURRENT_TIMESTAMP
+			WHERE id = (
+				SELECT id FROM statement_jobs
+				WHERE status = 'queued' OR (status = 'running' AND locked_until <= $1)
+				ORDER BY id
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+statementJobColumns,
+			now, now.Add(lease), models.StatementJobRunning,
+		), &job)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim statement job: %w", err)
+		}
+		claimed = &job
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return claimed, err
+}
+
+// CompleteStatementJob records the rendered statement and marks the job
+// succeeded in one transaction, so a statement exists exactly for the jobs
+// that succeeded. It returns ErrStatementJobLost, and records nothing, when
+// the caller no longer holds the job.
+func (r *statementJobRepository) CompleteStatementJob(job models.StatementJob, pdfURL string) (models.SOA, error) {
+	var soa models.SOA
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		if err := lockHeldStatementJob(tx, job); err != nil {
+			return err
+		}
+
+		var err error
+		soa, err = saveStatement(tx, pdfURL, job.UserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE statement_jobs
+			SET status = $2, statement_id = $3, locked_until = NULL, error = NULL, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, job.ID, models.StatementJobSucceeded, soa.ID)
+		if err != nil {
+			return fmt.Errorf("failed to complete statement job: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return soa, err
+}
+
+// FailStatementJob queues the job again, or fails it for good once it has
+// been tried statementJobMaxAttempts times.
+func (r *statementJobRepository) FailStatementJob(job models.StatementJob, jobErr error) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		if err := lockHeldStatementJob(tx, job); err != nil {
+			return err
+		}
+
+		status := models.StatementJobQueued
+		var finishedAt *time.Time
+		if job.Attempts >= statementJobMaxAttempts {
+			now := time.Now().UTC()
+			status, finishedAt = models.StatementJobFailed, &now
+		}
+
+		_, err := tx.Exec(`
+			UPDATE statement_jobs
+			SET status = $2, error = $3, locked_until = NULL, finished_at = $4, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, job.ID, status, jobErr.Error(), finishedAt)
+		if err != nil {
+			return fmt.Errorf("failed to fail statement job: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// lockHeldStatementJob locks job and checks the caller still holds it: the
+// job is running and was not started again since the caller claimed it.
+func lockHeldStatementJob(tx *sql.Tx, job models.StatementJob) error {
+	var held bool
+	err := tx.QueryRow(`
+		SELECT status = 'running' AND attempts = $2
+		FROM statement_jobs
+		WHERE id = $1
+		FOR UPDATE
+	`, job.ID, job.Attempts).Scan(&held)
+	if err == sql.ErrNoRows || (err == nil && !held) {
+		return ErrStatementJobLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock statement job: %w", err)
+	}
+	return nil
+}
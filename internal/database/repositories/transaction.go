@@ -7,6 +7,8 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"math/big"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,16 +16,24 @@ import (
 )
 
 type TransactionRepository interface {
-	Deposit(transaction models.CreateTransactionRequest, userID int) (map[string]interface{}, error)
-	Withdraw(transaction models.CreateTransactionRequest, userID int) (map[string]interface{}, error)
+	Deposit(transaction models.CreateTransactionRequest, userID int, actor models.AuditActor) (map[string]interface{}, error)
+	Withdraw(transaction models.CreateTransactionRequest, userID int, actor models.AuditActor) (map[string]interface{}, error)
+	Transfer(transfer models.CreateTransferRequest, userID int, actor models.AuditActor) (map[string]interface{}, error)
 	GetTransactions(
 		userID int,
 		filter *models.TransactionFilter,
 		sort *models.SortRequest,
 		pagination *models.PaginationRequest,
 	) (*models.PaginatedResponse[models.Transaction], error)
-	GetTransaction(transactionID int) (models.Transaction, error)
+	GetTransactionsByCursor(
+		userID int,
+		filter *models.TransactionFilter,
+		pagination *models.CursorPaginationRequest,
+	) (*models.CursorPaginatedResponse[models.Transaction], error)
+	GetTransaction(transactionID int, userID int) (models.Transaction, error)
 	GetTransactionsForSOA(userID int, request models.GenerateSOACustomRequest) ([]models.Transaction, error)
+	ReverseTransaction(transactionID int, reason string, actor models.AuditActor) (map[string]interface{}, error)
+	RefundTransaction(transactionID int, amount models.Amount, reason string, actor models.AuditActor) (map[string]interface{}, error)
 }
 
 type transactionRepository struct {
@@ -34,25 +44,51 @@ func NewTransactionRepository(db database.Service) TransactionRepository {
 	return &transactionRepository{db: db}
 }
 
-func (r *transactionRepository) Deposit(transaction models.CreateTransactionRequest, userID int) (map[string]interface{}, error) {
-	// Round amount to 2 decimal places
-	transaction.Amount = math.Round(transaction.Amount*100) / 100
-
+func (r *transactionRepository) Deposit(transaction models.CreateTransactionRequest, userID int, actor models.AuditActor) (map[string]interface{}, error) {
 	var transactionID int
 	var generatedReferenceID string
 	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
-		generatedReferenceID = uuid.New().String()
+		// Lock the account and read its currency for the ledger postings
+		var balance string
+		var currency models.Currency
+		var accountStatus models.AccountStatus
+		var tier models.AccountTier
+		err := tx.QueryRow(`SELECT balance, currency, status, tier FROM accounts WHERE id = $1 AND user_id = $2 FOR UPDATE`, transaction.AccountID, userID).Scan(&balance, &currency, &accountStatus, &tier)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		if err := checkCanReceive(accountStatus); err != nil {
+			return err
+		}
+
+		currentBalance, err := models.ParseMoney(balance, currency)
+		if err != nil {
+			return err
+		}
 
+		depositAmount, err := transaction.Amount.Money(currency)
+		if err != nil {
+			return err
+		}
+
+		if err := checkLimits(tx, transaction.AccountID, userID, tier, models.Deposit, depositAmount); err != nil {
+			return err
+		}
+
+		generatedReferenceID = uuid.New().String()
 
 		query := `
-		INSERT INTO transactions (account_id, amount, transaction_type, status, created_at, updated_at, reference_id, user_id)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $5, $6)
+		INSERT INTO transactions (account_id, amount, transaction_type, status, direction, created_at, updated_at, reference_id, user_id)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $6, $7)
 		RETURNING id, account_id, amount, transaction_type, status, created_at, updated_at, reference_id
 		`
 
 		var (
 			accountID       int
-			amount         float64
+			amount         string
 			transactionType string
 			status         models.TransactionStatus
 			createdAt      time.Time
@@ -60,11 +96,12 @@ func (r *transactionRepository) Deposit(transaction models.CreateTransactionRequ
 			referenceID    string
 		)
 
-		err := tx.QueryRow(query, 
+		err = tx.QueryRow(query, 
 			transaction.AccountID, 
-			transaction.Amount, 
+			depositAmount, 
 			models.Deposit,
 			models.Completed,
+			models.Credit,
 			generatedReferenceID,
 			userID,
 			).Scan(
@@ -82,28 +119,30 @@ func (r *transactionRepository) Deposit(transaction models.CreateTransactionRequ
 			return fmt.Errorf("failed to create deposit transaction: %w", err)
 		}
 
-		// Update account balance
-		updateQuery := `
-		UPDATE accounts 
-		SET balance = balance + $1, 
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $2
-		`
-
-		result, err := tx.Exec(updateQuery, transaction.Amount, transaction.AccountID)
+		// Cash comes in from outside the bank and is credited to the account
+		_, err = postJournalEntry(tx, models.JournalEntry{
+			ReferenceID: generatedReferenceID,
+			EntryType:   string(models.Deposit),
+			Postings: []models.Posting{
+				systemPosting(models.CashIn, models.Debit, depositAmount),
+				accountPosting(transaction.AccountID, transactionID, models.Credit, depositAmount),
+			},
+		})
 		if err != nil {
-			return fmt.Errorf("failed to update account balance: %w", err)
+			return err
 		}
 
-		rows, err := result.RowsAffected()
+		newBalance, err := currentBalance.Add(depositAmount)
 		if err != nil {
-			return fmt.Errorf("failed to get affected rows: %w", err)
+			return err
 		}
-		if rows == 0 {
-			return fmt.Errorf("account not found")
+		if err := enqueueTransactionEvents(tx, models.EventTransactionCompleted, transactionID); err != nil {
+			return err
 		}
-
-		return nil
+		return recordAudit(tx, actor, models.AuditDeposit, "account", transaction.AccountID,
+			map[string]interface{}{"balance": currentBalance},
+			map[string]interface{}{"balance": newBalance, "amount": depositAmount, "transaction_id": transactionID, "reference_id": generatedReferenceID},
+		)
 	}, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -118,90 +157,675 @@ func (r *transactionRepository) Deposit(transaction models.CreateTransactionRequ
 	}, nil
 }
 
-func (r *transactionRepository) Withdraw(transaction models.CreateTransactionRequest, userID int) (map[string]interface{}, error) {
-	// Round amount to 2 decimal places
-	transaction.Amount = math.Round(transaction.Amount*100) / 100
+func (r *transactionRepository) Withdraw(transaction models.CreateTransactionRequest, userID int, actor models.AuditActor) (map[string]interface{}, error) {
+	var transactionID int
+	var generatedReferenceID string
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		transactionID, generatedReferenceID, err = withdrawFunds(tx, transaction, userID, actor)
+		return err
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+
+	return map[string]interface{}{
+		"transaction_id": transactionID,
+		"reference_id": generatedReferenceID,
+	}, nil
+}
 
+// withdrawFunds books a withdrawal from one of userID's accounts in tx and
+// returns the transaction and reference IDs.
+func withdrawFunds(tx *sql.Tx, transaction models.CreateTransactionRequest, userID int, actor models.AuditActor) (int, string, error) {
 	var transactionID int
 	var generatedReferenceID string
+
+	// First check if account has sufficient balance
+	var balance string
+	var currency models.Currency
+	var accountStatus models.AccountStatus
+	var tier models.AccountTier
+	balanceQuery := `SELECT balance, currency, status, tier FROM accounts WHERE id = $1 AND user_id = $2 FOR UPDATE`
+	err := tx.QueryRow(balanceQuery, transaction.AccountID, userID).Scan(&balance, &currency, &accountStatus, &tier)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrNotFound
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get account balance: %w", err)
+	}
+	if err := checkCanSend(accountStatus); err != nil {
+		return 0, "", err
+	}
+
+	currentBalance, err := models.ParseMoney(balance, currency)
+	if err != nil {
+		return 0, "", err
+	}
+
+	withdrawAmount, err := transaction.Amount.Money(currency)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Money reserved by holds is not available to withdraw
+	available, err := availableBalance(tx, transaction.AccountID, currentBalance)
+	if err != nil {
+		return 0, "", err
+	}
+	if available.Minor < withdrawAmount.Minor {
+		return 0, "", ErrInsufficientFunds
+	}
+
+	if err := checkLimits(tx, transaction.AccountID, userID, tier, models.Withdrawal, withdrawAmount); err != nil {
+		return 0, "", err
+	}
+
+	generatedReferenceID = uuid.New().String()
+
+	query := `
+	INSERT INTO transactions (account_id, amount, transaction_type, status, direction, created_at, updated_at, reference_id, user_id)
+	VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $6, $7)
+	RETURNING id, account_id, amount, transaction_type, status, created_at, updated_at, reference_id
+	`
+
+	var (
+		accountID       int
+		amount         string
+		transactionType string
+		status         models.TransactionStatus
+		createdAt      time.Time
+		updatedAt      time.Time
+		referenceID    string
+	)
+
+	err = tx.QueryRow(query, 
+		transaction.AccountID, 
+		withdrawAmount, 
+		models.Withdrawal,
+		models.Completed,
+		models.Debit,
+		generatedReferenceID,
+		userID,
+	).Scan(
+		&transactionID,
+		&accountID,
+		&amount,
+		&transactionType,
+		&status,
+		&createdAt,
+		&updatedAt,
+		&referenceID,
+	)
+
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create withdrawal transaction: %w", err)
+	}
+
+	// Money leaves the account and is paid out of the bank
+	_, err = postJournalEntry(tx, models.JournalEntry{
+		ReferenceID: generatedReferenceID,
+		EntryType:   string(models.Withdrawal),
+		Postings: []models.Posting{
+			accountPosting(transaction.AccountID, transactionID, models.Debit, withdrawAmount),
+			systemPosting(models.CashOut, models.Credit, withdrawAmount),
+		},
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	newBalance, err := currentBalance.Sub(withdrawAmount)
+	if err != nil {
+		return 0, "", err
+	}
+	if err := enqueueTransactionEvents(tx, models.EventTransactionCompleted, transactionID); err != nil {
+		return 0, "", err
+	}
+	return transactionID, generatedReferenceID, recordAudit(tx, actor, models.AuditWithdrawal, "account", transaction.AccountID,
+		map[string]interface{}{"balance": currentBalance},
+		map[string]interface{}{"balance": newBalance, "amount": withdrawAmount, "transaction_id": transactionID, "reference_id": generatedReferenceID},
+	)
+}
+
+func (r *transactionRepository) Transfer(transfer models.CreateTransferRequest, userID int, actor models.AuditActor) (map[string]interface{}, error) {
+	var debitTransactionID, creditTransactionID int
+	var generatedReferenceID string
 	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
-		// First check if account has sufficient balance
-		var currentBalance float64
-		balanceQuery := `SELECT balance FROM accounts WHERE id = $1 FOR UPDATE`
-		err := tx.QueryRow(balanceQuery, transaction.AccountID).Scan(&currentBalance)
+		var err error
+		debitTransactionID, creditTransactionID, generatedReferenceID, err = transferFunds(tx, transfer, userID, actor)
+		return err
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+
+	return map[string]interface{}{
+		"debit_transaction_id":  debitTransactionID,
+		"credit_transaction_id": creditTransactionID,
+		"reference_id":          generatedReferenceID,
+	}, nil
+}
+
+// transferFunds books a transfer out of one of userID's accounts in tx and
+// returns the debit and credit transaction IDs and the reference ID.
+func transferFunds(tx *sql.Tx, transfer models.CreateTransferRequest, userID int, actor models.AuditActor) (int, int, string, error) {
+	var debitTransactionID, creditTransactionID int
+	var generatedReferenceID string
+
+	type lockedAccount struct {
+		userID   int
+		balance  models.Money
+		status   models.AccountStatus
+		tier     models.AccountTier
+	}
+
+	// Lock both accounts in ascending ID order so that two transfers
+	// running in opposite directions between the same pair of accounts
+	// always wait on each other instead of deadlocking.
+	lockOrder := []int{transfer.AccountID, transfer.DestinationAccountID}
+	if lockOrder[1] < lockOrder[0] {
+		lockOrder[0], lockOrder[1] = lockOrder[1], lockOrder[0]
+	}
+
+	accounts := make(map[int]lockedAccount, 2)
+	for _, id := range lockOrder {
+		var account lockedAccount
+		var balance string
+		var currency models.Currency
+		err := tx.QueryRow(
+			`SELECT user_id, balance, currency, status, tier FROM accounts WHERE id = $1 FOR UPDATE`,
+			id,
+		).Scan(&account.userID, &balance, &currency, &account.status, &account.tier)
+		if err == sql.ErrNoRows {
+			return 0, 0, "", ErrNotFound
+		}
 		if err != nil {
-			return fmt.Errorf("failed to get account balance: %w", err)
+			return 0, 0, "", fmt.Errorf("failed to lock account %d: %w", id, err)
+		}
+		if account.balance, err = models.ParseMoney(balance, currency); err != nil {
+			return 0, 0, "", err
 		}
+		accounts[id] = account
+	}
+
+	source := accounts[transfer.AccountID]
+	destination := accounts[transfer.DestinationAccountID]
+
+	if source.userID != userID {
+		return 0, 0, "", ErrNotFound
+	}
+
+	if err := checkCanSend(source.status); err != nil {
+		return 0, 0, "", err
+	}
+	if err := checkCanReceive(destination.status); err != nil {
+		return 0, 0, "", err
+	}
+
+	transferAmount, err := transfer.Amount.Money(source.balance.Currency)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	available, err := availableBalance(tx, transfer.AccountID, source.balance)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if available.Minor < transferAmount.Minor {
+		return 0, 0, "", ErrInsufficientFunds
+	}
 
-		// Round current balance to 2 decimal places for comparison
-		currentBalance = math.Round(currentBalance*100) / 100
+	// Limits count what leaves the source account; the destination is
+	// not limited on what it receives
+	if err := checkLimits(tx, transfer.AccountID, userID, source.tier, models.Transfer, transferAmount); err != nil {
+		return 0, 0, "", err
+	}
 
-		if currentBalance < transaction.Amount {
-			return fmt.Errorf("insufficient funds")
+	// Between currencies the destination is credited the amount fixed
+	// by the caller's quote, and both legs record the rate used
+	creditAmount := transferAmount
+	var fxRate, fxSpread, fxQuoteID *string
+	if source.balance.Currency != destination.balance.Currency {
+		if transfer.QuoteID == "" {
+			return 0, 0, "", ErrFXQuoteRequired
 		}
 
-		generatedReferenceID = uuid.New().String()
+		quote, err := consumeFXQuote(tx, transfer.QuoteID, userID, transferAmount, destination.balance.Currency)
+		if err != nil {
+			return 0, 0, "", err
+		}
 
-		query := `
-		INSERT INTO transactions (account_id, amount, transaction_type, status, created_at, updated_at, reference_id, user_id)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $5, $6)
-		RETURNING id, account_id, amount, transaction_type, status, created_at, updated_at, reference_id
-		`
+		creditAmount = quote.DestinationAmount
+		fxRate, fxSpread, fxQuoteID = &quote.Rate, &quote.Spread, &quote.ID
+	}
 
-		var (
-			accountID       int
-			amount         float64
-			transactionType string
-			status         models.TransactionStatus
-			createdAt      time.Time
-			updatedAt      time.Time
-			referenceID    string
-		)
+	debitTransactionID, creditTransactionID, generatedReferenceID, err = bookTransfer(tx, transferBooking{
+		sourceAccountID:      transfer.AccountID,
+		sourceUserID:         source.userID,
+		destinationAccountID: transfer.DestinationAccountID,
+		destinationUserID:    destination.userID,
+		debit:                transferAmount,
+		credit:               creditAmount,
+		description:          transfer.Description,
+		fxRate:               fxRate,
+		fxSpread:             fxSpread,
+		fxQuoteID:            fxQuoteID,
+	})
+	if err != nil {
+		return 0, 0, "", err
+	}
 
-		err = tx.QueryRow(query, 
-			transaction.AccountID, 
-			transaction.Amount, 
-			models.Withdrawal,
-			models.Completed,
-			generatedReferenceID,
-			userID,
-		).Scan(
-			&transactionID,
-			&accountID,
-			&amount,
-			&transactionType,
-			&status,
-			&createdAt,
-			&updatedAt,
-			&referenceID,
+	after := map[string]interface{}{
+		"destination_account_id": transfer.DestinationAccountID,
+		"amount":                 transferAmount,
+		"credited_amount":        creditAmount,
+		"debit_transaction_id":   debitTransactionID,
+		"credit_transaction_id":  creditTransactionID,
+		"reference_id":           generatedReferenceID,
+	}
+	if fxQuoteID != nil {
+		after["fx_quote_id"] = *fxQuoteID
+	}
+	return debitTransactionID, creditTransactionID, generatedReferenceID, recordAudit(tx, actor, models.AuditTransfer, "account", transfer.AccountID,
+		map[string]interface{}{"balance": source.balance},
+		after,
+	)
+}
+
+// transferBooking describes the two legs of a transfer. The FX fields are
+// only set when the accounts hold different currencies.
+type transferBooking struct {
+	sourceAccountID      int
+	sourceUserID         int
+	destinationAccountID int
+	destinationUserID    int
+	debit                models.Money
+	credit               models.Money
+	description          string
+	fxRate               *string
+	fxSpread             *string
+	fxQuoteID            *string
+}
+
+// bookTransfer writes the debit and credit rows of a transfer and its journal
+// entry. Both accounts must already be locked and checked by the caller.
+func bookTransfer(tx *sql.Tx, b transferBooking) (int, int, string, error) {
+	referenceID := uuid.New().String()
+
+	query := `
+	INSERT INTO transactions (account_id, amount, transaction_type, status, direction, counterparty_account_id, description, created_at, updated_at, reference_id, user_id, fx_rate, fx_spread, fx_quote_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $8, $9, $10, $11, $12)
+	RETURNING id
+	`
+
+	// Debit leg on the source account
+	var debitTransactionID int
+	err := tx.QueryRow(query,
+		b.sourceAccountID,
+		b.debit,
+		models.Transfer,
+		models.Completed,
+		models.Debit,
+		b.destinationAccountID,
+		b.description,
+		referenceID,
+		b.sourceUserID,
+		b.fxRate,
+		b.fxSpread,
+		b.fxQuoteID,
+	).Scan(&debitTransactionID)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to create transfer debit transaction: %w", err)
+	}
+
+	// Credit leg on the destination account, recorded against the
+	// destination owner so it shows up in their own history
+	var creditTransactionID int
+	err = tx.QueryRow(query,
+		b.destinationAccountID,
+		b.credit,
+		models.Transfer,
+		models.Completed,
+		models.Credit,
+		b.sourceAccountID,
+		b.description,
+		referenceID,
+		b.destinationUserID,
+		b.fxRate,
+		b.fxSpread,
+		b.fxQuoteID,
+	).Scan(&creditTransactionID)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to create transfer credit transaction: %w", err)
+	}
+
+	postings := []models.Posting{
+		accountPosting(b.sourceAccountID, debitTransactionID, models.Debit, b.debit),
+		accountPosting(b.destinationAccountID, creditTransactionID, models.Credit, b.credit),
+	}
+	if b.fxQuoteID != nil {
+		// The bank's FX position buys the source currency and sells the
+		// destination currency, keeping each currency balanced
+		postings = append(postings,
+			systemPosting(models.FXPosition, models.Credit, b.debit),
+			systemPosting(models.FXPosition, models.Debit, b.credit),
 		)
+	}
+
+	_, err = postJournalEntry(tx, models.JournalEntry{
+		ReferenceID: referenceID,
+		EntryType:   string(models.Transfer),
+		Description: b.description,
+		Postings:    postings,
+	})
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	if err := enqueueTransactionEvents(tx, models.EventTransactionCompleted, debitTransactionID, creditTransactionID); err != nil {
+		return 0, 0, "", err
+	}
+
+	return debitTransactionID, creditTransactionID, referenceID, nil
+}
+
+// ReverseTransaction undoes a completed transaction and every other leg
+// booked under its reference. Each leg gets a compensating REVERSAL row in the
+// opposite direction for whatever has not been refunded yet, and the original
+// legs are marked reversed.
+func (r *transactionRepository) ReverseTransaction(transactionID int, reason string, actor models.AuditActor) (map[string]interface{}, error) {
+	return r.compensate(transactionID, nil, reason, actor)
+}
+
+// RefundTransaction gives back part of a completed transaction, booking a
+// REFUND row against every leg. The legs are marked partially_refunded, or
+// reversed once refunds add up to the whole amount.
+func (r *transactionRepository) RefundTransaction(transactionID int, amount models.Amount, reason string, actor models.AuditActor) (map[string]interface{}, error) {
+	return r.compensate(transactionID, &amount, reason, actor)
+}
 
+// compensate books the compensating rows for a reversal, when amount is nil,
+// or for a refund of amount. The journal entry of the original is posted
+// again with every side flipped, scaled down to the compensated amount.
+func (r *transactionRepository) compensate(transactionID int, amount *models.Amount, reason string, actor models.AuditActor) (map[string]interface{}, error) {
+	compensationType, action := models.Reversal, models.AuditReversal
+	if amount != nil {
+		compensationType, action = models.Refund, models.AuditRefund
+	}
+
+	var generatedReferenceID string
+	var compensated models.Money
+	compensationIDs := make([]int, 0, 2)
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var referenceID string
+		var transactionType models.TransactionType
+		var status models.TransactionStatus
+		err := tx.QueryRow(`
+			SELECT COALESCE(reference_id, 'legacy-' || id::text), transaction_type, status
+			FROM transactions
+			WHERE id = $1
+			FOR UPDATE
+		`, transactionID).Scan(&referenceID, &transactionType, &status)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
 		if err != nil {
-			return fmt.Errorf("failed to create withdrawal transaction: %w", err)
+			return fmt.Errorf("failed to lock transaction: %w", err)
 		}
 
-		// Update account balance
-		updateQuery := `
-		UPDATE accounts 
-		SET balance = balance - $1, 
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $2
-		`
+		if status == models.Reversed {
+			return ErrAlreadyReversed
+		}
+		if transactionType == models.Reversal || transactionType == models.Refund ||
+			(status != models.Completed && status != models.PartiallyRefunded) {
+			return ErrNotReversible
+		}
 
-		result, err := tx.Exec(updateQuery, transaction.Amount, transaction.AccountID)
+		type leg struct {
+			id                    int
+			accountID             int
+			userID                int
+			direction             models.TransactionDirection
+			counterpartyAccountID sql.NullInt64
+			amount                models.Money
+			refunded              models.Money
+		}
+
+		// Legacy rows have no reference and stand alone
+		rows, err := tx.Query(`
+			SELECT t.id, t.account_id, t.user_id, t.direction, t.counterparty_account_id, t.amount, t.refunded_amount, a.currency
+			FROM transactions t
+			JOIN accounts a ON a.id = t.account_id
+			WHERE t.reference_id = $1 OR t.id = $2
+			ORDER BY t.id
+			FOR UPDATE OF t
+		`, referenceID, transactionID)
 		if err != nil {
-			return fmt.Errorf("failed to update account balance: %w", err)
+			return fmt.Errorf("failed to lock transaction legs: %w", err)
+		}
+		var legs []leg
+		for rows.Next() {
+			var l leg
+			var legAmount, refunded string
+			var currency models.Currency
+			if err := rows.Scan(&l.id, &l.accountID, &l.userID, &l.direction, &l.counterpartyAccountID, &legAmount, &refunded, &currency); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan transaction leg: %w", err)
+			}
+			if l.amount, err = models.ParseMoney(legAmount, currency); err != nil {
+				rows.Close()
+				return err
+			}
+			if l.refunded, err = models.ParseMoney(refunded, currency); err != nil {
+				rows.Close()
+				return err
+			}
+			legs = append(legs, l)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating transaction legs: %w", err)
 		}
 
-		rows, err := result.RowsAffected()
+		entry, err := loadJournalEntry(tx, referenceID)
 		if err != nil {
-			return fmt.Errorf("failed to get affected rows: %w", err)
+			return fmt.Errorf("failed to load journal entry: %w", err)
+		}
+
+		// A partial amount can only be taken from an entry whose postings
+		// all move the same money; a transfer between currencies has two
+		// amounts that would each need rounding
+		uniform := true
+		for _, posting := range entry.Postings {
+			if posting.Amount != entry.Postings[0].Amount {
+				uniform = false
+			}
 		}
-		if rows == 0 {
-			return fmt.Errorf("account not found")
+		for _, l := range legs {
+			if l.refunded != legs[0].refunded {
+				uniform = false
+			}
 		}
 
-		return nil
+		var compensation models.Money
+		switch {
+		case amount == nil && legs[0].refunded.IsZero():
+			// A full reversal posts the original entry back, whatever its shape
+		case !uniform:
+			return ErrNotRefundable
+		default:
+			remaining, err := legs[0].amount.Sub(legs[0].refunded)
+			if err != nil {
+				return err
+			}
+			compensation = remaining
+			if amount != nil {
+				if compensation, err = amount.Money(remaining.Currency); err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+				}
+				if !compensation.IsPositive() {
+					return fmt.Errorf("%w: refund must be greater than 0", ErrInvalidAmount)
+				}
+				if cmp, err := compensation.Cmp(remaining); err != nil {
+					return err
+				} else if cmp > 0 {
+					return ErrRefundTooLarge
+				}
+			}
+		}
+
+		postings := make([]models.Posting, 0, len(entry.Postings))
+		for _, posting := range entry.Postings {
+			posting.Side = posting.Side.Opposite()
+			if !compensation.IsZero() {
+				posting.Amount = compensation
+			}
+			postings = append(postings, posting)
+		}
+
+		// Lock every account the compensation touches in ascending ID order,
+		// the same order Transfer uses
+		balances := make(map[int]models.Money)
+		var lockOrder []int
+		for _, posting := range postings {
+			if posting.AccountID == nil {
+				continue
+			}
+			if _, ok := balances[*posting.AccountID]; !ok {
+				balances[*posting.AccountID] = models.Money{}
+				lockOrder = append(lockOrder, *posting.AccountID)
+			}
+		}
+		sort.Ints(lockOrder)
+		for _, id := range lockOrder {
+			var balance string
+			var currency models.Currency
+			var accountStatus models.AccountStatus
+			err := tx.QueryRow(`SELECT balance, currency, status FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&balance, &currency, &accountStatus)
+			if err != nil {
+				return fmt.Errorf("failed to lock account %d: %w", id, err)
+			}
+			// Frozen and dormant accounts are not an obstacle to a reversal,
+			// but a closed account cannot move money again
+			if accountStatus == models.AccountClosed {
+				return ErrAccountClosed
+			}
+			if balances[id], err = models.ParseMoney(balance, currency); err != nil {
+				return err
+			}
+		}
+
+		// Money that was paid in has to still be there to take back out
+		for _, posting := range postings {
+			if posting.AccountID == nil || posting.Side != models.Debit {
+				continue
+			}
+			remaining, err := balances[*posting.AccountID].Sub(posting.Amount)
+			if err != nil {
+				return err
+			}
+			if remaining.Minor < 0 {
+				return ErrInsufficientFunds
+			}
+			balances[*posting.AccountID] = remaining
+		}
+
+		generatedReferenceID = uuid.New().String()
+
+		amounts := make(map[int]models.Money, len(legs))
+		for _, posting := range postings {
+			if posting.TransactionID != nil {
+				amounts[*posting.TransactionID] = posting.Amount
+			}
+		}
+
+		query := `
+		INSERT INTO transactions (account_id, amount, transaction_type, status, direction, counterparty_account_id, description, created_at, updated_at, reference_id, user_id, reversal_of_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $8, $9, $10)
+		RETURNING id
+		`
+
+		compensationOf := make(map[int]int, len(legs))
+		newStatus := models.Reversed
+		for _, l := range legs {
+			legAmount, ok := amounts[l.id]
+			if !ok {
+				return fmt.Errorf("transaction %d has no posting in journal entry %d", l.id, entry.ID)
+			}
+
+			var compensationID int
+			err := tx.QueryRow(query,
+				l.accountID,
+				legAmount,
+				compensationType,
+				models.Completed,
+				l.direction.Opposite(),
+				l.counterpartyAccountID,
+				reason,
+				generatedReferenceID,
+				l.userID,
+				l.id,
+			).Scan(&compensationID)
+			if err != nil {
+				return fmt.Errorf("failed to create %s transaction: %w", strings.ToLower(string(compensationType)), err)
+			}
+			compensationOf[l.id] = compensationID
+			compensationIDs = append(compensationIDs, compensationID)
+
+			refunded, err := l.refunded.Add(legAmount)
+			if err != nil {
+				return err
+			}
+			legStatus := models.Reversed
+			if refunded.Minor < l.amount.Minor {
+				legStatus = models.PartiallyRefunded
+			}
+			if l.id == transactionID {
+				newStatus, compensated = legStatus, legAmount
+			}
+
+			_, err = tx.Exec(`
+				UPDATE transactions
+				SET refunded_amount = $2, status = $3, updated_at = CURRENT_TIMESTAMP
+				WHERE id = $1
+			`, l.id, refunded, legStatus)
+			if err != nil {
+				return fmt.Errorf("failed to update transaction %d: %w", l.id, err)
+			}
+		}
+
+		for i, posting := range postings {
+			if posting.TransactionID != nil {
+				id := compensationOf[*posting.TransactionID]
+				postings[i].TransactionID = &id
+			}
+		}
+
+		_, err = postJournalEntry(tx, models.JournalEntry{
+			ReferenceID: generatedReferenceID,
+			EntryType:   string(compensationType),
+			Description: reason,
+			Postings:    postings,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := enqueueTransactionEvents(tx, models.EventTransactionCompleted, compensationIDs...); err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, action, "transaction", transactionID,
+			map[string]interface{}{"status": status},
+			map[string]interface{}{"status": newStatus, "amount": compensated, "reason": reason, "compensating_transaction_ids": compensationIDs, "reference_id": generatedReferenceID},
+		)
 	}, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -210,8 +834,13 @@ func (r *transactionRepository) Withdraw(transaction models.CreateTransactionReq
 		return map[string]interface{}{}, err
 	}
 
+	key := "reversal_transaction_ids"
+	if amount != nil {
+		key = "refund_transaction_ids"
+	}
 	return map[string]interface{}{
-		"transaction_id": transactionID,
+		key:            compensationIDs,
+		"amount":       compensated,
 		"reference_id": generatedReferenceID,
 	}, nil
 }
@@ -228,13 +857,13 @@ func buildTransactionFilterClause(filter *models.TransactionFilter, startParam i
 	if filter.MinAmount != nil {
 		paramCount++
 		conditions = append(conditions, fmt.Sprintf("amount >= $%d", paramCount))
-		args = append(args, *filter.MinAmount)
+		args = append(args, filter.MinAmount.String())
 	}
 
 	if filter.MaxAmount != nil {
 		paramCount++
 		conditions = append(conditions, fmt.Sprintf("amount <= $%d", paramCount))
-		args = append(args, *filter.MaxAmount)
+		args = append(args, filter.MaxAmount.String())
 	}
 
 	if filter.Type != nil {
@@ -261,6 +890,12 @@ func buildTransactionFilterClause(filter *models.TransactionFilter, startParam i
 		args = append(args, *filter.DateTo)
 	}
 
+	if filter.AccountID != nil {
+		paramCount++
+		conditions = append(conditions, fmt.Sprintf("account_id = $%d", paramCount))
+		args = append(args, *filter.AccountID)
+	}
+
 	if len(conditions) == 0 {
 		return "", nil, nil
 	}
@@ -270,7 +905,7 @@ func buildTransactionFilterClause(filter *models.TransactionFilter, startParam i
 
 func buildTransactionSortClause(sort *models.SortRequest) string {
 	if sort == nil || sort.Field == "" {
-		return " ORDER BY created_at DESC"
+		return " ORDER BY created_at DESC, id DESC"
 	}
 
 	// Define allowed fields for sorting
@@ -282,7 +917,7 @@ func buildTransactionSortClause(sort *models.SortRequest) string {
 	}
 
 	if !allowedFields[sort.Field] {
-		return " ORDER BY created_at DESC"
+		return " ORDER BY created_at DESC, id DESC"
 	}
 
 	direction := "ASC"
@@ -290,7 +925,81 @@ func buildTransactionSortClause(sort *models.SortRequest) string {
 		direction = "DESC"
 	}
 
-	return fmt.Sprintf(" ORDER BY %s %s", sort.Field, direction)
+	// id breaks ties so that pages never overlap or skip rows
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", sort.Field, direction, direction)
+}
+
+const transactionColumns = `id, account_id, amount, (SELECT currency FROM accounts WHERE accounts.id = transactions.account_id), transaction_type, status, direction, counterparty_account_id, COALESCE(description, ''), created_at, updated_at, reference_id, fx_rate::text, fx_spread::text, fx_quote_id::text, reversal_of_id, refunded_amount`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransaction(row rowScanner, t *models.Transaction) error {
+	var amount, refunded string
+	var counterpartyAccountID sql.NullInt64
+	var fxRate, fxSpread, fxQuoteID sql.NullString
+	var reversalOfID sql.NullInt64
+	if err := row.Scan(
+		&t.ID,
+		&t.AccountID,
+		&amount,
+		&t.Currency,
+		&t.Type,
+		&t.Status,
+		&t.Direction,
+		&counterpartyAccountID,
+		&t.Description,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+		&t.ReferenceID,
+		&fxRate,
+		&fxSpread,
+		&fxQuoteID,
+		&reversalOfID,
+		&refunded,
+	); err != nil {
+		return err
+	}
+
+	var err error
+	if t.Amount, err = models.ParseMoney(amount, t.Currency); err != nil {
+		return err
+	}
+	if t.RefundedAmount, err = models.ParseMoney(refunded, t.Currency); err != nil {
+		return err
+	}
+
+	if counterpartyAccountID.Valid {
+		id := int(counterpartyAccountID.Int64)
+		t.CounterpartyAccountID = &id
+	}
+
+	if fxRate.Valid {
+		rate, err := models.ParseRate(fxRate.String)
+		if err != nil {
+			return err
+		}
+		formatted := models.FormatRate(rate)
+		t.FXRate = &formatted
+	}
+	if fxSpread.Valid {
+		spread, ok := new(big.Rat).SetString(fxSpread.String)
+		if !ok {
+			return fmt.Errorf("invalid fx spread: %q", fxSpread.String)
+		}
+		formatted := models.FormatRate(spread)
+		t.FXSpread = &formatted
+	}
+	if fxQuoteID.Valid {
+		t.FXQuoteID = &fxQuoteID.String
+	}
+	if reversalOfID.Valid {
+		id := int(reversalOfID.Int64)
+		t.ReversalOfID = &id
+	}
+
+	return nil
 }
 
 func (r *transactionRepository) GetTransactions(
@@ -336,7 +1045,7 @@ func (r *transactionRepository) GetTransactions(
 		}
 
 		// Execute final query
-		query := "SELECT id, account_id, amount, transaction_type, status, created_at, updated_at, reference_id " + baseQuery
+		query := "SELECT " + transactionColumns + " " + baseQuery
 		rows, err := tx.Query(query, args...)
 		if err != nil {
 			return fmt.Errorf("failed to query transactions: %w", err)
@@ -347,16 +1056,7 @@ func (r *transactionRepository) GetTransactions(
 		transactions = make([]models.Transaction, 0)
 		for rows.Next() {
 			var t models.Transaction
-			if err := rows.Scan(
-				&t.ID,
-				&t.AccountID,
-				&t.Amount,
-				&t.Type,
-				&t.Status,
-				&t.CreatedAt,
-				&t.UpdatedAt,
-				&t.ReferenceID,
-			); err != nil {
+			if err := scanTransaction(rows, &t); err != nil {
 				return fmt.Errorf("failed to scan transaction: %w", err)
 			}
 			transactions = append(transactions, t)
@@ -389,26 +1089,101 @@ func (r *transactionRepository) GetTransactions(
 	return response, nil
 }
 
-func (r *transactionRepository) GetTransaction(transactionID int) (models.Transaction, error) {
+// GetTransactionsByCursor pages through a user's transactions newest first
+// using keyset pagination on (created_at, id). Unlike GetTransactions it
+// never skips or repeats rows when new transactions arrive, and it only
+// counts the total when asked to.
+func (r *transactionRepository) GetTransactionsByCursor(
+	userID int,
+	filter *models.TransactionFilter,
+	pagination *models.CursorPaginationRequest,
+) (*models.CursorPaginatedResponse[models.Transaction], error) {
+	var response *models.CursorPaginatedResponse[models.Transaction]
+
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		baseQuery := `FROM transactions WHERE user_id = $1`
+		args := []interface{}{userID}
+		paramCount := 1
+
+		whereClause, filterArgs, err := buildTransactionFilterClause(filter, paramCount)
+		if err != nil {
+			return err
+		}
+		baseQuery += whereClause
+		args = append(args, filterArgs...)
+		paramCount += len(filterArgs)
+
+		var totalRecords *int64
+		if pagination.IncludeTotal {
+			var total int64
+			if err := tx.QueryRow("SELECT COUNT(*) "+baseQuery, args...).Scan(&total); err != nil {
+				return fmt.Errorf("failed to get total count: %w", err)
+			}
+			totalRecords = &total
+		}
+
+		keyset, order, keysetArgs := keysetClause(pagination.Cursor, paramCount)
+		args = append(args, keysetArgs...)
+		paramCount += len(keysetArgs)
+
+		// Fetch one extra row to learn whether another page follows
+		query := "SELECT " + transactionColumns + " " + baseQuery + keyset + order + fmt.Sprintf(" LIMIT $%d", paramCount+1)
+		args = append(args, pagination.PageSize+1)
+
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query transactions: %w", err)
+		}
+		defer rows.Close()
+
+		transactions := make([]models.Transaction, 0, pagination.PageSize+1)
+		for rows.Next() {
+			var t models.Transaction
+			if err := scanTransaction(rows, &t); err != nil {
+				return fmt.Errorf("failed to scan transaction: %w", err)
+			}
+			transactions = append(transactions, t)
+		}
+
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("error iterating transactions: %w", err)
+		}
+
+		page, cursors := cursorPage(transactions, pagination, func(t models.Transaction) (time.Time, int) {
+			return t.CreatedAt, t.ID
+		})
+		cursors.TotalRecords = totalRecords
+
+		response = &models.CursorPaginatedResponse[models.Transaction]{
+			Data:       page,
+			Pagination: cursors,
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetTransaction returns the transaction only if it was posted to one of
+// userID's accounts.
+func (r *transactionRepository) GetTransaction(transactionID int, userID int) (models.Transaction, error) {
 	var transaction models.Transaction
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
 		query := `
-		SELECT id, account_id, amount, transaction_type, status, created_at, updated_at
+		SELECT ` + transactionColumns + `
 		FROM transactions
-		WHERE id = $1
+		WHERE id = $1 AND user_id = $2
 		`
 
-		err := tx.QueryRow(query, transactionID).Scan(
-			&transaction.ID,
-			&transaction.AccountID,
-			&transaction.Amount,
-			&transaction.Type,
-			&transaction.Status,
-			&transaction.CreatedAt,
-			&transaction.UpdatedAt,
-		)
-
-
+		err := scanTransaction(tx.QueryRow(query, transactionID, userID), &transaction)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
 		if err != nil {
 			return fmt.Errorf("failed to get transaction: %w", err)
 		}
@@ -421,7 +1196,7 @@ func (r *transactionRepository) GetTransaction(transactionID int) (models.Transa
 	}
 
 	if transaction.ID == 0 {
-		return models.Transaction{}, fmt.Errorf("transaction not found")
+		return models.Transaction{}, ErrNotFound
 	}
 
 	return transaction, nil
@@ -432,40 +1207,40 @@ func (r *transactionRepository) GetTransactionsForSOA(userID int, request models
 	var transactions []models.Transaction
 
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		accountQuery := `SELECT id FROM accounts WHERE user_id = $1`
+		args := []interface{}{userID}
+		paramCount := 1
+
+		if request.Currency != "" {
+			paramCount++
+			accountQuery += fmt.Sprintf(" AND currency = $%d", paramCount)
+			args = append(args, request.Currency)
+		}
+
 		query := `
-			SELECT t.id, t.account_id, t.amount, t.transaction_type, t.status, t.created_at, t.updated_at, t.reference_id
-			FROM transactions t
-			JOIN accounts a ON t.account_id = a.id
-			WHERE t.account_id IN (SELECT id FROM accounts WHERE user_id = $1)
-			AND t.created_at BETWEEN $2 AND $3
+			SELECT ` + transactionColumns + `
+			FROM transactions
+			WHERE account_id IN (` + accountQuery + `)
 		`
-		args := []interface{}{userID, request.StartDate, request.EndDate}
-		paramCount := 3
+
+		args = append(args, request.StartDate, request.EndDate)
+		query += fmt.Sprintf(" AND created_at BETWEEN $%d AND $%d", paramCount+1, paramCount+2)
+		paramCount += 2
 
 		if request.AccountID != 0 {
-			query += fmt.Sprintf(" AND t.account_id = $%d", paramCount+1)
-			args = append(args, request.AccountID)
 			paramCount++
+			query += fmt.Sprintf(" AND account_id = $%d", paramCount)
+			args = append(args, request.AccountID)
 		}
 
-		if request.ItemCount != 0 {
-			query += fmt.Sprintf(" LIMIT $%d", paramCount+1)
-			args = append(args, request.ItemCount)
-			paramCount++
-		}
+		query += " ORDER BY created_at DESC"
 
-		if request.Currency != "" {
-			query += fmt.Sprintf(" AND a.currency = $%d", paramCount+1)
-			args = append(args, request.Currency)
+		if request.ItemCount != 0 {
 			paramCount++
+			query += fmt.Sprintf(" LIMIT $%d", paramCount)
+			args = append(args, request.ItemCount)
 		}
 
-		query += " ORDER BY t.created_at DESC"
-
-		fmt.Println(query)
-		fmt.Println(args)
-		fmt.Println(paramCount)
-
 		rows, err := tx.Query(query, args...)
 		if err != nil {
 			return fmt.Errorf("failed to query transactions: %w", err)
@@ -476,16 +1251,7 @@ func (r *transactionRepository) GetTransactionsForSOA(userID int, request models
 		transactions = make([]models.Transaction, 0)
 		for rows.Next() {
 			var t models.Transaction
-			if err := rows.Scan(
-				&t.ID,
-				&t.AccountID,
-				&t.Amount,
-				&t.Type,
-				&t.Status,
-				&t.CreatedAt,
-				&t.UpdatedAt,
-				&t.ReferenceID,
-			); err != nil {
+			if err := scanTransaction(rows, &t); err != nil {
 				return fmt.Errorf("failed to scan transaction: %w", err)
 			}
 			transactions = append(transactions, t)
@@ -502,5 +1268,32 @@ func (r *transactionRepository) GetTransactionsForSOA(userID int, request models
 		return nil, fmt.Errorf("failed to get transactions for SOA: %w", err)
 	}
 
-	return transactions, nil
+	return groupCompensations(transactions), nil
+}
+
+// groupCompensations moves every reversal and refund on a statement to just
+// below the transaction it compensates, when that transaction is on the
+// statement too. Everything else keeps its order.
+func groupCompensations(transactions []models.Transaction) []models.Transaction {
+	present := make(map[int]bool, len(transactions))
+	for _, t := range transactions {
+		present[t.ID] = true
+	}
+
+	compensations := make(map[int][]models.Transaction)
+	for _, t := range transactions {
+		if t.ReversalOfID != nil && present[*t.ReversalOfID] {
+			compensations[*t.ReversalOfID] = append(compensations[*t.ReversalOfID], t)
+		}
+	}
+
+	grouped := make([]models.Transaction, 0, len(transactions))
+	for _, t := range transactions {
+		if t.ReversalOfID != nil && present[*t.ReversalOfID] {
+			continue
+		}
+		grouped = append(grouped, t)
+		grouped = append(grouped, compensations[t.ID]...)
+	}
+	return grouped
 }
\ No newline at end of file
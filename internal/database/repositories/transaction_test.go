@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"banking-system/internal/database/models"
+	"testing"
+)
+
+func TestGroupCompensations(t *testing.T) {
+	of := func(id int) *int { return &id }
+
+	// Newest first, as statements are queried
+	transactions := []models.Transaction{
+		{ID: 9, Type: models.Refund, ReversalOfID: of(5)},
+		{ID: 8, Type: models.Reversal, ReversalOfID: of(1)},
+		{ID: 7, Type: models.Deposit},
+		{ID: 6, Type: models.Refund, ReversalOfID: of(5)},
+		{ID: 5, Type: models.Withdrawal},
+		{ID: 4, Type: models.Deposit},
+	}
+
+	got := groupCompensations(transactions)
+
+	// 8 undoes a transaction that is not on the statement, so it stays put
+	want := []int{8, 7, 5, 9, 6, 4}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d transactions; got %d", len(want), len(got))
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Errorf("position %d: expected transaction %d; got %d", i, id, got[i].ID)
+		}
+	}
+}
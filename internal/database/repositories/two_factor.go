@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/totp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type TwoFactorRepository interface {
+	IsEnabled(userID int) (bool, error)
+	StartEnrollment(userID int, secret string) (string, error)
+	ConfirmEnrollment(userID int, code string, recoveryCodes []string, actor models.AuditActor) error
+	Disable(userID int, code string, actor models.AuditActor) error
+	VerifyCode(userID int, code string) error
+}
+
+type twoFactorRepository struct {
+	db database.Service
+}
+
+func NewTwoFactorRepository(db database.Service) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+// hashRecoveryCode hashes a recovery code for storage. Codes are matched
+// ignoring case and spaces.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *twoFactorRepository) IsEnabled(userID int) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(context.Background(), `SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read two-factor status: %w", err)
+	}
+	return enabled, nil
+}
+
+// StartEnrollment stores a new secret for the user, replacing one from an
+// enrolment that was never confirmed, and returns the user's email for the
+// authenticator label.
+func (r *twoFactorRepository) StartEnrollment(userID int, secret string) (string, error) {
+	var email string
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var enabled bool
+		err := tx.QueryRow(`SELECT email, totp_enabled_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&email, &enabled)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+		if enabled {
+			return ErrTwoFactorEnabled
+		}
+
+		_, err = tx.Exec(`UPDATE users SET totp_secret = $2, totp_last_step = 0 WHERE id = $1`, userID, secret)
+		if err != nil {
+			return fmt.Errorf("failed to store two-factor secret: %w", err)
+		}
+		return nil
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return email, err
+}
+
+// ConfirmEnrollment turns two-factor on once the user proves their
+// authenticator works, and replaces their recovery codes.
+func (r *twoFactorRepository) ConfirmEnrollment(userID int, code string, recoveryCodes []string, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var secret sql.NullString
+		var enabled bool
+		err := tx.QueryRow(`SELECT totp_secret, totp_enabled_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&secret, &enabled)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+		if enabled {
+			return ErrTwoFactorEnabled
+		}
+		if !secret.Valid {
+			return ErrTwoFactorNotEnrolled
+		}
+
+		step, ok := totp.Validate(secret.String, code, time.Now(), 0)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		_, err = tx.Exec(`UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2 WHERE id = $1`, userID, step)
+		if err != nil {
+			return fmt.Errorf("failed to turn on two-factor: %w", err)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to replace recovery codes: %w", err)
+		}
+		for _, recoveryCode := range recoveryCodes {
+			if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hashRecoveryCode(recoveryCode)); err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+
+		return recordAudit(tx, actor, models.AuditUserTwoFactorOn, "user", userID, nil, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// Disable turns two-factor off and drops the secret and recovery codes. It
+// takes a current code, or a recovery code for a lost authenticator.
+func (r *twoFactorRepository) Disable(userID int, code string, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		if err := verifySecondFactor(tx, userID, code, true); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to turn off two-factor: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditUserTwoFactorOff, "user", userID, nil, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// VerifyCode checks the second step of a login: a current code, or a
+// recovery code, which is used up.
+func (r *twoFactorRepository) VerifyCode(userID int, code string) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		return verifySecondFactor(tx, userID, code, true)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// verifySecondFactor checks code for a user with two-factor on, in tx. A
+// code is accepted once: its time step is remembered and older steps are
+// refused from then on. With allowRecovery, an unused recovery code is
+// accepted too and marked used.
+func verifySecondFactor(tx *sql.Tx, userID int, code string, allowRecovery bool) error {
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := tx.QueryRow(`SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&secret, &enabled, &lastStep)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	if !enabled || !secret.Valid {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := totp.Validate(secret.String, code, time.Now(), lastStep); ok {
+		if _, err := tx.Exec(`UPDATE users SET totp_last_step = $2 WHERE id = $1`, userID, step); err != nil {
+			return fmt.Errorf("failed to record two-factor code: %w", err)
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return ErrInvalidTwoFactorCode
+	}
+
+	result, err := tx.Exec(`
+		UPDATE recovery_codes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if used, _ := result.RowsAffected(); used == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
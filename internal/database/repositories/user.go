@@ -6,19 +6,25 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRepository interface {
-	CreateUser(user models.CreateUserRequest) (models.User, error)
+	CreateUser(user models.CreateUserRequest, actor models.AuditActor) (models.User, error)
 	GetUserByEmail(email string) (models.User, error)
-	UpdateUser(user models.UpdateUserRequest, id int) (models.User, error)
-	UpdateUserPassword(user models.UpdateUserPasswordRequest, id int) (models.User, error)
+	UpdateUser(user models.UpdateUserRequest, id int, actor models.AuditActor) (models.User, error)
+	UpdateUserPassword(user models.UpdateUserPasswordRequest, id int, actor models.AuditActor) (models.User, error)
 	GetUser(id int) (models.User, error)
 	ViewBalance(id int) (models.ViewBalanceResponse, error)
 	GetUserCount() (int, error)
+	GetUserRole(id int) (models.Role, error)
+	SetUserRole(id int, role models.Role, actor models.AuditActor) (models.UserDTO, error)
+	SearchUsers(search string, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.UserDTO], error)
 }
 
 type userRepository struct {
@@ -29,7 +35,7 @@ func NewUserRepository(db database.Service) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) CreateUser(user models.CreateUserRequest) (models.User, error) {
+func (r *userRepository) CreateUser(user models.CreateUserRequest, actor models.AuditActor) (models.User, error) {
 	var newUser models.User
 	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
 		query := `
@@ -43,7 +49,7 @@ func (r *userRepository) CreateUser(user models.CreateUserRequest) (models.User,
 			return err
 		}
 
-		return tx.QueryRow(query, 
+		err = tx.QueryRow(query, 
 			user.FirstName, 
 			user.LastName, 
 			user.Email, 
@@ -56,6 +62,11 @@ func (r *userRepository) CreateUser(user models.CreateUserRequest) (models.User,
 			&newUser.CreatedAt,
 			&newUser.UpdatedAt,
 		)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(tx, actor, models.AuditUserCreate, "user", newUser.ID, nil, auditProfile(newUser))
 	}, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -71,7 +82,7 @@ func (r *userRepository) GetUserByEmail(email string) (models.User, error) {
 	var user models.User
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
 		query := `
-		SELECT id, first_name, last_name, email, password, created_at, updated_at
+		SELECT id, first_name, last_name, email, password, role, created_at, updated_at
 		FROM users
 		WHERE email = $1
 		`
@@ -82,6 +93,7 @@ func (r *userRepository) GetUserByEmail(email string) (models.User, error) {
 			&user.LastName,
 			&user.Email,
 			&user.Password,
+			&user.Role,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -94,7 +106,7 @@ func (r *userRepository) GetUserByEmail(email string) (models.User, error) {
 	return user, nil
 }
 
-func (r *userRepository) UpdateUser(user models.UpdateUserRequest, id int) (models.User, error) {
+func (r *userRepository) UpdateUser(user models.UpdateUserRequest, id int, actor models.AuditActor) (models.User, error) {
 	var updatedUser models.User
 	var accountsJSON string
 	
@@ -117,6 +129,7 @@ func (r *userRepository) UpdateUser(user models.UpdateUserRequest, id int) (mode
 			}
 			return err
 		}
+		before := auditProfile(updatedUser)
 
 		// Build dynamic update query based on provided fields
 		query := "UPDATE users SET"
@@ -164,6 +177,10 @@ func (r *userRepository) UpdateUser(user models.UpdateUserRequest, id int) (mode
 			return err
 		}
 
+		if err := recordAudit(tx, actor, models.AuditUserUpdate, "user", id, before, auditProfile(updatedUser)); err != nil {
+			return err
+		}
+
 		// Get accounts
 		accountsQuery := `
 		SELECT COALESCE(
@@ -198,7 +215,7 @@ func (r *userRepository) UpdateUser(user models.UpdateUserRequest, id int) (mode
 	return updatedUser, nil
 }
 
-func (r *userRepository) UpdateUserPassword(user models.UpdateUserPasswordRequest, id int) (models.User, error) {
+func (r *userRepository) UpdateUserPassword(user models.UpdateUserPasswordRequest, id int, actor models.AuditActor) (models.User, error) {
 	var updatedUser models.User
 	var currentHashedPassword string
 	var accountsJSON string
@@ -219,6 +236,12 @@ func (r *userRepository) UpdateUserPassword(user models.UpdateUserPasswordReques
 			return fmt.Errorf("invalid old password")
 		}
 
+		// With two-factor on, a stolen password alone cannot change it
+		err = verifySecondFactor(tx, id, user.TOTPCode, false)
+		if err != nil && !errors.Is(err, ErrTwoFactorNotEnabled) {
+			return err
+		}
+
 		// Update with new password
 		updateQuery := `
 		UPDATE users
@@ -244,6 +267,21 @@ func (r *userRepository) UpdateUserPassword(user models.UpdateUserPasswordReques
 			return err
 		}
 
+		// Changing the password signs the user out everywhere
+		_, err = tx.Exec(`
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+		`, id)
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		// Only the fact of the change is recorded, never the hashes
+		if err := recordAudit(tx, actor, models.AuditUserPasswordChange, "user", id, nil, nil); err != nil {
+			return err
+		}
+
 		// Get accounts
 		accountsQuery := `
 		SELECT COALESCE(
@@ -285,7 +323,7 @@ func (r *userRepository) GetUser(id int) (models.User, error) {
 	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
 		// First query to get user info
 		userQuery := `
-		SELECT id, first_name, last_name, email, created_at, updated_at
+		SELECT id, first_name, last_name, email, role, created_at, updated_at
 		FROM users
 		WHERE id = $1`
 
@@ -294,6 +332,7 @@ func (r *userRepository) GetUser(id int) (models.User, error) {
 			&user.FirstName,
 			&user.LastName,
 			&user.Email,
+			&user.Role,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -372,9 +411,13 @@ func (r *userRepository) ViewBalance(id int) (models.ViewBalanceResponse, error)
 	}
 
 	// Calculate total balance per currency
-	balancesByCurrency := make(map[string]float64)
+	balancesByCurrency := make(map[string]models.Money)
 	for _, account := range userBalance.Accounts {
-		balancesByCurrency[account.Currency] += account.Balance
+		total, err := balancesByCurrency[account.Currency].Add(account.Balance)
+		if err != nil {
+			return models.ViewBalanceResponse{}, err
+		}
+		balancesByCurrency[account.Currency] = total
 	}
 	userBalance.BalancesByCurrency = balancesByCurrency
 
@@ -389,3 +432,131 @@ func (r *userRepository) GetUserCount() (int, error) {
 	}
 	return count, nil
 }
+
+func (r *userRepository) GetUserRole(id int) (models.Role, error) {
+	var role models.Role
+	err := r.db.QueryRow(context.Background(), "SELECT role FROM users WHERE id = $1", id).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+const userDTOColumns = `id, first_name, last_name, email, role, created_at, updated_at`
+
+func scanUserDTO(row rowScanner, user *models.UserDTO) error {
+	return row.Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+}
+
+func (r *userRepository) SetUserRole(id int, role models.Role, actor models.AuditActor) (models.UserDTO, error) {
+	var user models.UserDTO
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var previous models.Role
+		err := tx.QueryRow(`SELECT role FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		err = scanUserDTO(tx.QueryRow(`
+			UPDATE users
+			SET role = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING `+userDTOColumns,
+			id, role,
+		), &user)
+		if err != nil {
+			return fmt.Errorf("failed to update user role: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditUserRoleChange, "user", id,
+			map[string]interface{}{"role": previous},
+			map[string]interface{}{"role": user.Role},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	if err != nil {
+		return models.UserDTO{}, err
+	}
+
+	return user, nil
+}
+
+// auditProfile is the part of a user recorded in the audit log.
+func auditProfile(user models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+	}
+}
+
+// SearchUsers matches search against the email and full name, ignoring case.
+// An empty search lists every user.
+func (r *userRepository) SearchUsers(search string, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.UserDTO], error) {
+	baseQuery := `FROM users WHERE ($1 = '' OR email ILIKE $2 OR (first_name || ' ' || last_name) ILIKE $2)`
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search) + "%"
+	args := []interface{}{search, pattern}
+
+	var response *models.PaginatedResponse[models.UserDTO]
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		var totalRecords int64
+		if err := tx.QueryRow("SELECT COUNT(*) "+baseQuery, args...).Scan(&totalRecords); err != nil {
+			return fmt.Errorf("failed to count users: %w", err)
+		}
+
+		offset := (pagination.Page - 1) * pagination.PageSize
+		rows, err := tx.Query(
+			"SELECT "+userDTOColumns+" "+baseQuery+" ORDER BY id LIMIT $3 OFFSET $4",
+			append(args, pagination.PageSize, offset)...,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to query users: %w", err)
+		}
+		defer rows.Close()
+
+		users := make([]models.UserDTO, 0, pagination.PageSize)
+		for rows.Next() {
+			var user models.UserDTO
+			if err := scanUserDTO(rows, &user); err != nil {
+				return fmt.Errorf("failed to scan user: %w", err)
+			}
+			users = append(users, user)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating users: %w", err)
+		}
+
+		response = &models.PaginatedResponse[models.UserDTO]{
+			Data: users,
+			Pagination: models.PaginationResponse{
+				CurrentPage:  pagination.Page,
+				PageSize:     pagination.PageSize,
+				TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pagination.PageSize))),
+				TotalRecords: totalRecords,
+			},
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserTokenRepository stores the single-use tokens mailed to users. Callers
+// pass the SHA-256 hash of a token, never the token itself.
+type UserTokenRepository interface {
+	IsEmailVerified(userID int) (bool, error)
+	CreateEmailVerification(userID int, tokenHash string, ttl time.Duration) (string, error)
+	VerifyEmail(tokenHash string, actor models.AuditActor) error
+	CreatePasswordReset(email string, tokenHash string, ttl time.Duration) error
+	ResetPassword(tokenHash string, newPassword string, actor models.AuditActor) error
+}
+
+type userTokenRepository struct {
+	db database.Service
+}
+
+func NewUserTokenRepository(db database.Service) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) IsEmailVerified(userID int) (bool, error) {
+	var verified bool
+	err := r.db.QueryRow(context.Background(), `SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read email verification: %w", err)
+	}
+	return verified, nil
+}
+
+// issueUserToken stores a new token for userID, replacing the user's unused
+// tokens for the same purpose so only the latest one mailed works.
+func issueUserToken(tx *sql.Tx, userID int, purpose models.UserTokenPurpose, tokenHash string, ttl time.Duration) error {
+	_, err := tx.Exec(`DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to replace %s tokens: %w", purpose, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+	`, userID, purpose, tokenHash, ttl.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to store %s token: %w", purpose, err)
+	}
+	return nil
+}
+
+// useUserToken marks an unexpired, unused token for purpose as used and
+// returns its user. Expiry is set and checked on the database clock, so the
+// app server's time zone does not change a token's lifetime.
+func useUserToken(tx *sql.Tx, purpose models.UserTokenPurpose, tokenHash string) (int, error) {
+	var userID int
+	err := tx.QueryRow(`
+		UPDATE user_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, tokenHash, purpose).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidUserToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to use %s token: %w", purpose, err)
+	}
+	return userID, nil
+}
+
+// CreateEmailVerification stores a verification token for a user whose email
+// is not verified yet, and returns the address to mail it to.
+func (r *userTokenRepository) CreateEmailVerification(userID int, tokenHash string, ttl time.Duration) (string, error) {
+	var email string
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var verified bool
+		err := tx.QueryRow(`SELECT email, email_verified_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&email, &verified)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+		if verified {
+			return ErrEmailAlreadyVerified
+		}
+
+		return issueUserToken(tx, userID, models.TokenEmailVerification, tokenHash, ttl)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return email, err
+}
+
+func (r *userTokenRepository) VerifyEmail(tokenHash string, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		userID, err := useUserToken(tx, models.TokenEmailVerification, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to verify email: %w", err)
+		}
+
+		if actor.UserID == nil {
+			actor.UserID = &userID
+		}
+		return recordAudit(tx, actor, models.AuditUserEmailVerify, "user", userID, nil, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// CreatePasswordReset stores a reset token for the user with email. It
+// returns ErrNotFound when there is no such user; callers must not reveal
+// that to the requester.
+func (r *userTokenRepository) CreatePasswordReset(email string, tokenHash string, ttl time.Duration) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var userID int
+		err := tx.QueryRow(`SELECT id FROM users WHERE email = $1 FOR UPDATE`, email).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		return issueUserToken(tx, userID, models.TokenPasswordReset, tokenHash, ttl)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// ResetPassword sets a new password with a reset token. Like a password
+// change it signs the user out everywhere, and since the token was mailed
+// to the user it also proves they own the address and lifts any login
+// lockout.
+func (r *userTokenRepository) ResetPassword(tokenHash string, newPassword string, actor models.AuditActor) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash new password: %w", err)
+	}
+
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		userID, err := useUserToken(tx, models.TokenPasswordReset, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		var email string
+		err = tx.QueryRow(`
+			UPDATE users
+			SET password = $2, email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING email
+		`, userID, hashedPassword).Scan(&email)
+		if err != nil {
+			return fmt.Errorf("failed to reset password: %w", err)
+		}
+
+		if err := clearFailedLogins(tx, email); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		// Other reset links mailed earlier stop working too
+		_, err = tx.Exec(`DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, models.TokenPasswordReset)
+		if err != nil {
+			return fmt.Errorf("failed to delete reset tokens: %w", err)
+		}
+
+		if actor.UserID == nil {
+			actor.UserID = &userID
+		}
+		return recordAudit(tx, actor, models.AuditUserPasswordReset, "user", userID, nil, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
@@ -0,0 +1,414 @@
+package repositories
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// dead
+	webhookMaxAttempts = 10
+
+	// webhookRetryBase is the wait after the first failed attempt; it
+	// doubles after every further failure
+	webhookRetryBase = 30 * time.Second
+)
+
+// WebhookDispatch is a delivery claimed by the dispatcher, with what it
+// needs to send it. Attempt counts this attempt.
+type WebhookDispatch struct {
+	DeliveryID int64
+	Attempt    int
+	URL        string
+	Secret     string
+	Event      models.WebhookEvent
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(request models.CreateWebhookEndpointRequest, secret string, userID int, actor models.AuditActor) (models.WebhookEndpoint, error)
+	GetEndpoints(userID int) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(endpointID int, userID int, actor models.AuditActor) error
+	GetDeliveries(userID int, filter *models.WebhookDeliveryFilter, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.WebhookDelivery], error)
+	ReplayDelivery(deliveryID int64, userID int, actor models.AuditActor) (models.WebhookDelivery, error)
+	ClaimDueDeliveries(limit int, lease time.Duration) ([]WebhookDispatch, error)
+	RecordDeliveryAttempt(dispatch WebhookDispatch, responseStatus *int, deliveryErr error) error
+}
+
+type webhookRepository struct {
+	db database.Service
+}
+
+func NewWebhookRepository(db database.Service) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// enqueueWebhookEvent writes an event for userID to the outbox in tx, with a
+// pending delivery for each of the user's endpoints subscribed to it. Nothing
+// is written when no endpoint is subscribed.
+func enqueueWebhookEvent(tx *sql.Tx, userID int, eventType models.WebhookEventType, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		WITH subscribed AS (
+			SELECT id FROM webhook_endpoints
+			WHERE user_id = $1 AND (jsonb_array_length(event_types) = 0 OR event_types @> jsonb_build_array($2::text))
+		), event AS (
+			INSERT INTO webhook_events (user_id, event_type, payload)
+			SELECT $1, $2, $3 WHERE EXISTS (SELECT 1 FROM subscribed)
+			RETURNING id
+		)
+		INSERT INTO webhook_deliveries (event_id, endpoint_id)
+		SELECT event.id, subscribed.id FROM event, subscribed
+	`, userID, eventType, string(payload))
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+	return nil
+}
+
+// enqueueTransactionEvents enqueues an event for each transaction, sent to
+// the owner of the transaction's account. The transactions are read back
+// from tx, so the event shows them as they will be committed.
+func enqueueTransactionEvents(tx *sql.Tx, eventType models.WebhookEventType, transactionIDs ...int) error {
+	for _, id := range transactionIDs {
+		var transaction models.Transaction
+		if err := scanTransaction(tx.QueryRow(`SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, id), &transaction); err != nil {
+			return fmt.Errorf("failed to read transaction %d for webhook event: %w", id, err)
+		}
+
+		var ownerID int
+		if err := tx.QueryRow(`SELECT user_id FROM accounts WHERE id = $1`, transaction.AccountID).Scan(&ownerID); err != nil {
+			return fmt.Errorf("failed to read owner of account %d: %w", transaction.AccountID, err)
+		}
+
+		if err := enqueueWebhookEvent(tx, ownerID, eventType, transaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookRetryDelay is the wait after the attempt-th failed attempt.
+func webhookRetryDelay(attempt int) time.Duration {
+	return webhookRetryBase << (attempt - 1)
+}
+
+const webhookEndpointColumns = `id, url, event_types::text, COALESCE(description, ''), created_at, updated_at`
+
+func scanWebhookEndpoint(row rowScanner, endpoint *models.WebhookEndpoint) error {
+	var eventTypes string
+	if err := row.Scan(&endpoint.ID, &endpoint.URL, &eventTypes, &endpoint.Description, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(eventTypes), &endpoint.EventTypes)
+}
+
+const webhookDeliveryColumns = `webhook_deliveries.id, webhook_deliveries.event_id, webhook_events.event_type, webhook_deliveries.endpoint_id, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.last_attempt_at, webhook_deliveries.response_status, COALESCE(webhook_deliveries.last_error, ''), webhook_deliveries.created_at, webhook_deliveries.updated_at`
+
+const webhookDeliveryFrom = ` FROM webhook_deliveries
+	JOIN webhook_events ON webhook_events.id = webhook_deliveries.event_id
+	JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id`
+
+func scanWebhookDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+	var responseStatus sql.NullInt64
+	if err := row.Scan(
+		&delivery.ID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.EndpointID,
+		&delivery.Status,
+		&delivery.Attempts,
+		&nextAttemptAt,
+		&lastAttemptAt,
+		&responseStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	); err != nil {
+		return err
+	}
+
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		delivery.LastAttemptAt = &lastAttemptAt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	return nil
+}
+
+func (r *webhookRepository) CreateEndpoint(request models.CreateWebhookEndpointRequest, secret string, userID int, actor models.AuditActor) (models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		eventTypes := request.EventTypes
+		if eventTypes == nil {
+			eventTypes = []models.WebhookEventType{}
+		}
+		encoded, err := json.Marshal(eventTypes)
+		if err != nil {
+			return fmt.Errorf("failed to encode event types: %w", err)
+		}
+
+		err = scanWebhookEndpoint(tx.QueryRow(`
+			INSERT INTO webhook_endpoints (user_id, url, secret, event_types, description)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			RETURNING `+webhookEndpointColumns,
+			userID, request.URL, secret, string(encoded), request.Description,
+		), &endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook endpoint: %w", err)
+		}
+
+		// The secret stays out of the audit log
+		return recordAudit(tx, actor, models.AuditWebhookCreate, "webhook_endpoint", endpoint.ID, nil, endpoint)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	endpoint.Secret = secret
+	return endpoint, err
+}
+
+func (r *webhookRepository) GetEndpoints(userID int) ([]models.WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(context.Background(), `SELECT `+webhookEndpointColumns+` FROM webhook_endpoints WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make([]models.WebhookEndpoint, 0)
+	for rows.Next() {
+		var endpoint models.WebhookEndpoint
+		if err := scanWebhookEndpoint(rows, &endpoint); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes one of userID's endpoints together with its
+// deliveries, pending ones included.
+func (r *webhookRepository) DeleteEndpoint(endpointID int, userID int, actor models.AuditActor) error {
+	return r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var endpoint models.WebhookEndpoint
+		err := scanWebhookEndpoint(tx.QueryRow(`DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2 RETURNING `+webhookEndpointColumns, endpointID, userID), &endpoint)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditWebhookDelete, "webhook_endpoint", endpointID, endpoint, nil)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+}
+
+// GetDeliveries lists the deliveries to userID's endpoints, newest first.
+func (r *webhookRepository) GetDeliveries(userID int, filter *models.WebhookDeliveryFilter, pagination *models.PaginationRequest) (*models.PaginatedResponse[models.WebhookDelivery], error) {
+	conditions := []string{"webhook_endpoints.user_id = $1"}
+	args := []interface{}{userID}
+	if filter != nil && filter.EndpointID != nil {
+		args = append(args, *filter.EndpointID)
+		conditions = append(conditions, fmt.Sprintf("webhook_deliveries.endpoint_id = $%d", len(args)))
+	}
+	if filter != nil && filter.Status != nil {
+		args = append(args, *filter.Status)
+		conditions = append(conditions, fmt.Sprintf("webhook_deliveries.status = $%d", len(args)))
+	}
+	baseQuery := webhookDeliveryFrom + " WHERE " + strings.Join(conditions, " AND ")
+
+	var response *models.PaginatedResponse[models.WebhookDelivery]
+	err := r.db.ExecTxReadOnly(context.Background(), func(tx *sql.Tx) error {
+		var totalRecords int64
+		if err := tx.QueryRow("SELECT COUNT(*) "+baseQuery, args...).Scan(&totalRecords); err != nil {
+			return fmt.Errorf("failed to count webhook deliveries: %w", err)
+		}
+
+		offset := (pagination.Page - 1) * pagination.PageSize
+		query := "SELECT " + webhookDeliveryColumns + baseQuery +
+			fmt.Sprintf(" ORDER BY webhook_deliveries.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		rows, err := tx.Query(query, append(args, pagination.PageSize, offset)...)
+		if err != nil {
+			return fmt.Errorf("failed to query webhook deliveries: %w", err)
+		}
+		defer rows.Close()
+
+		deliveries := make([]models.WebhookDelivery, 0, pagination.PageSize)
+		for rows.Next() {
+			var delivery models.WebhookDelivery
+			if err := scanWebhookDelivery(rows, &delivery); err != nil {
+				return fmt.Errorf("failed to scan webhook delivery: %w", err)
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating webhook deliveries: %w", err)
+		}
+
+		response = &models.PaginatedResponse[models.WebhookDelivery]{
+			Data: deliveries,
+			Pagination: models.PaginationResponse{
+				CurrentPage:  pagination.Page,
+				PageSize:     pagination.PageSize,
+				TotalPages:   int(math.Ceil(float64(totalRecords) / float64(pagination.PageSize))),
+				TotalRecords: totalRecords,
+			},
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ReplayDelivery sends a delivery to one of userID's endpoints again, with a
+// fresh set of attempts, whatever its status.
+func (r *webhookRepository) ReplayDelivery(deliveryID int64, userID int, actor models.AuditActor) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.ExecTx(context.Background(), func(tx *sql.Tx) error {
+		var previous models.WebhookDeliveryStatus
+		err := tx.QueryRow(`
+			SELECT webhook_deliveries.status`+webhookDeliveryFrom+`
+			WHERE webhook_deliveries.id = $1 AND webhook_endpoints.user_id = $2
+			FOR UPDATE OF webhook_deliveries
+		`, deliveryID, userID).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock webhook delivery: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			UPDATE webhook_deliveries
+			SET status = $2, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL, response_status = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, deliveryID, models.DeliveryPending)
+		if err != nil {
+			return fmt.Errorf("failed to replay webhook delivery: %w", err)
+		}
+
+		if err := scanWebhookDelivery(tx.QueryRow(`SELECT `+webhookDeliveryColumns+webhookDeliveryFrom+` WHERE webhook_deliveries.id = $1`, deliveryID), &delivery); err != nil {
+			return fmt.Errorf("failed to read webhook delivery: %w", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditWebhookReplay, "webhook_delivery", deliveryID,
+			map[string]interface{}{"status": previous},
+			map[string]interface{}{"status": models.DeliveryPending},
+		)
+	}, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+
+	return delivery, err
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries to the caller. Each
+// claim counts as an attempt and pushes the next attempt lease into the
+// future, so a dispatcher that dies mid-delivery leaves the delivery to be
+// retried once the lease runs out. SKIP LOCKED keeps dispatchers on several
+// replicas from claiming the same delivery.
+func (r *webhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]WebhookDispatch, error) {
+	now := time.Now().UTC()
+	rows, err := r.db.QueryContext(context.Background(), `
+		UPDATE webhook_deliveries
+		SET attempts = webhook_deliveries.attempts + 1, last_attempt_at = $1, next_attempt_at = $2, updated_at = CURRENT_TIMESTAMP
+		FROM webhook_endpoints, webhook_events
+		WHERE webhook_deliveries.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+			AND webhook_endpoints.id = webhook_deliveries.endpoint_id
+			AND webhook_events.id = webhook_deliveries.event_id
+		RETURNING webhook_deliveries.id, webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret,
+			webhook_events.id, webhook_events.event_type, webhook_events.created_at, webhook_events.payload::text
+	`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var dispatches []WebhookDispatch
+	for rows.Next() {
+		var dispatch WebhookDispatch
+		var payload string
+		if err := rows.Scan(
+			&dispatch.DeliveryID,
+			&dispatch.Attempt,
+			&dispatch.URL,
+			&dispatch.Secret,
+			&dispatch.Event.ID,
+			&dispatch.Event.Type,
+			&dispatch.Event.CreatedAt,
+			&payload,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		dispatch.Event.Data = json.RawMessage(payload)
+		dispatches = append(dispatches, dispatch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return dispatches, nil
+}
+
+// RecordDeliveryAttempt stores the outcome of a claimed delivery. A failed
+// delivery is retried with backoff until it has been tried
+// webhookMaxAttempts times, and is dead after that. The outcome is dropped
+// if the delivery was replayed or claimed again in the meantime.
+func (r *webhookRepository) RecordDeliveryAttempt(dispatch WebhookDispatch, responseStatus *int, deliveryErr error) error {
+	status := models.DeliverySucceeded
+	var nextAttemptAt *time.Time
+	lastError := ""
+	if deliveryErr != nil {
+		lastError = deliveryErr.Error()
+		status = models.DeliveryDead
+		if dispatch.Attempt < webhookMaxAttempts {
+			next := time.Now().UTC().Add(webhookRetryDelay(dispatch.Attempt))
+			status, nextAttemptAt = models.DeliveryPending, &next
+		}
+	}
+
+	_, err := r.db.Exec(context.Background(), `
+		UPDATE webhook_deliveries
+		SET status = $3, next_attempt_at = $4, response_status = $5, last_error = NULLIF($6, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND attempts = $2 AND status = 'pending'
+	`, dispatch.DeliveryID, dispatch.Attempt, status, nextAttemptAt, responseStatus, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{webhookMaxAttempts - 1, 30 * time.Second << (webhookMaxAttempts - 2)},
+	}
+
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempt); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %s; want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package fx
+
+import (
+	"banking-system/internal/database/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rateFile is the format read by NewFileRateProvider:
+//
+//	{"base": "USD", "as_of": "2024-03-01T00:00:00Z", "rates": {"EUR": "0.92", "GBP": "0.79"}}
+//
+// Every rate is the price of one unit of base in the keyed currency.
+type rateFile struct {
+	Base  models.Currency   `json:"base"`
+	AsOf  time.Time         `json:"as_of"`
+	Rates map[string]string `json:"rates"`
+}
+
+// NewFileRateProvider loads a fixed set of rates from a JSON file. It is
+// meant for local development and tests, where no rate feed is available.
+func NewFileRateProvider(path string) (FXRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %w", err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("rates file has no base currency")
+	}
+
+	asOf := file.AsOf
+	if asOf.IsZero() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		asOf = info.ModTime()
+	}
+
+	quotes := make([]string, 0, len(file.Rates))
+	for quote := range file.Rates {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+
+	rates := make([]models.ExchangeRate, 0, len(quotes))
+	for _, quote := range quotes {
+		rates = append(rates, models.ExchangeRate{
+			Base:  models.Currency(strings.ToUpper(string(file.Base))),
+			Quote: models.Currency(strings.ToUpper(quote)),
+			Rate:  file.Rates[quote],
+			AsOf:  asOf,
+		})
+	}
+
+	table, err := NewRateTable(rates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rates file: %w", err)
+	}
+	return table, nil
+}
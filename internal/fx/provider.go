@@ -0,0 +1,126 @@
+// Package fx prices currency conversions. Rates come from an FXRateProvider;
+// customer-facing quotes add a spread on top of the mid-market rate.
+package fx
+
+import (
+	"banking-system/internal/database/models"
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrRateUnavailable is returned when no rate is known for a currency pair.
+	ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+	// ErrAmountTooSmall is returned when an amount converts to zero.
+	ErrAmountTooSmall = errors.New("amount is too small to convert")
+)
+
+// FXRateProvider returns mid-market exchange rates.
+type FXRateProvider interface {
+	// Rate returns the price of one unit of base in quote.
+	Rate(ctx context.Context, base models.Currency, quote models.Currency) (models.ExchangeRate, error)
+}
+
+type edge struct {
+	rate *big.Rat
+	asOf time.Time
+}
+
+// RateTable answers rate lookups from a set of published pairs. A pair can be
+// used in either direction, and a missing pair is crossed through a single
+// intermediate currency, e.g. EUR/GBP through USD/EUR and USD/GBP.
+type RateTable struct {
+	edges map[models.Currency]map[models.Currency]edge
+}
+
+// NewRateTable builds a table from published rates. Every rate must be a
+// positive decimal between two supported currencies.
+func NewRateTable(rates []models.ExchangeRate) (*RateTable, error) {
+	table := &RateTable{edges: make(map[models.Currency]map[models.Currency]edge)}
+
+	parsed := make([]*big.Rat, len(rates))
+	for i, r := range rates {
+		if _, err := r.Base.MinorUnits(); err != nil {
+			return nil, err
+		}
+		if _, err := r.Quote.MinorUnits(); err != nil {
+			return nil, err
+		}
+
+		rate, err := models.ParseRate(r.Rate)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = rate
+	}
+
+	// Inverses first, so a directly published pair always overwrites the
+	// inverse of its counterpart
+	for i, r := range rates {
+		table.add(r.Quote, r.Base, edge{rate: new(big.Rat).Inv(parsed[i]), asOf: r.AsOf})
+	}
+	for i, r := range rates {
+		table.add(r.Base, r.Quote, edge{rate: parsed[i], asOf: r.AsOf})
+	}
+
+	return table, nil
+}
+
+func (t *RateTable) add(base models.Currency, quote models.Currency, e edge) {
+	if t.edges[base] == nil {
+		t.edges[base] = make(map[models.Currency]edge)
+	}
+	t.edges[base][quote] = e
+}
+
+// Rate implements FXRateProvider.
+func (t *RateTable) Rate(ctx context.Context, base models.Currency, quote models.Currency) (models.ExchangeRate, error) {
+	rate, asOf, err := t.lookup(base, quote)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	return models.ExchangeRate{
+		Base:  base,
+		Quote: quote,
+		Rate:  models.FormatRate(models.RoundRate(rate)),
+		AsOf:  asOf,
+	}, nil
+}
+
+func (t *RateTable) lookup(base models.Currency, quote models.Currency) (*big.Rat, time.Time, error) {
+	if base == quote {
+		return big.NewRat(1, 1), time.Now(), nil
+	}
+
+	if e, ok := t.edges[base][quote]; ok {
+		return e.rate, e.asOf, nil
+	}
+
+	// Cross through the first intermediate currency, in a stable order
+	pivots := make([]string, 0, len(t.edges[base]))
+	for pivot := range t.edges[base] {
+		pivots = append(pivots, string(pivot))
+	}
+	sort.Strings(pivots)
+
+	for _, pivot := range pivots {
+		first := t.edges[base][models.Currency(pivot)]
+		second, ok := t.edges[models.Currency(pivot)][quote]
+		if !ok {
+			continue
+		}
+
+		asOf := first.asOf
+		if second.asOf.Before(asOf) {
+			asOf = second.asOf
+		}
+		return new(big.Rat).Mul(first.rate, second.rate), asOf, nil
+	}
+
+	return nil, time.Time{}, ErrRateUnavailable
+}
@@ -0,0 +1,79 @@
+package fx
+
+import (
+	"banking-system/internal/database/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateTable(t *testing.T) {
+	asOf := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	table, err := NewRateTable([]models.ExchangeRate{
+		{Base: models.USD, Quote: models.EUR, Rate: "0.8", AsOf: asOf},
+		{Base: models.USD, Quote: models.GBP, Rate: "0.5", AsOf: asOf},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		base  models.Currency
+		quote models.Currency
+		want  string
+	}{
+		{models.USD, models.EUR, "0.8"},
+		{models.EUR, models.USD, "1.25"},
+		{models.EUR, models.GBP, "0.625"},
+		{models.GBP, models.EUR, "1.6"},
+		{models.GBP, models.GBP, "1"},
+	}
+
+	for _, tt := range tests {
+		got, err := table.Rate(context.Background(), tt.base, tt.quote)
+		if err != nil {
+			t.Errorf("Rate(%s, %s) unexpected error: %v", tt.base, tt.quote, err)
+			continue
+		}
+		if got.Rate != tt.want {
+			t.Errorf("Rate(%s, %s) = %s; want %s", tt.base, tt.quote, got.Rate, tt.want)
+		}
+	}
+
+	empty, _ := NewRateTable(nil)
+	if _, err := empty.Rate(context.Background(), models.USD, models.EUR); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("expected ErrRateUnavailable; got %v", err)
+	}
+}
+
+func TestQuoterAppliesSpread(t *testing.T) {
+	table, err := NewRateTable([]models.ExchangeRate{{Base: models.USD, Quote: models.EUR, Rate: "0.92"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quoter := &Quoter{Provider: table, TTL: time.Minute}
+	quoter.Spread, _ = models.ParseRate("0.01")
+
+	quote, err := quoter.Quote(context.Background(), models.NewMoney(10000, models.USD), models.EUR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.MidRate != "0.92" || quote.Rate != "0.9108" || quote.Spread != "0.01" {
+		t.Errorf("got mid %s, rate %s, spread %s; want 0.92, 0.9108, 0.01", quote.MidRate, quote.Rate, quote.Spread)
+	}
+	if quote.DestinationAmount.Minor != 9108 || quote.DestinationAmount.Currency != models.EUR {
+		t.Errorf("destination amount = %s; want 91.08 EUR", quote.DestinationAmount.Format())
+	}
+	if !quote.ExpiresAt.After(quote.CreatedAt) {
+		t.Errorf("quote expires at %s, before it was created at %s", quote.ExpiresAt, quote.CreatedAt)
+	}
+	if quote.CreatedAt.Location() != time.UTC || quote.ExpiresAt.Location() != time.UTC {
+		t.Errorf("quote times are in %s and %s; want UTC for the TIMESTAMP columns", quote.CreatedAt.Location(), quote.ExpiresAt.Location())
+	}
+
+	if _, err := quoter.Quote(context.Background(), models.NewMoney(1, models.USD), models.EUR); !errors.Is(err, ErrAmountTooSmall) {
+		t.Errorf("expected ErrAmountTooSmall for 0.01 USD; got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package fx
+
+import (
+	"banking-system/internal/database/models"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"time"
+)
+
+const (
+	defaultSpread   = "0.005"
+	defaultQuoteTTL = 30 * time.Second
+)
+
+// Quoter prices customer conversions. The customer rate is the mid-market
+// rate reduced by Spread, a fraction such as 0.005 for 0.5%.
+type Quoter struct {
+	Provider FXRateProvider
+	Spread   *big.Rat
+	TTL      time.Duration
+}
+
+// NewQuoter reads the spread from FX_SPREAD and the quote lifetime from
+// FX_QUOTE_TTL, falling back to 0.5% and 30 seconds when either is unset or
+// invalid.
+func NewQuoter(provider FXRateProvider) *Quoter {
+	spread, _ := new(big.Rat).SetString(defaultSpread)
+	if value := os.Getenv("FX_SPREAD"); value != "" {
+		parsed, ok := new(big.Rat).SetString(value)
+		if ok && parsed.Sign() >= 0 && parsed.Cmp(big.NewRat(1, 1)) < 0 {
+			spread = parsed
+		} else {
+			log.Printf("Invalid FX_SPREAD %q, using %s", value, defaultSpread)
+		}
+	}
+
+	ttl := defaultQuoteTTL
+	if value := os.Getenv("FX_QUOTE_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err == nil && parsed > 0 {
+			ttl = parsed
+		} else {
+			log.Printf("Invalid FX_QUOTE_TTL %q, using %s", value, defaultQuoteTTL)
+		}
+	}
+
+	return &Quoter{Provider: provider, Spread: spread, TTL: ttl}
+}
+
+// Quote prices the conversion of amount into the target currency. The
+// returned quote has no ID; it is assigned when the quote is stored.
+func (q *Quoter) Quote(ctx context.Context, amount models.Money, to models.Currency) (models.FXQuote, error) {
+	if amount.Currency == to {
+		return models.FXQuote{}, fmt.Errorf("cannot quote a conversion from %s to itself", to)
+	}
+
+	mid, err := q.Provider.Rate(ctx, amount.Currency, to)
+	if err != nil {
+		return models.FXQuote{}, err
+	}
+	midRate, err := models.ParseRate(mid.Rate)
+	if err != nil {
+		return models.FXQuote{}, err
+	}
+
+	discount := new(big.Rat).Sub(big.NewRat(1, 1), q.Spread)
+	rate := models.RoundRate(new(big.Rat).Mul(midRate, discount))
+
+	converted, err := amount.Convert(to, rate)
+	if err != nil {
+		return models.FXQuote{}, err
+	}
+	if !converted.IsPositive() {
+		return models.FXQuote{}, ErrAmountTooSmall
+	}
+
+	// fx_quotes stores TIMESTAMP columns, which drop the zone
+	now := time.Now().UTC()
+	return models.FXQuote{
+		FromCurrency:      amount.Currency,
+		ToCurrency:        to,
+		MidRate:           mid.Rate,
+		Spread:            models.FormatRate(q.Spread),
+		Rate:              models.FormatRate(rate),
+		SourceAmount:      amount,
+		DestinationAmount: converted,
+		ExpiresAt:         now.Add(q.TTL),
+		CreatedAt:         now,
+	}, nil
+}
+
+// ConvertTotal sums balances held in several currencies into one, at
+// mid-market rates. It also returns the rate used for each currency.
+func ConvertTotal(ctx context.Context, provider FXRateProvider, balances map[string]models.Money, to models.Currency) (models.ConvertedTotal, error) {
+	total := models.ConvertedTotal{
+		Currency: to,
+		Amount:   models.NewMoney(0, to),
+		Rates:    make(map[string]string, len(balances)),
+	}
+
+	for currency, balance := range balances {
+		mid, err := provider.Rate(ctx, balance.Currency, to)
+		if err != nil {
+			return models.ConvertedTotal{}, err
+		}
+		rate, err := models.ParseRate(mid.Rate)
+		if err != nil {
+			return models.ConvertedTotal{}, err
+		}
+
+		converted, err := balance.Convert(to, rate)
+		if err != nil {
+			return models.ConvertedTotal{}, err
+		}
+		if total.Amount, err = total.Amount.Add(converted); err != nil {
+			return models.ConvertedTotal{}, err
+		}
+		total.Rates[currency] = mid.Rate
+	}
+
+	return total, nil
+}
@@ -0,0 +1,180 @@
+// Package mailer sends outbound email. Production uses SMTP; local
+// development can write messages to files or to the log instead.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewFromEnv returns the mailer picked by MAIL_DRIVER:
+//
+//   - "smtp" sends through SMTP_HOST:SMTP_PORT, authenticating with
+//     SMTP_USERNAME and SMTP_PASSWORD when set
+//   - "file" writes each message to MAIL_DIR (default "tmp/mail")
+//   - "log" logs each message, tokens included
+//
+// MAIL_DRIVER has no default, so a deployment that forgets it fails to
+// start instead of writing tokens to its logs. Messages are sent from
+// MAIL_FROM.
+func NewFromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@banking-system.local"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is not set")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Addr:     net.JoinHostPort(host, port),
+			Host:     host,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = filepath.Join("tmp", "mail")
+		}
+		return &FileMailer{Dir: dir, From: from}, nil
+	case "log":
+		return &LogMailer{From: from}, nil
+	case "":
+		return nil, fmt.Errorf("MAIL_DRIVER is not set, expected smtp, file or log")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+// format renders message as an RFC 5322 email.
+func format(from string, message Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domainOf(from))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return strings.Trim(address[i+1:], ">")
+	}
+	return "localhost"
+}
+
+// validate refuses header injection through the recipient or subject.
+func validate(message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return fmt.Errorf("mail headers must not contain line breaks")
+	}
+	return nil
+}
+
+// SMTPMailer sends through an SMTP server. The connection is upgraded with
+// STARTTLS when the server offers it, and credentials are only sent over
+// TLS or to localhost.
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if err := validate(message); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.Addr, auth, m.From, []string{message.To}, format(m.From, message, time.Now()))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send mail: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileMailer writes each message to its own .eml file in Dir.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := validate(message); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString())
+	if err := os.WriteFile(filepath.Join(m.Dir, name), format(m.From, message, now), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
+
+// LogMailer logs each message instead of sending it. Messages carry tokens,
+// so it is only fit for local development.
+type LogMailer struct {
+	From string
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	if err := validate(message); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
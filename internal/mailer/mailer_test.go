@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	raw := string(format("no-reply@example.com", Message{
+		To:      "jane@example.com",
+		Subject: "Verify your email",
+		Body:    "line one\nline two",
+	}, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+
+	for _, want := range []string{
+		"From: no-reply@example.com\r\n",
+		"To: jane@example.com\r\n",
+		"Subject: Verify your email\r\n",
+		"Date: Fri, 01 Mar 2024 00:00:00 +0000\r\n",
+		"@example.com>\r\n",
+		"\r\n\r\nline one\r\nline two",
+	} {
+		if !strings.Contains(raw, want) {
+			t.Errorf("formatted mail is missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := &FileMailer{Dir: dir, From: "no-reply@example.com"}
+
+	if err := mailer.Send(context.Background(), Message{To: "jane@example.com", Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one message in %s; got %v, %v", dir, files, err)
+	}
+
+	injected := Message{To: "jane@example.com\r\nBcc: everyone@example.com", Subject: "Hello", Body: "Hi"}
+	if err := mailer.Send(context.Background(), injected); err == nil {
+		t.Error("a recipient with a line break should be refused")
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	tests := []struct {
+		driver  string
+		want    Mailer
+		wantErr bool
+	}{
+		{driver: "", wantErr: true},
+		{driver: "log", want: &LogMailer{}},
+		{driver: "file", want: &FileMailer{}},
+		{driver: "smtp", wantErr: true}, // SMTP_HOST is not set
+		{driver: "carrier-pigeon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			t.Setenv("MAIL_DRIVER", tt.driver)
+			t.Setenv("SMTP_HOST", "")
+
+			mailer, err := NewFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error; got %T", mailer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%T", mailer) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("expected %T; got %T", tt.want, mailer)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-pdf/fpdf"
@@ -55,7 +56,7 @@ func (g *StatementGenerator) handleError(err error, operation string) error {
 	return nil
 }
 
-func (g *StatementGenerator) GenerateStatement(transactions []models.Transaction, totalAmount float64, userID int, userFullName string) (string, error) {
+func (g *StatementGenerator) GenerateStatement(transactions []models.Transaction, totalAmount models.Money, userID int, userFullName string) (string, error) {
 	pdf := g.initializePDF()
 	
 	g.addHeader(pdf)
@@ -165,10 +166,10 @@ func (g *StatementGenerator) addTransactionTable(pdf *fpdf.Fpdf, transactions []
 		pdf.Cell(widths[0], 7, trans.CreatedAt.Format("02/01/2006"))
 		pdf.Cell(widths[1], 7, trans.ReferenceID)
 		pdf.Cell(widths[2], 7, strconv.Itoa(trans.ID))
-		pdf.Cell(widths[3], 7, string(trans.Type))
+		pdf.Cell(widths[3], 7, transactionTypeLabel(trans))
 		
-		amount := fmt.Sprintf("%.2f", trans.Amount)
-		if trans.Type == models.Withdrawal {
+		amount := g.formatAmount(pdf, trans.Amount)
+		if trans.Direction == models.Debit {
 			pdf.SetTextColor(200, 0, 0)
 		} else {
 			pdf.SetTextColor(0, 150, 0)
@@ -180,14 +181,27 @@ func (g *StatementGenerator) addTransactionTable(pdf *fpdf.Fpdf, transactions []
 	}
 }
 
-func (g *StatementGenerator) addSummarySection(pdf *fpdf.Fpdf, totalAmount float64) {
+// formatAmount writes an amount with its currency symbol, e.g. "€12.30",
+// when the core font's code page has the symbol, and with the currency code
+// otherwise, since missing glyphs would be printed as dots.
+func (g *StatementGenerator) formatAmount(pdf *fpdf.Fpdf, amount models.Money) string {
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	info, err := amount.Currency.Info()
+	if err != nil || info.Symbol == "" || strings.Contains(translate(info.Symbol), ".") {
+		return amount.Format()
+	}
+	return translate(amount.Display())
+}
+
+func (g *StatementGenerator) addSummarySection(pdf *fpdf.Fpdf, totalAmount models.Money) {
 	pdf.Ln(10)
 	pdf.SetFont("Arial", "B", 10)
 	pdf.SetTextColor(0, 48, 87)
 	
 	pdf.Cell(140, 8, "Closing Balance:")
 	pdf.SetFont("Arial", "B", 12)
-	pdf.Cell(30, 8, fmt.Sprintf("%.2f", totalAmount))
+	pdf.Cell(30, 8, g.formatAmount(pdf, totalAmount))
 	pdf.Ln(15)
 }
 
@@ -221,6 +235,15 @@ func (g *StatementGenerator) saveFile(pdf *fpdf.Fpdf, userID int) (string, error
 	return absPath, nil
 }
 
+// transactionTypeLabel names the transaction a reversal or refund undoes,
+// e.g. "REFUND of 20".
+func transactionTypeLabel(trans models.Transaction) string {
+	if trans.ReversalOfID != nil {
+		return fmt.Sprintf("%s of %d", trans.Type, *trans.ReversalOfID)
+	}
+	return string(trans.Type)
+}
+
 func getTransactionDescription(trans models.Transaction) string {
 	// You can implement custom logic here to generate meaningful descriptions
 	// based on transaction type, reference, or other attributes
@@ -7,11 +7,19 @@ import (
 	"banking-system/internal/lib"
 	"banking-system/internal/utils"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// defaultDormancyPeriod is how long an account may go without a transaction
+// before it is marked dormant.
+const defaultDormancyPeriod = 365 * 24 * time.Hour
+
 type AccountService struct {
 	db database.Service
 }
@@ -20,6 +28,41 @@ func NewAccountService(db database.Service) *AccountService {
 	return &AccountService{db: db}
 }
 
+// accountDormancyPeriod reads ACCOUNT_DORMANCY_PERIOD (a Go duration such as
+// "8760h") and falls back to a year when it is unset or invalid.
+func accountDormancyPeriod() time.Duration {
+	value := os.Getenv("ACCOUNT_DORMANCY_PERIOD")
+	if value == "" {
+		return defaultDormancyPeriod
+	}
+
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		log.Printf("Invalid ACCOUNT_DORMANCY_PERIOD %q, using %s", value, defaultDormancyPeriod)
+		return defaultDormancyPeriod
+	}
+	return period
+}
+
+// startDormancySweep marks inactive accounts dormant once an hour.
+func (s *Server) startDormancySweep() {
+	period := accountDormancyPeriod()
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		accountRepository := repositories.NewAccountRepository(s.db)
+		for range ticker.C {
+			marked, err := accountRepository.MarkDormantAccounts(time.Now().Add(-period))
+			if err != nil {
+				log.Printf("Failed to mark dormant accounts: %v", err)
+				continue
+			}
+			if marked > 0 {
+				log.Printf("Marked %d accounts dormant", marked)
+			}
+		}
+	}()
+}
+
 
 // CreateAccount creates a new account for a user
 // @Summary Create a new account
@@ -46,21 +89,27 @@ func (s *AccountService) CreateAccount(w http.ResponseWriter, r *http.Request, u
 		createAccountRequest.Currency = models.USD
 	}
 
+	createAccountRequest.Currency = models.Currency(strings.ToUpper(string(createAccountRequest.Currency)))
+	if err := createAccountRequest.Currency.CheckEnabled(); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid currency", err)
+		return
+	}
+
 	accountRepository := repositories.NewAccountRepository(s.db)
-	accountID, err := accountRepository.CreateAccount(createAccountRequest, userID)
+	accountID, err := accountRepository.CreateAccount(createAccountRequest, userID, auditActor(r))
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create account", err)
 		return
 	}
 
-	account, err := accountRepository.GetAccount(accountID)
+	account, err := accountRepository.GetAccount(accountID, userID)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get account", err)
 		return
 	}
 
 	// Record account balance after creation
-	lib.RecordAccountBalance(account.Balance, string(account.Currency))
+	lib.RecordAccountBalance(account.Balance.Float64(), string(account.Currency))
 	lib.RecordNewAccount(string(account.Currency))
 	
 	// Record API latency
@@ -77,13 +126,13 @@ func (s *AccountService) CreateAccount(w http.ResponseWriter, r *http.Request, u
 // @Description Get all accounts for a user with filtering, sorting, and pagination
 // @Accept json
 // @Produce json
-// @Param minBalance query float64 false "Minimum balance filter"
-// @Param maxBalance query float64 false "Maximum balance filter"
-// @Param currency query string false "Currency filter (USD, EUR, GBP)"
+// @Param minBalance query number false "Minimum balance filter"
+// @Param maxBalance query number false "Maximum balance filter"
+// @Param currency query string false "Currency filter, e.g. USD"
 // @Param dateFrom query string false "Date from filter (RFC3339)"
 // @Param dateTo query string false "Date to filter (RFC3339)"
 // @Param page query int false "Page number" default(1)
-// @Param pageSize query int false "Page size" default(10)
+// @Param pageSize query int false "Page size, at most 100" default(10)
 // @Param sortField query string false "Sort field (balance, currency, created_at)"
 // @Param sortDirection query string false "Sort direction (ASC, DESC)"
 // @Success 200 {object} models.Response
@@ -95,73 +144,36 @@ func (s *AccountService) CreateAccount(w http.ResponseWriter, r *http.Request, u
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *AccountService) GetAccounts(w http.ResponseWriter, r *http.Request, userID int) {
 	// Parse query parameters for filtering
+	query := r.URL.Query()
 	filter := &models.AccountFilter{}
-	
-	if minBalance := r.URL.Query().Get("minBalance"); minBalance != "" {
-		val, err := strconv.ParseFloat(minBalance, 64)
-		if err != nil {
-			http.Error(w, "Invalid minBalance parameter", http.StatusBadRequest)
-			return
-		}
-		filter.MinBalance = &val
-	}
-
-	if maxBalance := r.URL.Query().Get("maxBalance"); maxBalance != "" {
-		val, err := strconv.ParseFloat(maxBalance, 64)
-		if err != nil {
-			http.Error(w, "Invalid maxBalance parameter", http.StatusBadRequest)
-			return
-		}
-		filter.MaxBalance = &val
-	}
-
-	if currency := r.URL.Query().Get("currency"); currency != "" {
-		curr := models.Currency(currency)
-		filter.Currency = &curr
-	}
 
-	if dateFrom := r.URL.Query().Get("dateFrom"); dateFrom != "" {
-		date, err := time.Parse(time.RFC3339, dateFrom)
-		if err != nil {
-			http.Error(w, "Invalid dateFrom parameter", http.StatusBadRequest)
-			return
-		}
-		filter.DateFrom = &date
-	}
-
-	if dateTo := r.URL.Query().Get("dateTo"); dateTo != "" {
-		date, err := time.Parse(time.RFC3339, dateTo)
-		if err != nil {
-			http.Error(w, "Invalid dateTo parameter", http.StatusBadRequest)
-			return
-		}
-		filter.DateTo = &date
+	var err error
+	if filter.MinBalance, filter.MaxBalance, err = parseAmountRange(query, "minBalance", "maxBalance"); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
-	// Parse pagination parameters
-	page := 1
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if val, err := strconv.Atoi(pageStr); err == nil && val > 0 {
-			page = val
-		}
+	if filter.Currency, err = parseCurrency(query, "currency"); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
-	pageSize := 10
-	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
-		if val, err := strconv.Atoi(pageSizeStr); err == nil && val > 0 {
-			pageSize = val
-		}
+	if filter.DateFrom, filter.DateTo, err = parseDateRange(query); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
-	// Parse sorting parameters
-	sort := &models.SortRequest{
-		Field:     r.URL.Query().Get("sortField"),
-		Direction: r.URL.Query().Get("sortDirection"),
+	// Parse sorting and pagination parameters
+	sort, err := parseSort(query, []string{"balance", "currency", "created_at"})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
-	pagination := &models.PaginationRequest{
-		Page:     page,
-		PageSize: pageSize,
+	pagination, err := parsePagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
 
 	accountRepository := repositories.NewAccountRepository(s.db)
@@ -181,7 +193,6 @@ func (s *AccountService) GetAccounts(w http.ResponseWriter, r *http.Request, use
 // @Produce json
 // @Param id query int true "Account ID"
 // @Success 200 {object} models.Response "Account details retrieved successfully"
-// @Failure 403 {object} models.Response "Unauthorized access to account"
 // @Failure 404 {object} models.Response "Account not found"
 // @Failure 500 {object} models.Response "Internal server error"
 // @Router /account/get [get]
@@ -191,14 +202,14 @@ func (s *AccountService) GetAccounts(w http.ResponseWriter, r *http.Request, use
 func (s *AccountService) GetAccount(w http.ResponseWriter, r *http.Request, accountID int, userID int) {
 	start := time.Now()
 	accountRepository := repositories.NewAccountRepository(s.db)
-	account, err := accountRepository.GetAccount(accountID)
+	account, err := accountRepository.GetAccount(accountID, userID)
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get account", err)
+		writeLookupError(w, "Account", err)
 		return
 	}
 
 	// Record account balance on retrieval
-	lib.RecordAccountBalance(account.Balance, string(account.Currency))
+	lib.RecordAccountBalance(account.Balance.Float64(), string(account.Currency))
 	
 	// Record API latency
 	duration := time.Since(start).Seconds()
@@ -207,47 +218,130 @@ func (s *AccountService) GetAccount(w http.ResponseWriter, r *http.Request, acco
 	utils.WriteJSONResponse(w, http.StatusOK, "Account fetched successfully", account)
 }
 
-// DeleteAccount deletes an account
-// @Summary Delete an account
-// @Description Permanently delete an account and all associated data
+// ReconcileAccount compares an account's balance with its ledger history
+// @Summary Reconcile an account against the ledger
+// @Description Compare the stored account balance with the sum of its ledger postings
 // @Accept json
 // @Produce json
 // @Param id query int true "Account ID"
-// @Success 200 {object} models.Response "Account deleted successfully"
+// @Success 200 {object} models.Response{data=models.LedgerReconciliation} "Account reconciled successfully"
+// @Failure 400 {object} models.Response{data=map[string]string} "Invalid account ID"
 // @Failure 404 {object} models.Response{data=map[string]string} "Account not found"
 // @Failure 500 {object} models.Response{data=map[string]string} "Internal server error"
-// @Router /account/delete [delete]
+// @Router /account/reconcile [get]
 // @Tags account
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
-func (s *AccountService) DeleteAccount(w http.ResponseWriter, r *http.Request, userID int) {
-	accountID := r.URL.Query().Get("id")
-	accountIDInt, err := strconv.Atoi(accountID)
+func (s *AccountService) ReconcileAccount(w http.ResponseWriter, r *http.Request, userID int) {
+	accountID, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", err)
 		return
 	}
 
-	// Validate account ownership
-	accountRepository := repositories.NewAccountRepository(s.db)
-	account, err := accountRepository.GetAccount(accountIDInt)
+	ledgerRepository := repositories.NewLedgerRepository(s.db)
+	reconciliation, err := ledgerRepository.ReconcileAccount(accountID, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get account", err)
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to reconcile account", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Account reconciled successfully", reconciliation)
+}
+
+// CloseAccount closes an account
+// @Summary Close an account
+// @Description Close an account. An account that still holds money must name another account of the same owner and currency to sweep the balance into. Closed accounts and their transactions stay readable.
+// @Accept json
+// @Produce json
+// @Param closeAccountRequest body models.CloseAccountRequest true "Close account request"
+// @Success 200 {object} models.Response{data=models.Account} "Account closed successfully"
+// @Failure 400 {object} models.Response{data=map[string]string} "Invalid request"
+// @Failure 404 {object} models.Response{data=map[string]string} "Account not found"
+// @Failure 409 {object} models.Response{data=map[string]string} "Account cannot be closed"
+// @Failure 500 {object} models.Response{data=map[string]string} "Internal server error"
+// @Router /account/close [post]
+// @Tags account
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AccountService) CloseAccount(w http.ResponseWriter, r *http.Request, userID int) {
+	var closeRequest models.CloseAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&closeRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	s.closeAccount(w, r, closeRequest, userID)
+}
+
+// DeleteAccount closes an account
+// @Summary Close an account
+// @Description Close an account, like POST /account/close. Kept for existing clients; accounts are never deleted.
+// @Accept json
+// @Produce json
+// @Param id query int true "Account ID"
+// @Param sweepTo query int false "Account ID to move the remaining balance to"
+// @Success 200 {object} models.Response{data=models.Account} "Account closed successfully"
+// @Failure 400 {object} models.Response{data=map[string]string} "Invalid account ID"
+// @Failure 404 {object} models.Response{data=map[string]string} "Account not found"
+// @Failure 409 {object} models.Response{data=map[string]string} "Account cannot be closed"
+// @Failure 500 {object} models.Response{data=map[string]string} "Internal server error"
+// @Router /account/delete [delete]
+// @Tags account
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AccountService) DeleteAccount(w http.ResponseWriter, r *http.Request, userID int) {
+	query := r.URL.Query()
+	accountID, err := strconv.Atoi(query.Get("id"))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", err)
 		return
 	}
 
-	if account.UserID != userID {
-		utils.WriteJSONError(w, http.StatusForbidden, "Unauthorized access to account", nil)
+	closeRequest := models.CloseAccountRequest{AccountID: accountID}
+	if value := query.Get("sweepTo"); value != "" {
+		sweepTo, err := strconv.Atoi(value)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", err)
+			return
+		}
+		closeRequest.SweepToAccountID = &sweepTo
+	}
+
+	s.closeAccount(w, r, closeRequest, userID)
+}
+
+func (s *AccountService) closeAccount(w http.ResponseWriter, r *http.Request, closeRequest models.CloseAccountRequest, userID int) {
+	if closeRequest.SweepToAccountID != nil && *closeRequest.SweepToAccountID == closeRequest.AccountID {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", errors.New("cannot sweep an account into itself"))
 		return
 	}
 
-	err = accountRepository.DeleteAccount(accountIDInt)
+	// Both lookups are scoped to the caller, so another user's account is reported as not found
+	accountRepository := repositories.NewAccountRepository(s.db)
+	account, err := accountRepository.CloseAccount(closeRequest, userID, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
+	if errors.Is(err, repositories.ErrCurrencyMismatch) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid sweep account", err)
+		return
+	}
+	if errors.Is(err, repositories.ErrAccountNotEmpty) || errors.Is(err, repositories.ErrAccountHasHolds) || errors.Is(err, repositories.ErrInvalidStatusTransition) || errors.Is(err, repositories.ErrAccountClosed) {
+		utils.WriteJSONError(w, http.StatusConflict, "Account cannot be closed", err)
+		return
+	}
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to delete account", err)
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to close account", err)
 		return
 	}
 
-	utils.WriteJSONResponse(w, http.StatusOK, "Account deleted successfully", nil)
+	utils.WriteJSONResponse(w, http.StatusOK, "Account closed successfully", account)
 }
 
 
@@ -0,0 +1,392 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loadCurrencies copies the currencies table into the in-memory catalogue.
+func (s *Server) loadCurrencies() error {
+	currencies, err := repositories.NewCurrencyRepository(s.db).ListCurrencies()
+	if err != nil {
+		return err
+	}
+	models.SetCurrencies(currencies)
+	return nil
+}
+
+// startCurrencyRefresh reloads the catalogue every minute so that a currency
+// enabled through another instance is picked up here too.
+func (s *Server) startCurrencyRefresh() {
+	if err := s.loadCurrencies(); err != nil {
+		log.Printf("Failed to load currencies, using the built-in defaults: %v", err)
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		for range ticker.C {
+			if err := s.loadCurrencies(); err != nil {
+				log.Printf("Failed to refresh currencies: %v", err)
+			}
+		}
+	}()
+}
+
+type AdminService struct {
+	db database.Service
+}
+
+func NewAdminService(db database.Service) *AdminService {
+	return &AdminService{db: db}
+}
+
+// @Summary List currencies
+// @Description List every currency in the catalogue, enabled or not
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.CurrencyInfo}
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/currencies [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) ListCurrencies(w http.ResponseWriter, r *http.Request) {
+	currencyRepository := repositories.NewCurrencyRepository(s.db)
+	currencies, err := currencyRepository.ListCurrencies()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get currencies", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Currencies retrieved successfully", currencies)
+}
+
+// @Summary Enable or disable a currency
+// @Description Allow or stop new accounts and conversions in a currency. Existing accounts keep working.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param currency body models.UpdateCurrencyRequest true "Currency to update"
+// @Success 200 {object} models.Response{data=models.CurrencyInfo}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/currencies/update [put]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) UpdateCurrency(w http.ResponseWriter, r *http.Request) {
+	var updateRequest models.UpdateCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	code := models.Currency(strings.ToUpper(string(updateRequest.Code)))
+	if code == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid currency", fmt.Errorf("code is required"))
+		return
+	}
+
+	currencyRepository := repositories.NewCurrencyRepository(s.db)
+	currency, err := currencyRepository.SetCurrencyEnabled(code, updateRequest.Enabled, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Currency", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update currency", err)
+		return
+	}
+
+	// Apply the change here right away; other instances pick it up on their next refresh
+	if currencies, err := currencyRepository.ListCurrencies(); err == nil {
+		models.SetCurrencies(currencies)
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Currency updated successfully", currency)
+}
+
+// @Summary Search users
+// @Description Find users by email or name. Staff use this to look up the customer behind a ticket.
+// @Tags admin
+// @Produce json
+// @Param search query string false "Part of the email, first name or last name"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Items per page"
+// @Success 200 {object} models.Response{data=models.PaginatedResponse[models.UserDTO]}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/users [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pagination, err := parsePagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	userRepository := repositories.NewUserRepository(s.db)
+	users, err := userRepository.SearchUsers(strings.TrimSpace(query.Get("search")), pagination)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to search users", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Users retrieved successfully", users)
+}
+
+// @Summary Change a user's role
+// @Description Make a user a customer, support agent or admin. The new role applies from the user's next token refresh.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param role body models.UpdateUserRoleRequest true "User and role"
+// @Success 200 {object} models.Response{data=models.UserDTO}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/users/role [put]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) UpdateUserRole(w http.ResponseWriter, r *http.Request, userID int) {
+	var roleRequest models.UpdateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&roleRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	roleRequest.Role = models.Role(strings.ToLower(string(roleRequest.Role)))
+	if !roleRequest.Role.Valid() {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid role", fmt.Errorf("role must be one of customer, support, admin"))
+		return
+	}
+
+	// Keep at least one admin by never letting admins demote themselves
+	if roleRequest.UserID == userID && roleRequest.Role != models.RoleAdmin {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid role", fmt.Errorf("admins cannot change their own role"))
+		return
+	}
+
+	userRepository := repositories.NewUserRepository(s.db)
+	user, err := userRepository.SetUserRole(roleRequest.UserID, roleRequest.Role, auditActor(r))
+	if err != nil {
+		writeLookupError(w, "User", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Role updated successfully", user)
+}
+
+// @Summary Unlock a user's login
+// @Description Lift a login lockout early by forgetting the user's failed attempts. Lockouts of the IP the attempts came from stay in place.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param user body models.UnlockUserRequest true "User to unlock"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/users/unlock [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	var unlockRequest models.UnlockUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&unlockRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	failedLoginRepository := repositories.NewFailedLoginRepository(s.db)
+	if err := failedLoginRepository.UnlockUser(unlockRequest.UserID, auditActor(r)); err != nil {
+		writeLookupError(w, "User", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "User unlocked successfully", nil)
+}
+
+// @Summary List a user's API keys
+// @Description List any user's API keys, revoked and expired ones included. Keys themselves are not included.
+// @Tags admin
+// @Produce json
+// @Param user_id query int true "User ID"
+// @Success 200 {object} models.Response{data=[]models.APIKey}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/keys [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) ListAPIKeys(w http.ResponseWriter, r *http.Request, userID int) {
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	keys, err := apiKeyRepository.GetAPIKeys(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to fetch API keys", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "API keys fetched successfully", keys)
+}
+
+// @Summary Revoke any API key
+// @Description Revoke an API key whoever owns it, e.g. after it leaked. Tokens issued to it stop working too.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body models.RevokeAPIKeyRequest true "Key to revoke"
+// @Success 200 {object} models.Response{data=models.APIKey}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/keys/revoke [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	var revokeRequest models.RevokeAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&revokeRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	key, err := apiKeyRepository.RevokeAnyAPIKey(revokeRequest.ID, auditActor(r))
+	if err != nil {
+		writeLookupError(w, "API key", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "API key revoked successfully", key)
+}
+
+// @Summary Get any account
+// @Description Get an account by ID, whoever owns it
+// @Tags admin
+// @Produce json
+// @Param id query int true "Account ID"
+// @Success 200 {object} models.Response{data=models.Account}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/accounts/get [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) GetAccount(w http.ResponseWriter, r *http.Request, accountID int) {
+	accountRepository := repositories.NewAccountRepository(s.db)
+	account, err := accountRepository.GetAccountByID(accountID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Account fetched successfully", account)
+}
+
+// @Summary Freeze an account
+// @Description Stop an account from sending money. It can still receive deposits and incoming transfers.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param account body models.UpdateAccountStatusRequest true "Account to freeze"
+// @Success 200 {object} models.Response{data=models.Account}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/accounts/freeze [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	s.setAccountStatus(w, r, models.AccountFrozen)
+}
+
+// @Summary Unfreeze an account
+// @Description Return a frozen or dormant account to active so that it can send money again
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param account body models.UpdateAccountStatusRequest true "Account to unfreeze"
+// @Success 200 {object} models.Response{data=models.Account}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/accounts/unfreeze [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	s.setAccountStatus(w, r, models.AccountActive)
+}
+
+func (s *AdminService) setAccountStatus(w http.ResponseWriter, r *http.Request, status models.AccountStatus) {
+	var statusRequest models.UpdateAccountStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&statusRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	accountRepository := repositories.NewAccountRepository(s.db)
+	account, err := accountRepository.SetAccountStatus(statusRequest.AccountID, status, statusRequest.Reason, auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidStatusTransition) {
+		utils.WriteJSONError(w, http.StatusConflict, "Account status cannot change", err)
+		return
+	}
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Account status updated successfully", account)
+}
+
+// @Summary Reverse a transaction
+// @Description Undo a completed or partially refunded transaction. Same as POST /transaction/{id}/reverse, with the transaction ID in the body.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param reversal body models.ReverseTransactionRequest true "Transaction to reverse"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/transactions/reverse [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+	var reverseRequest models.ReverseTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&reverseRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	reason, err := compensationReason(reverseRequest.Reason)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	transactionRepository := repositories.NewTransactionRepository(s.db)
+	reversal, err := transactionRepository.ReverseTransaction(reverseRequest.TransactionID, reason, auditActor(r))
+	writeCompensationResult(w, "Reversal", "Transaction reversed successfully", reversal, err)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireRole(t *testing.T) {
+	s := &Server{}
+	handler := s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), models.RoleSupport, models.RoleAdmin)
+
+	tests := []struct {
+		name string
+		role any
+		want int
+	}{
+		{"admin", models.RoleAdmin, http.StatusOK},
+		{"support", models.RoleSupport, http.StatusOK},
+		{"customer", models.RoleCustomer, http.StatusForbidden},
+		{"no role", nil, http.StatusForbidden},
+		{"untyped role", "admin", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			if tt.role != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "role", tt.role))
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d; got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefixTag starts every API key, so that AuthGuard can tell keys
+// from JWTs and secret scanners can spot leaked ones.
+const apiKeyPrefixTag = "bk_"
+
+// apiKeyPrefixLength is the length of the public prefix, e.g.
+// "bk_1a2b3c4d5e6f", which also serves as the OAuth2 client_id.
+const apiKeyPrefixLength = len(apiKeyPrefixTag) + 12
+
+// generateAPIKey returns a new key "<prefix>_<secret>" and its prefix. The
+// secret carries 256 bits of entropy.
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyPrefixTag + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// parseAPIKey returns the prefix of something shaped like an API key.
+func parseAPIKey(key string) (string, bool) {
+	if len(key) <= apiKeyPrefixLength+1 || !strings.HasPrefix(key, apiKeyPrefixTag) || key[apiKeyPrefixLength] != '_' {
+		return "", false
+	}
+	return key[:apiKeyPrefixLength], true
+}
+
+// authenticateAPIKey checks an API key presented as a bearer token. The
+// claims carry the key's prefix as ClientID and its scopes.
+func (s *Server) authenticateAPIKey(key string, prefix string) (utils.TokenClaims, error) {
+	principal, err := repositories.NewAPIKeyRepository(s.db).Authenticate(prefix, utils.HashToken(key))
+	if err != nil {
+		return utils.TokenClaims{}, err
+	}
+
+	return utils.TokenClaims{
+		UserID:   principal.UserID,
+		Role:     principal.Role,
+		ClientID: principal.Prefix,
+		Scopes:   principal.Scopes,
+	}, nil
+}
+
+// authenticateToken checks a JWT access token, and that its session or,
+// for a client-credentials token, its API key is still live.
+func (s *Server) authenticateToken(tokenString string) (utils.TokenClaims, error) {
+	claims, err := utils.ValidateToken(tokenString)
+	if err != nil {
+		return utils.TokenClaims{}, err
+	}
+
+	if claims.ClientID != "" {
+		principal, err := repositories.NewAPIKeyRepository(s.db).GetActivePrincipal(claims.ClientID)
+		if err != nil {
+			return utils.TokenClaims{}, err
+		}
+		if principal.UserID != claims.UserID {
+			return utils.TokenClaims{}, repositories.ErrInvalidAPIKey
+		}
+
+		// The token acts with the owner's current role, and keeps only the
+		// scopes its key still has, as the key itself would
+		claims.Role = principal.Role
+		var scopes []models.APIScope
+		for _, scope := range claims.Scopes {
+			if containsScope(principal.Scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		claims.Scopes = scopes
+		return claims, nil
+	}
+
+	revoked, err := repositories.NewSessionRepository(s.db).IsRevoked(claims.TokenID, claims.SessionID)
+	if err != nil {
+		return utils.TokenClaims{}, err
+	}
+	if revoked {
+		return utils.TokenClaims{}, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+// checkScopes reports whether a request may go on: user sessions always
+// may, API keys and their tokens only with one of scopes.
+func checkScopes(w http.ResponseWriter, r *http.Request, scopes []models.APIScope) bool {
+	if clientID, _ := r.Context().Value("client_id").(string); clientID == "" {
+		return true
+	}
+	if len(scopes) == 0 {
+		utils.WriteJSONError(w, http.StatusForbidden, "Forbidden", errors.New("this endpoint needs a user login, API keys cannot call it"))
+		return false
+	}
+
+	granted, _ := r.Context().Value("scopes").([]models.APIScope)
+	for _, scope := range scopes {
+		if containsScope(granted, scope) {
+			return true
+		}
+	}
+
+	utils.WriteJSONError(w, http.StatusForbidden, "Forbidden", fmt.Errorf("API key lacks the %s scope", scopes[0]))
+	return false
+}
+
+// RequireScope narrows the scopes AuthGuard accepted, for handlers that
+// serve reads and writes on one path. It must run after AuthGuard.
+func (s *Server) RequireScope(next http.Handler, scopes ...models.APIScope) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checkScopes(w, r, scopes) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+type APIKeyService struct {
+	db database.Service
+}
+
+func NewAPIKeyService(db database.Service) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+func validateAPIKeyRequest(request *models.CreateAPIKeyRequest) error {
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || len(request.Name) > 100 {
+		return fmt.Errorf("name must be 1 to 100 characters")
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
+	seen := make(map[models.APIScope]bool)
+	scopes := make([]models.APIScope, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		scope = models.APIScope(strings.ToLower(strings.TrimSpace(string(scope))))
+		if !scope.Valid() {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("scopes must name at least one scope")
+	}
+	request.Scopes = scopes
+	return nil
+}
+
+// @Summary Create an API key
+// @Description Create a key for scripts to call the API as the authenticated user, limited to scopes. Send it as "Authorization: Bearer <key>", or exchange it at /oauth/token. The key is only returned here.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.CreateAPIKeyRequest true "Key name, scopes and optional expiry"
+// @Success 201 {object} models.Response{data=models.APIKey}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /keys/create [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *APIKeyService) CreateAPIKey(w http.ResponseWriter, r *http.Request, userID int) {
+	var keyRequest models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&keyRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := validateAPIKeyRequest(&keyRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid API key", err)
+		return
+	}
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create API key", err)
+		return
+	}
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	apiKey, err := apiKeyRepository.CreateAPIKey(keyRequest, prefix, utils.HashToken(key), userID, auditActor(r))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create API key", err)
+		return
+	}
+
+	apiKey.Key = key
+	utils.WriteJSONResponse(w, http.StatusCreated, "API key created successfully", apiKey)
+}
+
+// @Summary List API keys
+// @Description List the authenticated user's API keys, revoked and expired ones included. Keys themselves are not included.
+// @Tags api-keys
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.APIKey}
+// @Failure 500 {object} models.Response
+// @Router /keys/ [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *APIKeyService) GetAPIKeys(w http.ResponseWriter, r *http.Request, userID int) {
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	keys, err := apiKeyRepository.GetAPIKeys(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to fetch API keys", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "API keys fetched successfully", keys)
+}
+
+// @Summary Revoke an API key
+// @Description Revoke one of the authenticated user's API keys. Tokens issued to it stop working too.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.RevokeAPIKeyRequest true "Key to revoke"
+// @Success 200 {object} models.Response{data=models.APIKey}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /keys/revoke [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *APIKeyService) RevokeAPIKey(w http.ResponseWriter, r *http.Request, userID int) {
+	var revokeRequest models.RevokeAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&revokeRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	key, err := apiKeyRepository.RevokeAPIKey(revokeRequest.ID, userID, auditActor(r))
+	if err != nil {
+		writeLookupError(w, "API key", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "API key revoked successfully", key)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// @Summary Get an OAuth2 access token
+// @Description OAuth2 client-credentials grant (RFC 6749 section 4.4). The client_id is an API key's prefix and the client_secret is the key, sent with HTTP Basic authentication or as form fields. Request a subset of the key's scopes with scope, or leave it out for all of them. Unlike the rest of the API, responses use the RFC 6749 format.
+// @Tags api-keys
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "client_credentials"
+// @Param scope formData string false "Space-separated scopes"
+// @Param client_id formData string false "API key prefix, unless sent with Basic authentication"
+// @Param client_secret formData string false "API key, unless sent with Basic authentication"
+// @Success 200 {object} models.OAuthTokenResponse
+// @Failure 400 {object} models.OAuthErrorResponse
+// @Failure 401 {object} models.OAuthErrorResponse
+// @Router /oauth/token [post]
+func (s *APIKeyService) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "body must be form-encoded")
+		return
+	}
+	if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientID, clientSecret, basic := r.BasicAuth()
+	if !basic {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	prefix, ok := parseAPIKey(clientSecret)
+	if !ok || prefix != clientID {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+
+	apiKeyRepository := repositories.NewAPIKeyRepository(s.db)
+	principal, err := apiKeyRepository.Authenticate(prefix, utils.HashToken(clientSecret))
+	if errors.Is(err, repositories.ErrInvalidAPIKey) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	scopes := principal.Scopes
+	if requested := utils.SplitScopes(r.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !containsScope(principal.Scopes, scope) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("the key does not have the %s scope", scope))
+				return
+			}
+		}
+		scopes = requested
+	}
+
+	accessToken, _, err := utils.GenerateClientToken(principal.Prefix, principal.UserID, principal.Role, scopes)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(utils.AccessTokenTTL.Seconds()),
+		Scope:       utils.JoinScopes(scopes),
+	})
+}
+
+func containsScope(scopes []models.APIScope, scope models.APIScope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/utils"
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, ok := parseAPIKey(key)
+	if !ok || parsed != prefix || len(prefix) != apiKeyPrefixLength {
+		t.Errorf("parseAPIKey(%q) = %q, %v; want %q, true", key, parsed, ok, prefix)
+	}
+
+	for _, notAKey := range []string{"", prefix, prefix + "_", "eyJhbGciOiJIUzI1NiJ9.e30.sig", "xx_1a2b3c4d5e6f_secret"} {
+		if _, ok := parseAPIKey(notAKey); ok {
+			t.Errorf("parseAPIKey(%q) accepted a value that is not a key", notAKey)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	s := &Server{}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		scopes  []models.APIScope
+		want    int
+	}{
+		{"user login", s.RequireScope(ok, models.ScopeAccountsRead), nil, http.StatusOK},
+		{"key with the scope", s.RequireScope(ok, models.ScopeAccountsRead), []models.APIScope{models.ScopeAccountsRead}, http.StatusOK},
+		{"key with one of the scopes", s.RequireScope(ok, models.ScopeAdminRead, models.ScopeAdminWrite), []models.APIScope{models.ScopeAdminWrite}, http.StatusOK},
+		{"key without the scope", s.RequireScope(ok, models.ScopeAccountsWrite), []models.APIScope{models.ScopeAccountsRead}, http.StatusForbidden},
+		{"key on a login-only endpoint", s.RequireScope(ok), []models.APIScope{models.ScopeAccountsRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/account/get-accounts", nil)
+			if tt.scopes != nil {
+				ctx := context.WithValue(r.Context(), "client_id", "bk_1a2b3c4d5e6f")
+				r = r.WithContext(context.WithValue(ctx, "scopes", tt.scopes))
+			}
+
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d; got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestClientTokenUsesLiveRoleAndScopes(t *testing.T) {
+	db, d := newScriptedDB(t)
+	// Since the token was issued, the owner lost the admin role and the key
+	// lost its write scope
+	d.on("FROM api_keys", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{int64(1), args[0], int64(ownerID), string(models.RoleCustomer), "hash", `["accounts:read"]`}}, nil
+	})
+
+	token, _, err := utils.GenerateClientToken("bk_1a2b3c4d5e6f", ownerID, models.RoleAdmin,
+		[]models.APIScope{models.ScopeAccountsRead, models.ScopeAccountsWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := (&Server{db: db}).authenticateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Role != models.RoleCustomer {
+		t.Errorf("role = %s; want the owner's current role %s", claims.Role, models.RoleCustomer)
+	}
+	if len(claims.Scopes) != 1 || claims.Scopes[0] != models.ScopeAccountsRead {
+		t.Errorf("scopes = %v; want only %s", claims.Scopes, models.ScopeAccountsRead)
+	}
+}
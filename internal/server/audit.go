@@ -0,0 +1,140 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds a caller-supplied X-Request-ID so it cannot bloat
+// the logs and the audit table.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware tags every request with an ID, taken from the
+// X-Request-ID header when the caller sends one. The ID is echoed back in the
+// response and recorded on audit events, so a change can be traced to the
+// request that made it.
+func (s *Server) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), "request_id", requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// auditActor describes the caller of r for the audit log. The user is unset
+// on routes that run before authentication, such as registration.
+func auditActor(r *http.Request) models.AuditActor {
+	ctx := r.Context()
+	actor := models.AuditActor{
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	if userID, ok := ctx.Value("user_id").(int); ok {
+		actor.UserID = &userID
+	}
+	if role, ok := ctx.Value("role").(models.Role); ok {
+		actor.Role = role
+	}
+	if requestID, ok := ctx.Value("request_id").(string); ok {
+		actor.RequestID = requestID
+	}
+
+	return actor
+}
+
+// @Summary List audit events
+// @Description List audit log events, newest first
+// @Tags admin
+// @Produce json
+// @Param actorId query int false "Filter by the user who made the change"
+// @Param action query string false "Filter by action, e.g. account.close"
+// @Param resourceType query string false "Filter by resource type, e.g. account"
+// @Param resourceId query string false "Filter by resource ID"
+// @Param requestId query string false "Filter by request ID"
+// @Param dateFrom query string false "Events at or after this RFC3339 timestamp"
+// @Param dateTo query string false "Events at or before this RFC3339 timestamp"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Items per page"
+// @Success 200 {object} models.Response{data=models.PaginatedResponse[models.AuditEvent]}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/audit [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pagination, err := parsePagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	filter := &models.AuditFilter{}
+	if actorID := query.Get("actorId"); actorID != "" {
+		id, err := strconv.Atoi(actorID)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("actorId must be an integer"))
+			return
+		}
+		filter.ActorUserID = &id
+	}
+	for key, target := range map[string]**string{
+		"action":       &filter.Action,
+		"resourceType": &filter.ResourceType,
+		"resourceId":   &filter.ResourceID,
+		"requestId":    &filter.RequestID,
+	} {
+		if value := strings.TrimSpace(query.Get(key)); value != "" {
+			*target = &value
+		}
+	}
+	if filter.DateFrom, filter.DateTo, err = parseDateRange(query); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	auditRepository := repositories.NewAuditRepository(s.db)
+	events, err := auditRepository.ListEvents(filter, pagination)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to list audit events", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Audit events retrieved successfully", events)
+}
+
+// @Summary Verify the audit log
+// @Description Re-compute the audit hash chain and report the first event that was altered or removed, if any
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.Response{data=models.AuditVerification}
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/audit/verify [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) VerifyAuditChain(w http.ResponseWriter, r *http.Request) {
+	auditRepository := repositories.NewAuditRepository(s.db)
+	verification, err := auditRepository.VerifyChain()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to verify audit log", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Audit log verified", verification)
+}
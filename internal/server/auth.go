@@ -4,9 +4,13 @@ import (
 	"banking-system/internal/database"
 	"banking-system/internal/database/models"
 	"banking-system/internal/database/repositories"
+	"banking-system/internal/mailer"
 	"banking-system/utils"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"banking-system/internal/lib"
@@ -15,20 +19,22 @@ import (
 )
 
 type AuthService struct {
-	db database.Service
+	db       database.Service
+	mailer   mailer.Mailer
+	throttle *loginThrottle
 }
 
-func NewAuthService(db database.Service) *AuthService {
-	return &AuthService{db: db}
+func NewAuthService(db database.Service, mailer mailer.Mailer) *AuthService {
+	return &AuthService{db: db, mailer: mailer, throttle: newLoginThrottle(db)}
 }
 
 // Register a new user
 // @Summary Register a new user
-// @Description Register a new user with email and password
+// @Description Register a new user with email and password. A verification token is mailed to the address; withdrawals, transfers, holds and scheduled transfers need a verified email.
 // @Accept json
 // @Produce json
 // @Param user body models.CreateUserRequest true "User details"
-// @Success 201 {object} models.Response
+// @Success 201 {object} models.Response{data=models.TokenResponse}
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /auth/register [post]
@@ -39,15 +45,25 @@ func (s *AuthService) Register(w http.ResponseWriter, r *http.Request) {
 		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
+	user.Email = strings.TrimSpace(user.Email)
+	if err := validateEmail(user.Email); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid email", err)
+		return
+	}
 
 	userRepository := repositories.NewUserRepository(s.db)
-	createdUser, err := userRepository.CreateUser(user)
+	createdUser, err := userRepository.CreateUser(user, auditActor(r))
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "User creation failed", err)
 		return
 	}
 
-	token, err := utils.GenerateToken(createdUser.ID)
+	// The account works without it, so a failed mail only needs a resend
+	if err := s.sendEmailVerification(createdUser.ID); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", createdUser.ID, err)
+	}
+
+	tokens, err := s.issueTokens(r, createdUser.ID, models.RoleCustomer)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
 		return
@@ -61,20 +77,20 @@ func (s *AuthService) Register(w http.ResponseWriter, r *http.Request) {
 	}
 	lib.UpdateActiveUsers(userCount)
 
-	utils.WriteJSONResponse(w, http.StatusCreated, "User created successfully", map[string]interface{}{
-		"access_token": token,
-	})
+	utils.WriteJSONResponse(w, http.StatusCreated, "User created successfully", tokens)
 }
 
 // Login a user
 // @Summary Login a user
-// @Description Login a user with email and password
+// @Description Login a user with email and password. When the user has two-factor on, the response is an mfa_token instead of a token pair; finish the login at /auth/2fa/verify. Failed attempts slow down further attempts for the email, and too many lock it out for a while; the response is then 429 with a Retry-After header.
 // @Accept json
 // @Produce json
 // @Param user body models.LoginRequest true "User details"
-// @Success 200 {object} models.Response
+// @Success 200 {object} models.Response{data=models.TokenResponse}
+// @Success 200 {object} models.Response{data=models.MFAChallengeResponse}
 // @Failure 400 {object} models.Response
 // @Failure 401 {object} models.Response
+// @Failure 429 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /auth/login [post]
 // @Tags auth
@@ -86,6 +102,13 @@ func (s *AuthService) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The attempt counts as failed until the password is checked
+	attempt, ok := s.throttle.begin(w, loginRequest.Email, clientIP(r))
+	if !ok {
+		lib.RecordLoginAttempt(false)
+		return
+	}
+
 	userRepository := repositories.NewUserRepository(s.db)
 	user, err := userRepository.GetUserByEmail(loginRequest.Email)
 	if err != nil {
@@ -121,9 +144,34 @@ func (s *AuthService) Login(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+
+	// With two-factor on, the password only earns a token for the second step
+	twoFactorEnabled, err := repositories.NewTwoFactorRepository(s.db).IsEnabled(user.ID)
+	if err != nil {
+		attempt.forget()
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to log in", err)
+		return
+	}
+	if twoFactorEnabled {
+		// The password was right, but earlier failures stand until the code is
+		attempt.forget()
+		mfaToken, err := utils.GenerateMFAToken(user.ID)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
+			return
+		}
+
+		utils.WriteJSONResponse(w, http.StatusOK, "Two-factor code required", models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int(utils.MFATokenTTL.Seconds()),
+		})
+		return
+	}
 	lib.RecordLoginAttempt(true)
+	attempt.succeeded()
 
-	token, err := utils.GenerateToken(user.ID)
+	tokens, err := s.issueTokens(r, user.ID, user.Role)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
 		return
@@ -136,21 +184,110 @@ func (s *AuthService) Login(w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(start).Seconds()
 	lib.RecordRequest(r.URL.Path, r.Method, http.StatusOK, duration)
 
-	utils.WriteJSONResponse(w, http.StatusOK, "User logged in successfully", map[string]interface{}{
-		"access_token": token,
-	})
+	utils.WriteJSONResponse(w, http.StatusOK, "User logged in successfully", tokens)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can only be used once; reusing one revokes the session.
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.Response{data=models.TokenResponse}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/refresh [post]
+// @Tags auth
+func (s *AuthService) Refresh(w http.ResponseWriter, r *http.Request) {
+	var request models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if request.RefreshToken == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", errors.New("refresh_token is required"))
+		return
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	sessionRepository := repositories.NewSessionRepository(s.db)
+	session, err := sessionRepository.RotateRefreshToken(utils.HashToken(request.RefreshToken), utils.HashToken(refreshToken), utils.RefreshTokenTTL)
+	if errors.Is(err, repositories.ErrInvalidRefreshToken) || errors.Is(err, repositories.ErrRefreshTokenReused) {
+		utils.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to refresh token", err)
+		return
+	}
+
+	// Read the role again so that a role change applies from the next refresh
+	role, err := repositories.NewUserRepository(s.db).GetUserRole(session.UserID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to refresh token", err)
+		return
+	}
+
+	accessToken, _, err := utils.GenerateToken(session.UserID, role, session.ID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Token refreshed successfully", newTokenResponse(accessToken, refreshToken))
 }
 
 // @Router /auth/logout [post]
 // @Tags auth
 // @Summary Logout a user
-// @Description Logout a user
+// @Description Revoke the current access token and its session. Send {"all": true} to sign out of every session.
 // @Accept json
 // @Produce json
+// @Param request body models.LogoutRequest false "Logout options"
 // @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
 // @Failure 500 {object} models.Response
-func (s *AuthService) Logout(w http.ResponseWriter, r *http.Request) {
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AuthService) Logout(w http.ResponseWriter, r *http.Request, userID int) {
 	start := time.Now()
+
+	var request models.LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+
+	sessionID, _ := r.Context().Value("session_id").(string)
+	tokenID, _ := r.Context().Value("token_id").(string)
+	tokenExpiresAt, _ := r.Context().Value("token_expires_at").(time.Time)
+
+	sessionRepository := repositories.NewSessionRepository(s.db)
+	if err := sessionRepository.RevokeToken(tokenID, userID, tokenExpiresAt); err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to logout", err)
+		return
+	}
+
+	var err error
+	if request.All {
+		err = sessionRepository.RevokeAllSessions(userID)
+	} else {
+		err = sessionRepository.RevokeSession(sessionID, userID)
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to logout", err)
+		return
+	}
+
 	// Decrement active users on logout
 	lib.DecrementActiveUsers()
 	
@@ -160,3 +297,46 @@ func (s *AuthService) Logout(w http.ResponseWriter, r *http.Request) {
 
 	utils.WriteJSONResponse(w, http.StatusOK, "User logged out successfully", nil)
 }
+
+// issueTokens starts a new session for the user and returns its first token pair.
+func (s *AuthService) issueTokens(r *http.Request, userID int, role models.Role) (models.TokenResponse, error) {
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	sessionRepository := repositories.NewSessionRepository(s.db)
+	session, err := sessionRepository.CreateSession(userID, r.UserAgent(), clientIP(r), utils.HashToken(refreshToken), utils.RefreshTokenTTL)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	accessToken, _, err := utils.GenerateToken(userID, role, session.ID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	return newTokenResponse(accessToken, refreshToken), nil
+}
+
+func newTokenResponse(accessToken string, refreshToken string) models.TokenResponse {
+	return models.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}
+}
+
+// startSessionCleanup drops stale revocation entries and refresh tokens once an hour.
+func (s *Server) startSessionCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		sessionRepository := repositories.NewSessionRepository(s.db)
+		for range ticker.C {
+			if err := sessionRepository.DeleteExpired(); err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sessionTables are the session tables kept by scriptSessions.
+type sessionTables struct {
+	mu            sync.Mutex
+	sessions      map[string]*scriptedSession
+	refreshTokens map[string]*scriptedRefreshToken
+	revokedTokens map[string]bool
+}
+
+type scriptedSession struct {
+	userID  int64
+	revoked bool
+}
+
+type scriptedRefreshToken struct {
+	id        int64
+	sessionID string
+	used      bool
+	expiresAt time.Time
+}
+
+func (t *sessionTables) sessionRevoked(sessionID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessions[sessionID].revoked
+}
+
+// scriptSessions answers the statements of the session repository from
+// in-memory tables.
+func scriptSessions(d *scriptedDriver) *sessionTables {
+	tables := &sessionTables{
+		sessions:      make(map[string]*scriptedSession),
+		refreshTokens: make(map[string]*scriptedRefreshToken),
+		revokedTokens: make(map[string]bool),
+	}
+	now := time.Now()
+
+	d.on("INSERT INTO sessions", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		tables.sessions[args[0].(string)] = &scriptedSession{userID: args[1].(int64)}
+		return [][]driver.Value{{now, now, now.Add(time.Duration(args[4].(float64) * float64(time.Second)))}}, nil
+	})
+	d.on("INSERT INTO refresh_tokens", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		tables.refreshTokens[args[1].(string)] = &scriptedRefreshToken{
+			id:        int64(len(tables.refreshTokens) + 1),
+			sessionID: args[0].(string),
+			expiresAt: args[2].(time.Time),
+		}
+		return [][]driver.Value{{}}, nil
+	})
+	d.on("FROM refresh_tokens rt", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		token, ok := tables.refreshTokens[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		session := tables.sessions[token.sessionID]
+		var usedAt, revokedAt driver.Value
+		if token.used {
+			usedAt = now
+		}
+		if session.revoked {
+			revokedAt = now
+		}
+		expired := !token.expiresAt.After(time.Now())
+		return [][]driver.Value{{token.id, usedAt, expired, token.sessionID, session.userID, "", "", now, revokedAt}}, nil
+	})
+	d.on("UPDATE refresh_tokens SET used_at", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		for _, token := range tables.refreshTokens {
+			if token.id == args[0].(int64) {
+				token.used = true
+			}
+		}
+		return [][]driver.Value{{}}, nil
+	})
+	d.on("SET last_used_at", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{now, now.Add(time.Duration(args[0].(float64) * float64(time.Second)))}}, nil
+	})
+	// Revoking one session, on reuse of a refresh token or on logout
+	revokeSession := func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		if session, ok := tables.sessions[args[0].(string)]; ok {
+			session.revoked = true
+			return [][]driver.Value{{}}, nil
+		}
+		return nil, nil
+	}
+	d.on("UPDATE sessions SET revoked_at", revokeSession)
+	d.on("WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", revokeSession)
+	d.on("INSERT INTO revoked_tokens", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		tables.revokedTokens[args[0].(string)] = true
+		return [][]driver.Value{{}}, nil
+	})
+	d.on("FROM revoked_tokens WHERE jti", func(args []driver.Value) ([][]driver.Value, error) {
+		tables.mu.Lock()
+		defer tables.mu.Unlock()
+		session, ok := tables.sessions[args[1].(string)]
+		revoked := tables.revokedTokens[args[0].(string)] || !ok || session.revoked
+		return [][]driver.Value{{revoked}}, nil
+	})
+	d.on("SELECT role FROM users", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{string(models.RoleCustomer)}}, nil
+	})
+
+	return tables
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db, d := newScriptedDB(t)
+	tables := scriptSessions(d)
+	auth := NewAuthService(db, nil)
+
+	tokens, err := auth.issueTokens(httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), ownerID, models.RoleCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := func(refreshToken string) (*httptest.ResponseRecorder, models.TokenResponse) {
+		w := httptest.NewRecorder()
+		body := `{"refresh_token": "` + refreshToken + `"}`
+		auth.Refresh(w, httptest.NewRequest(http.MethodPost, "/api/auth/refresh", strings.NewReader(body)))
+
+		var response struct {
+			Data models.TokenResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Data
+	}
+
+	w, rotated := refresh(tokens.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: expected status %d; got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q; want a new one", rotated.RefreshToken)
+	}
+	if rotated.AccessToken == "" {
+		t.Fatal("refresh returned no access token")
+	}
+
+	// The old token was used up: presenting it again means it leaked
+	w, _ = refresh(tokens.RefreshToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: expected status %d; got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+
+	var sessionID string
+	tables.mu.Lock()
+	for _, token := range tables.refreshTokens {
+		sessionID = token.sessionID
+	}
+	tables.mu.Unlock()
+	if !tables.sessionRevoked(sessionID) {
+		t.Error("reusing a refresh token did not revoke the session")
+	}
+
+	// The whole session is gone, including the token from the rotation
+	if w, _ := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token of a revoked session: expected status %d; got %d", http.StatusUnauthorized, w.Code)
+	}
+	if _, err := (&Server{db: db}).authenticateToken(rotated.AccessToken); err == nil {
+		t.Error("access token of a revoked session was accepted")
+	}
+
+	if w, _ := refresh("unknown"); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown refresh token: expected status %d; got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	db, d := newScriptedDB(t)
+	tables := scriptSessions(d)
+	s := &Server{db: db, authService: NewAuthService(db, nil)}
+
+	tokens, err := s.authService.issueTokens(httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), ownerID, models.RoleCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var served int
+	guarded := s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		s.authService.Logout(w, r, r.Context().Value("user_id").(int))
+	}))
+	logout := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+		r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		w := httptest.NewRecorder()
+		guarded.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := logout(); w.Code != http.StatusOK {
+		t.Fatalf("logout: expected status %d; got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	tables.mu.Lock()
+	revoked := len(tables.revokedTokens)
+	tables.mu.Unlock()
+	if revoked != 1 {
+		t.Errorf("logout revoked %d access tokens; want 1", revoked)
+	}
+
+	// The jti alone keeps the token out, even if its session were still live
+	tables.mu.Lock()
+	for _, session := range tables.sessions {
+		session.revoked = false
+	}
+	tables.mu.Unlock()
+
+	if w := logout(); w.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: expected status %d; got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+	if served != 1 {
+		t.Errorf("handler ran %d times; want the revoked token stopped at the guard", served)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesOnce sync.Once
+	trustedProxyNets   []*net.IPNet
+)
+
+// trustedProxies parses TRUSTED_PROXIES, a comma-separated list of IPs and
+// CIDR ranges of the reverse proxies in front of the server. Invalid entries
+// are logged and skipped.
+func trustedProxies() []*net.IPNet {
+	trustedProxiesOnce.Do(func() {
+		trustedProxyNets = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	})
+	return trustedProxyNets
+}
+
+func parseTrustedProxies(value string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Invalid TRUSTED_PROXIES entry %q, ignoring it", entry)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func isTrusted(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client behind r. Forwarding headers
+// are only believed when the connection comes from a trusted proxy, since
+// anyone else can set them: X-Forwarded-For is read from the right, skipping
+// trusted proxies, and X-Real-IP is used when it is absent.
+func clientIP(r *http.Request) string {
+	return clientIPFrom(r, trustedProxies())
+}
+
+func clientIPFrom(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrusted(trusted, remote) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				// A malformed hop cannot be trusted past
+				return host
+			}
+			if !isTrusted(trusted, ip) {
+				return ip.String()
+			}
+		}
+		// Every hop is a proxy: the leftmost is the closest to the client
+		return strings.TrimSpace(hops[0])
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+	return host
+}
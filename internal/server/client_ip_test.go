@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := parseTrustedProxies("10.0.0.0/8, 192.0.2.1, not-an-ip")
+	if len(trusted) != 2 {
+		t.Fatalf("parsed %d trusted proxies; want 2", len(trusted))
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "headers from an untrusted peer are ignored", remote: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.7"},
+		{name: "behind a trusted proxy", remote: "10.1.2.3:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed hops left of the client are skipped", remote: "10.1.2.3:5000", forwarded: []string{"1.1.1.1, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", remote: "10.1.2.3:5000", forwarded: []string{"198.51.100.1, 192.0.2.1", "10.9.9.9"}, want: "198.51.100.1"},
+		{name: "malformed hop", remote: "10.1.2.3:5000", forwarded: []string{"198.51.100.1, garbage"}, want: "10.1.2.3"},
+		{name: "X-Real-IP from a trusted proxy", remote: "192.0.2.1:5000", realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "trusted proxy without headers", remote: "192.0.2.1:5000", want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIPFrom(r, trusted); got != tt.want {
+				t.Errorf("clientIP = %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/mailer"
+	"banking-system/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// validateEmail accepts a bare address such as "jane@example.com", without
+// a display name or angle brackets.
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return errors.New("email must be a valid address such as jane@example.com")
+	}
+	return nil
+}
+
+// sendMail delivers message in the background; the caller's response does
+// not wait on the mail server, and failures are only logged.
+func (s *AuthService) sendMail(message mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.mailer.Send(ctx, message); err != nil {
+			log.Printf("Failed to send %q mail: %v", message.Subject, err)
+		}
+	}()
+}
+
+// sendEmailVerification issues a new verification token for the user and
+// mails it.
+func (s *AuthService) sendEmailVerification(userID int) error {
+	token, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	userTokenRepository := repositories.NewUserTokenRepository(s.db)
+	email, err := userTokenRepository.CreateEmailVerification(userID, utils.HashToken(token), emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Use this token to verify your email address:\n\n%s\n\n"+
+			"Send it to POST /api/auth/verify-email. It expires in %s.\n", token, emailVerificationTTL),
+	})
+	return nil
+}
+
+// RequireVerifiedEmail only lets users with a verified email through. It
+// must run after AuthGuard.
+func (s *Server) RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value("user_id").(int)
+		verified, err := repositories.NewUserTokenRepository(s.db).IsEmailVerified(userID)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to check email verification", err)
+			return
+		}
+		if !verified {
+			utils.WriteJSONError(w, http.StatusForbidden, "Forbidden", repositories.ErrEmailNotVerified)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// @Summary Verify an email address
+// @Description Verify the user's email address with the token mailed at registration
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/verify-email [post]
+func (s *AuthService) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var request models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	userTokenRepository := repositories.NewUserTokenRepository(s.db)
+	err := userTokenRepository.VerifyEmail(utils.HashToken(request.Token), auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidUserToken) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid verification token", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to verify email", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Email verified", nil)
+}
+
+// @Summary Resend the verification email
+// @Description Mail a new verification token to the authenticated user. Tokens mailed before stop working.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/verify-email/resend [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AuthService) ResendEmailVerification(w http.ResponseWriter, r *http.Request, userID int) {
+	err := s.sendEmailVerification(userID)
+	if errors.Is(err, repositories.ErrEmailAlreadyVerified) {
+		utils.WriteJSONError(w, http.StatusConflict, "Email already verified", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to send verification email", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Verification email sent", nil)
+}
+
+// @Summary Request a password reset
+// @Description Mail a password reset token to the address if it belongs to a user. The response is the same either way, so it does not reveal who has an account.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetRequest true "Email address"
+// @Success 202 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Router /auth/password-reset/request [post]
+func (s *AuthService) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request models.PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	email := strings.TrimSpace(request.Email)
+	if err := validateEmail(email); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid email", err)
+		return
+	}
+
+	token, err := utils.GenerateRefreshToken()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to request password reset", err)
+		return
+	}
+
+	userTokenRepository := repositories.NewUserTokenRepository(s.db)
+	err = userTokenRepository.CreatePasswordReset(email, utils.HashToken(token), passwordResetTTL)
+	switch {
+	case err == nil:
+		s.sendMail(mailer.Message{
+			To:      email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Use this token to choose a new password:\n\n%s\n\n"+
+				"Send it with the new password to POST /api/auth/password-reset/confirm. It expires in %s.\n"+
+				"If you did not ask for a reset, ignore this email.\n", token, passwordResetTTL),
+		})
+	case errors.Is(err, repositories.ErrNotFound):
+		// Answer as if a mail was sent
+	default:
+		log.Printf("Failed to create password reset: %v", err)
+	}
+
+	utils.WriteJSONResponse(w, http.StatusAccepted, "If the address belongs to an account, a reset email is on its way", nil)
+}
+
+// @Summary Reset a password
+// @Description Set a new password with a mailed reset token. Every session of the user is signed out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ConfirmPasswordResetRequest true "Reset token and new password"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/password-reset/confirm [post]
+func (s *AuthService) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var request models.ConfirmPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if request.NewPassword == "" {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", errors.New("new_password is required"))
+		return
+	}
+
+	userTokenRepository := repositories.NewUserTokenRepository(s.db)
+	err := userTokenRepository.ResetPassword(utils.HashToken(request.Token), request.NewPassword, auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidUserToken) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid reset token", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to reset password", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Password reset, sign in with the new password", nil)
+}
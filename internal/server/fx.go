@@ -0,0 +1,100 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/fx"
+	"banking-system/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// newRateProvider serves rates from the JSON file named by FX_RATES_FILE
+// when it is set, and from the fx_rates table otherwise.
+func newRateProvider(db database.Service) (fx.FXRateProvider, error) {
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		return fx.NewFileRateProvider(path)
+	}
+	return repositories.NewFXRepository(db), nil
+}
+
+type FXService struct {
+	db     database.Service
+	quoter *fx.Quoter
+}
+
+func NewFXService(db database.Service, quoter *fx.Quoter) *FXService {
+	return &FXService{db: db, quoter: quoter}
+}
+
+// @Summary Quote a currency conversion
+// @Description Fix a rate for converting an amount into another currency. Pass the quote ID as quote_id on a transfer between accounts in different currencies before the quote expires.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Param quote body models.CreateFXQuoteRequest true "Conversion to quote"
+// @Success 201 {object} models.Response{data=models.FXQuote}
+// @Failure 400 {object} models.Response
+// @Failure 503 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /fx/quote [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *FXService) CreateQuote(w http.ResponseWriter, r *http.Request, userID int) {
+	var quoteRequest models.CreateFXQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&quoteRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	from := models.Currency(strings.ToUpper(string(quoteRequest.FromCurrency)))
+	to := models.Currency(strings.ToUpper(string(quoteRequest.ToCurrency)))
+	for _, currency := range []models.Currency{from, to} {
+		if err := currency.CheckEnabled(); err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid currency", err)
+			return
+		}
+	}
+	if from == to {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid currency", fmt.Errorf("from_currency and to_currency must differ"))
+		return
+	}
+
+	amount, err := quoteRequest.Amount.Money(from)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+	if err := validateTransactionAmount(amount); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+
+	quote, err := s.quoter.Quote(r.Context(), amount, to)
+	if errors.Is(err, fx.ErrRateUnavailable) {
+		utils.WriteJSONError(w, http.StatusServiceUnavailable, "Exchange rate unavailable", err)
+		return
+	}
+	if errors.Is(err, fx.ErrAmountTooSmall) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to quote conversion", err)
+		return
+	}
+
+	fxRepository := repositories.NewFXRepository(s.db)
+	quote, err = fxRepository.CreateQuote(quote, userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create quote", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, "Quote created successfully", quote)
+}
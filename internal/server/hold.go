@@ -0,0 +1,260 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+// holdTTL reads HOLD_TTL (a Go duration such as "168h") and falls back to a
+// week when it is unset or invalid.
+func holdTTL() time.Duration {
+	value := os.Getenv("HOLD_TTL")
+	if value == "" {
+		return defaultHoldTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid HOLD_TTL %q, using %s", value, defaultHoldTTL)
+		return defaultHoldTTL
+	}
+	return ttl
+}
+
+// startHoldExpiry ends holds that were neither captured nor released in time.
+// Expired holds stop reserving money as soon as they expire; the job only
+// records it.
+func (s *Server) startHoldExpiry() {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		holdRepository := repositories.NewHoldRepository(s.db)
+		for range ticker.C {
+			expired, err := holdRepository.ExpireHolds()
+			if err != nil {
+				log.Printf("Failed to expire holds: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d holds", expired)
+			}
+		}
+	}()
+}
+
+type HoldService struct {
+	db  database.Service
+	ttl time.Duration
+}
+
+func NewHoldService(db database.Service) *HoldService {
+	return &HoldService{db: db, ttl: holdTTL()}
+}
+
+// @Summary Authorize a hold
+// @Description Reserve money on an account without moving it. The available balance drops by the amount, the ledger balance does not. The hold expires after HOLD_TTL unless it is captured or released first.
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param hold body models.AuthorizeHoldRequest true "Account and amount to hold"
+// @Success 201 {object} models.Response{data=models.Hold}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /hold/authorize [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *HoldService) AuthorizeHold(w http.ResponseWriter, r *http.Request, userID int) {
+	var holdRequest models.AuthorizeHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&holdRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if holdRequest.Amount.IsZero() || holdRequest.Amount.IsNegative() {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", fmt.Errorf("amount must be greater than 0"))
+		return
+	}
+	holdRequest.Description = strings.TrimSpace(holdRequest.Description)
+
+	holdRepository := repositories.NewHoldRepository(s.db)
+	hold, err := holdRepository.AuthorizeHold(holdRequest, userID, s.ttl, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
+	if writeLimitError(w, "Hold rejected", err) {
+		return
+	}
+	if writeHoldError(w, "Hold rejected", err) {
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, "Hold authorized successfully", hold)
+}
+
+// @Summary Capture a hold
+// @Description Settle a hold as a completed withdrawal. Without an amount the whole hold is captured; a smaller amount captures part of it and releases the rest.
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param capture body models.CaptureHoldRequest true "Hold and optional amount"
+// @Success 200 {object} models.Response{data=models.Hold}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /hold/capture [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *HoldService) CaptureHold(w http.ResponseWriter, r *http.Request, userID int) {
+	var captureRequest models.CaptureHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&captureRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	holdRepository := repositories.NewHoldRepository(s.db)
+	hold, err := holdRepository.CaptureHold(captureRequest, userID, auditActor(r))
+	if writeHoldError(w, "Capture rejected", err) {
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Hold captured successfully", hold)
+}
+
+// @Summary Release a hold
+// @Description Give the money reserved by a hold back to the available balance
+// @Tags holds
+// @Accept json
+// @Produce json
+// @Param release body models.ReleaseHoldRequest true "Hold to release"
+// @Success 200 {object} models.Response{data=models.Hold}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /hold/release [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *HoldService) ReleaseHold(w http.ResponseWriter, r *http.Request, userID int) {
+	var releaseRequest models.ReleaseHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&releaseRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	holdRepository := repositories.NewHoldRepository(s.db)
+	hold, err := holdRepository.ReleaseHold(releaseRequest.HoldID, userID, auditActor(r))
+	if writeHoldError(w, "Release rejected", err) {
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Hold released successfully", hold)
+}
+
+// @Summary Get a hold
+// @Description Get one of the authenticated user's holds
+// @Tags holds
+// @Produce json
+// @Param id query int true "Hold ID"
+// @Success 200 {object} models.Response{data=models.Hold}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /hold/get [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *HoldService) GetHold(w http.ResponseWriter, r *http.Request, holdID int, userID int) {
+	holdRepository := repositories.NewHoldRepository(s.db)
+	hold, err := holdRepository.GetHold(holdID, userID)
+	if err != nil {
+		writeLookupError(w, "Hold", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Hold fetched successfully", hold)
+}
+
+// @Summary List holds
+// @Description List the authenticated user's holds, newest first
+// @Tags holds
+// @Produce json
+// @Param accountId query int false "Only holds on this account"
+// @Param status query string false "Hold status (authorized, captured, released, expired)"
+// @Success 200 {object} models.Response{data=[]models.Hold}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /hold/ [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *HoldService) GetHolds(w http.ResponseWriter, r *http.Request, userID int) {
+	query := r.URL.Query()
+
+	var accountID *int
+	if value := query.Get("accountId"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("accountId must be a positive integer"))
+			return
+		}
+		accountID = &id
+	}
+
+	var status *models.HoldStatus
+	if value := query.Get("status"); value != "" {
+		holdStatus := models.HoldStatus(strings.ToLower(value))
+		switch holdStatus {
+		case models.HoldAuthorized, models.HoldCaptured, models.HoldReleased, models.HoldExpired:
+			status = &holdStatus
+		default:
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("status must be one of authorized, captured, released, expired"))
+			return
+		}
+	}
+
+	holdRepository := repositories.NewHoldRepository(s.db)
+	holds, err := holdRepository.GetHolds(userID, accountID, status)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get holds", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Holds fetched successfully", holds)
+}
+
+// writeHoldError answers a failed hold operation and reports whether there
+// was an error to answer.
+func writeHoldError(w http.ResponseWriter, message string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repositories.ErrNotFound):
+		writeLookupError(w, "Hold", err)
+	case errors.Is(err, repositories.ErrInvalidAmount):
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+	case errors.Is(err, repositories.ErrInsufficientFunds),
+		errors.Is(err, repositories.ErrHoldNotAuthorized),
+		errors.Is(err, repositories.ErrHoldExpired),
+		isAccountStatusError(err):
+		utils.WriteJSONError(w, http.StatusConflict, message, err)
+	default:
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Hold operation failed", err)
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+
+	// idempotencyLease is how long a reserved key stays locked to the
+	// request that reserved it. It outlasts the server's write timeout, so a
+	// live request keeps its key, while the key of a request lost to a crash
+	// frees up soon after.
+	idempotencyLease = time.Minute
+)
+
+// idempotencyKeyTTL reads IDEMPOTENCY_KEY_TTL (a Go duration such as "24h")
+// and falls back to 24 hours when it is unset or invalid.
+func idempotencyKeyTTL() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_KEY_TTL")
+	if value == "" {
+		return defaultIdempotencyKeyTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Invalid IDEMPOTENCY_KEY_TTL %q, using %s", value, defaultIdempotencyKeyTTL)
+		return defaultIdempotencyKeyTTL
+	}
+	return ttl
+}
+
+// recordingResponseWriter keeps a copy of everything written so the response
+// can be stored and replayed later.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// IdempotencyGuard makes a money-moving endpoint safe to retry. When the
+// client sends an Idempotency-Key header the first response for that key is
+// stored and replayed for every retry with the same body. Reusing a key with
+// a different body, or while the first request is still running, returns 409.
+// A key is released again unless a response below 500 is stored, so the
+// client can retry after a server error, a panic or a failed store.
+// Must be wrapped by AuthGuard because keys are scoped to the user.
+func (s *Server) IdempotencyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid Idempotency-Key", fmt.Errorf("key must be at most %d characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		userID := r.Context().Value("user_id").(int)
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		idempotencyRepository := repositories.NewIdempotencyRepository(s.db)
+		existing, err := idempotencyRepository.Reserve(models.IdempotencyRecord{
+			UserID:        userID,
+			Key:           key,
+			RequestMethod: r.Method,
+			RequestPath:   r.URL.Path,
+			RequestHash:   requestHash,
+		}, s.idempotencyTTL, idempotencyLease)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to check Idempotency-Key", err)
+			return
+		}
+
+		if existing != nil {
+			if existing.RequestHash != requestHash {
+				utils.WriteJSONError(w, http.StatusConflict, "Idempotency-Key already used", fmt.Errorf("the key was used with a different request"))
+				return
+			}
+
+			if existing.ResponseStatus == nil {
+				utils.WriteJSONError(w, http.StatusConflict, "Request in progress", fmt.Errorf("a request with this key is still being processed"))
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(*existing.ResponseStatus)
+			w.Write(existing.ResponseBody)
+			return
+		}
+
+		// Deferred so that the key is also released when the handler panics
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := idempotencyRepository.Release(userID, key); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+		}()
+
+		recorder := &recordingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		// Server errors are not stored so the client can retry them
+		if recorder.statusCode == 0 || recorder.statusCode >= http.StatusInternalServerError {
+			return
+		}
+
+		if err := idempotencyRepository.Complete(userID, key, recorder.statusCode, recorder.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+			return
+		}
+		stored = true
+	})
+}
+
+// startIdempotencyKeyCleanup removes expired keys once an hour.
+func (s *Server) startIdempotencyKeyCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		idempotencyRepository := repositories.NewIdempotencyRepository(s.db)
+		for range ticker.C {
+			if _, err := idempotencyRepository.DeleteExpired(); err != nil {
+				log.Printf("Failed to delete expired idempotency keys: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idempotencyKey is a row of the idempotency_keys table kept by
+// scriptIdempotencyKeys.
+type idempotencyKey struct {
+	hash        string
+	status      *int64
+	body        []byte
+	lockedUntil time.Time
+}
+
+type idempotencyKeys struct {
+	mu          sync.Mutex
+	rows        map[string]*idempotencyKey
+	completeErr error
+}
+
+func (k *idempotencyKeys) get(key string) *idempotencyKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.rows[key]
+}
+
+// scriptIdempotencyKeys answers the idempotency statements from an
+// in-memory table.
+func scriptIdempotencyKeys(d *scriptedDriver) *idempotencyKeys {
+	keys := &idempotencyKeys{rows: make(map[string]*idempotencyKey)}
+
+	d.on("INSERT INTO idempotency_keys", func(args []driver.Value) ([][]driver.Value, error) {
+		keys.mu.Lock()
+		defer keys.mu.Unlock()
+
+		key, hash := args[1].(string), args[4].(string)
+		lockedUntil := time.Now().Add(time.Duration(args[6].(float64) * float64(time.Second)))
+		row, ok := keys.rows[key]
+		switch {
+		case !ok:
+			keys.rows[key] = &idempotencyKey{hash: hash, lockedUntil: lockedUntil}
+		case row.status == nil && !row.lockedUntil.After(time.Now()) && row.hash == hash:
+			row.lockedUntil = lockedUntil
+		default:
+			return nil, nil
+		}
+		return [][]driver.Value{{}}, nil
+	})
+	d.on("SET response_status", func(args []driver.Value) ([][]driver.Value, error) {
+		keys.mu.Lock()
+		defer keys.mu.Unlock()
+
+		if keys.completeErr != nil {
+			return nil, keys.completeErr
+		}
+		row := keys.rows[args[3].(string)]
+		status := args[0].(int64)
+		row.status, row.body = &status, args[1].([]byte)
+		return [][]driver.Value{{}}, nil
+	})
+	d.on("AND response_status IS NULL", func(args []driver.Value) ([][]driver.Value, error) {
+		keys.mu.Lock()
+		defer keys.mu.Unlock()
+
+		key := args[1].(string)
+		if row, ok := keys.rows[key]; ok && row.status == nil {
+			delete(keys.rows, key)
+			return [][]driver.Value{{}}, nil
+		}
+		return nil, nil
+	})
+	d.on("SELECT user_id, idempotency_key", func(args []driver.Value) ([][]driver.Value, error) {
+		keys.mu.Lock()
+		defer keys.mu.Unlock()
+
+		row, ok := keys.rows[args[1].(string)]
+		if !ok {
+			return nil, nil
+		}
+		var status driver.Value
+		if row.status != nil {
+			status = *row.status
+		}
+		now := time.Now()
+		return [][]driver.Value{{args[0], args[1], http.MethodPost, "/api/transaction/deposit", row.hash, status, row.body, now, now.Add(time.Hour)}}, nil
+	})
+
+	return keys
+}
+
+func TestIdempotencyGuard(t *testing.T) {
+	db, d := newScriptedDB(t)
+	keys := scriptIdempotencyKeys(d)
+	s := &Server{db: db, idempotencyTTL: time.Hour}
+
+	var calls int
+	status := http.StatusCreated
+	var panicWith interface{}
+	handler := s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if panicWith != nil {
+			panic(panicWith)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"transaction_id": 1}`))
+	}))
+
+	serve := func(key string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/transaction/deposit", strings.NewReader(body))
+		r.Header.Set(idempotencyKeyHeader, key)
+		r = r.WithContext(context.WithValue(r.Context(), "user_id", ownerID))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("replays the first response", func(t *testing.T) {
+		calls = 0
+		first := serve("replay", `{"amount": 10}`)
+		second := serve("replay", `{"amount": 10}`)
+
+		if calls != 1 {
+			t.Fatalf("handler ran %d times; want 1", calls)
+		}
+		if second.Code != first.Code || second.Body.String() != first.Body.String() {
+			t.Errorf("replay = %d %s; want %d %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+		}
+		if second.Header().Get(idempotentReplayedHeader) != "true" {
+			t.Error("replay is missing the Idempotent-Replayed header")
+		}
+	})
+
+	t.Run("rejects a different body", func(t *testing.T) {
+		calls = 0
+		serve("mismatch", `{"amount": 10}`)
+		w := serve("mismatch", `{"amount": 11}`)
+
+		if calls != 1 {
+			t.Errorf("handler ran %d times; want 1", calls)
+		}
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "different request") {
+			t.Errorf("expected 409 for a different request; got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects a retry while the request runs", func(t *testing.T) {
+		calls = 0
+		serve("running", `{"amount": 10}`)
+		// Put the key back in the state it has while the first request runs
+		row := keys.get("running")
+		keys.mu.Lock()
+		row.status, row.lockedUntil = nil, time.Now().Add(time.Minute)
+		keys.mu.Unlock()
+
+		w := serve("running", `{"amount": 10}`)
+		if calls != 1 {
+			t.Errorf("handler ran %d times; want 1", calls)
+		}
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "in progress") {
+			t.Errorf("expected 409 in progress; got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("takes over a key whose lease has passed", func(t *testing.T) {
+		calls = 0
+		keys.mu.Lock()
+		keys.rows["abandoned"] = &idempotencyKey{hash: keys.rows["running"].hash, lockedUntil: time.Now().Add(-time.Second)}
+		keys.mu.Unlock()
+
+		w := serve("abandoned", `{"amount": 10}`)
+		if calls != 1 || w.Code != http.StatusCreated {
+			t.Errorf("expected the retry to run; got %d calls and status %d: %s", calls, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("releases the key after a server error", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		serve("error", `{"amount": 10}`)
+		if keys.get("error") != nil {
+			t.Fatal("key still reserved after a server error")
+		}
+		serve("error", `{"amount": 10}`)
+		if calls != 2 {
+			t.Errorf("handler ran %d times; want the retry to run", calls)
+		}
+	})
+
+	t.Run("releases the key when the handler panics", func(t *testing.T) {
+		panicWith = errors.New("boom")
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != panicWith {
+					t.Errorf("recovered %v; want the handler's panic", recovered)
+				}
+			}()
+			serve("panic", `{"amount": 10}`)
+		}()
+		panicWith = nil
+
+		if keys.get("panic") != nil {
+			t.Error("key still reserved after a panic")
+		}
+	})
+
+	t.Run("releases the key when the response cannot be stored", func(t *testing.T) {
+		keys.mu.Lock()
+		keys.completeErr = errors.New("connection reset")
+		keys.mu.Unlock()
+		defer func() {
+			keys.mu.Lock()
+			keys.completeErr = nil
+			keys.mu.Unlock()
+		}()
+
+		serve("unstored", `{"amount": 10}`)
+		if keys.get("unstored") != nil {
+			t.Error("key still reserved after its response failed to store")
+		}
+	})
+}
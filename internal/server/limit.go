@@ -0,0 +1,232 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeLimitError answers a transaction that would break a limit with 409
+// and the limit it hit, and reports whether err was such a rejection.
+func writeLimitError(w http.ResponseWriter, message string, err error) bool {
+	var limitErr *repositories.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	utils.WriteJSONResponse(w, http.StatusConflict, message, models.Response{
+		StatusCode: http.StatusConflict,
+		Success:    false,
+		Message:    message,
+		Data: map[string]interface{}{
+			"error":   err.Error(),
+			"measure": limitErr.Breach.Measure,
+			"limit":   limitErr.Breach.Limit,
+		},
+	})
+	return true
+}
+
+// @Summary Get account limits
+// @Description List the transaction limits on one of the authenticated user's accounts and how much of each is left in the current period. Periods are calendar days, weeks starting Monday, and months, in UTC.
+// @Tags account
+// @Produce json
+// @Param id query int true "Account ID"
+// @Success 200 {object} models.Response{data=[]models.LimitUsage}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /account/limits [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AccountService) GetAccountLimits(w http.ResponseWriter, r *http.Request, accountID int, userID int) {
+	limitRepository := repositories.NewLimitRepository(s.db)
+	limits, err := limitRepository.GetAccountLimits(accountID, userID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Account limits fetched successfully", limits)
+}
+
+// @Summary List transaction limits
+// @Description List every configured limit: defaults, account tier limits and user overrides
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.TransactionLimit}
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/limits [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) ListLimits(w http.ResponseWriter, r *http.Request) {
+	limitRepository := repositories.NewLimitRepository(s.db)
+	limits, err := limitRepository.ListLimits()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to list limits", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Limits retrieved successfully", limits)
+}
+
+// @Summary Set a transaction limit
+// @Description Create a limit, or replace the caps of the limit with the same type, currency, period, scope and target. Set account_tier for a tier limit, user_id for a user override, or neither for the default. A user override beats the tier limit, which beats the default.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param limit body models.SetLimitRequest true "Limit to set"
+// @Success 200 {object} models.Response{data=models.TransactionLimit}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/limits [put]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) SetLimit(w http.ResponseWriter, r *http.Request) {
+	var limitRequest models.SetLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&limitRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := validateSetLimitRequest(&limitRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	limitRepository := repositories.NewLimitRepository(s.db)
+	limit, err := limitRepository.SetLimit(limitRequest, auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidAmount) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+	if err != nil {
+		writeLookupError(w, "User", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Limit saved successfully", limit)
+}
+
+func validateSetLimitRequest(request *models.SetLimitRequest) error {
+	request.TransactionType = models.TransactionType(strings.ToUpper(string(request.TransactionType)))
+	switch request.TransactionType {
+	case models.Deposit, models.Withdrawal, models.Transfer:
+	default:
+		return fmt.Errorf("transaction_type must be one of DEPOSIT, WITHDRAWAL, TRANSFER")
+	}
+
+	request.Currency = models.Currency(strings.ToUpper(string(request.Currency)))
+	if _, err := request.Currency.Info(); err != nil {
+		return err
+	}
+
+	request.Period = models.LimitPeriod(strings.ToLower(string(request.Period)))
+	if !request.Period.Valid() {
+		return fmt.Errorf("period must be one of daily, weekly, monthly")
+	}
+
+	if request.Scope == "" {
+		request.Scope = models.LimitPerAccount
+	}
+	request.Scope = models.LimitScope(strings.ToLower(string(request.Scope)))
+	if !request.Scope.Valid() {
+		return fmt.Errorf("scope must be account or user")
+	}
+
+	if request.AccountTier != nil && request.UserID != nil {
+		return fmt.Errorf("set either account_tier or user_id, not both")
+	}
+	if request.AccountTier != nil && !request.AccountTier.Valid() {
+		return fmt.Errorf("account_tier must be one of standard, premium, business")
+	}
+	if request.UserID != nil && *request.UserID < 1 {
+		return fmt.Errorf("user_id must be a positive integer")
+	}
+
+	if request.MaxAmount == nil && request.MaxCount == nil {
+		return fmt.Errorf("set max_amount, max_count or both")
+	}
+	if request.MaxAmount != nil && request.MaxAmount.IsNegative() {
+		return fmt.Errorf("max_amount must not be negative")
+	}
+	if request.MaxCount != nil && *request.MaxCount < 0 {
+		return fmt.Errorf("max_count must not be negative")
+	}
+	return nil
+}
+
+// @Summary Delete a transaction limit
+// @Description Remove a limit. Accounts it applied to fall back to the next less specific limit, if any.
+// @Tags admin
+// @Produce json
+// @Param id query int true "Limit ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/limits [delete]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) DeleteLimit(w http.ResponseWriter, r *http.Request) {
+	limitID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid limit ID", err)
+		return
+	}
+
+	limitRepository := repositories.NewLimitRepository(s.db)
+	if err := limitRepository.DeleteLimit(limitID, auditActor(r)); err != nil {
+		writeLookupError(w, "Limit", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Limit deleted successfully", nil)
+}
+
+// @Summary Change an account's tier
+// @Description Move an account to another tier, which changes the limits that apply to it
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param tier body models.UpdateAccountTierRequest true "Account and tier"
+// @Success 200 {object} models.Response{data=models.Account}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /admin/accounts/tier [put]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AdminService) UpdateAccountTier(w http.ResponseWriter, r *http.Request) {
+	var tierRequest models.UpdateAccountTierRequest
+	if err := json.NewDecoder(r.Body).Decode(&tierRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	tierRequest.Tier = models.AccountTier(strings.ToLower(string(tierRequest.Tier)))
+	if !tierRequest.Tier.Valid() {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid tier", fmt.Errorf("tier must be one of standard, premium, business"))
+		return
+	}
+
+	limitRepository := repositories.NewLimitRepository(s.db)
+	account, err := limitRepository.SetAccountTier(tierRequest.AccountID, tierRequest.Tier, auditActor(r))
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Account tier updated successfully", account)
+}
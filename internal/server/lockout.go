@@ -0,0 +1,184 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/repositories"
+	"banking-system/utils"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLoginMaxFailures   = 5
+	defaultLoginMaxIPFailures = 50
+	defaultLoginLockout       = 15 * time.Minute
+
+	// loginBaseDelay is the wait after the first failure for an email. It
+	// doubles with each further failure, up to loginMaxDelay.
+	loginBaseDelay = time.Second
+	loginMaxDelay  = time.Minute
+)
+
+// loginPolicy decides when a login may be attempted again. Failures count
+// for lockout while they are younger than lockout:
+//
+//   - each failure for an email delays the next attempt for it, doubling
+//     from loginBaseDelay
+//   - maxFailures failures lock the email until the oldest of them ages out
+//   - maxIPFailures failures from one IP, across any emails, lock the IP
+//     the same way
+//
+// The same rules apply to emails without an account, so a lockout does not
+// reveal who has one.
+type loginPolicy struct {
+	maxFailures   int
+	maxIPFailures int
+	lockout       time.Duration
+}
+
+func loginPolicyFromEnv() loginPolicy {
+	return loginPolicy{
+		maxFailures:   envPositiveInt("LOGIN_MAX_FAILURES", defaultLoginMaxFailures),
+		maxIPFailures: envPositiveInt("LOGIN_MAX_IP_FAILURES", defaultLoginMaxIPFailures),
+		lockout:       envPositiveDuration("LOGIN_LOCKOUT_DURATION", defaultLoginLockout),
+	}
+}
+
+func envPositiveInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func envPositiveDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// retryAfter returns how long until a login may be attempted, given recent
+// failures for the email and from the IP, newest first. Zero means now.
+func (p loginPolicy) retryAfter(emailFailures []time.Time, ipFailures []time.Time, now time.Time) time.Duration {
+	var until time.Time
+
+	if n := len(emailFailures); n >= p.maxFailures {
+		until = emailFailures[p.maxFailures-1].Add(p.lockout)
+	} else if n > 0 {
+		delay := loginBaseDelay << (n - 1)
+		if delay > loginMaxDelay || delay <= 0 {
+			delay = loginMaxDelay
+		}
+		until = emailFailures[0].Add(delay)
+	}
+
+	if len(ipFailures) >= p.maxIPFailures {
+		if ipUntil := ipFailures[p.maxIPFailures-1].Add(p.lockout); ipUntil.After(until) {
+			until = ipUntil
+		}
+	}
+
+	if wait := until.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// loginThrottle applies a loginPolicy to every check of a password or a
+// two-factor code. Logins, two-factor logins, turning two-factor off and
+// changing the password share one failure count per email, so no endpoint
+// allows more guesses than the login does.
+type loginThrottle struct {
+	db     database.Service
+	policy loginPolicy
+}
+
+func newLoginThrottle(db database.Service) *loginThrottle {
+	return &loginThrottle{db: db, policy: loginPolicyFromEnv()}
+}
+
+// loginAttempt is an attempt let through by loginThrottle.begin. It counts
+// as a failure unless the caller calls succeeded or forget.
+type loginAttempt struct {
+	throttle *loginThrottle
+	id       int64
+	email    string
+}
+
+// begin records an attempt for email from ip before the password or code
+// is checked. The check and the record happen under one lock, so parallel
+// attempts cannot all slip through before any failure is counted. When
+// email or ip must wait, it answers 429 with a Retry-After header and
+// returns false.
+func (t *loginThrottle) begin(w http.ResponseWriter, email string, ip string) (loginAttempt, bool) {
+	now := time.Now()
+	failedLoginRepository := repositories.NewFailedLoginRepository(t.db)
+	id, wait, err := failedLoginRepository.BeginAttempt(email, ip, now.Add(-t.policy.lockout), t.policy.maxFailures, t.policy.maxIPFailures,
+		func(emailFailures []time.Time, ipFailures []time.Time) time.Duration {
+			return t.policy.retryAfter(emailFailures, ipFailures, now)
+		})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to check failed attempts", err)
+		return loginAttempt{}, false
+	}
+
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		utils.WriteJSONError(w, http.StatusTooManyRequests, "Too many failed attempts",
+			fmt.Errorf("try again in %d seconds", seconds))
+		return loginAttempt{}, false
+	}
+
+	return loginAttempt{throttle: t, id: id, email: email}, true
+}
+
+// succeeded clears the email's failures, this attempt included. It only
+// logs errors, so the caller can still answer.
+func (a loginAttempt) succeeded() {
+	if err := repositories.NewFailedLoginRepository(a.throttle.db).ClearFailures(a.email); err != nil {
+		log.Printf("Failed to clear failed logins: %v", err)
+	}
+}
+
+// forget drops this attempt but keeps earlier failures: the password was
+// right but a second factor is still due, or the check itself errored.
+func (a loginAttempt) forget() {
+	if err := repositories.NewFailedLoginRepository(a.throttle.db).ForgetAttempt(a.id); err != nil {
+		log.Printf("Failed to forget login attempt: %v", err)
+	}
+}
+
+// startFailedLoginCleanup drops failed logins that no longer count, once an
+// hour.
+func (s *Server) startFailedLoginCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		failedLoginRepository := repositories.NewFailedLoginRepository(s.db)
+		for range ticker.C {
+			if _, err := failedLoginRepository.DeleteExpired(time.Now().Add(-s.authService.throttle.policy.lockout)); err != nil {
+				log.Printf("Failed to delete expired failed logins: %v", err)
+			}
+		}
+	}()
+}
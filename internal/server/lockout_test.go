@@ -0,0 +1,289 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginPolicyRetryAfter(t *testing.T) {
+	policy := loginPolicy{maxFailures: 3, maxIPFailures: 4, lockout: 15 * time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d ...time.Duration) []time.Time {
+		times := make([]time.Time, len(d))
+		for i := range d {
+			times[i] = now.Add(-d[i])
+		}
+		return times
+	}
+
+	tests := []struct {
+		name   string
+		email  []time.Time
+		ip     []time.Time
+		expect time.Duration
+	}{
+		{"no failures", nil, nil, 0},
+		{"first failure delays a second", ago(0), ago(0), time.Second},
+		{"delay has passed", ago(2 * time.Second), ago(2 * time.Second), 0},
+		{"delay doubles", ago(time.Second, time.Minute), ago(time.Second, time.Minute), time.Second},
+		{"locked until the oldest failure ages out", ago(time.Minute, 2*time.Minute, 5*time.Minute), nil, 10 * time.Minute},
+		{"lock ends", ago(15*time.Minute, 16*time.Minute, 17*time.Minute), nil, 0},
+		{"ip locked across emails", nil, ago(time.Minute, 2*time.Minute, 3*time.Minute, 4*time.Minute), 11 * time.Minute},
+		{"ip below limit", nil, ago(time.Minute, 2*time.Minute, 3*time.Minute), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryAfter(tt.email, tt.ip, now); got != tt.expect {
+				t.Errorf("expected %s; got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+// failedLogin is a row of the failed_logins table kept by scriptFailedLogins.
+type failedLogin struct {
+	id          int64
+	email       string
+	ip          string
+	attemptedAt time.Time
+}
+
+type failedLogins struct {
+	mu     sync.Mutex
+	rows   []failedLogin
+	nextID int64
+	// readDelay slows every read, to widen the window between reading
+	// failures and recording one
+	readDelay time.Duration
+}
+
+func (f *failedLogins) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.rows)
+}
+
+// scriptFailedLogins answers the failed login statements from an in-memory
+// table.
+func scriptFailedLogins(d *scriptedDriver) *failedLogins {
+	table := &failedLogins{}
+
+	recent := func(match func(failedLogin, string) bool) func(args []driver.Value) ([][]driver.Value, error) {
+		return func(args []driver.Value) ([][]driver.Value, error) {
+			time.Sleep(table.readDelay)
+			table.mu.Lock()
+			defer table.mu.Unlock()
+
+			var times []time.Time
+			for _, row := range table.rows {
+				if match(row, args[0].(string)) && row.attemptedAt.After(args[1].(time.Time)) {
+					times = append(times, row.attemptedAt)
+				}
+			}
+			sort.Slice(times, func(i, j int) bool { return times[i].After(times[j]) })
+
+			var rows [][]driver.Value
+			for i := 0; i < len(times) && i < int(args[2].(int64)); i++ {
+				rows = append(rows, []driver.Value{times[i]})
+			}
+			return rows, nil
+		}
+	}
+	d.on("WHERE email = $1 AND attempted_at", recent(func(row failedLogin, email string) bool { return row.email == email }))
+	d.on("WHERE ip = $1 AND attempted_at", recent(func(row failedLogin, ip string) bool { return row.ip == ip }))
+	d.on("INSERT INTO failed_logins", func(args []driver.Value) ([][]driver.Value, error) {
+		table.mu.Lock()
+		defer table.mu.Unlock()
+		table.nextID++
+		table.rows = append(table.rows, failedLogin{id: table.nextID, email: args[0].(string), ip: args[1].(string), attemptedAt: args[2].(time.Time)})
+		return [][]driver.Value{{table.nextID}}, nil
+	})
+	remove := func(match func(failedLogin, driver.Value) bool) func(args []driver.Value) ([][]driver.Value, error) {
+		return func(args []driver.Value) ([][]driver.Value, error) {
+			table.mu.Lock()
+			defer table.mu.Unlock()
+			kept := table.rows[:0]
+			for _, row := range table.rows {
+				if !match(row, args[0]) {
+					kept = append(kept, row)
+				}
+			}
+			table.rows = kept
+			return [][]driver.Value{{}}, nil
+		}
+	}
+	d.on("DELETE FROM failed_logins WHERE email", remove(func(row failedLogin, email driver.Value) bool { return row.email == email }))
+	d.on("DELETE FROM failed_logins WHERE id", remove(func(row failedLogin, id driver.Value) bool { return row.id == id }))
+
+	return table
+}
+
+// scriptTwoFactorUser answers the user and two-factor statements for
+// ownerID, who has two-factor on and the password "old-password".
+func scriptTwoFactorUser(t *testing.T, d *scriptedDriver) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	d.on("SELECT id, first_name, last_name, email, role", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{int64(ownerID), "Ada", "Lovelace", "Ada@Example.com", string(models.RoleCustomer), now, now}}, nil
+	})
+	d.on("json_agg", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{"[]"}}, nil
+	})
+	d.on("SELECT password FROM users", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{string(hash)}}, nil
+	})
+	d.on("totp_enabled_at IS NOT NULL", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{"JBSWY3DPEHPK3PXP", true, int64(0)}}, nil
+	})
+	// No recovery code matches
+	d.on("UPDATE recovery_codes", func(args []driver.Value) ([][]driver.Value, error) {
+		return nil, nil
+	})
+}
+
+func TestSecondFactorGuessesAreThrottled(t *testing.T) {
+	db, d := newScriptedDB(t)
+	failures := scriptFailedLogins(d)
+	scriptTwoFactorUser(t, d)
+
+	auth := NewAuthService(db, nil)
+	users := NewUserService(db, nil)
+
+	disable := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		auth.DisableTwoFactor(w, httptest.NewRequest(http.MethodPost, "/api/auth/2fa/disable", strings.NewReader(`{"code": "wrong"}`)), ownerID)
+		return w
+	}
+	changePassword := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"old_password": "old-password", "new_password": "new-password", "totp_code": "wrong"}`
+		users.UpdateUserPassword(w, httptest.NewRequest(http.MethodPut, "/api/user/update-password", strings.NewReader(body)), ownerID)
+		return w
+	}
+
+	if w := disable(); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong code: expected status %d; got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+	if n := failures.count(); n != 1 {
+		t.Fatalf("recorded %d failures; want 1", n)
+	}
+
+	// The next guess, at either endpoint, waits out the delay
+	for name, try := range map[string]func() *httptest.ResponseRecorder{"disable": disable, "change password": changePassword} {
+		w := try()
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected status %d; got %d: %s", name, http.StatusTooManyRequests, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: 429 without a Retry-After header", name)
+		}
+	}
+
+	// Once the delay has passed, a wrong code on the password change counts
+	// as well
+	failures.mu.Lock()
+	failures.rows[0].attemptedAt = failures.rows[0].attemptedAt.Add(-time.Minute)
+	failures.mu.Unlock()
+
+	if w := changePassword(); w.Code != http.StatusUnauthorized {
+		t.Fatalf("change password: expected status %d; got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+	if n := failures.count(); n != 2 {
+		t.Errorf("recorded %d failures; want 2", n)
+	}
+	if writes := d.statements("SET password"); len(writes) != 0 {
+		t.Error("password changed with a wrong two-factor code")
+	}
+}
+
+func TestLoginThrottleCountsConcurrentAttempts(t *testing.T) {
+	db, d := newScriptedDB(t)
+	failures := scriptFailedLogins(d)
+	failures.readDelay = 10 * time.Millisecond
+	auth := NewAuthService(db, nil)
+
+	// Every attempt starts together; without the lock they would all read
+	// no failures and all get to guess
+	const attempts = 10
+	codes := make(chan int, attempts)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			w := httptest.NewRecorder()
+			body := `{"email": "nobody@example.com", "password": "guess"}`
+			auth.Login(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body)))
+			codes <- w.Code
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusUnauthorized] != 1 || counts[http.StatusTooManyRequests] != attempts-1 {
+		t.Errorf("expected one 401 and %d 429s; got %v", attempts-1, counts)
+	}
+	if n := failures.count(); n != 1 {
+		t.Errorf("recorded %d failures; want 1", n)
+	}
+}
+
+func TestLoginAttemptWithRightPasswordIsForgotten(t *testing.T) {
+	db, d := newScriptedDB(t)
+	failures := scriptFailedLogins(d)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	d.on("FROM users\n\t\tWHERE email", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{int64(ownerID), "Ada", "Lovelace", "ada@example.com", string(hash), string(models.RoleCustomer), now, now}}, nil
+	})
+	d.on("SELECT totp_enabled_at IS NOT NULL FROM users", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{true}}, nil
+	})
+
+	// An earlier failure whose delay has passed
+	failures.mu.Lock()
+	failures.rows = append(failures.rows, failedLogin{id: 99, email: "ada@example.com", ip: "192.0.2.1", attemptedAt: now.UTC().Add(-time.Minute)})
+	failures.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	body := `{"email": "ada@example.com", "password": "old-password"}`
+	NewAuthService(db, nil).Login(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "mfa_token") {
+		t.Fatalf("expected a two-factor challenge; got %d: %s", w.Code, w.Body.String())
+	}
+	// The password was right, so its attempt is gone; the code is still due,
+	// so the earlier failure stays
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	if len(failures.rows) != 1 || failures.rows[0].id != 99 {
+		t.Errorf("failed logins = %+v; want only the earlier failure", failures.rows)
+	}
+}
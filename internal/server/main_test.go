@@ -0,0 +1,13 @@
+package server
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Tokens issued by the handlers under test are signed with a key that
+	// lives as long as the test binary
+	os.Setenv("JWT_EPHEMERAL_KEY", "true")
+	os.Exit(m.Run())
+}
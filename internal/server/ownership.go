@@ -0,0 +1,20 @@
+package server
+
+import (
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// writeLookupError answers a failed resource lookup. Resources that do not
+// exist and resources owned by someone else both produce a 404, so callers
+// cannot probe for other users' IDs.
+func writeLookupError(w http.ResponseWriter, resource string, err error) {
+	if errors.Is(err, repositories.ErrNotFound) {
+		utils.WriteJSONError(w, http.StatusNotFound, resource+" not found", err)
+		return
+	}
+	utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get "+strings.ToLower(resource), err)
+}
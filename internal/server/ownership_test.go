@@ -0,0 +1,350 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	ownerID    = 1
+	intruderID = 2
+)
+
+// fixtureRow is a row owned by a user, stored in the column order the
+// repositories select it in.
+type fixtureRow struct {
+	id     int64
+	userID int64
+	values []driver.Value
+}
+
+// ownershipDriver is a minimal database/sql driver serving fixed rows. It
+// only applies the "id = $n" and "user_id = $n" predicates it finds in the
+// query, so a repository that forgets to scope a lookup to the caller hands
+// the row to anyone.
+type ownershipDriver struct {
+	mu     sync.Mutex
+	tables map[string][]fixtureRow
+}
+
+var (
+	predicatePattern = regexp.MustCompile(`(?:\w+\.)?\b(id|user_id) = \$(\d+)`)
+	// timestampReturningPattern matches updates that only return the times
+	// they stamped, such as closed_at
+	timestampReturningPattern = regexp.MustCompile(`RETURNING (\w+_at(?:, \w+_at)*)\s*$`)
+	fixtures                  = &ownershipDriver{}
+)
+
+func init() {
+	sql.Register("ownership-fixtures", fixtures)
+}
+
+func (d *ownershipDriver) Open(string) (driver.Conn, error) { return &ownershipConn{driver: d}, nil }
+
+type ownershipConn struct{ driver *ownershipDriver }
+
+func (c *ownershipConn) Prepare(query string) (driver.Stmt, error) {
+	return &ownershipStmt{driver: c.driver, query: query}, nil
+}
+func (c *ownershipConn) Close() error              { return nil }
+func (c *ownershipConn) Begin() (driver.Tx, error) { return ownershipTx{}, nil }
+func (c *ownershipConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return ownershipTx{}, nil
+}
+
+type ownershipTx struct{}
+
+func (ownershipTx) Commit() error   { return nil }
+func (ownershipTx) Rollback() error { return nil }
+
+type ownershipStmt struct {
+	driver *ownershipDriver
+	query  string
+}
+
+func (s *ownershipStmt) Close() error  { return nil }
+func (s *ownershipStmt) NumInput() int { return -1 }
+
+// table picks the table a statement reads from. Transactions are checked
+// first because their column list embeds a subquery on accounts, and
+// accounts before holds for the same reason.
+func (s *ownershipStmt) table() string {
+	for _, table := range []string{"transactions", "statements", "accounts", "holds", "scheduled_transfers", "statement_jobs"} {
+		if strings.Contains(s.query, "FROM "+table) || strings.Contains(s.query, "UPDATE "+table) {
+			return table
+		}
+	}
+	return ""
+}
+
+func (s *ownershipStmt) matching(args []driver.Value) []int {
+	var matches []int
+	for i, row := range s.driver.tables[s.table()] {
+		ok := true
+		for _, m := range predicatePattern.FindAllStringSubmatch(s.query, -1) {
+			n, _ := strconv.Atoi(m[2])
+			want := row.id
+			if m[1] == "user_id" {
+				want = row.userID
+			}
+			if got, _ := args[n-1].(int64); got != want {
+				ok = false
+			}
+		}
+		if ok {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func (s *ownershipStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+
+	statement := strings.TrimSpace(s.query)
+	matches := s.matching(args)
+
+	// Updates only report the rows they would touch; the tests check who
+	// may reach a row, not what is written to it
+	if strings.HasPrefix(statement, "UPDATE") {
+		return driver.RowsAffected(len(matches)), nil
+	}
+	// Audit events and the lock that orders them are accepted and dropped
+	if strings.HasPrefix(statement, "SELECT pg_advisory_xact_lock") || strings.HasPrefix(statement, "INSERT INTO audit_events") {
+		return driver.RowsAffected(1), nil
+	}
+	if !strings.HasPrefix(statement, "DELETE") {
+		return nil, fmt.Errorf("unexpected statement: %s", s.query)
+	}
+
+	table := s.driver.tables[s.table()]
+	for i := len(matches) - 1; i >= 0; i-- {
+		table = append(table[:matches[i]], table[matches[i]+1:]...)
+	}
+	s.driver.tables[s.table()] = table
+
+	return driver.RowsAffected(len(matches)), nil
+}
+
+func (s *ownershipStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+
+	rows := &ownershipRows{}
+	// The fixtures hold no money on hold
+	if strings.HasPrefix(strings.TrimSpace(s.query), "SELECT COALESCE((") {
+		rows.values = append(rows.values, []driver.Value{"0.00"})
+		return rows, nil
+	}
+	if m := timestampReturningPattern.FindStringSubmatch(s.query); m != nil {
+		for range s.matching(args) {
+			var stamps []driver.Value
+			for range strings.Split(m[1], ",") {
+				stamps = append(stamps, time.Now())
+			}
+			rows.values = append(rows.values, stamps)
+		}
+		return rows, nil
+	}
+	for _, i := range s.matching(args) {
+		rows.values = append(rows.values, s.driver.tables[s.table()][i].values)
+	}
+	return rows, nil
+}
+
+type ownershipRows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *ownershipRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	columns := make([]string, len(r.values[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return columns
+}
+func (r *ownershipRows) Close() error { return nil }
+func (r *ownershipRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// testDB implements the parts of database.Service the handlers under test use.
+type testDB struct {
+	database.Service
+	db *sql.DB
+}
+
+func (t *testDB) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.db.QueryRowContext(ctx, query, args...)
+}
+
+func (t *testDB) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.db.QueryContext(ctx, query, args...)
+}
+
+func (t *testDB) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.db.ExecContext(ctx, query, args...)
+}
+
+func (t *testDB) ExecTx(ctx context.Context, fn func(*sql.Tx) error, opts *sql.TxOptions) error {
+	tx, err := t.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (t *testDB) ExecTxReadOnly(ctx context.Context, fn func(*sql.Tx) error) error {
+	return t.ExecTx(ctx, fn, &sql.TxOptions{ReadOnly: true})
+}
+
+func newOwnershipTestDB(t *testing.T) (*testDB, string) {
+	t.Helper()
+
+	pdfPath := filepath.Join(t.TempDir(), "statement.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	fixtures.mu.Lock()
+	fixtures.tables = map[string][]fixtureRow{
+		"accounts": {
+			{id: 10, userID: ownerID, values: []driver.Value{int64(10), int64(ownerID), "150.00", "150.00", "USD", "Savings", "", "active", "standard", nil, now, now}},
+		},
+		"transactions": {
+			{id: 20, userID: ownerID, values: []driver.Value{int64(20), int64(10), "150.00", "USD", "DEPOSIT", "COMPLETED", "CREDIT", nil, "", now, now, "ref-20", nil, nil, nil, nil, "0.00"}},
+		},
+		"holds": {
+			{id: 40, userID: ownerID, values: []driver.Value{int64(40), int64(10), int64(21), "25.00", nil, "USD", "authorized", "", now.Add(time.Hour), now, now}},
+		},
+		"scheduled_transfers": {
+			{id: 50, userID: ownerID, values: []driver.Value{int64(50), int64(ownerID), int64(10), nil, "WITHDRAWAL", "25.00", "USD", "", "monthly", now, nil, "active", int64(0), int64(0), now.Add(time.Hour), nil, now, now}},
+		},
+		"statement_jobs": {
+			{id: 60, userID: ownerID, values: []driver.Value{int64(60), int64(ownerID), "queued", `{"start_date":"2024-03-01T00:00:00Z","end_date":"2024-03-31T00:00:00Z","account_id":10,"item_count":100,"currency":"USD"}`, int64(0), nil, "", nil, nil, now, now}},
+		},
+		"statements": {
+			{id: 30, userID: ownerID, values: []driver.Value{int64(30), pdfPath, int64(ownerID), now, now, now}},
+		},
+	}
+	fixtures.mu.Unlock()
+
+	db, err := sql.Open("ownership-fixtures", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &testDB{db: db}, pdfPath
+}
+
+func TestOwnershipEnforcement(t *testing.T) {
+	db, _ := newOwnershipTestDB(t)
+	accounts := NewAccountService(db)
+	transactions := NewTransactionService(db)
+	statements := NewSOAService(db)
+	holds := NewHoldService(db)
+	scheduled := NewScheduledTransferService(db)
+
+	tests := []struct {
+		name  string
+		serve func(w http.ResponseWriter, userID int)
+	}{
+		{"get account", func(w http.ResponseWriter, userID int) {
+			accounts.GetAccount(w, httptest.NewRequest(http.MethodGet, "/api/account/get?id=10", nil), 10, userID)
+		}},
+		{"get transaction", func(w http.ResponseWriter, userID int) {
+			transactions.GetTransaction(w, httptest.NewRequest(http.MethodGet, "/api/transaction/get?id=20", nil), 20, userID)
+		}},
+		{"get hold", func(w http.ResponseWriter, userID int) {
+			holds.GetHold(w, httptest.NewRequest(http.MethodGet, "/api/hold/get?id=40", nil), 40, userID)
+		}},
+		{"get scheduled transfer", func(w http.ResponseWriter, userID int) {
+			scheduled.GetScheduledTransfer(w, httptest.NewRequest(http.MethodGet, "/api/scheduled/get?id=50", nil), 50, userID)
+		}},
+		{"get statement job", func(w http.ResponseWriter, userID int) {
+			statements.GetStatementJob(w, httptest.NewRequest(http.MethodGet, "/api/soa/jobs/60", nil), 60, userID)
+		}},
+		{"download statement", func(w http.ResponseWriter, userID int) {
+			statements.DownloadSOA(w, httptest.NewRequest(http.MethodGet, "/api/soa/download?id=30", nil), 30, userID)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intruder := httptest.NewRecorder()
+			tt.serve(intruder, intruderID)
+			if intruder.Code != http.StatusNotFound {
+				t.Errorf("other user: expected status %d; got %d: %s", http.StatusNotFound, intruder.Code, intruder.Body.String())
+			}
+
+			owner := httptest.NewRecorder()
+			tt.serve(owner, ownerID)
+			if owner.Code != http.StatusOK {
+				t.Errorf("owner: expected status %d; got %d: %s", http.StatusOK, owner.Code, owner.Body.String())
+			}
+		})
+	}
+}
+
+func TestOwnershipEnforcementDeleteAccount(t *testing.T) {
+	db, _ := newOwnershipTestDB(t)
+	accounts := NewAccountService(db)
+
+	// Only an empty account can be closed without a sweep
+	fixtures.mu.Lock()
+	fixtures.tables["accounts"][0].values[2] = "0.00"
+	fixtures.mu.Unlock()
+
+	intruder := httptest.NewRecorder()
+	accounts.DeleteAccount(intruder, httptest.NewRequest(http.MethodDelete, "/api/account/delete?id=10", nil), intruderID)
+	if intruder.Code != http.StatusNotFound {
+		t.Fatalf("other user: expected status %d; got %d: %s", http.StatusNotFound, intruder.Code, intruder.Body.String())
+	}
+
+	owner := httptest.NewRecorder()
+	accounts.DeleteAccount(owner, httptest.NewRequest(http.MethodDelete, "/api/account/delete?id=10", nil), ownerID)
+	if owner.Code != http.StatusOK {
+		t.Fatalf("owner: expected status %d; got %d: %s", http.StatusOK, owner.Code, owner.Body.String())
+	}
+}
+
+func TestCloseAccountWithBalance(t *testing.T) {
+	db, _ := newOwnershipTestDB(t)
+	accounts := NewAccountService(db)
+
+	w := httptest.NewRecorder()
+	accounts.DeleteAccount(w, httptest.NewRequest(http.MethodDelete, "/api/account/delete?id=10", nil), ownerID)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected status %d; got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+}
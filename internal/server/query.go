@@ -0,0 +1,180 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// parsePagination reads page and pageSize. Both are optional, but when
+// present they must be positive and pageSize may not exceed maxPageSize.
+func parsePagination(query url.Values) (*models.PaginationRequest, error) {
+	pagination := &models.PaginationRequest{
+		Page:     1,
+		PageSize: defaultPageSize,
+	}
+
+	if page := query.Get("page"); page != "" {
+		val, err := strconv.Atoi(page)
+		if err != nil || val < 1 {
+			return nil, fmt.Errorf("page must be a positive integer")
+		}
+		pagination.Page = val
+	}
+
+	if pageSize := query.Get("pageSize"); pageSize != "" {
+		val, err := strconv.Atoi(pageSize)
+		if err != nil || val < 1 || val > maxPageSize {
+			return nil, fmt.Errorf("pageSize must be an integer between 1 and %d", maxPageSize)
+		}
+		pagination.PageSize = val
+	}
+
+	return pagination, nil
+}
+
+// parseCursorPagination switches a listing to keyset pagination when the
+// cursor parameter is present; an empty cursor asks for the first page. It
+// returns nil for offset pagination. Cursor pages are always ordered newest
+// first, so page and sorting parameters are rejected alongside a cursor.
+func parseCursorPagination(query url.Values) (*models.CursorPaginationRequest, error) {
+	if !query.Has("cursor") {
+		return nil, nil
+	}
+
+	for _, key := range []string{"page", "sortField", "sortDirection"} {
+		if query.Has(key) {
+			return nil, fmt.Errorf("%s cannot be combined with cursor", key)
+		}
+	}
+
+	pagination, err := parsePagination(query)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &models.CursorPaginationRequest{PageSize: pagination.PageSize}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.DecodeCursor(value)
+		if err != nil {
+			return nil, fmt.Errorf("cursor: %w", err)
+		}
+		request.Cursor = &cursor
+	}
+
+	if value := query.Get("includeTotal"); value != "" {
+		if request.IncludeTotal, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("includeTotal must be true or false")
+		}
+	}
+
+	return request, nil
+}
+
+// parseSort reads sortField and sortDirection. sortField must be one of
+// allowedFields; sortDirection is ASC or DESC in any case.
+func parseSort(query url.Values, allowedFields []string) (*models.SortRequest, error) {
+	sort := &models.SortRequest{
+		Field:     query.Get("sortField"),
+		Direction: strings.ToUpper(query.Get("sortDirection")),
+	}
+
+	if sort.Field != "" {
+		allowed := false
+		for _, field := range allowedFields {
+			if sort.Field == field {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("sortField must be one of %s", strings.Join(allowedFields, ", "))
+		}
+	}
+
+	if sort.Direction != "" && sort.Direction != "ASC" && sort.Direction != "DESC" {
+		return nil, fmt.Errorf("sortDirection must be ASC or DESC")
+	}
+
+	return sort, nil
+}
+
+// parseDateRange reads dateFrom and dateTo as RFC3339 timestamps.
+func parseDateRange(query url.Values) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+
+	if dateFrom := query.Get("dateFrom"); dateFrom != "" {
+		date, err := time.Parse(time.RFC3339, dateFrom)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dateFrom must be an RFC3339 timestamp")
+		}
+		from = &date
+	}
+
+	if dateTo := query.Get("dateTo"); dateTo != "" {
+		date, err := time.Parse(time.RFC3339, dateTo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dateTo must be an RFC3339 timestamp")
+		}
+		to = &date
+	}
+
+	if from != nil && to != nil && from.After(*to) {
+		return nil, nil, fmt.Errorf("dateFrom must not be after dateTo")
+	}
+
+	return from, to, nil
+}
+
+// parseAmountRange reads an optional minimum and maximum amount.
+func parseAmountRange(query url.Values, minKey string, maxKey string) (*models.Amount, *models.Amount, error) {
+	var min, max *models.Amount
+
+	if value := query.Get(minKey); value != "" {
+		amount, err := models.ParseAmount(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s must be a decimal number", minKey)
+		}
+		min = &amount
+	}
+
+	if value := query.Get(maxKey); value != "" {
+		amount, err := models.ParseAmount(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s must be a decimal number", maxKey)
+		}
+		max = &amount
+	}
+
+	if min != nil && max != nil && min.Cmp(*max) > 0 {
+		return nil, nil, fmt.Errorf("%s must not be greater than %s", minKey, maxKey)
+	}
+
+	return min, max, nil
+}
+
+// parseCurrency reads an optional currency from key and checks that it is
+// in the catalogue. Disabled currencies are accepted so that existing
+// balances held in them can still be found.
+func parseCurrency(query url.Values, key string) (*models.Currency, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	currency := models.Currency(strings.ToUpper(value))
+	if _, err := currency.MinorUnits(); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+
+	return &currency, nil
+}
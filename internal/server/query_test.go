@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantErr bool
+	}{
+		{"", false},
+		{"page=2&pageSize=100&sortField=amount&sortDirection=desc", false},
+		{"type=deposit&status=COMPLETED&accountId=3", false},
+		{"type=reversal&status=reversed", false},
+		{"type=refund&status=partially_refunded", false},
+		{"minAmount=10&maxAmount=10.00&dateFrom=2024-03-01T00:00:00Z&dateTo=2024-03-31T00:00:00Z", false},
+		{"page=0", true},
+		{"pageSize=101", true},
+		{"pageSize=abc", true},
+		{"sortField=password", true},
+		{"sortDirection=sideways", true},
+		{"type=CHARGEBACK", true},
+		{"status=done", true},
+		{"accountId=-1", true},
+		{"minAmount=1e3", true},
+		{"minAmount=20&maxAmount=10", true},
+		{"dateFrom=yesterday", true},
+		{"dateFrom=2024-03-31T00:00:00Z&dateTo=2024-03-01T00:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = parseTransactionFilter(query)
+			if err == nil {
+				_, err = parseSort(query, []string{"amount", "transaction_type", "status", "created_at"})
+			}
+			if err == nil {
+				_, err = parsePagination(query)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
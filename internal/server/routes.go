@@ -6,6 +6,7 @@ import (
 	"banking-system/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
@@ -65,13 +66,56 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Auth Routes
 	mux.Handle("/api/auth/register", s.MethodGuard(http.HandlerFunc(s.authService.Register), http.MethodPost))
 	mux.Handle("/api/auth/login", s.MethodGuard(http.HandlerFunc(s.authService.Login), http.MethodPost))
-	mux.Handle("/api/auth/logout", s.MethodGuard(http.HandlerFunc(s.authService.Logout), http.MethodPost))
+	mux.Handle("/api/auth/2fa/verify", s.MethodGuard(http.HandlerFunc(s.authService.VerifyTwoFactor), http.MethodPost))
+	mux.Handle("/api/auth/2fa/enroll", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.authService.EnrollTwoFactor(w, r, userID)
+	})), http.MethodPost))
+	mux.Handle("/api/auth/2fa/confirm", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.authService.ConfirmTwoFactor(w, r, userID)
+	})), http.MethodPost))
+	mux.Handle("/api/auth/2fa/disable", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.authService.DisableTwoFactor(w, r, userID)
+	})), http.MethodPost))
+	mux.Handle("/api/auth/verify-email", s.MethodGuard(http.HandlerFunc(s.authService.VerifyEmail), http.MethodPost))
+	mux.Handle("/api/auth/verify-email/resend", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.authService.ResendEmailVerification(w, r, userID)
+	})), http.MethodPost))
+	mux.Handle("/api/auth/password-reset/request", s.MethodGuard(http.HandlerFunc(s.authService.RequestPasswordReset), http.MethodPost))
+	mux.Handle("/api/auth/password-reset/confirm", s.MethodGuard(http.HandlerFunc(s.authService.ConfirmPasswordReset), http.MethodPost))
+	mux.Handle("/api/auth/refresh", s.MethodGuard(http.HandlerFunc(s.authService.Refresh), http.MethodPost))
+	mux.Handle("/api/auth/logout", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.authService.Logout(w, r, userID)
+	})), http.MethodPost))
+
+	// The client-credentials grant for API keys
+	mux.Handle("/api/oauth/token", s.MethodGuard(http.HandlerFunc(s.apiKeyService.Token), http.MethodPost))
+
+	// API keys are managed with a user login only, so a key cannot mint more
+	mux.Handle("/api/keys/create", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.apiKeyService.CreateAPIKey(w, r, userID)
+	})), http.MethodPost))
+
+	mux.Handle("/api/keys/", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.apiKeyService.GetAPIKeys(w, r, userID)
+	})), http.MethodGet))
+
+	mux.Handle("/api/keys/revoke", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.apiKeyService.RevokeAPIKey(w, r, userID)
+	})), http.MethodPost))
 
 	// Account Routes all routes are protected
 	mux.Handle("/api/account/create", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.accountService.CreateAccount(w, r, userID)
-	})), http.MethodPost))
+	}), models.ScopeAccountsWrite), http.MethodPost))
 
 	mux.Handle("/api/account/get", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
@@ -94,35 +138,62 @@ func (s *Server) RegisterRoutes() http.Handler {
 		}
 
 		s.accountService.GetAccount(w, r, accountIDInt, userID)
-	})), http.MethodGet))
+	}), models.ScopeAccountsRead), http.MethodGet))
 
 	mux.Handle("/api/account/get-accounts", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.accountService.GetAccounts(w, r, userID)
-	})), http.MethodGet))
+	}), models.ScopeAccountsRead), http.MethodGet))
+
+	mux.Handle("/api/account/reconcile", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.accountService.ReconcileAccount(w, r, userID)
+	}), models.ScopeAccountsRead), http.MethodGet))
+
+	mux.Handle("/api/account/limits", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		accountID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", err)
+			return
+		}
+
+		s.accountService.GetAccountLimits(w, r, accountID, userID)
+	}), models.ScopeAccountsRead), http.MethodGet))
+
+	mux.Handle("/api/account/close", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.accountService.CloseAccount(w, r, userID)
+	}), models.ScopeAccountsWrite), http.MethodPost))
 
 	mux.Handle("/api/account/delete", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.accountService.DeleteAccount(w, r, userID)
-	})), http.MethodDelete))
+	}), models.ScopeAccountsWrite), http.MethodDelete))
 
 	// Transaction Routes all routes are protected
-	mux.Handle("/api/transaction/deposit", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/transaction/deposit", s.MethodGuard(s.AuthGuard(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.transactionService.Deposit(w, r, userID)
-	})), http.MethodPost))
+	})), models.ScopeTransactionsWrite), http.MethodPost))
 
-	mux.Handle("/api/transaction/withdraw", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/transaction/withdraw", s.MethodGuard(s.AuthGuard(s.RequireVerifiedEmail(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.transactionService.Withdraw(w, r, userID)
-	})), http.MethodPost))
+	}))), models.ScopeTransactionsWrite), http.MethodPost))
+
+	mux.Handle("/api/transaction/transfer", s.MethodGuard(s.AuthGuard(s.RequireVerifiedEmail(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.transactionService.Transfer(w, r, userID)
+	}))), models.ScopeTransactionsWrite), http.MethodPost))
 
 	mux.Handle("/api/transaction/", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.transactionService.GetTransactions(w, r, userID)
-	})), http.MethodGet))
+	}), models.ScopeTransactionsRead), http.MethodGet))
 
 	mux.Handle("/api/transaction/get", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
 		transactionID := r.URL.Query().Get("id")
 		transactionIDInt, err := strconv.Atoi(transactionID)
 		if err != nil {
@@ -141,14 +212,153 @@ func (s *Server) RegisterRoutes() http.Handler {
 			return
 		}
 
-		s.transactionService.GetTransaction(w, r, transactionIDInt)
-	})), http.MethodGet))
+		s.transactionService.GetTransaction(w, r, transactionIDInt, userID)
+	}), models.ScopeTransactionsRead), http.MethodGet))
+
+	// Reversals and refunds move money on anyone's account, so only admins
+	// may book them
+	mux.Handle("/api/transaction/{id}/reverse", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transactionID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid transaction ID", err)
+			return
+		}
+
+		s.transactionService.ReverseTransaction(w, r, transactionID)
+	}), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+
+	mux.Handle("/api/transaction/{id}/refund", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		transactionID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid transaction ID", err)
+			return
+		}
+
+		s.transactionService.RefundTransaction(w, r, transactionID)
+	}), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+
+	// Hold Routes: reserve money now, capture or release it later
+	mux.Handle("/api/hold/authorize", s.MethodGuard(s.AuthGuard(s.RequireVerifiedEmail(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.holdService.AuthorizeHold(w, r, userID)
+	}))), models.ScopeHoldsWrite), http.MethodPost))
+
+	mux.Handle("/api/hold/capture", s.MethodGuard(s.AuthGuard(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.holdService.CaptureHold(w, r, userID)
+	})), models.ScopeHoldsWrite), http.MethodPost))
+
+	mux.Handle("/api/hold/release", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.holdService.ReleaseHold(w, r, userID)
+	}), models.ScopeHoldsWrite), http.MethodPost))
+
+	mux.Handle("/api/hold/", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.holdService.GetHolds(w, r, userID)
+	}), models.ScopeHoldsRead), http.MethodGet))
+
+	mux.Handle("/api/hold/get", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		holdID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid hold ID", err)
+			return
+		}
+
+		s.holdService.GetHold(w, r, holdID, userID)
+	}), models.ScopeHoldsRead), http.MethodGet))
+
+	// Scheduled Routes: future-dated and recurring transfers and withdrawals
+	mux.Handle("/api/scheduled/create", s.MethodGuard(s.AuthGuard(s.RequireVerifiedEmail(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.scheduledTransferService.CreateScheduledTransfer(w, r, userID)
+	}))), models.ScopeScheduledWrite), http.MethodPost))
+
+	mux.Handle("/api/scheduled/", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.scheduledTransferService.GetScheduledTransfers(w, r, userID)
+	}), models.ScopeScheduledRead), http.MethodGet))
+
+	mux.Handle("/api/scheduled/get", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		scheduledTransferID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid scheduled transfer ID", err)
+			return
+		}
+
+		s.scheduledTransferService.GetScheduledTransfer(w, r, scheduledTransferID, userID)
+	}), models.ScopeScheduledRead), http.MethodGet))
+
+	mux.Handle("/api/scheduled/runs", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		scheduledTransferID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid scheduled transfer ID", err)
+			return
+		}
+
+		s.scheduledTransferService.GetScheduledTransferRuns(w, r, scheduledTransferID, userID)
+	}), models.ScopeScheduledRead), http.MethodGet))
+
+	mux.Handle("/api/scheduled/pause", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.scheduledTransferService.PauseScheduledTransfer(w, r, userID)
+	}), models.ScopeScheduledWrite), http.MethodPost))
+
+	mux.Handle("/api/scheduled/resume", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.scheduledTransferService.ResumeScheduledTransfer(w, r, userID)
+	}), models.ScopeScheduledWrite), http.MethodPost))
+
+	mux.Handle("/api/scheduled/cancel", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.scheduledTransferService.CancelScheduledTransfer(w, r, userID)
+	}), models.ScopeScheduledWrite), http.MethodPost))
+
+	// Webhook Routes: endpoints receiving the user's events, and their deliveries
+	mux.Handle("/api/webhooks/create", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.webhookService.CreateEndpoint(w, r, userID)
+	}), models.ScopeWebhooksWrite), http.MethodPost))
+
+	mux.Handle("/api/webhooks/", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.webhookService.GetEndpoints(w, r, userID)
+	}), models.ScopeWebhooksRead), http.MethodGet))
+
+	mux.Handle("/api/webhooks/delete", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		endpointID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid webhook endpoint ID", err)
+			return
+		}
+
+		s.webhookService.DeleteEndpoint(w, r, endpointID, userID)
+	}), models.ScopeWebhooksWrite), http.MethodDelete))
+
+	mux.Handle("/api/webhooks/deliveries", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.webhookService.GetDeliveries(w, r, userID)
+	}), models.ScopeWebhooksRead), http.MethodGet))
+
+	mux.Handle("/api/webhooks/deliveries/replay", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.webhookService.ReplayDelivery(w, r, userID)
+	}), models.ScopeWebhooksWrite), http.MethodPost))
+
+	mux.Handle("/api/fx/quote", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.fxService.CreateQuote(w, r, userID)
+	}), models.ScopeTransactionsWrite), http.MethodPost))
 
 	// User Routes all routes are protected
 	mux.Handle("/api/user/view-balance", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.userService.ViewBalance(w, r, userID)
-	})), http.MethodGet))
+	}), models.ScopeAccountsRead), http.MethodGet))
 
 	mux.Handle("/api/user/update-profile", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
@@ -163,20 +373,32 @@ func (s *Server) RegisterRoutes() http.Handler {
 	mux.Handle("/api/user/me", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.userService.GetUser(w, r, userID)
-	})), http.MethodGet))
+	}), models.ScopeProfileRead), http.MethodGet))
 
 	// SOA Routes all routes are protected
-	mux.Handle("/api/soa/generate", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/soa/generate", s.MethodGuard(s.AuthGuard(s.IdempotencyGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
-		s.soaService.GetSOA(w, r, userID)
-	})), http.MethodPost))
+		s.soaService.GenerateSOA(w, r, userID)
+	})), models.ScopeStatementsWrite), http.MethodPost))
+
+	mux.Handle("/api/soa/jobs/{id}", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		jobID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid SOA job ID", err)
+			return
+		}
+
+		s.soaService.GetStatementJob(w, r, jobID, userID)
+	}), models.ScopeStatementsRead), http.MethodGet))
 
 	mux.Handle("/api/soa/generated", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("user_id").(int)
 		s.soaService.GetGeneratedSOA(w, r, userID)
-	})), http.MethodGet))
+	}), models.ScopeStatementsRead), http.MethodGet))
 
 	mux.Handle("/api/soa/download", s.MethodGuard(s.AuthGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
 		soaID := r.URL.Query().Get("id")
 		soaIDInt, err := strconv.Atoi(soaID)
 		if err != nil {
@@ -195,16 +417,72 @@ func (s *Server) RegisterRoutes() http.Handler {
 			return
 		}
 
-		s.soaService.DownloadSOA(w, r, soaIDInt)
-	})), http.MethodGet))
+		s.soaService.DownloadSOA(w, r, soaIDInt, userID)
+	}), models.ScopeStatementsRead), http.MethodGet))
+
+	// Admin Routes are open to support staff for lookups and account freezes,
+	// everything that moves money or changes configuration needs an admin
+	mux.Handle("/api/admin/users", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.SearchUsers), models.RoleSupport, models.RoleAdmin), models.ScopeAdminRead), http.MethodGet))
+
+	mux.Handle("/api/admin/users/role", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("user_id").(int)
+		s.adminService.UpdateUserRole(w, r, userID)
+	}), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPut))
+
+	mux.Handle("/api/admin/users/unlock", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.UnlockUser), models.RoleSupport, models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+
+	mux.Handle("/api/admin/keys", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		s.adminService.ListAPIKeys(w, r, userID)
+	}), models.RoleSupport, models.RoleAdmin)), http.MethodGet))
+	mux.Handle("/api/admin/keys/revoke", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.RevokeAPIKey), models.RoleSupport, models.RoleAdmin)), http.MethodPost))
+
+	mux.Handle("/api/admin/accounts/get", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accountID, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid account ID", err)
+			return
+		}
+
+		s.adminService.GetAccount(w, r, accountID)
+	}), models.RoleSupport, models.RoleAdmin), models.ScopeAdminRead), http.MethodGet))
+
+	mux.Handle("/api/admin/accounts/freeze", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.FreezeAccount), models.RoleSupport, models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+	mux.Handle("/api/admin/accounts/unfreeze", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.UnfreezeAccount), models.RoleSupport, models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+	mux.Handle("/api/admin/accounts/tier", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.UpdateAccountTier), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPut))
+	mux.Handle("/api/admin/transactions/reverse", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.ReverseTransaction), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPost))
+
+	mux.Handle("/api/admin/audit", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.ListAuditEvents), models.RoleAdmin), models.ScopeAdminRead), http.MethodGet))
+	mux.Handle("/api/admin/audit/verify", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.VerifyAuditChain), models.RoleAdmin), models.ScopeAdminRead), http.MethodGet))
+
+	mux.Handle("/api/admin/limits", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			s.RequireScope(http.HandlerFunc(s.adminService.SetLimit), models.ScopeAdminWrite).ServeHTTP(w, r)
+		case http.MethodDelete:
+			s.RequireScope(http.HandlerFunc(s.adminService.DeleteLimit), models.ScopeAdminWrite).ServeHTTP(w, r)
+		default:
+			s.RequireScope(http.HandlerFunc(s.adminService.ListLimits), models.ScopeAdminRead).ServeHTTP(w, r)
+		}
+	}), models.RoleAdmin), models.ScopeAdminRead, models.ScopeAdminWrite), http.MethodGet, http.MethodPut, http.MethodDelete))
+
+	mux.Handle("/api/admin/currencies", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.ListCurrencies), models.RoleAdmin), models.ScopeAdminRead), http.MethodGet))
+	mux.Handle("/api/admin/currencies/update", s.MethodGuard(s.AuthGuard(s.RequireRole(http.HandlerFunc(s.adminService.UpdateCurrency), models.RoleAdmin), models.ScopeAdminWrite), http.MethodPut))
 
 	mux.Handle("/health", s.MethodGuard(http.HandlerFunc(s.healthHandler), http.MethodGet))
+	mux.Handle("/.well-known/jwks.json", s.MethodGuard(http.HandlerFunc(s.jwksHandler), http.MethodGet))
 
 	// Move the root route to the end
 	mux.Handle("/", s.MethodGuard(http.HandlerFunc(s.HelloWorldHandler), http.MethodGet))
 
 	// Add all middleware in the correct order
 	handler := s.corsMiddleware(mux)
+	handler = s.RequestIDMiddleware(handler)
 	handler = s.PrometheusMiddleware(handler)
 	handler = s.LoggerMiddleware(handler)
 
@@ -216,7 +494,8 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*") // Replace "*" with specific origins if needed
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, Idempotency-Key, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "false") // Set to "true" if credentials are required
 
 		// Handle preflight OPTIONS requests
@@ -230,9 +509,11 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) AuthGuard(next http.Handler) http.Handler {
+// AuthGuard lets requests with a valid user access token through, and API
+// keys or client-credentials tokens holding one of scopes. With no scopes
+// the endpoint is for user logins only.
+func (s *Server) AuthGuard(next http.Handler, scopes ...models.APIScope) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("AuthGuard")
 		token := r.Header.Get("Authorization")
 		if token == "" {
 			response := models.Response{
@@ -250,11 +531,15 @@ func (s *Server) AuthGuard(next http.Handler) http.Handler {
 			return
 		}
 
-		log.Println(token)
-
 		tokenString := strings.TrimPrefix(token, "Bearer ")
 		
-		userID, err := utils.ValidateToken(tokenString)
+		var claims utils.TokenClaims
+		var err error
+		if prefix, ok := parseAPIKey(tokenString); ok {
+			claims, err = s.authenticateAPIKey(tokenString, prefix)
+		} else {
+			claims, err = s.authenticateToken(tokenString)
+		}
 		if err != nil {
 			response := models.Response{
 				StatusCode: http.StatusUnauthorized,
@@ -271,9 +556,38 @@ func (s *Server) AuthGuard(next http.Handler) http.Handler {
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "user_id", userID)
+		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+		ctx = context.WithValue(ctx, "token_id", claims.TokenID)
+		ctx = context.WithValue(ctx, "token_expires_at", claims.ExpiresAt)
+		ctx = context.WithValue(ctx, "role", claims.Role)
+		if claims.ClientID != "" {
+			ctx = context.WithValue(ctx, "client_id", claims.ClientID)
+			ctx = context.WithValue(ctx, "scopes", claims.Scopes)
+		}
+		r = r.WithContext(ctx)
+
+		if !checkScopes(w, r, scopes) {
+			return
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole only lets users holding one of roles through. It must run
+// after AuthGuard, which puts the token's role in the request context.
+func (s *Server) RequireRole(next http.Handler, roles ...models.Role) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value("role").(models.Role)
+		for _, allowed := range roles {
+			if role == allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		utils.WriteJSONError(w, http.StatusForbidden, "Forbidden", errors.New("insufficient role"))
 	})
 }
 
@@ -336,6 +650,26 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jwksHandler publishes the public keys access tokens are verified with,
+// so other services can check our tokens.
+func (s *Server) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := utils.JWKS()
+	if err != nil {
+		http.Error(w, "Failed to load signing keys", http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(keys)
+	if err != nil {
+		http.Error(w, "Failed to marshal key set", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
 // MethodGuard middleware to restrict HTTP methods
 func (s *Server) MethodGuard(next http.Handler, allowedMethods ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
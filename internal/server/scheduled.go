@@ -0,0 +1,300 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// scheduledTransferInterval is how often the worker looks for due
+// scheduled transfers.
+const scheduledTransferInterval = 30 * time.Second
+
+// startScheduledTransfers runs scheduled transfers as they fall due. Each
+// replica runs its own worker; due schedules are claimed with SKIP LOCKED,
+// so no two workers run the same one.
+func (s *Server) startScheduledTransfers() {
+	ticker := time.NewTicker(scheduledTransferInterval)
+	go func() {
+		scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+		for range ticker.C {
+			ran := 0
+			for {
+				found, err := scheduledTransferRepository.RunDueTransfer()
+				if err != nil {
+					log.Printf("Failed to run scheduled transfer: %v", err)
+					break
+				}
+				if !found {
+					break
+				}
+				ran++
+			}
+			if ran > 0 {
+				log.Printf("Ran %d scheduled transfers", ran)
+			}
+		}
+	}()
+}
+
+type ScheduledTransferService struct {
+	db database.Service
+}
+
+func NewScheduledTransferService(db database.Service) *ScheduledTransferService {
+	return &ScheduledTransferService{db: db}
+}
+
+// @Summary Schedule a transfer
+// @Description Schedule a transfer, or a withdrawal when destination_account_id is unset, to run once at start_at or repeatedly from then on. Monthly schedules keep the day of the month, moved back to the last day in shorter months. Both accounts must hold the same currency. A failed run is retried with backoff and recorded either way.
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param schedule body models.CreateScheduledTransferRequest true "Schedule details"
+// @Success 201 {object} models.Response{data=models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/create [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) CreateScheduledTransfer(w http.ResponseWriter, r *http.Request, userID int) {
+	var scheduleRequest models.CreateScheduledTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&scheduleRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	account, err := repositories.NewAccountRepository(s.db).GetAccount(scheduleRequest.AccountID, userID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	amount, err := scheduleRequest.Amount.Money(account.Currency)
+	if err == nil {
+		err = validateTransactionAmount(amount)
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+
+	if err := validateScheduleRequest(&scheduleRequest, time.Now()); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid schedule", err)
+		return
+	}
+
+	scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+	scheduled, err := scheduledTransferRepository.CreateScheduledTransfer(scheduleRequest, userID, auditActor(r))
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		writeLookupError(w, "Account", err)
+	case errors.Is(err, repositories.ErrCurrencyMismatch):
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid schedule", err)
+	case errors.Is(err, repositories.ErrAccountClosed):
+		utils.WriteJSONError(w, http.StatusConflict, "Schedule rejected", err)
+	case err != nil:
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to schedule transfer", err)
+	default:
+		utils.WriteJSONResponse(w, http.StatusCreated, "Transfer scheduled successfully", scheduled)
+	}
+}
+
+func validateScheduleRequest(request *models.CreateScheduledTransferRequest, now time.Time) error {
+	request.Description = strings.TrimSpace(request.Description)
+
+	if request.DestinationAccountID != nil && *request.DestinationAccountID == request.AccountID {
+		return fmt.Errorf("cannot transfer to the same account")
+	}
+
+	if request.Frequency == "" {
+		request.Frequency = models.ScheduleOnce
+	}
+	request.Frequency = models.ScheduleFrequency(strings.ToLower(string(request.Frequency)))
+	if !request.Frequency.Valid() {
+		return fmt.Errorf("frequency must be one of once, daily, weekly, monthly")
+	}
+
+	// Without a start time the first run is due at once
+	if request.StartAt.IsZero() {
+		request.StartAt = now
+	} else if request.StartAt.Before(now.Add(-time.Minute)) {
+		return fmt.Errorf("start_at must not be in the past")
+	}
+
+	if request.EndAt != nil {
+		if request.Frequency == models.ScheduleOnce {
+			return fmt.Errorf("end_at only applies to recurring schedules")
+		}
+		if !request.EndAt.After(request.StartAt) {
+			return fmt.Errorf("end_at must be after start_at")
+		}
+	}
+	return nil
+}
+
+// @Summary Get a scheduled transfer
+// @Description Get one of the authenticated user's scheduled transfers
+// @Tags scheduled
+// @Produce json
+// @Param id query int true "Scheduled transfer ID"
+// @Success 200 {object} models.Response{data=models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/get [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) GetScheduledTransfer(w http.ResponseWriter, r *http.Request, scheduledTransferID int, userID int) {
+	scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+	scheduled, err := scheduledTransferRepository.GetScheduledTransfer(scheduledTransferID, userID)
+	if err != nil {
+		writeLookupError(w, "Scheduled transfer", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Scheduled transfer fetched successfully", scheduled)
+}
+
+// @Summary List scheduled transfers
+// @Description List the authenticated user's scheduled transfers, the next due first
+// @Tags scheduled
+// @Produce json
+// @Param status query string false "Schedule status (active, paused, completed, cancelled, failed)"
+// @Success 200 {object} models.Response{data=[]models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/ [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) GetScheduledTransfers(w http.ResponseWriter, r *http.Request, userID int) {
+	var status *models.ScheduledTransferStatus
+	if value := r.URL.Query().Get("status"); value != "" {
+		scheduleStatus := models.ScheduledTransferStatus(strings.ToLower(value))
+		switch scheduleStatus {
+		case models.ScheduleActive, models.SchedulePaused, models.ScheduleCompleted, models.ScheduleCancelled, models.ScheduleFailed:
+			status = &scheduleStatus
+		default:
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("status must be one of active, paused, completed, cancelled, failed"))
+			return
+		}
+	}
+
+	scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+	scheduledTransfers, err := scheduledTransferRepository.GetScheduledTransfers(userID, status)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get scheduled transfers", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Scheduled transfers fetched successfully", scheduledTransfers)
+}
+
+// @Summary List the runs of a scheduled transfer
+// @Description List every attempt to run one of the authenticated user's scheduled transfers, newest first, with the transaction it booked or why it failed
+// @Tags scheduled
+// @Produce json
+// @Param id query int true "Scheduled transfer ID"
+// @Success 200 {object} models.Response{data=[]models.ScheduledTransferRun}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/runs [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) GetScheduledTransferRuns(w http.ResponseWriter, r *http.Request, scheduledTransferID int, userID int) {
+	scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+	runs, err := scheduledTransferRepository.GetScheduledTransferRuns(scheduledTransferID, userID)
+	if err != nil {
+		writeLookupError(w, "Scheduled transfer", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Scheduled transfer runs fetched successfully", runs)
+}
+
+// @Summary Pause a scheduled transfer
+// @Description Stop an active scheduled transfer from running until it is resumed
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param schedule body models.ScheduledTransferActionRequest true "Scheduled transfer to pause"
+// @Success 200 {object} models.Response{data=models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/pause [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) PauseScheduledTransfer(w http.ResponseWriter, r *http.Request, userID int) {
+	s.setScheduledTransferStatus(w, r, userID, models.SchedulePaused)
+}
+
+// @Summary Resume a scheduled transfer
+// @Description Let a paused scheduled transfer run again. A run that fell due while it was paused happens at once; earlier missed runs are skipped.
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param schedule body models.ScheduledTransferActionRequest true "Scheduled transfer to resume"
+// @Success 200 {object} models.Response{data=models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/resume [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) ResumeScheduledTransfer(w http.ResponseWriter, r *http.Request, userID int) {
+	s.setScheduledTransferStatus(w, r, userID, models.ScheduleActive)
+}
+
+// @Summary Cancel a scheduled transfer
+// @Description Stop an active or paused scheduled transfer for good
+// @Tags scheduled
+// @Accept json
+// @Produce json
+// @Param schedule body models.ScheduledTransferActionRequest true "Scheduled transfer to cancel"
+// @Success 200 {object} models.Response{data=models.ScheduledTransfer}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /scheduled/cancel [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *ScheduledTransferService) CancelScheduledTransfer(w http.ResponseWriter, r *http.Request, userID int) {
+	s.setScheduledTransferStatus(w, r, userID, models.ScheduleCancelled)
+}
+
+func (s *ScheduledTransferService) setScheduledTransferStatus(w http.ResponseWriter, r *http.Request, userID int, status models.ScheduledTransferStatus) {
+	var actionRequest models.ScheduledTransferActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&actionRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	scheduledTransferRepository := repositories.NewScheduledTransferRepository(s.db)
+	scheduled, err := scheduledTransferRepository.SetScheduledTransferStatus(actionRequest.ScheduledTransferID, userID, status, auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidScheduleTransition) {
+		utils.WriteJSONError(w, http.StatusConflict, "Scheduled transfer status cannot change", err)
+		return
+	}
+	if err != nil {
+		writeLookupError(w, "Scheduled transfer", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Scheduled transfer updated successfully", scheduled)
+}
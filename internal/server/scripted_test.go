@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedDriver is a database/sql driver whose answers come from the test.
+// A statement goes to the first rule whose fragment it contains. Without a
+// rule, queries return no rows and other statements affect one row, so a
+// test only scripts what the path under test depends on.
+//
+// pg_advisory_xact_lock is honoured: the lock is held until the transaction
+// that took it ends, so concurrent tests see the same serialisation as
+// PostgreSQL.
+type scriptedDriver struct {
+	mu       sync.Mutex
+	rules    []scriptedRule
+	executed []scriptedStatement
+	locks    map[string]*sync.Mutex
+}
+
+type scriptedRule struct {
+	fragment string
+	answer   func(args []driver.Value) ([][]driver.Value, error)
+}
+
+type scriptedStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// newScriptedDB returns a database served by a new scriptedDriver.
+func newScriptedDB(t *testing.T) (*testDB, *scriptedDriver) {
+	t.Helper()
+
+	d := &scriptedDriver{locks: make(map[string]*sync.Mutex)}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return &testDB{db: db}, d
+}
+
+// on answers statements containing fragment. Queries return the rows;
+// other statements affect one row per row returned.
+func (d *scriptedDriver) on(fragment string, answer func(args []driver.Value) ([][]driver.Value, error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules = append(d.rules, scriptedRule{fragment: fragment, answer: answer})
+}
+
+// statements returns the executed statements containing fragment.
+func (d *scriptedDriver) statements(fragment string) []scriptedStatement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var matches []scriptedStatement
+	for _, statement := range d.executed {
+		if strings.Contains(statement.query, fragment) {
+			matches = append(matches, statement)
+		}
+	}
+	return matches
+}
+
+func (d *scriptedDriver) answer(query string, args []driver.Value) ([][]driver.Value, bool, error) {
+	d.mu.Lock()
+	d.executed = append(d.executed, scriptedStatement{query: query, args: args})
+	var rule *scriptedRule
+	for i := range d.rules {
+		if strings.Contains(query, d.rules[i].fragment) {
+			rule = &d.rules[i]
+			break
+		}
+	}
+	d.mu.Unlock()
+
+	if rule == nil {
+		return nil, false, nil
+	}
+	rows, err := rule.answer(args)
+	return rows, true, err
+}
+
+func (d *scriptedDriver) lock(args []driver.Value) *sync.Mutex {
+	d.mu.Lock()
+	key := fmt.Sprint(args)
+	lock, ok := d.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		d.locks[key] = lock
+	}
+	d.mu.Unlock()
+
+	lock.Lock()
+	return lock
+}
+
+func (d *scriptedDriver) Connect(context.Context) (driver.Conn, error) {
+	return &scriptedConn{driver: d}, nil
+}
+func (d *scriptedDriver) Driver() driver.Driver            { return d }
+func (d *scriptedDriver) Open(string) (driver.Conn, error) { return &scriptedConn{driver: d}, nil }
+
+type scriptedConn struct {
+	driver *scriptedDriver
+	inTx   bool
+	held   []*sync.Mutex
+}
+
+func (c *scriptedConn) Prepare(query string) (driver.Stmt, error) {
+	return &scriptedStmt{conn: c, query: query}, nil
+}
+func (c *scriptedConn) Close() error { return nil }
+func (c *scriptedConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return scriptedTx{conn: c}, nil
+}
+func (c *scriptedConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+// release drops the advisory locks taken in the transaction.
+func (c *scriptedConn) release() {
+	for _, lock := range c.held {
+		lock.Unlock()
+	}
+	c.held = nil
+	c.inTx = false
+}
+
+type scriptedTx struct{ conn *scriptedConn }
+
+func (tx scriptedTx) Commit() error   { tx.conn.release(); return nil }
+func (tx scriptedTx) Rollback() error { tx.conn.release(); return nil }
+
+type scriptedStmt struct {
+	conn  *scriptedConn
+	query string
+}
+
+func (s *scriptedStmt) Close() error  { return nil }
+func (s *scriptedStmt) NumInput() int { return -1 }
+
+func (s *scriptedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "pg_advisory_xact_lock") {
+		lock := s.conn.driver.lock(args)
+		if s.conn.inTx {
+			s.conn.held = append(s.conn.held, lock)
+		} else {
+			lock.Unlock()
+		}
+	}
+
+	rows, answered, err := s.conn.driver.answer(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if !answered {
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(len(rows)), nil
+}
+
+func (s *scriptedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.conn.driver.answer(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &ownershipRows{values: rows}, nil
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -10,6 +12,9 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"banking-system/internal/database"
+	"banking-system/internal/fx"
+	"banking-system/internal/mailer"
+	"banking-system/utils"
 )
 
 type Server struct {
@@ -21,20 +26,54 @@ type Server struct {
 	transactionService *TransactionService
 	userService *UserService
 	soaService *SOAService
+	fxService *FXService
+	adminService *AdminService
+	holdService *HoldService
+	scheduledTransferService *ScheduledTransferService
+	webhookService *WebhookService
+	apiKeyService *APIKeyService
+
+	idempotencyTTL time.Duration
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	db := database.New()
 
+	// Apply pending migrations before serving when MIGRATE_ON_STARTUP is set
+	if os.Getenv("MIGRATE_ON_STARTUP") == "true" {
+		applied, err := db.MigrateUp(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+		}
+	}
+
 	// Start metrics collection for DB
 	db.StartMetricsCollection()
 
-	authService := NewAuthService(db)
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	mail, err := mailer.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up mail: %v", err)
+	}
+
+	authService := NewAuthService(db, mail)
 	accountService := NewAccountService(db)
 	transactionService := NewTransactionService(db)
-	userService := NewUserService(db)
+	rateProvider, err := newRateProvider(db)
+	if err != nil {
+		log.Fatalf("Failed to load exchange rates: %v", err)
+	}
+
+	userService := NewUserService(db, rateProvider)
 	soaService := NewSOAService(db)
+	fxService := NewFXService(db, fx.NewQuoter(rateProvider))
 
 	server := &Server{
 		port: port,
@@ -44,6 +83,13 @@ func NewServer() *http.Server {
 		transactionService: transactionService,
 		userService: userService,
 		soaService: soaService,
+		fxService: fxService,
+		adminService: NewAdminService(db),
+		holdService: NewHoldService(db),
+		scheduledTransferService: NewScheduledTransferService(db),
+		webhookService: NewWebhookService(db),
+		apiKeyService: NewAPIKeyService(db),
+		idempotencyTTL: idempotencyKeyTTL(),
 	}
 
 	// Declare Server config
@@ -57,6 +103,16 @@ func NewServer() *http.Server {
 
 	go server.db.StartMetricsCollection()
 
+	server.startIdempotencyKeyCleanup()
+	server.startSessionCleanup()
+	server.startFailedLoginCleanup()
+	server.startCurrencyRefresh()
+	server.startDormancySweep()
+	server.startHoldExpiry()
+	server.startScheduledTransfers()
+	server.startWebhookDispatcher()
+	server.startStatementWorkers()
+
 	return httpServer
 }
 
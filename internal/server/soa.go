@@ -7,10 +7,13 @@ import (
 	"banking-system/internal/lib"
 	"banking-system/internal/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -23,20 +26,99 @@ func NewSOAService(db database.Service) *SOAService {
 	return &SOAService{db: db}
 }
 
-// GetSOA retrieves the SOA for a given user and with custom filters such as start date, end date, transaction type and item count.
-// @Summary Get Statement of Account
-// @Description Get a statement of account for a user with custom filters such as start date, end date, transaction type and item count.
+const (
+	defaultStatementWorkers = 2
+
+	// statementJobPollInterval is how often an idle worker looks for
+	// queued statements.
+	statementJobPollInterval = 2 * time.Second
+
+	// statementJobLease is how long a worker may render a statement before
+	// the job is considered abandoned and handed to another worker.
+	statementJobLease = 5 * time.Minute
+)
+
+// statementWorkers reads STATEMENT_WORKERS, the number of statements each
+// replica renders at once, and falls back to 2 when it is unset or invalid.
+func statementWorkers() int {
+	value := os.Getenv("STATEMENT_WORKERS")
+	if value == "" {
+		return defaultStatementWorkers
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 {
+		log.Printf("Invalid STATEMENT_WORKERS %q, using %d", value, defaultStatementWorkers)
+		return defaultStatementWorkers
+	}
+	return workers
+}
+
+// startStatementWorkers renders queued statements. Jobs live in the
+// database, so queued ones survive a restart, and a job whose worker died
+// mid-render is picked up again once its lease runs out.
+func (s *Server) startStatementWorkers() {
+	for i := 0; i < statementWorkers(); i++ {
+		go func() {
+			statementJobRepository := repositories.NewStatementJobRepository(s.db)
+			soaRepository := repositories.NewSOARepository(s.db)
+			ticker := time.NewTicker(statementJobPollInterval)
+			for range ticker.C {
+				for {
+					job, err := statementJobRepository.ClaimStatementJob(statementJobLease)
+					if err != nil {
+						log.Printf("Failed to claim statement job: %v", err)
+						break
+					}
+					if job == nil {
+						break
+					}
+					runStatementJob(statementJobRepository, soaRepository, *job)
+				}
+			}
+		}()
+	}
+}
+
+func runStatementJob(statementJobRepository repositories.StatementJobRepository, soaRepository repositories.SOARepository, job models.StatementJob) {
+	start := time.Now()
+	soa, err := soaRepository.GetSOA(job.UserID, job.Request)
+	if err == nil {
+		_, err = statementJobRepository.CompleteStatementJob(job, soa.PDFUrl)
+		if err != nil {
+			// The statement was not recorded, so its file is never served
+			os.Remove(soa.PDFUrl)
+		}
+	}
+	if errors.Is(err, repositories.ErrStatementJobLost) {
+		log.Printf("Statement job %d was taken over by another worker", job.ID)
+		return
+	}
+
+	lib.RecordSOAGeneration(err == nil, time.Since(start).Seconds())
+	if err != nil {
+		log.Printf("Failed to generate statement for job %d: %v", job.ID, err)
+		if err := statementJobRepository.FailStatementJob(job, err); err != nil {
+			log.Printf("Failed to record statement job %d failure: %v", job.ID, err)
+		}
+	}
+}
+
+// GenerateSOA queues a statement of account for a given user with custom filters such as start date, end date, account and currency.
+// @Summary Generate Statement of Account
+// @Description Queue a statement of account for the user with custom filters such as start date, end date, account and currency. The PDF is rendered in the background; poll /soa/jobs/{id} until the job has succeeded, then download the statement by its statement_id.
 // @Tags soa
 // @Accept json
 // @Produce json
-// @Param request body models.GenerateSOACustomRequest true "Generate SOA Request" example({"start_date": "2024-03-01T00:00:00Z", "end_date": "2024-03-25T23:59:59Z", "item_count": 100, "transaction_type": "ALL"})
-// @Success 200 {object} models.SOA
+// @Param request body models.GenerateSOACustomRequestUnformatted true "Generate SOA Request" example({"start_date": "2024-03-01T00:00:00Z", "end_date": "2024-03-25T23:59:59Z", "item_count": 100})
+// @Success 202 {object} models.Response{data=models.StatementJob}
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /soa/generate [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
-func (s *SOAService) GetSOA(w http.ResponseWriter, r *http.Request, userID int) {
+func (s *SOAService) GenerateSOA(w http.ResponseWriter, r *http.Request, userID int) {
 	start := time.Now()
 	// Record transaction when generating SOA
 	lib.RecordTransaction("SOA_GENERATION", 0)
@@ -75,7 +157,13 @@ func (s *SOAService) GetSOA(w http.ResponseWriter, r *http.Request, userID int)
 		}
 	}
 
-	// Default item count to 50 if not specified
+	if finalRequest.EndDate.Before(finalRequest.StartDate) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid end date", fmt.Errorf("end_date must not be before start_date"))
+		return
+	}
+
+	// Default item count to 100 if not specified
+	finalRequest.ItemCount = request.ItemCount
 	if request.ItemCount <= 0 {
 		finalRequest.ItemCount = 100
 	}
@@ -84,26 +172,40 @@ func (s *SOAService) GetSOA(w http.ResponseWriter, r *http.Request, userID int)
 	finalRequest.Currency = request.Currency
 
 
-	soaRepository := repositories.NewSOARepository(s.db)
-	soa, err := soaRepository.GetSOA(userID, finalRequest)
+	statementJobRepository := repositories.NewStatementJobRepository(s.db)
+	job, err := statementJobRepository.CreateStatementJob(userID, finalRequest)
 	if err != nil {
-		lib.RecordSOAGeneration(false, time.Since(start).Seconds())
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate SOA", err)
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to queue SOA", err)
 		return
 	}
 
-	// Save the PDF to the database
-	err = soaRepository.SavePDF(soa.PDFUrl, userID)
+	lib.RecordRequest(r.URL.Path, r.Method, http.StatusAccepted, time.Since(start).Seconds())
+
+	utils.WriteJSONResponse(w, http.StatusAccepted, "SOA queued for generation", job)
+}
+
+// GetStatementJob reports how a queued SOA is getting on
+// @Summary Get SOA Generation Job
+// @Description Get the status of a statement generation job queued by the user. Once it has succeeded, statement_id identifies the statement to download.
+// @Tags soa
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.Response{data=models.StatementJob}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /soa/jobs/{id} [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *SOAService) GetStatementJob(w http.ResponseWriter, r *http.Request, jobID int, userID int) {
+	statementJobRepository := repositories.NewStatementJobRepository(s.db)
+	job, err := statementJobRepository.GetStatementJob(jobID, userID)
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to save PDF", err)
+		writeLookupError(w, "SOA job", err)
 		return
 	}
 
-	duration := time.Since(start).Seconds()
-	lib.RecordSOAGeneration(true, duration)
-	lib.RecordRequest(r.URL.Path, r.Method, http.StatusOK, duration)
-
-	utils.WriteJSONResponse(w, http.StatusOK, "SOA retrieved successfully", soa)
+	utils.WriteJSONResponse(w, http.StatusOK, "SOA job fetched successfully", job)
 }
 
 // GetGeneratedSOA retrieves all generated SOAs for a user
@@ -112,6 +214,14 @@ func (s *SOAService) GetSOA(w http.ResponseWriter, r *http.Request, userID int)
 // @Tags soa
 // @Accept json
 // @Produce json
+// @Param dateFrom query string false "Generated at or after (RFC3339)"
+// @Param dateTo query string false "Generated at or before (RFC3339)"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size, at most 100" default(10)
+// @Param sortField query string false "Sort field (created_at, statement_date)"
+// @Param sortDirection query string false "Sort direction (ASC, DESC)"
+// @Param cursor query string false "Use keyset pagination; empty for the first page, then next_cursor or prev_cursor"
+// @Param includeTotal query bool false "With cursor, also return total_records"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
@@ -119,8 +229,47 @@ func (s *SOAService) GetSOA(w http.ResponseWriter, r *http.Request, userID int)
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *SOAService) GetGeneratedSOA(w http.ResponseWriter, r *http.Request, userID int) {
+	query := r.URL.Query()
+	filter := &models.StatementFilter{}
+
+	var err error
+	if filter.DateFrom, filter.DateTo, err = parseDateRange(query); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	cursorPagination, err := parseCursorPagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
 	soaRepository := repositories.NewSOARepository(s.db)
-	soas, err := soaRepository.GetGeneratedSOA(userID)
+
+	if cursorPagination != nil {
+		soas, err := soaRepository.GetGeneratedSOAByCursor(userID, filter, cursorPagination)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get generated SOAs", err)
+			return
+		}
+
+		utils.WriteJSONResponse(w, http.StatusOK, "Generated SOAs retrieved successfully", soas)
+		return
+	}
+
+	sort, err := parseSort(query, []string{"created_at", "statement_date"})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	pagination, err := parsePagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	soas, err := soaRepository.GetGeneratedSOA(userID, filter, sort, pagination)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get generated SOAs", err)
 		return
@@ -144,16 +293,11 @@ func (s *SOAService) GetGeneratedSOA(w http.ResponseWriter, r *http.Request, use
 // @Router /soa/download [get]
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
-func (s *SOAService) DownloadSOA(w http.ResponseWriter, r *http.Request, soaID int) {
+func (s *SOAService) DownloadSOA(w http.ResponseWriter, r *http.Request, soaID int, userID int) {
 	soaRepository := repositories.NewSOARepository(s.db)
-	soa, err := soaRepository.GetSOAByID(soaID)
+	soa, err := soaRepository.GetSOAByID(soaID, userID)
 	if err != nil {
-		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get SOA", err)
-		return
-	}
-
-	if soa == nil {
-		utils.WriteJSONError(w, http.StatusNotFound, "SOA not found", nil)
+		writeLookupError(w, "SOA", err)
 		return
 	}
 
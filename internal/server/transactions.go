@@ -7,8 +7,13 @@ import (
 	"banking-system/internal/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"banking-system/internal/lib"
@@ -30,8 +35,11 @@ func NewTransactionService(db database.Service) *TransactionService {
 // @Param transaction body models.CreateTransactionRequest true "Deposit details"
 // @Success 201 {object} models.Response
 // @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /transaction/deposit [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *TransactionService) Deposit(w http.ResponseWriter, r *http.Request, userID int) {
@@ -45,36 +53,55 @@ func (s *TransactionService) Deposit(w http.ResponseWriter, r *http.Request, use
 	}
 
 	// Validate account ownership
-	if err := s.validateAccountOwnership(ctx, depositRequest.AccountID, userID); err != nil {
-		utils.WriteJSONError(w, http.StatusForbidden, "Unauthorized access to account", err)
+	sourceAccount, err := s.validateAccountOwnership(ctx, depositRequest.AccountID, userID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	// Bind the amount to the account currency, rejecting extra decimal places
+	amount, err := depositRequest.Amount.Money(sourceAccount.Currency)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
 		return
 	}
 
 	// Enhanced amount validation
-	if err := validateTransactionAmount(depositRequest.Amount); err != nil {
+	if err := validateTransactionAmount(amount); err != nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
 		return
 	}
 
 	transactionRepository := repositories.NewTransactionRepository(s.db)
-	transaction, err := transactionRepository.Deposit(depositRequest, userID)
+	transaction, err := transactionRepository.Deposit(depositRequest, userID, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
+	if writeLimitError(w, "Deposit rejected", err) {
+		return
+	}
+	if isAccountStatusError(err) {
+		utils.WriteJSONError(w, http.StatusConflict, "Deposit rejected", err)
+		return
+	}
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Deposit failed", err)
 		return
 	}
 
 	// Record transaction metrics
-	lib.RecordTransaction("deposit", depositRequest.Amount)
+	lib.RecordTransaction("deposit", amount.Float64())
 	
 	// Update account balance metrics
 	accountRepository := repositories.NewAccountRepository(s.db)
-	account, err := accountRepository.GetAccount(depositRequest.AccountID)
+	account, err := accountRepository.GetAccount(depositRequest.AccountID, userID)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update account balance", err)
 		return
 	}
 	
-	lib.RecordAccountBalance(account.Balance, string(account.Currency))
+	lib.RecordAccountBalance(account.Balance.Float64(), string(account.Currency))
 
 	// Record API latency
 	duration := time.Since(start).Seconds()
@@ -94,8 +121,11 @@ func (s *TransactionService) Deposit(w http.ResponseWriter, r *http.Request, use
 // @Param transaction body models.CreateTransactionRequest true "Withdrawal details" default(models.CreateTransactionRequest{AccountID: 1, Amount: 1000, Type: "WITHDRAWAL"})
 // @Success 201 {object} models.Response
 // @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /transaction/withdraw [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *TransactionService) Withdraw(w http.ResponseWriter, r *http.Request, userID int) {
@@ -109,35 +139,54 @@ func (s *TransactionService) Withdraw(w http.ResponseWriter, r *http.Request, us
 	}
 
 	// Validate account ownership
-	if err := s.validateAccountOwnership(ctx, withdrawRequest.AccountID, userID); err != nil {
-		utils.WriteJSONError(w, http.StatusForbidden, "Unauthorized access to account", err)
+	sourceAccount, err := s.validateAccountOwnership(ctx, withdrawRequest.AccountID, userID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	// Bind the amount to the account currency, rejecting extra decimal places
+	amount, err := withdrawRequest.Amount.Money(sourceAccount.Currency)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
 		return
 	}
 
 	// Enhanced amount validation
-	if err := validateTransactionAmount(withdrawRequest.Amount); err != nil {
+	if err := validateTransactionAmount(amount); err != nil {
 		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
 		return
 	}
 
 	transactionRepository := repositories.NewTransactionRepository(s.db)
-	transaction, err := transactionRepository.Withdraw(withdrawRequest, userID)
+	transaction, err := transactionRepository.Withdraw(withdrawRequest, userID, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
+	if writeLimitError(w, "Withdrawal rejected", err) {
+		return
+	}
+	if errors.Is(err, repositories.ErrInsufficientFunds) || isAccountStatusError(err) {
+		utils.WriteJSONError(w, http.StatusConflict, "Withdrawal rejected", err)
+		return
+	}
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Withdrawal failed", err)
 		return
 	}
 
 	// Record transaction metrics
-	lib.RecordTransaction("withdraw", withdrawRequest.Amount)
+	lib.RecordTransaction("withdraw", amount.Float64())
 	
 	// Update account balance metrics
 	accountRepository := repositories.NewAccountRepository(s.db)
-	account, err := accountRepository.GetAccount(withdrawRequest.AccountID)
+	account, err := accountRepository.GetAccount(withdrawRequest.AccountID, userID)
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update account balance", err)
 		return
 	}
-	lib.RecordAccountBalance(account.Balance, string(account.Currency))
+	lib.RecordAccountBalance(account.Balance.Float64(), string(account.Currency))
 
 	// Record API latency
 	duration := time.Since(start).Seconds()
@@ -149,14 +198,120 @@ func (s *TransactionService) Withdraw(w http.ResponseWriter, r *http.Request, us
 	})
 }
 
+// @Summary Transfer money between accounts
+// @Description Move money from one of the user's accounts to another account in a single transaction. Accounts in different currencies need the quote_id of an unexpired quote from /fx/quote for the same amount.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Param transfer body models.CreateTransferRequest true "Transfer details"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /transaction/transfer [post]
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *TransactionService) Transfer(w http.ResponseWriter, r *http.Request, userID int) {
+	start := time.Now()
+	ctx := r.Context()
+
+	var transferRequest models.CreateTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&transferRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if transferRequest.AccountID == transferRequest.DestinationAccountID {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid destination account", fmt.Errorf("source and destination accounts must differ"))
+		return
+	}
+
+	// Validate account ownership
+	sourceAccount, err := s.validateAccountOwnership(ctx, transferRequest.AccountID, userID)
+	if err != nil {
+		writeLookupError(w, "Account", err)
+		return
+	}
+
+	// Bind the amount to the account currency, rejecting extra decimal places
+	amount, err := transferRequest.Amount.Money(sourceAccount.Currency)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+
+	// Enhanced amount validation
+	if err := validateTransactionAmount(amount); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+
+	transactionRepository := repositories.NewTransactionRepository(s.db)
+	transaction, err := transactionRepository.Transfer(transferRequest, userID, auditActor(r))
+	if errors.Is(err, repositories.ErrNotFound) {
+		writeLookupError(w, "Account", err)
+		return
+	}
+	if errors.Is(err, repositories.ErrFXQuoteRequired) || errors.Is(err, repositories.ErrFXQuoteInvalid) {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid FX quote", err)
+		return
+	}
+	if errors.Is(err, repositories.ErrFXQuoteUnavailable) {
+		utils.WriteJSONError(w, http.StatusConflict, "FX quote no longer valid", err)
+		return
+	}
+	if writeLimitError(w, "Transfer rejected", err) {
+		return
+	}
+	if errors.Is(err, repositories.ErrInsufficientFunds) || isAccountStatusError(err) {
+		utils.WriteJSONError(w, http.StatusConflict, "Transfer rejected", err)
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Transfer failed", err)
+		return
+	}
+
+	// Record transaction metrics
+	lib.RecordTransaction("transfer", amount.Float64())
+
+	// Update account balance metrics. The destination may belong to another
+	// user, so only the caller's own account is looked up.
+	accountRepository := repositories.NewAccountRepository(s.db)
+	account, err := accountRepository.GetAccount(transferRequest.AccountID, userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update account balance", err)
+		return
+	}
+	lib.RecordAccountBalance(account.Balance.Float64(), string(account.Currency))
+
+	// Record API latency
+	duration := time.Since(start).Seconds()
+	lib.RecordRequest(r.URL.Path, r.Method, http.StatusOK, duration)
+
+	utils.WriteJSONResponse(w, http.StatusCreated, "Transfer successful", transaction)
+}
+
 // @Summary Get all transactions of authenticated user
 // @Description Get all transactions with optional filtering, sorting, and pagination
 // @Tags transactions
 // @Accept json
 // @Produce json
-// @Param filter query models.TransactionFilter false "Filter parameters"
-// @Param sort query models.SortRequest false "Sort parameters"
-// @Param pagination query models.PaginationRequest false "Pagination parameters"
+// @Param type query string false "Transaction type (DEPOSIT, WITHDRAWAL, TRANSFER, REVERSAL, REFUND)"
+// @Param status query string false "Transaction status (pending, completed, failed, reversed, partially_refunded)"
+// @Param accountId query int false "Only transactions of this account"
+// @Param minAmount query number false "Minimum amount"
+// @Param maxAmount query number false "Maximum amount"
+// @Param dateFrom query string false "Created at or after (RFC3339)"
+// @Param dateTo query string false "Created at or before (RFC3339)"
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Page size, at most 100" default(10)
+// @Param sortField query string false "Sort field (amount, transaction_type, status, created_at)"
+// @Param sortDirection query string false "Sort direction (ASC, DESC)"
+// @Param cursor query string false "Use keyset pagination; empty for the first page, then next_cursor or prev_cursor"
+// @Param includeTotal query bool false "With cursor, also return total_records"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
@@ -165,16 +320,46 @@ func (s *TransactionService) Withdraw(w http.ResponseWriter, r *http.Request, us
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *TransactionService) GetTransactions(w http.ResponseWriter, r *http.Request, userID int) {
 	// Parse query parameters for filter, sort, and pagination
-	filter := &models.TransactionFilter{}
-	sort := &models.SortRequest{}
-	pagination := &models.PaginationRequest{
-		Page:     1,
-		PageSize: 10,
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	cursorPagination, err := parseCursorPagination(r.URL.Query())
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
 	}
-	
-	// Add query parameter parsing here if needed
 
 	transactionRepository := repositories.NewTransactionRepository(s.db)
+
+	if cursorPagination != nil {
+		cursorResponse, err := transactionRepository.GetTransactionsByCursor(userID, filter, cursorPagination)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to get transactions", err)
+			return
+		}
+
+		utils.WriteJSONResponse(w, http.StatusOK, "Transactions retrieved successfully", map[string]interface{}{
+			"transactions": cursorResponse.Data,
+			"pagination":   cursorResponse.Pagination,
+		})
+		return
+	}
+
+	sort, err := parseSort(r.URL.Query(), []string{"amount", "transaction_type", "status", "created_at"})
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	pagination, err := parsePagination(r.URL.Query())
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
 	paginatedResponse, err := transactionRepository.GetTransactions(userID, filter, sort, pagination)
 	if err != nil {
 		response := models.Response{
@@ -220,10 +405,22 @@ func (s *TransactionService) GetTransactions(w http.ResponseWriter, r *http.Requ
 // @Router /transaction/get [get]
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
-func (s *TransactionService) GetTransaction(w http.ResponseWriter, r *http.Request, transactionID int) {
+func (s *TransactionService) GetTransaction(w http.ResponseWriter, r *http.Request, transactionID int, userID int) {
 	transactionRepository := repositories.NewTransactionRepository(s.db)
 
-	transaction, err := transactionRepository.GetTransaction(transactionID)
+	transaction, err := transactionRepository.GetTransaction(transactionID, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		response := models.Response{
+			StatusCode: http.StatusNotFound,
+			Success:    false,
+			Message:    "Transaction not found",
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
 	if err != nil {
 		response := models.Response{
 			StatusCode: http.StatusInternalServerError,
@@ -267,24 +464,182 @@ func (s *TransactionService) GetTransaction(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *TransactionService) validateAccountOwnership(ctx context.Context, accountID, userID int) error {
-	// Implement account ownership validation using the database service
-	accountRepository := repositories.NewAccountRepository(s.db)
-	account, err := accountRepository.GetAccount(accountID)
+// @Summary Reverse a transaction
+// @Description Undo a completed or partially refunded transaction. Every leg of it gets a compensating REVERSAL transaction for the amount not yet refunded, and the original legs are marked reversed.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Param reversal body models.ReverseTransactionRequest true "Reason for the reversal"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /transaction/{id}/reverse [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *TransactionService) ReverseTransaction(w http.ResponseWriter, r *http.Request, transactionID int) {
+	var reverseRequest models.ReverseTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&reverseRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	reason, err := compensationReason(reverseRequest.Reason)
 	if err != nil {
-		return err
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
 	}
-	if account.UserID != userID {
-		return fmt.Errorf("account does not belong to user")
+
+	transactionRepository := repositories.NewTransactionRepository(s.db)
+	reversal, err := transactionRepository.ReverseTransaction(transactionID, reason, auditActor(r))
+	writeCompensationResult(w, "Reversal", "Transaction reversed successfully", reversal, err)
+}
+
+// @Summary Refund part of a transaction
+// @Description Give back part of a completed transaction in its own currency. Every leg gets a compensating REFUND transaction and is marked partially_refunded, or reversed once refunds add up to the whole amount. Transfers between currencies can only be reversed in full.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID"
+// @Param refund body models.RefundTransactionRequest true "Amount and reason"
+// @Success 201 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /transaction/{id}/refund [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *TransactionService) RefundTransaction(w http.ResponseWriter, r *http.Request, transactionID int) {
+	var refundRequest models.RefundTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&refundRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if refundRequest.Amount.IsZero() || refundRequest.Amount.IsNegative() {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", fmt.Errorf("amount must be greater than 0"))
+		return
+	}
+
+	reason, err := compensationReason(refundRequest.Reason)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	transactionRepository := repositories.NewTransactionRepository(s.db)
+	refund, err := transactionRepository.RefundTransaction(transactionID, refundRequest.Amount, reason, auditActor(r))
+	writeCompensationResult(w, "Refund", "Transaction refunded successfully", refund, err)
+}
+
+// compensationReason requires a reason for every reversal and refund, so that
+// the compensating transactions explain themselves on statements.
+func compensationReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", fmt.Errorf("reason is required")
+	}
+	return reason, nil
+}
+
+// writeCompensationResult answers a reversal or refund. kind is "Reversal" or
+// "Refund" and names the failure in error messages.
+func writeCompensationResult(w http.ResponseWriter, kind string, message string, result map[string]interface{}, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		writeLookupError(w, "Transaction", err)
+	case errors.Is(err, repositories.ErrInvalidAmount):
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid amount", err)
+	case errors.Is(err, repositories.ErrAlreadyReversed),
+		errors.Is(err, repositories.ErrNotReversible),
+		errors.Is(err, repositories.ErrNotRefundable),
+		errors.Is(err, repositories.ErrRefundTooLarge),
+		errors.Is(err, repositories.ErrInsufficientFunds),
+		errors.Is(err, repositories.ErrAccountClosed):
+		utils.WriteJSONError(w, http.StatusConflict, kind+" rejected", err)
+	case err != nil:
+		utils.WriteJSONError(w, http.StatusInternalServerError, kind+" failed", err)
+	default:
+		utils.WriteJSONResponse(w, http.StatusCreated, message, result)
 	}
-	return nil
 }
 
-func validateTransactionAmount(amount float64) error {
-	if amount <= 0 {
+func parseTransactionFilter(query url.Values) (*models.TransactionFilter, error) {
+	filter := &models.TransactionFilter{}
+
+	if value := query.Get("type"); value != "" {
+		transactionType := models.TransactionType(strings.ToUpper(value))
+		switch transactionType {
+		case models.Deposit, models.Withdrawal, models.Transfer, models.Reversal, models.Refund:
+			filter.Type = &transactionType
+		default:
+			return nil, fmt.Errorf("type must be one of DEPOSIT, WITHDRAWAL, TRANSFER, REVERSAL, REFUND")
+		}
+	}
+
+	if value := query.Get("status"); value != "" {
+		status := models.TransactionStatus(strings.ToLower(value))
+		switch status {
+		case models.Pending, models.Completed, models.Failed, models.Reversed, models.PartiallyRefunded:
+			filter.Status = &status
+		default:
+			return nil, fmt.Errorf("status must be one of pending, completed, failed, reversed, partially_refunded")
+		}
+	}
+
+	if value := query.Get("accountId"); value != "" {
+		accountID, err := strconv.Atoi(value)
+		if err != nil || accountID < 1 {
+			return nil, fmt.Errorf("accountId must be a positive integer")
+		}
+		filter.AccountID = &accountID
+	}
+
+	var err error
+	if filter.MinAmount, filter.MaxAmount, err = parseAmountRange(query, "minAmount", "maxAmount"); err != nil {
+		return nil, err
+	}
+
+	if filter.DateFrom, filter.DateTo, err = parseDateRange(query); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// isAccountStatusError tells whether err means an account's status does not
+// allow the requested movement.
+func isAccountStatusError(err error) bool {
+	return errors.Is(err, repositories.ErrAccountFrozen) ||
+		errors.Is(err, repositories.ErrAccountDormant) ||
+		errors.Is(err, repositories.ErrAccountClosed)
+}
+
+func (s *TransactionService) validateAccountOwnership(ctx context.Context, accountID, userID int) (models.Account, error) {
+	// The lookup is scoped to the user, so accounts owned by someone else come back as ErrNotFound
+	accountRepository := repositories.NewAccountRepository(s.db)
+	return accountRepository.GetAccount(accountID, userID)
+}
+
+// maxTransactionAmount is the largest amount, in major units, a single
+// transaction may move.
+const maxTransactionAmount = 1000000
+
+func validateTransactionAmount(amount models.Money) error {
+	if !amount.IsPositive() {
 		return fmt.Errorf("amount must be greater than 0")
 	}
-	if amount > 1000000 { // Add maximum limit
+
+	units, err := amount.Currency.MinorUnits()
+	if err != nil {
+		return err
+	}
+	if amount.Minor > maxTransactionAmount*int64(math.Pow10(units)) { // Add maximum limit
 		return fmt.Errorf("amount exceeds maximum allowed")
 	}
 	return nil
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transferAccount is an account served by scriptTransferAccounts.
+type transferAccount struct {
+	userID   int64
+	balance  string
+	currency models.Currency
+}
+
+// scriptTransferAccounts answers the statements of a transfer from
+// accounts, applying balance updates to it.
+func scriptTransferAccounts(t *testing.T, d *scriptedDriver, accounts map[int64]*transferAccount) *sync.Mutex {
+	t.Helper()
+
+	var mu sync.Mutex
+	now := time.Now()
+	account := func(args []driver.Value) *transferAccount {
+		id, _ := args[0].(int64)
+		return accounts[id]
+	}
+
+	d.on("FOR UPDATE", func(args []driver.Value) ([][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if a := account(args); a != nil {
+			return [][]driver.Value{{a.userID, a.balance, string(a.currency), "active", "standard"}}, nil
+		}
+		return nil, nil
+	})
+	d.on("SELECT user_id FROM accounts", func(args []driver.Value) ([][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if a := account(args); a != nil {
+			return [][]driver.Value{{a.userID}}, nil
+		}
+		return nil, nil
+	})
+	d.on("account_name", func(args []driver.Value) ([][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		a := account(args)
+		if a == nil || a.userID != args[1].(int64) {
+			return nil, nil
+		}
+		id := args[0].(int64)
+		return [][]driver.Value{{id, a.userID, a.balance, a.balance, string(a.currency), "Savings", "", "active", "standard", nil, now, now}}, nil
+	})
+	d.on("FROM accounts WHERE id", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{"0.00"}}, nil
+	})
+	d.on("UPDATE accounts", func(args []driver.Value) ([][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		a := accounts[args[1].(int64)]
+		if a == nil {
+			return nil, nil
+		}
+		balance, err := models.ParseMoney(a.balance, a.currency)
+		if err != nil {
+			return nil, err
+		}
+		delta, err := models.ParseMoney(args[0].(string), a.currency)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := balance.Add(delta)
+		if err != nil {
+			return nil, err
+		}
+		a.balance = sum.String()
+		return [][]driver.Value{{}}, nil
+	})
+
+	var nextID int64 = 100
+	d.on("INSERT INTO transactions", func(args []driver.Value) ([][]driver.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		nextID++
+		return [][]driver.Value{{nextID}}, nil
+	})
+	d.on("INSERT INTO journal_entries", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{int64(1)}}, nil
+	})
+	d.on("FROM transactions WHERE id", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{{args[0], int64(10), "25.00", "USD", "TRANSFER", "COMPLETED", "DEBIT", int64(11), "", now, now, "ref", nil, nil, nil, nil, "0.00"}}, nil
+	})
+
+	return &mu
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		destCurrency models.Currency
+		want         int
+		wantError    string
+		wantBalances [2]string
+	}{
+		{
+			name:         "moves the amount",
+			body:         `{"account_id": 10, "destination_account_id": 11, "amount": "25.50"}`,
+			destCurrency: models.USD,
+			want:         http.StatusCreated,
+			wantBalances: [2]string{"124.50", "25.50"},
+		},
+		{
+			name:         "same account",
+			body:         `{"account_id": 10, "destination_account_id": 10, "amount": "25.50"}`,
+			destCurrency: models.USD,
+			want:         http.StatusBadRequest,
+			wantError:    "source and destination accounts must differ",
+			wantBalances: [2]string{"150.00", "0.00"},
+		},
+		{
+			name:         "currency mismatch without a quote",
+			body:         `{"account_id": 10, "destination_account_id": 11, "amount": "25.50"}`,
+			destCurrency: models.EUR,
+			want:         http.StatusBadRequest,
+			wantError:    "quote",
+			wantBalances: [2]string{"150.00", "0.00"},
+		},
+		{
+			name:         "insufficient funds",
+			body:         `{"account_id": 10, "destination_account_id": 11, "amount": "150.01"}`,
+			destCurrency: models.USD,
+			want:         http.StatusConflict,
+			wantError:    "insufficient funds",
+			wantBalances: [2]string{"150.00", "0.00"},
+		},
+		{
+			name:         "someone else's account",
+			body:         `{"account_id": 11, "destination_account_id": 10, "amount": "1.00"}`,
+			destCurrency: models.USD,
+			want:         http.StatusNotFound,
+			wantBalances: [2]string{"150.00", "0.00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, d := newScriptedDB(t)
+			accounts := map[int64]*transferAccount{
+				10: {userID: ownerID, balance: "150.00", currency: models.USD},
+				11: {userID: intruderID, balance: "0.00", currency: tt.destCurrency},
+			}
+			mu := scriptTransferAccounts(t, d, accounts)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/transaction/transfer", strings.NewReader(tt.body))
+			NewTransactionService(db).Transfer(w, r, ownerID)
+
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d; got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if !strings.Contains(strings.ToLower(w.Body.String()), tt.wantError) {
+				t.Errorf("expected error mentioning %q; got %s", tt.wantError, w.Body.String())
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			got := [2]string{accounts[10].balance, accounts[11].balance}
+			if got != tt.wantBalances {
+				t.Errorf("balances = %v; want %v", got, tt.wantBalances)
+			}
+
+			// A rejected transfer must not write anything
+			if tt.want != http.StatusCreated {
+				if writes := d.statements("INSERT INTO"); len(writes) != 0 {
+					t.Errorf("rejected transfer wrote %d rows", len(writes))
+				}
+				return
+			}
+			if postings := d.statements("INSERT INTO postings"); len(postings) != 2 {
+				t.Errorf("expected 2 postings; got %d", len(postings))
+			}
+		})
+	}
+}
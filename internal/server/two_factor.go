@@ -0,0 +1,252 @@
+package server
+
+import (
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/totp"
+	"banking-system/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"banking-system/internal/lib"
+)
+
+// recoveryCodeCount is how many recovery codes a user gets when turning
+// two-factor on.
+const recoveryCodeCount = 10
+
+// totpIssuer names the service in authenticator apps (TOTP_ISSUER, default
+// "Banking System").
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Banking System"
+}
+
+// generateRecoveryCodes returns n random codes such as "k7qzp-3mxa2", each
+// carrying 50 bits of entropy.
+func generateRecoveryCodes(n int) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// writeTwoFactorError maps the two-factor errors to responses and reports
+// whether err was one of them.
+func writeTwoFactorError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repositories.ErrInvalidTwoFactorCode):
+		utils.WriteJSONError(w, http.StatusUnauthorized, "Invalid two-factor code", err)
+	case errors.Is(err, repositories.ErrTwoFactorEnabled),
+		errors.Is(err, repositories.ErrTwoFactorNotEnabled),
+		errors.Is(err, repositories.ErrTwoFactorNotEnrolled):
+		utils.WriteJSONError(w, http.StatusConflict, "Two-factor change rejected", err)
+	default:
+		return false
+	}
+	return true
+}
+
+// @Summary Start two-factor enrolment
+// @Description Generate a TOTP secret for the authenticated user. Add it to an authenticator app by scanning provisioning_uri as a QR code, then confirm with a code from the app. Two-factor stays off until confirmed; starting again replaces an unconfirmed secret.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} models.Response{data=models.TwoFactorEnrollment}
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/2fa/enroll [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AuthService) EnrollTwoFactor(w http.ResponseWriter, r *http.Request, userID int) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to start two-factor enrolment", err)
+		return
+	}
+
+	twoFactorRepository := repositories.NewTwoFactorRepository(s.db)
+	email, err := twoFactorRepository.StartEnrollment(userID, secret)
+	if writeTwoFactorError(w, err) {
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to start two-factor enrolment", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Two-factor enrolment started", models.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer(), email, secret),
+	})
+}
+
+// @Summary Confirm two-factor enrolment
+// @Description Turn two-factor on with a code from the newly enrolled authenticator. Returns recovery codes, each usable once in place of a code; they are not shown again.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param code body models.TwoFactorCodeRequest true "Code from the authenticator"
+// @Success 200 {object} models.Response{data=models.TwoFactorRecoveryCodes}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/2fa/confirm [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AuthService) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request, userID int) {
+	var request models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	recoveryCodes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to turn on two-factor", err)
+		return
+	}
+
+	twoFactorRepository := repositories.NewTwoFactorRepository(s.db)
+	err = twoFactorRepository.ConfirmEnrollment(userID, request.Code, recoveryCodes, auditActor(r))
+	if writeTwoFactorError(w, err) {
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to turn on two-factor", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Two-factor turned on", models.TwoFactorRecoveryCodes{RecoveryCodes: recoveryCodes})
+}
+
+// @Summary Turn two-factor off
+// @Description Turn two-factor off with a current code from the authenticator, or a recovery code if it was lost. Wrong codes count as failed logins: too many answer 429 with a Retry-After header.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param code body models.TwoFactorCodeRequest true "Code from the authenticator or a recovery code"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 409 {object} models.Response
+// @Failure 429 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/2fa/disable [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *AuthService) DisableTwoFactor(w http.ResponseWriter, r *http.Request, userID int) {
+	var request models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	user, err := repositories.NewUserRepository(s.db).GetUser(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to turn off two-factor", err)
+		return
+	}
+
+	// A stolen access token must not allow guessing codes either
+	attempt, ok := s.throttle.begin(w, user.Email, clientIP(r))
+	if !ok {
+		return
+	}
+
+	twoFactorRepository := repositories.NewTwoFactorRepository(s.db)
+	err = twoFactorRepository.Disable(userID, request.Code, auditActor(r))
+	if err != nil && !errors.Is(err, repositories.ErrInvalidTwoFactorCode) {
+		attempt.forget()
+	}
+	if writeTwoFactorError(w, err) {
+		return
+	}
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to turn off two-factor", err)
+		return
+	}
+	attempt.succeeded()
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Two-factor turned off", nil)
+}
+
+// @Summary Finish a two-factor login
+// @Description Exchange the mfa_token returned by login and a code from the authenticator, or a recovery code, for a token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyTwoFactorRequest true "Login token and code"
+// @Success 200 {object} models.Response{data=models.TokenResponse}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 429 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/2fa/verify [post]
+func (s *AuthService) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var request models.VerifyTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	userID, err := utils.ValidateMFAToken(request.MFAToken)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	// Read the user now, in case the role changed since the password step
+	user, err := repositories.NewUserRepository(s.db).GetUser(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to verify two-factor code", err)
+		return
+	}
+
+	// Codes are throttled with the password, so the mfa_token does not
+	// allow guessing them
+	attempt, ok := s.throttle.begin(w, user.Email, clientIP(r))
+	if !ok {
+		lib.RecordLoginAttempt(false)
+		return
+	}
+
+	twoFactorRepository := repositories.NewTwoFactorRepository(s.db)
+	err = twoFactorRepository.VerifyCode(userID, request.Code)
+	if errors.Is(err, repositories.ErrInvalidTwoFactorCode) || errors.Is(err, repositories.ErrTwoFactorNotEnabled) {
+		lib.RecordLoginAttempt(false)
+		utils.WriteJSONError(w, http.StatusUnauthorized, "Invalid two-factor code", err)
+		return
+	}
+	if err != nil {
+		attempt.forget()
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to verify two-factor code", err)
+		return
+	}
+	lib.RecordLoginAttempt(true)
+	attempt.succeeded()
+
+	tokens, err := s.issueTokens(r, userID, user.Role)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to generate token", err)
+		return
+	}
+
+	lib.IncrementActiveUsers()
+
+	utils.WriteJSONResponse(w, http.StatusOK, "User logged in successfully", tokens)
+}
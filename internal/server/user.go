@@ -4,17 +4,21 @@ import (
 	"banking-system/internal/database"
 	"banking-system/internal/database/models"
 	"banking-system/internal/database/repositories"
+	"banking-system/internal/fx"
 	"banking-system/internal/utils"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
 type UserService struct {
-	db database.Service
+	db       database.Service
+	rates    fx.FXRateProvider
+	throttle *loginThrottle
 }
 
-func NewUserService(db database.Service) *UserService {
-	return &UserService{db: db}
+func NewUserService(db database.Service, rates fx.FXRateProvider) *UserService {
+	return &UserService{db: db, rates: rates, throttle: newLoginThrottle(db)}
 }
 
 // GetUser gets user details
@@ -41,16 +45,25 @@ func (s *UserService) GetUser(w http.ResponseWriter, r *http.Request, userID int
 
 // ViewBalance gets user balance
 // @Summary View user balance
-// @Description Get the current balance for a specific user
+// @Description Get the current balance for a specific user. With in, the balances are also totalled in that currency at mid-market rates.
 // @Accept json
 // @Produce json
+// @Param in query string false "Currency to total all balances in, e.g. EUR"
 // @Success 200 {object} models.Response{data=models.ViewBalanceResponse} "Balance retrieved successfully"
+// @Failure 400 {object} models.Response{data=map[string]string} "Invalid currency"
+// @Failure 503 {object} models.Response{data=map[string]string} "Exchange rate unavailable"
 // @Failure 500 {object} models.Response{data=map[string]string} "Internal server error"
 // @Router /user/view-balance [get]
 // @Tags user
 // @Security ApiKeyAuth
 // @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
 func (s *UserService) ViewBalance(w http.ResponseWriter, r *http.Request, userID int) {
+	target, err := parseCurrency(r.URL.Query(), "in")
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
 	userRepository := repositories.NewUserRepository(s.db)
 	balance, err := userRepository.ViewBalance(userID)
 	if err != nil {
@@ -58,6 +71,19 @@ func (s *UserService) ViewBalance(w http.ResponseWriter, r *http.Request, userID
 		return
 	}
 
+	if target != nil {
+		total, err := fx.ConvertTotal(r.Context(), s.rates, balance.BalancesByCurrency, *target)
+		if errors.Is(err, fx.ErrRateUnavailable) {
+			utils.WriteJSONError(w, http.StatusServiceUnavailable, "Exchange rate unavailable", err)
+			return
+		}
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to convert balance", err)
+			return
+		}
+		balance.Total = &total
+	}
+
 	utils.WriteJSONResponse(w, http.StatusOK, "Balance retrieved successfully", balance)
 }
 
@@ -82,7 +108,7 @@ func (s *UserService) UpdateUser(w http.ResponseWriter, r *http.Request, userID
 	}
 
 	userRepository := repositories.NewUserRepository(s.db)
-	user, err := userRepository.UpdateUser(updateUserRequest, userID)
+	user, err := userRepository.UpdateUser(updateUserRequest, userID, auditActor(r))
 	if err != nil {
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update user", err)
 		return
@@ -93,12 +119,14 @@ func (s *UserService) UpdateUser(w http.ResponseWriter, r *http.Request, userID
 
 // UpdateUserPassword updates user password
 // @Summary Update user password
-// @Description Update the password for a specific user
+// @Description Update the password for a specific user. Users with two-factor on must also send a current totp_code; wrong codes count as failed logins.
 // @Accept json
 // @Produce json
 // @Param password body models.UpdateUserPasswordRequest true "Password update details"
 // @Success 200 {object} models.Response{data=models.User} "Password updated successfully"
 // @Failure 400 {object} models.Response{data=map[string]string} "Invalid request"
+// @Failure 401 {object} models.Response{data=map[string]string} "Invalid two-factor code"
+// @Failure 429 {object} models.Response{data=map[string]string} "Too many failed attempts"
 // @Failure 500 {object} models.Response{data=map[string]string} "Internal server error"
 // @Router /user/update-password [put]
 // @Tags user
@@ -112,11 +140,30 @@ func (s *UserService) UpdateUserPassword(w http.ResponseWriter, r *http.Request,
 	}
 
 	userRepository := repositories.NewUserRepository(s.db)
-	user, err := userRepository.UpdateUserPassword(updateUserPasswordRequest, userID)
+	current, err := userRepository.GetUser(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update user password", err)
+		return
+	}
+
+	// The two-factor code is throttled with the login, so a stolen access
+	// token and password do not allow guessing it
+	attempt, ok := s.throttle.begin(w, current.Email, clientIP(r))
+	if !ok {
+		return
+	}
+
+	user, err := userRepository.UpdateUserPassword(updateUserPasswordRequest, userID, auditActor(r))
+	if errors.Is(err, repositories.ErrInvalidTwoFactorCode) {
+		utils.WriteJSONError(w, http.StatusUnauthorized, "Invalid two-factor code", err)
+		return
+	}
 	if err != nil {
+		attempt.forget()
 		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to update user password", err)
 		return
 	}
+	attempt.succeeded()
 
 	utils.WriteJSONResponse(w, http.StatusOK, "User password updated successfully", user)
 }
\ No newline at end of file
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"banking-system/internal/database"
+	"banking-system/internal/database/models"
+	"banking-system/internal/database/repositories"
+	"banking-system/internal/utils"
+	"banking-system/internal/webhook"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookDispatchInterval is how often the dispatcher looks for due
+	// deliveries.
+	webhookDispatchInterval = 5 * time.Second
+
+	// webhookDispatchBatch is how many deliveries are sent at once.
+	webhookDispatchBatch = 20
+
+	// webhookDeliveryLease is how long a claimed delivery is left alone
+	// before another dispatcher may retry it. It must outlast a delivery
+	// attempt.
+	webhookDeliveryLease = time.Minute
+)
+
+// startWebhookDispatcher sends pending webhook deliveries. Events are written
+// to the outbox in the transaction that caused them, so a delivery exists for
+// every committed change and none for a rolled-back one. Delivery is at least
+// once: receivers should de-duplicate on the event ID.
+func (s *Server) startWebhookDispatcher() {
+	ticker := time.NewTicker(webhookDispatchInterval)
+	go func() {
+		webhookRepository := repositories.NewWebhookRepository(s.db)
+		sender := webhook.NewSender()
+		for range ticker.C {
+			for {
+				dispatches, err := webhookRepository.ClaimDueDeliveries(webhookDispatchBatch, webhookDeliveryLease)
+				if err != nil {
+					log.Printf("Failed to claim webhook deliveries: %v", err)
+					break
+				}
+
+				var wg sync.WaitGroup
+				for _, dispatch := range dispatches {
+					wg.Add(1)
+					go func(dispatch repositories.WebhookDispatch) {
+						defer wg.Done()
+						status, deliveryErr := sender.Send(context.Background(), dispatch.URL, dispatch.Secret, dispatch.DeliveryID, dispatch.Event)
+						if err := webhookRepository.RecordDeliveryAttempt(dispatch, status, deliveryErr); err != nil {
+							log.Printf("Failed to record webhook delivery %d: %v", dispatch.DeliveryID, err)
+						}
+					}(dispatch)
+				}
+				wg.Wait()
+
+				if len(dispatches) < webhookDispatchBatch {
+					break
+				}
+			}
+		}
+	}()
+}
+
+type WebhookService struct {
+	db database.Service
+}
+
+func NewWebhookService(db database.Service) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+// @Summary Register a webhook endpoint
+// @Description Register a URL to receive the user's events as signed JSON POSTs. Leave event_types empty to receive every type: transaction.completed, transaction.failed, account.created, statement.generated. The secret used to sign deliveries is only returned here. Each request carries an X-Webhook-Signature header "t=<unix>,v1=<hex HMAC-SHA256 of t.body>". Failed deliveries are retried with exponential backoff.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param endpoint body models.CreateWebhookEndpointRequest true "Endpoint details"
+// @Success 201 {object} models.Response{data=models.WebhookEndpoint}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /webhooks/create [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *WebhookService) CreateEndpoint(w http.ResponseWriter, r *http.Request, userID int) {
+	var endpointRequest models.CreateWebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&endpointRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := validateWebhookEndpointRequest(&endpointRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid webhook endpoint", err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create webhook endpoint", err)
+		return
+	}
+
+	webhookRepository := repositories.NewWebhookRepository(s.db)
+	endpoint, err := webhookRepository.CreateEndpoint(endpointRequest, secret, userID, auditActor(r))
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to create webhook endpoint", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusCreated, "Webhook endpoint created successfully", endpoint)
+}
+
+func validateWebhookEndpointRequest(request *models.CreateWebhookEndpointRequest) error {
+	request.URL = strings.TrimSpace(request.URL)
+	request.Description = strings.TrimSpace(request.Description)
+
+	endpointURL, err := url.Parse(request.URL)
+	if err != nil || endpointURL.Host == "" || (endpointURL.Scheme != "https" && endpointURL.Scheme != "http") {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if endpointURL.User != nil {
+		return fmt.Errorf("url must not contain credentials")
+	}
+	if len(request.URL) > 2048 {
+		return fmt.Errorf("url must be at most 2048 characters")
+	}
+	if len(request.Description) > 255 {
+		return fmt.Errorf("description must be at most 255 characters")
+	}
+
+	seen := make(map[models.WebhookEventType]bool)
+	eventTypes := make([]models.WebhookEventType, 0, len(request.EventTypes))
+	for _, eventType := range request.EventTypes {
+		eventType = models.WebhookEventType(strings.ToLower(strings.TrimSpace(string(eventType))))
+		if !eventType.Valid() {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	request.EventTypes = eventTypes
+	return nil
+}
+
+// @Summary List webhook endpoints
+// @Description List the authenticated user's webhook endpoints. Secrets are not included.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.WebhookEndpoint}
+// @Failure 500 {object} models.Response
+// @Router /webhooks/ [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *WebhookService) GetEndpoints(w http.ResponseWriter, r *http.Request, userID int) {
+	webhookRepository := repositories.NewWebhookRepository(s.db)
+	endpoints, err := webhookRepository.GetEndpoints(userID)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to fetch webhook endpoints", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Webhook endpoints fetched successfully", endpoints)
+}
+
+// @Summary Delete a webhook endpoint
+// @Description Delete one of the authenticated user's webhook endpoints. Its pending deliveries are dropped.
+// @Tags webhooks
+// @Produce json
+// @Param id query int true "Endpoint ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /webhooks/delete [delete]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *WebhookService) DeleteEndpoint(w http.ResponseWriter, r *http.Request, endpointID int, userID int) {
+	webhookRepository := repositories.NewWebhookRepository(s.db)
+	if err := webhookRepository.DeleteEndpoint(endpointID, userID, auditActor(r)); err != nil {
+		writeLookupError(w, "Webhook endpoint", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Webhook endpoint deleted successfully", nil)
+}
+
+// @Summary List webhook deliveries
+// @Description List deliveries to the authenticated user's webhook endpoints, newest first, with the outcome of the last attempt
+// @Tags webhooks
+// @Produce json
+// @Param endpointId query int false "Filter by endpoint"
+// @Param status query string false "Filter by status: pending, succeeded, dead"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Items per page"
+// @Success 200 {object} models.Response{data=models.PaginatedResponse[models.WebhookDelivery]}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /webhooks/deliveries [get]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *WebhookService) GetDeliveries(w http.ResponseWriter, r *http.Request, userID int) {
+	query := r.URL.Query()
+	pagination, err := parsePagination(query)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", err)
+		return
+	}
+
+	filter := &models.WebhookDeliveryFilter{}
+	if endpointID := query.Get("endpointId"); endpointID != "" {
+		id, err := strconv.Atoi(endpointID)
+		if err != nil {
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("endpointId must be an integer"))
+			return
+		}
+		filter.EndpointID = &id
+	}
+	if status := query.Get("status"); status != "" {
+		deliveryStatus := models.WebhookDeliveryStatus(strings.ToLower(status))
+		switch deliveryStatus {
+		case models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+		default:
+			utils.WriteJSONError(w, http.StatusBadRequest, "Invalid query parameters", fmt.Errorf("status must be one of pending, succeeded, dead"))
+			return
+		}
+		filter.Status = &deliveryStatus
+	}
+
+	webhookRepository := repositories.NewWebhookRepository(s.db)
+	deliveries, err := webhookRepository.GetDeliveries(userID, filter, pagination)
+	if err != nil {
+		utils.WriteJSONError(w, http.StatusInternalServerError, "Failed to fetch webhook deliveries", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Webhook deliveries fetched successfully", deliveries)
+}
+
+// @Summary Replay a webhook delivery
+// @Description Send a delivery again with a fresh set of retries, e.g. once a dead endpoint is fixed. The event keeps its ID.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param delivery body models.ReplayWebhookDeliveryRequest true "Delivery to replay"
+// @Success 200 {object} models.Response{data=models.WebhookDelivery}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /webhooks/deliveries/replay [post]
+// @Security ApiKeyAuth
+// @param Authorization header string true "Authorization" default(Bearer <Add access token here>)
+func (s *WebhookService) ReplayDelivery(w http.ResponseWriter, r *http.Request, userID int) {
+	var replayRequest models.ReplayWebhookDeliveryRequest
+	if err := json.NewDecoder(r.Body).Decode(&replayRequest); err != nil {
+		utils.WriteJSONError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	webhookRepository := repositories.NewWebhookRepository(s.db)
+	delivery, err := webhookRepository.ReplayDelivery(replayRequest.DeliveryID, userID, auditActor(r))
+	if err != nil {
+		writeLookupError(w, "Webhook delivery", err)
+		return
+	}
+
+	utils.WriteJSONResponse(w, http.StatusOK, "Webhook delivery queued for replay", delivery)
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The RFC 6238 appendix B SHA-1 vectors, truncated to six digits
+func TestCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d) unexpected error: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s; want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	current := Step(now)
+	code, _ := Code(secret, current)
+	previous, _ := Code(secret, current-1)
+	stale, _ := Code(secret, current-2)
+
+	if step, ok := Validate(secret, code, now, 0); !ok || step != current {
+		t.Errorf("current code: got step %d, %v; want %d, true", step, ok, current)
+	}
+	if _, ok := Validate(secret, code[:3]+" "+code[3:], now, 0); !ok {
+		t.Error("a code with a space in it should be accepted")
+	}
+	if step, ok := Validate(secret, previous, now, 0); !ok || step != current-1 {
+		t.Errorf("previous code: got step %d, %v; want %d, true", step, ok, current-1)
+	}
+	if _, ok := Validate(secret, stale, now, 0); ok {
+		t.Error("a code two steps old should be refused")
+	}
+	if _, ok := Validate(secret, code, now, current); ok {
+		t.Error("a code from an already used step should be refused")
+	}
+	if _, ok := Validate(secret, "12345", now, 0); ok {
+		t.Error("a short code should be refused")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Banking System", "jane@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Banking%20System:jane@example.com?") {
+		t.Errorf("unexpected label in %s", uri)
+	}
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=Banking+System", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("%s is missing %s", uri, part)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package webhook signs and sends webhook events to customer endpoints.
+//
+// Each request carries the event as its JSON body and a signature header of
+// the form "t=<unix seconds>,v1=<hex HMAC-SHA256>". The MAC is computed with
+// the endpoint's secret over "<t>.<body>", so receivers can check both who
+// sent the event and when, and reject replays outside their tolerance.
+package webhook
+
+import (
+	"banking-system/internal/database/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	// requestTimeout bounds a whole delivery attempt, body included
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature header is malformed
+	// or does not match the body.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrSignatureExpired is returned when a signature is older than the
+	// tolerance given to Verify.
+	ErrSignatureExpired = errors.New("webhook signature expired")
+
+	// ErrPrivateNetwork is returned when an endpoint resolves to an address
+	// the sender is not allowed to reach.
+	ErrPrivateNetwork = errors.New("webhook endpoint resolves to a private network address")
+)
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := timestamp.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", t, hex.EncodeToString(mac(secret, t, body)))
+}
+
+// Verify checks a signature header against body. A tolerance of zero skips
+// the age check.
+func Verify(secret string, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp int64
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = t
+		case "v1":
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	expected := mac(secret, timestamp, body)
+	matched := false
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			matched = true
+		}
+	}
+	if !matched {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 && now.Sub(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// Sender POSTs signed events to endpoints.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a sender that refuses to connect to loopback, private,
+// link-local and unspecified addresses, so an endpoint URL cannot be used to
+// reach internal services. The check runs on the address actually dialled,
+// after DNS resolution. Set WEBHOOK_ALLOW_PRIVATE_NETWORKS=true to lift it,
+// e.g. for local development.
+func NewSender() *Sender {
+	return newSender(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true")
+}
+
+func newSender(allowPrivateNetworks bool) *Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !IsPublicIP(ip) {
+				return ErrPrivateNetwork
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+			// A redirect is treated as the endpoint's answer rather than
+			// followed, so it cannot bounce the request elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// IsPublicIP reports whether ip is a globally routable address.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// Send delivers event to url as delivery deliveryID. Any 2xx answer is a
+// success. The response status is returned whenever the endpoint answered.
+func (s *Sender) Send(ctx context.Context, url string, secret string, deliveryID int64, event models.WebhookEvent) (*int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "banking-system-webhooks/1.0")
+	request.Header.Set(SignatureHeader, Sign(secret, s.now(), body))
+	request.Header.Set(EventHeader, string(event.Type))
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(deliveryID, 10))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	status := response.StatusCode
+	if status < 200 || status > 299 {
+		return &status, fmt.Errorf("endpoint answered %d", status)
+	}
+	return &status, nil
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"banking-system/internal/database/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", now, body)
+
+	want := "t=1700000000,v1=2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8"
+	if header != want {
+		t.Fatalf("Sign() = %q; want %q", header, want)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"valid", "whsec_test", header, body, now, nil},
+		{"within tolerance", "whsec_test", header, body, now.Add(4 * time.Minute), nil},
+		{"expired", "whsec_test", header, body, now.Add(10 * time.Minute), ErrSignatureExpired},
+		{"wrong secret", "whsec_other", header, body, now, ErrInvalidSignature},
+		{"tampered body", "whsec_test", header, []byte(`{"id":2}`), now, ErrInvalidSignature},
+		{"malformed", "whsec_test", "garbage", body, now, ErrInvalidSignature},
+		{"no signature", "whsec_test", "t=1700000000", body, now, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute, tt.now)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() = %v; want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+	}
+
+	for address, want := range tests {
+		if got := IsPublicIP(net.ParseIP(address)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v; want %v", address, got, want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	event := models.WebhookEvent{
+		ID:        7,
+		Type:      models.EventAccountCreated,
+		CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Data:      json.RawMessage(`{"id":3}`),
+	}
+
+	var received *http.Request
+	var receivedBody []byte
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	status, err := newSender(true).Send(context.Background(), endpoint.URL, "whsec_test", 42, event)
+	if err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if status == nil || *status != http.StatusNoContent {
+		t.Errorf("Send() status = %v; want 204", status)
+	}
+	if got := received.Header.Get(DeliveryHeader); got != "42" {
+		t.Errorf("delivery header = %q; want 42", got)
+	}
+	if got := received.Header.Get(EventHeader); got != string(models.EventAccountCreated) {
+		t.Errorf("event header = %q", got)
+	}
+	if err := Verify("whsec_test", received.Header.Get(SignatureHeader), receivedBody, time.Minute, time.Now()); err != nil {
+		t.Errorf("signature did not verify: %v", err)
+	}
+
+	// The test server listens on loopback, which the default sender refuses
+	if _, err := newSender(false).Send(context.Background(), endpoint.URL, "whsec_test", 42, event); !errors.Is(err, ErrPrivateNetwork) {
+		t.Errorf("expected ErrPrivateNetwork; got %v", err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer failing.Close()
+
+	status, err = newSender(true).Send(context.Background(), failing.URL, "whsec_test", 42, event)
+	if err == nil || status == nil || *status != http.StatusFound {
+		t.Errorf("redirect should fail with its status; got %v, %v", status, err)
+	}
+}
@@ -1,48 +1,284 @@
 package utils
 
 import (
+	"banking-system/internal/database/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtSecret = os.Getenv("JWT_SECRET")
+// jwtLeeway absorbs clock skew between the servers issuing and verifying
+// tokens
+const jwtLeeway = 30 * time.Second
 
+var (
+	// AccessTokenTTL is how long an access token stays valid (ACCESS_TOKEN_TTL, default 15m)
+	AccessTokenTTL = durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	// RefreshTokenTTL is how long a session survives without being refreshed (REFRESH_TOKEN_TTL, default 30 days)
+	RefreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+)
 
-func GenerateToken(userID int) (string, error) {
-	if jwtSecret == "" {
-		return "", errors.New("JWT_SECRET is not set")
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
 	}
+	return value
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+// TokenClaims are the claims carried by an access token. Tokens issued to
+// an API key through the client-credentials grant have a ClientID and
+// Scopes instead of a SessionID.
+type TokenClaims struct {
+	UserID    int
+	Role      models.Role
+	SessionID string
+	ClientID  string
+	Scopes    []models.APIScope
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+func GenerateToken(userID int, role models.Role, sessionID string) (string, TokenClaims, error) {
+	claims := TokenClaims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		TokenID:   uuid.New().String(),
+		ExpiresAt: time.Now().Add(AccessTokenTTL),
+	}
+
+	signed, err := signToken(jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
+		"role":    string(role),
+		"sid":     sessionID,
+		"jti":     claims.TokenID,
+	}, claims.ExpiresAt)
+	if err != nil {
+		return "", TokenClaims{}, err
+	}
 
-	return token.SignedString([]byte(jwtSecret))
+	return signed, claims, nil
 }
 
-func ValidateToken(tokenString string) (int, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+// signToken adds the registered claims to claims and signs them with the
+// current signing key, naming it in the kid header.
+func signToken(claims jwt.MapClaims, expiresAt time.Time) (string, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims["iss"] = keys.issuer
+	claims["aud"] = keys.audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = keys.signingKID
+	return token.SignedString(keys.signing)
+}
 
+// parseToken verifies a token's signature against the key named by its kid
+// header, and that it is an EdDSA token for our issuer and audience which
+// has an expiry and is currently valid.
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	keys, err := currentJWTKeys()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.public[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(keys.issuer),
+		jwt.WithAudience(keys.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(jwtLeeway),
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	userID := int(claims["user_id"].(float64))
+	// The parser only checks nbf when it is present; every token we issue
+	// has one
+	if notBefore, err := claims.GetNotBefore(); err != nil || notBefore == nil {
+		return nil, errors.New("invalid token start time")
+	}
+
+	return claims, nil
+}
 
+// clientTokenType marks an access token issued to an API key
+const clientTokenType = "client"
+
+// GenerateClientToken returns an access token for the API key clientID,
+// acting as userID and limited to scopes.
+func GenerateClientToken(clientID string, userID int, role models.Role, scopes []models.APIScope) (string, TokenClaims, error) {
+	claims := TokenClaims{
+		UserID:    userID,
+		Role:      role,
+		ClientID:  clientID,
+		Scopes:    scopes,
+		TokenID:   uuid.New().String(),
+		ExpiresAt: time.Now().Add(AccessTokenTTL),
+	}
+
+	signed, err := signToken(jwt.MapClaims{
+		"user_id":   userID,
+		"role":      string(role),
+		"typ":       clientTokenType,
+		"client_id": clientID,
+		"scope":     JoinScopes(scopes),
+		"jti":       claims.TokenID,
+	}, claims.ExpiresAt)
+	if err != nil {
+		return "", TokenClaims{}, err
+	}
+
+	return signed, claims, nil
+}
+
+// JoinScopes formats scopes as an OAuth2 scope string: space-separated.
+func JoinScopes(scopes []models.APIScope) string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+	return strings.Join(values, " ")
+}
+
+// SplitScopes parses an OAuth2 scope string.
+func SplitScopes(scope string) []models.APIScope {
+	fields := strings.Fields(scope)
+	scopes := make([]models.APIScope, len(fields))
+	for i, field := range fields {
+		scopes[i] = models.APIScope(field)
+	}
+	return scopes
+}
+
+// MFATokenTTL is how long a user has to enter their two-factor code after
+// the password step of a login
+const MFATokenTTL = 5 * time.Minute
+
+// mfaPendingType marks a token that only proves the password step of a
+// login. It is refused everywhere except the two-factor verification.
+const mfaPendingType = "mfa_pending"
+
+// GenerateMFAToken returns a short-lived token for a user who passed the
+// password step of a login and still has to enter a two-factor code.
+func GenerateMFAToken(userID int) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id": userID,
+		"typ":     mfaPendingType,
+		"jti":     uuid.New().String(),
+	}, time.Now().Add(MFATokenTTL))
+}
+
+// ValidateMFAToken returns the user a token from GenerateMFAToken was issued to.
+func ValidateMFAToken(tokenString string) (int, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return 0, err
+	}
+	if typ, _ := claims["typ"].(string); typ != mfaPendingType {
+		return 0, errors.New("invalid token type")
+	}
+
+	userID, _ := claims["user_id"].(float64)
 	if userID == 0 {
 		return 0, errors.New("invalid user ID")
 	}
+	return int(userID), nil
+}
+
+func ValidateToken(tokenString string) (TokenClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+
+	// User access tokens carry no type and client tokens are typed "client";
+	// anything else, such as a token from the password step of a two-factor
+	// login, is not an access token
+	typ, typed := claims["typ"]
+	if typed && typ != clientTokenType {
+		return TokenClaims{}, errors.New("invalid token type")
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	if userID == 0 {
+		return TokenClaims{}, errors.New("invalid user ID")
+	}
+
+	sessionID, _ := claims["sid"].(string)
+	clientID, _ := claims["client_id"].(string)
+	tokenID, _ := claims["jti"].(string)
+	if tokenID == "" || (typed && clientID == "") || (!typed && sessionID == "") {
+		return TokenClaims{}, errors.New("invalid token")
+	}
+
+	var scopes []models.APIScope
+	if typed {
+		scope, _ := claims["scope"].(string)
+		scopes = SplitScopes(scope)
+	}
+
+	value, _ := claims["role"].(string)
+	role := models.Role(value)
+	if !role.Valid() {
+		return TokenClaims{}, errors.New("invalid role")
+	}
+
+	expiresAt, _ := claims.GetExpirationTime()
+
+	return TokenClaims{
+		UserID:    int(userID),
+		Role:      role,
+		SessionID: sessionID,
+		ClientID:  clientID,
+		Scopes:    scopes,
+		TokenID:   tokenID,
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
+
+// GenerateRefreshToken returns a random opaque refresh token. Only its
+// HashToken value is stored server-side.
+func GenerateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
 
-	return userID, nil
+// HashToken returns the hex SHA-256 of an opaque token for storage and lookup.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
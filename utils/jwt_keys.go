@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultJWTIssuer   = "banking-system"
+	defaultJWTAudience = "banking-system"
+)
+
+// jwtKeySet holds the Ed25519 keys tokens are signed and verified with,
+// by key ID. Tokens are signed with one key; every key in public is
+// accepted, so a key can be rotated out without invalidating the tokens it
+// already signed.
+type jwtKeySet struct {
+	signingKID string
+	signing    ed25519.PrivateKey
+	public     map[string]ed25519.PublicKey
+	issuer     string
+	audience   string
+}
+
+var (
+	jwtKeysMu     sync.Mutex
+	loadedJWTKeys *jwtKeySet
+)
+
+// LoadJWTKeys loads the signing keys from the environment. The server
+// calls it at startup so a bad key fails fast; otherwise they are loaded on
+// first use.
+func LoadJWTKeys() error {
+	_, err := currentJWTKeys()
+	return err
+}
+
+func currentJWTKeys() (*jwtKeySet, error) {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+
+	if loadedJWTKeys == nil {
+		keys, err := loadJWTKeys()
+		if err != nil {
+			return nil, err
+		}
+		loadedJWTKeys = keys
+	}
+	return loadedJWTKeys, nil
+}
+
+// loadJWTKeys reads every <kid>.pem file in JWT_KEYS_DIR. A file holds
+// either a PKCS #8 Ed25519 private key, which can sign, or a PKIX public
+// key, which only verifies tokens signed before a rotation. Tokens are
+// signed with JWT_SIGNING_KID, by default the private key whose kid sorts
+// last.
+//
+// Without JWT_KEYS_DIR loading fails, unless JWT_EPHEMERAL_KEY is "true":
+// then a key is generated for the life of the process, which is only fit
+// for development.
+func loadJWTKeys() (*jwtKeySet, error) {
+	keys := &jwtKeySet{
+		public:   make(map[string]ed25519.PublicKey),
+		issuer:   envOrDefault("JWT_ISSUER", defaultJWTIssuer),
+		audience: envOrDefault("JWT_AUDIENCE", defaultJWTAudience),
+	}
+
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if os.Getenv("JWT_EPHEMERAL_KEY") != "true" {
+			return nil, fmt.Errorf("JWT_KEYS_DIR is not set; for local development, set JWT_EPHEMERAL_KEY=true to sign with a temporary key")
+		}
+		kid, err := generateKID()
+		if err != nil {
+			return nil, err
+		}
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		log.Printf("JWT_EPHEMERAL_KEY is set, signing tokens with temporary key %s; they stop working on restart", kid)
+		keys.signingKID, keys.signing = kid, private
+		keys.public[kid] = public
+		return keys, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list JWT keys: %w", err)
+	}
+	sort.Strings(paths)
+
+	private := make(map[string]ed25519.PrivateKey)
+	var lastPrivateKID string
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key %s: %w", kid, err)
+		}
+
+		publicKey, privateKey, err := parseJWTKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT key %s: %w", kid, err)
+		}
+		keys.public[kid] = publicKey
+		if privateKey != nil {
+			private[kid] = privateKey
+			lastPrivateKID = kid
+		}
+	}
+
+	keys.signingKID = envOrDefault("JWT_SIGNING_KID", lastPrivateKID)
+	if keys.signingKID == "" {
+		return nil, fmt.Errorf("no private key in JWT_KEYS_DIR %s", dir)
+	}
+	keys.signing = private[keys.signingKID]
+	if keys.signing == nil {
+		return nil, fmt.Errorf("JWT_SIGNING_KID %s is not a private key in %s", keys.signingKID, dir)
+	}
+
+	return keys, nil
+}
+
+// parseJWTKey decodes a PEM-encoded Ed25519 key. The private key is nil
+// for a public key.
+func parseJWTKey(data []byte) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("expected an Ed25519 key, got %T", key)
+		}
+		return private.Public().(ed25519.PublicKey), private, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("expected an Ed25519 key, got %T", key)
+		}
+		return public, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+}
+
+func generateKID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "temp-" + hex.EncodeToString(buf), nil
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// JSONWebKey is a public key in RFC 7517 form, as served by the JWKS
+// endpoint. Ed25519 keys are "OKP" keys per RFC 8037.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns every public key tokens may be verified with, by kid.
+func JWKS() (JSONWebKeySet, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return JSONWebKeySet{}, err
+	}
+
+	kids := make([]string, 0, len(keys.public))
+	for kid := range keys.public {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, len(kids))}
+	for i, kid := range kids {
+		set.Keys[i] = JSONWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(keys.public[kid]),
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		}
+	}
+	return set, nil
+}
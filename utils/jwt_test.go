@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"banking-system/internal/database/models"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useTestKeys signs tokens with a new key named by the last of kids, and
+// accepts tokens signed by any of them.
+func useTestKeys(t *testing.T, kids ...string) *jwtKeySet {
+	t.Helper()
+	keys := &jwtKeySet{
+		public:   make(map[string]ed25519.PublicKey),
+		issuer:   "test-issuer",
+		audience: "test-audience",
+	}
+	for _, kid := range kids {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys.public[kid] = public
+		keys.signingKID, keys.signing = kid, private
+	}
+
+	loadedJWTKeys = keys
+	t.Cleanup(func() { loadedJWTKeys = nil })
+	return keys
+}
+
+func TestMFATokenIsNotAnAccessToken(t *testing.T) {
+	useTestKeys(t, "test")
+
+	mfaToken, err := GenerateMFAToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(mfaToken); err == nil {
+		t.Error("an mfa_pending token was accepted as an access token")
+	}
+	if userID, err := ValidateMFAToken(mfaToken); err != nil || userID != 7 {
+		t.Errorf("ValidateMFAToken() = %d, %v; want 7, nil", userID, err)
+	}
+
+	accessToken, _, err := GenerateToken(7, models.RoleCustomer, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateMFAToken(accessToken); err == nil {
+		t.Error("an access token was accepted as an mfa_pending token")
+	}
+	if _, err := ValidateToken(accessToken); err != nil {
+		t.Errorf("ValidateToken() unexpected error: %v", err)
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	useTestKeys(t, "test")
+
+	scopes := []models.APIScope{models.ScopeAccountsRead, models.ScopeTransactionsWrite}
+	token, _, err := GenerateClientToken("bk_1a2b3c4d5e6f", 7, models.RoleCustomer, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() unexpected error: %v", err)
+	}
+	if claims.UserID != 7 || claims.ClientID != "bk_1a2b3c4d5e6f" || claims.SessionID != "" {
+		t.Errorf("ValidateToken() = %+v; want user 7 of client bk_1a2b3c4d5e6f without a session", claims)
+	}
+	if JoinScopes(claims.Scopes) != "accounts:read transactions:write" {
+		t.Errorf("scopes = %v; want %v", claims.Scopes, scopes)
+	}
+
+	if _, err := ValidateMFAToken(token); err == nil {
+		t.Error("a client token was accepted as an mfa_pending token")
+	}
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	oldKeys := useTestKeys(t, "2024-01")
+	oldToken, _, err := GenerateToken(7, models.RoleCustomer, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: sign with a new key and keep verifying with the old one
+	newKeys := useTestKeys(t, "2024-06")
+	newKeys.public["2024-01"] = oldKeys.public["2024-01"]
+
+	newToken, _, err := GenerateToken(7, models.RoleCustomer, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != "2024-06" {
+		t.Errorf("kid = %v; want 2024-06", kid)
+	}
+
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := ValidateToken(token); err != nil {
+			t.Errorf("ValidateToken(%s token) unexpected error: %v", name, err)
+		}
+	}
+
+	// Retire the old key
+	delete(newKeys.public, "2024-01")
+	if _, err := ValidateToken(oldToken); err == nil {
+		t.Error("a token signed by a retired key was accepted")
+	}
+}
+
+func TestValidateTokenRejects(t *testing.T) {
+	keys := useTestKeys(t, "test")
+	now := time.Now()
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"user_id": 7,
+			"role":    string(models.RoleCustomer),
+			"sid":     "session",
+			"jti":     "token",
+			"iss":     keys.issuer,
+			"aud":     keys.audience,
+			"iat":     now.Unix(),
+			"nbf":     now.Unix(),
+			"exp":     now.Add(time.Minute).Unix(),
+		}
+	}
+	sign := func(claims jwt.MapClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(keys.signing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	with := func(name string, value interface{}) string {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return sign(claims, "test")
+	}
+
+	// An HS256 token keyed with the public key must not pass for EdDSA
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	hmac.Header["kid"] = "test"
+	hmacToken, err := hmac.SignedString([]byte(keys.public["test"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateToken(sign(validClaims(), "test")); err != nil {
+		t.Fatalf("ValidateToken() unexpected error for a valid token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256", hmacToken},
+		{"alg none", noneToken},
+		{"unknown kid", sign(validClaims(), "other")},
+		{"wrong issuer", with("iss", "someone-else")},
+		{"no issuer", with("iss", nil)},
+		{"wrong audience", with("aud", "another-api")},
+		{"no audience", with("aud", nil)},
+		{"expired", with("exp", now.Add(-time.Hour).Unix())},
+		{"no expiry", with("exp", nil)},
+		{"not yet valid", with("nbf", now.Add(time.Hour).Unix())},
+		{"no not-before", with("nbf", nil)},
+		{"issued in the future", with("iat", now.Add(time.Hour).Unix())},
+		{"no user", with("user_id", nil)},
+		{"no role", with("role", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateToken(tt.token); err == nil {
+				t.Error("ValidateToken() accepted the token")
+			}
+		})
+	}
+}
+
+func TestLoadJWTKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	writeKey := func(kid string, public bool) ed25519.PublicKey {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := &pem.Block{Type: "PRIVATE KEY"}
+		if public {
+			block.Type = "PUBLIC KEY"
+			block.Bytes, err = x509.MarshalPKIXPublicKey(publicKey)
+		} else {
+			block.Bytes, err = x509.MarshalPKCS8PrivateKey(privateKey)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return publicKey
+	}
+
+	writeKey("2023-06", true)
+	writeKey("2024-01", false)
+	newest := writeKey("2024-06", false)
+	t.Setenv("JWT_KEYS_DIR", dir)
+
+	keys, err := loadJWTKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.signingKID != "2024-06" {
+		t.Errorf("signing kid = %s; want 2024-06", keys.signingKID)
+	}
+	if len(keys.public) != 3 {
+		t.Errorf("loaded %d public keys; want 3", len(keys.public))
+	}
+	if keys.issuer != defaultJWTIssuer || keys.audience != defaultJWTAudience {
+		t.Errorf("issuer, audience = %s, %s; want the defaults", keys.issuer, keys.audience)
+	}
+
+	t.Setenv("JWT_SIGNING_KID", "2024-01")
+	if keys, err := loadJWTKeys(); err != nil || keys.signingKID != "2024-01" {
+		t.Errorf("loadJWTKeys() with JWT_SIGNING_KID = %v; want to sign with 2024-01", err)
+	}
+
+	// A public key cannot sign
+	t.Setenv("JWT_SIGNING_KID", "2023-06")
+	if _, err := loadJWTKeys(); err == nil {
+		t.Error("loadJWTKeys() signing with a public key: expected an error")
+	}
+
+	// Without a key directory, a temporary key needs an explicit opt-in
+	t.Setenv("JWT_KEYS_DIR", "")
+	t.Setenv("JWT_SIGNING_KID", "")
+	if _, err := loadJWTKeys(); err == nil {
+		t.Error("loadJWTKeys() without JWT_KEYS_DIR: expected an error")
+	}
+	t.Setenv("JWT_EPHEMERAL_KEY", "true")
+	if ephemeral, err := loadJWTKeys(); err != nil || ephemeral.signing == nil {
+		t.Errorf("loadJWTKeys() with JWT_EPHEMERAL_KEY: %v; want a temporary signing key", err)
+	}
+
+	loadedJWTKeys = keys
+	t.Cleanup(func() { loadedJWTKeys = nil })
+	set, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("JWKS() has %d keys; want 3", len(set.Keys))
+	}
+	last := set.Keys[2]
+	if last.KeyID != "2024-06" || last.KeyType != "OKP" || last.Curve != "Ed25519" || last.Algorithm != "EdDSA" || last.Use != "sig" {
+		t.Errorf("JWKS() key = %+v", last)
+	}
+	if x, err := base64.RawURLEncoding.DecodeString(last.X); err != nil || !newest.Equal(ed25519.PublicKey(x)) {
+		t.Errorf("JWKS() key x does not match the public key")
+	}
+}